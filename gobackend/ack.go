@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Acknowledgements silence a port's warning (a process change, say) for a
+// while. POST /acknowledge takes an optional body
+//
+//	{"comment": "planned upgrade", "expires_in": "7d"}
+//
+// and once expires_in has passed the warning resurfaces on /ports as if it
+// had never been dismissed. Without expires_in PORTMONOTE_ACK_TTL applies
+// (default 0: until the next warning).
+var ackTTL = envDuration("PORTMONOTE_ACK_TTL", 0)
+
+type Acknowledgement struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	PortRuntimeID uint       `gorm:"index" json:"port_runtime_id"`
+	EventID       uint       `gorm:"uniqueIndex" json:"event_id"` // the acknowledged event row
+	WarningType   string     `json:"warning_type,omitempty"`      // what was acknowledged
+	Actor         string     `json:"actor,omitempty"`
+	Comment       string     `json:"comment,omitempty"`
+	ExpiresAt     *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	CreatedAt     time.Time  `json:"acknowledged_at"`
+}
+
+func (Acknowledgement) TableName() string {
+	return "acknowledgement"
+}
+
+func (a *Acknowledgement) expired(now time.Time) bool {
+	return a.ExpiresAt != nil && !now.Before(*a.ExpiresAt)
+}
+
+type AckRequest struct {
+	Comment   string `json:"comment"`
+	ExpiresIn string `json:"expires_in"` // "8h", "7d"
+}
+
+// POST /acknowledge?host_id=&protocol=&port=
+func acknowledgeWarning(c *gin.Context) {
+	hostID := c.Query("host_id")
+	proto := c.Query("protocol")
+	portStr := c.Query("port")
+	port, _ := strconv.Atoi(portStr)
+
+	var req AckRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ttl := ackTTL
+	if req.ExpiresIn != "" {
+		d, err := parseWindow(req.ExpiresIn)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in: " + err.Error()})
+			return
+		}
+		ttl = d
+	}
+
+	var runtime PortRuntime
+	if err := DB.Where("host_id = ? AND protocol = ? AND port = ?", hostID, proto, port).First(&runtime).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Runtime not found"})
+		return
+	}
+
+	now := time.Now()
+	var warning PortEvent
+	DB.Where("port_runtime_id = ? AND event_type <> ?", runtime.ID, EventAcknowledged).Order("timestamp desc").First(&warning)
+
+	// Add Acknowledged Event
+	evt := PortEvent{
+		PortRuntimeID: runtime.ID,
+		EventType:     "acknowledged",
+		Timestamp:     now,
+		PID:           runtime.CurrentPID,
+		ProcessName:   runtime.ProcessName,
+		Actor:         actorName(c),
+		Detail:        req.Comment,
+	}
+	ack := Acknowledgement{PortRuntimeID: runtime.ID, WarningType: warning.EventType, Actor: evt.Actor, Comment: req.Comment}
+	if ttl > 0 {
+		until := now.Add(ttl)
+		ack.ExpiresAt = &until
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&evt).Error; err != nil {
+			return err
+		}
+		ack.EventID = evt.ID
+		return tx.Create(&ack).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resolveEscalations(runtime.ID, "acknowledged")
+	c.JSON(http.StatusOK, gin.H{"status": "acknowledged", "acknowledgement": ack})
+}
+
+// applyAcknowledgement sets the latest event of a port from its newest
+// event evt. An acknowledgement that has expired no longer hides the
+// warning before it.
+func applyAcknowledgement(item *MergedPortItem, evt PortEvent, now time.Time) {
+	item.LatestEventType = evt.EventType
+	item.LatestEventTimestamp = &evt.Timestamp
+	if evt.EventType != string(EventAcknowledged) {
+		return
+	}
+	var ack Acknowledgement
+	if err := DB.Where("event_id = ?", evt.ID).First(&ack).Error; err != nil {
+		return // acknowledged before expiry existed: for good
+	}
+	if !ack.expired(now) {
+		item.Acknowledgement = &ack
+		return
+	}
+	var warning PortEvent
+	if err := DB.Where("port_runtime_id = ? AND event_type <> ?", evt.PortRuntimeID, EventAcknowledged).Order("timestamp desc").First(&warning).Error; err == nil {
+		item.LatestEventType = warning.EventType
+		item.LatestEventTimestamp = &warning.Timestamp
+	}
+}
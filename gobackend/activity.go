@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Who is logged in and from where. Sessions remember the login IP and their
+// last request (throttled to one write per minute); API keys their last use.
+// Admins can end sessions one by one or all of a user's at once, e.g. when
+// someone leaves the team; API keys are revoked via /admin/apikeys.
+const activityResolution = time.Minute
+
+// touchSession records a request on a session.
+func touchSession(sess *Session, ip string) {
+	now := time.Now()
+	if sess.LastSeenAt != nil && now.Sub(*sess.LastSeenAt) < activityResolution && sess.LastIP == ip {
+		return
+	}
+	DB.Model(sess).Updates(map[string]any{"last_seen_at": now, "last_ip": ip})
+}
+
+// GET /admin/sessions lists unexpired sessions and active API keys, most
+// recently used first.
+func listSessions(c *gin.Context) {
+	var sessions []Session
+	DB.Where("expires_at > ?", time.Now()).Order("last_seen_at desc, created_at desc").Find(&sessions)
+	var keys []ApiKey
+	DB.Where("revoked_at IS NULL").Order("last_used_at desc").Find(&keys)
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions, "api_keys": keys})
+}
+
+// DELETE /admin/sessions/:id
+func revokeSession(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var sess Session
+	if err := DB.First(&sess, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err := DB.Delete(&sess).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("Session #%d of %s ended by %s", sess.ID, sess.Username, actorName(c))
+	c.JSON(http.StatusOK, gin.H{"status": "logged_out"})
+}
+
+// DELETE /admin/users/:id/sessions logs a user out everywhere.
+func logoutUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		return
+	}
+	var user User
+	if err := DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	n, err := endUserSessions(user.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("All sessions of %s ended by %s", user.Username, actorName(c))
+	c.JSON(http.StatusOK, gin.H{"status": "logged_out", "sessions": n})
+}
+
+func endUserSessions(username string) (int64, error) {
+	res := DB.Where("username = ?", username).Delete(&Session{})
+	if res.Error != nil {
+		return 0, fmt.Errorf("log out %q: %w", username, res.Error)
+	}
+	return res.RowsAffected, nil
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RehostResult summarizes a host_id migration.
+type RehostResult struct {
+	From            string `json:"from"`
+	To              string `json:"to"`
+	RuntimesMoved   int    `json:"runtimes_moved"`
+	RuntimesMerged  int    `json:"runtimes_merged"` // target already had the port; events were re-pointed
+	NotesMoved      int    `json:"notes_moved"`
+	NotesConflicted int    `json:"notes_conflicted"` // target note kept, source note dropped
+	EventsMoved     int64  `json:"events_moved"`
+	LinkedMoved     int64  `json:"linked_moved"` // mutes, approvals, tickets, application/baseline/deployment hosts
+}
+
+// Rows that name a port by host_id rather than through its runtime, and rows
+// that hang off a runtime; both follow the port when it is rehosted.
+var (
+	hostKeyedModels    = []any{&PortMute{}, &RiskApproval{}, &PortTicket{}}
+	runtimeKeyedModels = []any{&Acknowledgement{}, &Escalation{}, &ShareLink{}, &ProcessSample{}}
+)
+
+// rehostHost moves all runtimes, notes and events of one host_id to another in
+// a single transaction, along with everything else keyed by the host or the
+// runtime. Archived rows are moved too so inheritance keeps working.
+func rehostHost(from, to string) (RehostResult, error) {
+	res := RehostResult{From: from, To: to}
+	if from == "" || to == "" {
+		return res, errors.New("both from and to host ids are required")
+	}
+	if from == to {
+		return res, errors.New("from and to host ids are identical")
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped().Session(&gorm.Session{}) // a new session, so conditions don't pile up
+
+		var runtimes []PortRuntime
+		if err := tx.Where("host_id = ?", from).Find(&runtimes).Error; err != nil {
+			return err
+		}
+		for _, r := range runtimes {
+			var existing PortRuntime
+			err := tx.Where("host_id = ? AND protocol = ? AND port = ?", to, r.Protocol, r.Port).First(&existing).Error
+			if err == nil {
+				// Merge: history goes to the runtime that already lives on the target
+				moved := tx.Model(&PortEvent{}).Where("port_runtime_id = ?", r.ID).Update("port_runtime_id", existing.ID)
+				if moved.Error != nil {
+					return moved.Error
+				}
+				res.EventsMoved += moved.RowsAffected
+				for _, model := range runtimeKeyedModels {
+					if err := tx.Model(model).Where("port_runtime_id = ?", r.ID).Update("port_runtime_id", existing.ID).Error; err != nil {
+						return err
+					}
+				}
+				if err := mergePeers(tx, r.ID, existing.ID); err != nil {
+					return err
+				}
+				if err := tx.Delete(&PortRuntime{}, r.ID).Error; err != nil {
+					return err
+				}
+				res.RuntimesMerged++
+				continue
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			var events int64
+			tx.Model(&PortEvent{}).Where("port_runtime_id = ?", r.ID).Count(&events)
+			if err := tx.Model(&PortRuntime{}).Where("id = ?", r.ID).Update("host_id", to).Error; err != nil {
+				return err
+			}
+			res.EventsMoved += events
+			res.RuntimesMoved++
+		}
+
+		var notes []PortNote
+		if err := tx.Where("host_id = ?", from).Find(&notes).Error; err != nil {
+			return err
+		}
+		for _, n := range notes {
+			var count int64
+			tx.Model(&PortNote{}).Where("host_id = ? AND protocol = ? AND port = ?", to, n.Protocol, n.Port).Count(&count)
+			if count > 0 {
+				if err := tx.Delete(&PortNote{}, n.ID).Error; err != nil {
+					return err
+				}
+				// Its pending approvals would otherwise be decided on the target's note
+				err := tx.Where("host_id = ? AND protocol = ? AND port = ? AND status = ?", from, n.Protocol, n.Port, ApprovalPending).
+					Delete(&RiskApproval{}).Error
+				if err != nil {
+					return err
+				}
+				res.NotesConflicted++
+				continue
+			}
+			if err := tx.Model(&PortNote{}).Where("id = ?", n.ID).Update("host_id", to).Error; err != nil {
+				return err
+			}
+			res.NotesMoved++
+		}
+
+		for _, model := range hostKeyedModels {
+			moved := tx.Model(model).Where("host_id = ?", from).Update("host_id", to)
+			if moved.Error != nil {
+				return moved.Error
+			}
+			res.LinkedMoved += moved.RowsAffected
+		}
+		// Application and baseline membership: drop what the target already has
+		var appPorts []ApplicationPort
+		if err := tx.Where("host_id = ?", from).Find(&appPorts).Error; err != nil {
+			return err
+		}
+		for _, ap := range appPorts {
+			var dup int64
+			tx.Model(&ApplicationPort{}).Where("application_id = ? AND host_id = ? AND protocol = ? AND port = ?", ap.ApplicationID, to, ap.Protocol, ap.Port).Count(&dup)
+			if err := rehostRow(tx, &ApplicationPort{}, ap.ID, to, dup > 0, &res); err != nil {
+				return err
+			}
+		}
+		var basePorts []BaselinePort
+		if err := tx.Where("host_id = ?", from).Find(&basePorts).Error; err != nil {
+			return err
+		}
+		for _, bp := range basePorts {
+			var dup int64
+			tx.Model(&BaselinePort{}).Where("baseline_id = ? AND host_id = ? AND protocol = ? AND port = ?", bp.BaselineID, to, bp.Protocol, bp.Port).Count(&dup)
+			if err := rehostRow(tx, &BaselinePort{}, bp.ID, to, dup > 0, &res); err != nil {
+				return err
+			}
+		}
+
+		// Baselines and deployments list their hosts
+		var baselines []Baseline
+		if err := tx.Find(&baselines).Error; err != nil {
+			return err
+		}
+		for _, b := range baselines {
+			if err := rehostList(tx, &Baseline{}, b.ID, b.HostIDs, from, to, &res); err != nil {
+				return err
+			}
+		}
+		var deployments []Deployment
+		if err := tx.Find(&deployments).Error; err != nil {
+			return err
+		}
+		for _, d := range deployments {
+			if err := rehostList(tx, &Deployment{}, d.ID, d.HostIDs, from, to, &res); err != nil {
+				return err
+			}
+		}
+
+		// The registry entry follows unless the new ID already reports
+		var hosts int64
+		tx.Model(&Host{}).Where("host_id = ?", to).Count(&hosts)
+		if hosts > 0 {
+			return tx.Where("host_id = ?", from).Delete(&Host{}).Error
+		}
+		return tx.Model(&Host{}).Where("host_id = ?", from).Update("host_id", to).Error
+	})
+	return res, err
+}
+
+// rehostRow moves one row to the new host, or deletes it when the target
+// already has an equivalent one.
+func rehostRow(tx *gorm.DB, model any, id uint, to string, duplicate bool, res *RehostResult) error {
+	if duplicate {
+		return tx.Delete(model, id).Error
+	}
+	if err := tx.Model(model).Where("id = ?", id).Update("host_id", to).Error; err != nil {
+		return err
+	}
+	res.LinkedMoved++
+	return nil
+}
+
+// rehostList renames from to to in a row's comma-separated host_ids.
+func rehostList(tx *gorm.DB, model any, id uint, list, from, to string, res *RehostResult) error {
+	hosts := splitList(list)
+	i := slices.Index(hosts, from)
+	if i < 0 {
+		return nil
+	}
+	if slices.Contains(hosts, to) {
+		hosts = slices.Delete(hosts, i, i+1)
+	} else {
+		hosts[i] = to
+	}
+	if err := tx.Model(model).Where("id = ?", id).Update("host_ids", strings.Join(hosts, ",")).Error; err != nil {
+		return err
+	}
+	res.LinkedMoved++
+	return nil
+}
+
+// mergePeers moves the peers of runtime from onto runtime to, adding up the
+// counters of remote IPs both have seen.
+func mergePeers(tx *gorm.DB, from, to uint) error {
+	var peers []PortPeer
+	if err := tx.Where("port_runtime_id = ?", from).Find(&peers).Error; err != nil {
+		return err
+	}
+	for _, p := range peers {
+		var existing PortPeer
+		err := tx.Where("port_runtime_id = ? AND remote_ip = ?", to, p.RemoteIP).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := tx.Model(&PortPeer{}).Where("id = ?", p.ID).Update("port_runtime_id", to).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		existing.SeenCount += p.SeenCount
+		existing.ConnCount += p.ConnCount
+		if p.FirstSeenAt.Before(existing.FirstSeenAt) {
+			existing.FirstSeenAt = p.FirstSeenAt
+		}
+		if p.LastSeenAt.After(existing.LastSeenAt) {
+			existing.LastSeenAt = p.LastSeenAt
+		}
+		if err := tx.Save(&existing).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&PortPeer{}, p.ID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type RehostRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func handleRehost(c *gin.Context) {
+	var req RehostRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := rehostHost(req.From, req.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// runAdmin implements `portmonote admin <command>`.
+func runAdmin(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: portmonote admin rehost --from OLD --to NEW")
+		fmt.Println("       portmonote admin useradd --username NAME [--password PW] [--role viewer|editor|admin]")
+		fmt.Println("       portmonote admin role --username NAME --role viewer|editor|admin")
+		fmt.Println("       portmonote admin passwd --username NAME [--password PW]")
+		fmt.Println("       portmonote admin logout --username NAME")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "rehost":
+		fs := flag.NewFlagSet("rehost", flag.ExitOnError)
+		from := fs.String("from", "", "Current host_id")
+		to := fs.String("to", "", "New host_id")
+		fs.Parse(args[1:])
+
+		InitDB("portmonote.db")
+		res, err := rehostHost(*from, *to)
+		if err != nil {
+			log.Fatalf("❌ Rehost failed: %v", err)
+		}
+		log.Printf("✅ Rehosted %s -> %s: %d runtimes moved, %d merged, %d notes moved, %d note conflicts, %d events, %d linked rows",
+			res.From, res.To, res.RuntimesMoved, res.RuntimesMerged, res.NotesMoved, res.NotesConflicted, res.EventsMoved, res.LinkedMoved)
+	case "useradd", "passwd":
+		fs := flag.NewFlagSet(args[0], flag.ExitOnError)
+		username := fs.String("username", "", "Login name")
+		password := fs.String("password", "", "Password (default: $PORTMONOTE_PASSWORD or prompt)")
+		role := fs.String("role", RoleEditor, "Role for new users: viewer, editor or admin")
+		fs.Parse(args[1:])
+
+		pw := *password
+		if pw == "" {
+			pw = os.Getenv("PORTMONOTE_PASSWORD")
+		}
+		if pw == "" {
+			fmt.Print("Password: ")
+			fmt.Scanln(&pw)
+		}
+
+		InitDB("portmonote.db")
+		if args[0] == "useradd" {
+			if _, err := createUser(*username, pw, *role); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			log.Printf("✅ Created user %s (%s)", *username, *role)
+		} else {
+			if err := setUserPassword(*username, pw); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			log.Printf("✅ Password updated for %s (existing sessions logged out)", *username)
+		}
+	case "role":
+		fs := flag.NewFlagSet("role", flag.ExitOnError)
+		username := fs.String("username", "", "Login name")
+		role := fs.String("role", "", "viewer, editor or admin")
+		fs.Parse(args[1:])
+
+		InitDB("portmonote.db")
+		if err := setUserRole(*username, *role); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		log.Printf("✅ %s is now %s", *username, *role)
+	case "logout":
+		fs := flag.NewFlagSet("logout", flag.ExitOnError)
+		username := fs.String("username", "", "Login name")
+		fs.Parse(args[1:])
+
+		InitDB("portmonote.db")
+		n, err := endUserSessions(*username)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		log.Printf("✅ Logged out %s (%d sessions)", *username, n)
+	default:
+		fmt.Printf("Unknown admin command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRehostHost(t *testing.T) {
+	openTestDB(t)
+	now := time.Now().Truncate(time.Second)
+	create := func(rows ...any) {
+		t.Helper()
+		for _, row := range rows {
+			if err := DB.Create(row).Error; err != nil {
+				t.Fatalf("create %T: %v", row, err)
+			}
+		}
+	}
+
+	// Port 80 exists on both hosts and merges; port 22 only moves
+	target := PortRuntime{HostID: "new", Protocol: "tcp", Port: 80, CurrentState: "active", FirstSeenAt: now, LastSeenAt: now}
+	merged := PortRuntime{HostID: "old", Protocol: "tcp", Port: 80, CurrentState: "disappeared", FirstSeenAt: now, LastSeenAt: now}
+	moved := PortRuntime{HostID: "old", Protocol: "tcp", Port: 22, CurrentState: "active", FirstSeenAt: now, LastSeenAt: now}
+	create(&target, &merged, &moved)
+
+	app := Application{Name: "web"}
+	base := Baseline{Name: "golden", HostIDs: "old,other"}
+	deploy := Deployment{Name: "v2", HostIDs: "old,new", StartedAt: now}
+	create(&app, &base, &deploy)
+	create(
+		&PortEvent{PortRuntimeID: merged.ID, EventType: string(EventAppeared), Timestamp: now},
+		&Acknowledgement{PortRuntimeID: merged.ID, EventID: 1001},
+		&Escalation{PortRuntimeID: merged.ID, Policy: "oncall", NextAt: now},
+		&ShareLink{PortRuntimeID: merged.ID, TokenHash: "h1", ExpiresAt: now.Add(time.Hour)},
+		&ProcessSample{PortRuntimeID: merged.ID, Timestamp: now},
+		&PortPeer{PortRuntimeID: target.ID, RemoteIP: "10.0.0.9", FirstSeenAt: now, LastSeenAt: now, SeenCount: 2, ConnCount: 4},
+		&PortPeer{PortRuntimeID: merged.ID, RemoteIP: "10.0.0.9", FirstSeenAt: now.Add(-time.Hour), LastSeenAt: now, SeenCount: 1, ConnCount: 3},
+		&PortPeer{PortRuntimeID: merged.ID, RemoteIP: "10.0.0.7", FirstSeenAt: now, LastSeenAt: now, SeenCount: 1, ConnCount: 1},
+		&PortMute{HostID: "old", Protocol: "tcp", Port: 22, Until: now.Add(time.Hour)},
+		&RiskApproval{HostID: "old", Protocol: "tcp", Port: 22, FromRisk: "suspicious", ToRisk: "trusted", Status: ApprovalPending, RequestedAt: now},
+		&PortTicket{HostID: "old", Protocol: "tcp", Port: 22, Tracker: "github", ExternalID: "7", OpenedAt: now},
+		&ApplicationPort{ApplicationID: app.ID, HostID: "old", Protocol: "tcp", Port: 22},
+		&ApplicationPort{ApplicationID: app.ID, HostID: "old", Protocol: "tcp", Port: 80},
+		&ApplicationPort{ApplicationID: app.ID, HostID: "new", Protocol: "tcp", Port: 80},
+		&BaselinePort{BaselineID: base.ID, HostID: "old", Protocol: "tcp", Port: 22},
+	)
+
+	res, err := rehostHost("old", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RuntimesMerged != 1 || res.RuntimesMoved != 1 || res.EventsMoved != 1 {
+		t.Errorf("result %+v", res)
+	}
+
+	count := func(model any, where string, args ...any) int64 {
+		var n int64
+		DB.Unscoped().Model(model).Where(where, args...).Count(&n)
+		return n
+	}
+	for _, c := range []struct {
+		name  string
+		model any
+	}{
+		{"events", &PortEvent{}},
+		{"acknowledgements", &Acknowledgement{}},
+		{"escalations", &Escalation{}},
+		{"share links", &ShareLink{}},
+		{"samples", &ProcessSample{}},
+	} {
+		if n := count(c.model, "port_runtime_id = ?", target.ID); n != 1 {
+			t.Errorf("%s on the surviving runtime: %d, want 1", c.name, n)
+		}
+		if n := count(c.model, "port_runtime_id = ?", merged.ID); n != 0 {
+			t.Errorf("%s left on the deleted runtime: %d", c.name, n)
+		}
+	}
+
+	var peers []PortPeer
+	DB.Where("port_runtime_id = ?", target.ID).Order("remote_ip").Find(&peers)
+	if len(peers) != 2 || peers[1].RemoteIP != "10.0.0.9" || peers[1].SeenCount != 3 || peers[1].ConnCount != 7 ||
+		!peers[1].FirstSeenAt.Equal(now.Add(-time.Hour)) {
+		t.Errorf("peers %+v", peers)
+	}
+	if n := count(&PortPeer{}, "port_runtime_id = ?", merged.ID); n != 0 {
+		t.Errorf("%d peers left on the deleted runtime", n)
+	}
+
+	for _, c := range []struct {
+		name  string
+		model any
+		want  int64
+	}{
+		{"mutes", &PortMute{}, 1},
+		{"approvals", &RiskApproval{}, 1},
+		{"tickets", &PortTicket{}, 1},
+		{"application ports", &ApplicationPort{}, 2}, // the duplicate port 80 is dropped
+		{"baseline ports", &BaselinePort{}, 1},
+	} {
+		if n := count(c.model, "host_id = ?", "old"); n != 0 {
+			t.Errorf("%d %s left on the old host", n, c.name)
+		}
+		if n := count(c.model, "host_id = ?", "new"); n != c.want {
+			t.Errorf("%s on the new host: %d, want %d", c.name, n, c.want)
+		}
+	}
+
+	DB.First(&base, base.ID)
+	DB.First(&deploy, deploy.ID)
+	if base.HostIDs != "new,other" || deploy.HostIDs != "new" {
+		t.Errorf("host lists: baseline %q, deployment %q", base.HostIDs, deploy.HostIDs)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Applications name a service made of several ports, e.g. GitLab = 22, 80,
+// 443 and 5432, possibly across hosts, and roll their health up:
+//
+//	healthy   every port listening, none suspicious or flapping
+//	warning   every port listening, some suspicious or flapping
+//	degraded  some ports down
+//	down      no port listening
+type Application struct {
+	ID          uint              `gorm:"primaryKey" json:"id"`
+	Name        string            `gorm:"uniqueIndex;size:128" json:"name"`
+	Description string            `json:"description,omitempty"`
+	Owner       string            `json:"owner,omitempty"`
+	Ports       []ApplicationPort `gorm:"foreignKey:ApplicationID;constraint:OnDelete:CASCADE;" json:"ports"`
+	CreatedBy   string            `json:"created_by,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+
+	Health *ApplicationHealth `gorm:"-" json:"health,omitempty"`
+}
+
+func (Application) TableName() string {
+	return "application"
+}
+
+type ApplicationPort struct {
+	ID            uint   `gorm:"primaryKey" json:"-"`
+	ApplicationID uint   `gorm:"index" json:"-"`
+	HostID        string `json:"host_id"`
+	Protocol      string `json:"protocol"`
+	Port          int    `json:"port"`
+	Label         string `json:"label,omitempty"` // e.g. "HTTPS", "database"
+
+	// Filled in for responses
+	CurrentState  string `gorm:"-" json:"current_state,omitempty"`
+	DerivedStatus string `gorm:"-" json:"derived_status,omitempty"`
+	ProcessName   string `gorm:"-" json:"process_name,omitempty"`
+}
+
+func (ApplicationPort) TableName() string {
+	return "application_port"
+}
+
+type ApplicationHealth struct {
+	Status     string `json:"status"` // healthy, warning, degraded, down
+	Up         int    `json:"up"`
+	Down       int    `json:"down"`
+	Suspicious int    `json:"suspicious"`
+	Flapping   int    `json:"flapping"`
+}
+
+// withHealth fills in the state of every member port and the rollup.
+func withHealth(apps []Application) {
+	var runtimes []PortRuntime
+	var notes []PortNote
+	DB.Find(&runtimes)
+	DB.Find(&notes)
+	items := map[string]MergedPortItem{}
+	for _, item := range mergePortItems(runtimes, notes) {
+		items[fmtKey(item.HostID, item.Protocol, item.Port)] = item
+	}
+
+	for i := range apps {
+		h := &ApplicationHealth{}
+		for j := range apps[i].Ports {
+			p := &apps[i].Ports[j]
+			item, ok := items[fmtKey(p.HostID, p.Protocol, p.Port)]
+			if !ok || item.CurrentState != string(StateActive) {
+				h.Down++
+				p.CurrentState = item.CurrentState
+				if !ok {
+					p.CurrentState = "unknown"
+				}
+				continue
+			}
+			p.CurrentState, p.DerivedStatus, p.ProcessName = item.CurrentState, item.DerivedStatus, item.ProcessName
+			h.Up++
+			switch item.DerivedStatus {
+			case "suspicious":
+				h.Suspicious++
+			case "flapping":
+				h.Flapping++
+			}
+		}
+		switch {
+		case h.Up == 0:
+			h.Status = "down"
+		case h.Down > 0:
+			h.Status = "degraded"
+		case h.Suspicious > 0 || h.Flapping > 0:
+			h.Status = "warning"
+		default:
+			h.Status = "healthy"
+		}
+		apps[i].Health = h
+	}
+}
+
+// GET /applications
+func listApplications(c *gin.Context) {
+	var apps []Application
+	DB.Preload("Ports").Order("name").Find(&apps)
+	if apps == nil {
+		apps = []Application{}
+	}
+	withHealth(apps)
+	c.JSON(http.StatusOK, apps)
+}
+
+// GET /applications/:id
+func getApplication(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var app Application
+	if err := DB.Preload("Ports").First(&app, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
+		return
+	}
+	apps := []Application{app}
+	withHealth(apps)
+	c.JSON(http.StatusOK, apps[0])
+}
+
+type ApplicationRequest struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Owner       string            `json:"owner"`
+	Ports       []ApplicationPort `json:"ports"`
+}
+
+func (r *ApplicationRequest) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	seen := map[string]bool{}
+	for i := range r.Ports {
+		p := &r.Ports[i]
+		if p.HostID == "" {
+			p.HostID = HostID
+		}
+		if p.Protocol != string(TCP) && p.Protocol != string(UDP) {
+			return fmt.Errorf("ports[%d]: protocol must be tcp or udp", i)
+		}
+		if p.Port <= 0 || p.Port > 65535 {
+			return fmt.Errorf("ports[%d]: invalid port %d", i, p.Port)
+		}
+		key := fmtKey(p.HostID, p.Protocol, p.Port)
+		if seen[key] {
+			return fmt.Errorf("ports[%d]: %s/%d on %s listed twice", i, p.Protocol, p.Port, p.HostID)
+		}
+		seen[key] = true
+		p.ID, p.ApplicationID = 0, 0
+	}
+	return nil
+}
+
+// POST /applications
+func createApplication(c *gin.Context) {
+	var req ApplicationRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var n int64
+	DB.Model(&Application{}).Where("name = ?", req.Name).Count(&n)
+	if n > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "An application with this name exists"})
+		return
+	}
+	app := Application{Name: req.Name, Description: req.Description, Owner: req.Owner, Ports: req.Ports, CreatedBy: actorName(c)}
+	if err := DB.Create(&app).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	apps := []Application{app}
+	withHealth(apps)
+	c.JSON(http.StatusCreated, apps[0])
+}
+
+// PUT /applications/:id replaces name, description, owner and ports.
+func updateApplication(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var app Application
+	if err := DB.First(&app, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
+		return
+	}
+	var req ApplicationRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var n int64
+	DB.Model(&Application{}).Where("name = ? AND id <> ?", req.Name, app.ID).Count(&n)
+	if n > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "An application with this name exists"})
+		return
+	}
+	app.Name, app.Description, app.Owner = req.Name, req.Description, req.Owner
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("application_id = ?", app.ID).Delete(&ApplicationPort{}).Error; err != nil {
+			return err
+		}
+		for i := range req.Ports {
+			req.Ports[i].ApplicationID = app.ID
+		}
+		if len(req.Ports) > 0 {
+			if err := tx.Create(&req.Ports).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Omit("Ports").Save(&app).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	app.Ports = req.Ports
+	apps := []Application{app}
+	withHealth(apps)
+	c.JSON(http.StatusOK, apps[0])
+}
+
+// DELETE /applications/:id
+func deleteApplication(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var app Application
+	if err := DB.First(&app, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
+		return
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("application_id = ?", app.ID).Delete(&ApplicationPort{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&app).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
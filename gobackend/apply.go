@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// POST /ports/apply sets owner, risk level or tags on every port matching
+// a filter (query.go), so conventions can be kept declaratively:
+//
+//	{"filter": "proc~^kube", "set": {"owner": "Platform", "add_tags": ["k8s"]}, "dry_run": true}
+//
+// Applying is idempotent: ports that already match the operation are not
+// touched, so the same request can be re-applied after new ports appear.
+// dry_run returns the changes without saving them. Risk changes that need
+// a second user (PORTMONOTE_REQUIRE_APPROVAL) are filed as approvals. Ports
+// without a note get one that stays unreviewed (suspicious) unless the
+// operation sets risk_level.
+type ApplyRequest struct {
+	Filter string         `json:"filter"`
+	Set    ApplyOperation `json:"set"`
+	DryRun bool           `json:"dry_run"`
+}
+
+type ApplyOperation struct {
+	Owner      *string  `json:"owner"`
+	RiskLevel  *string  `json:"risk_level"`
+	Tags       *string  `json:"tags"` // replaces all tags
+	AddTags    []string `json:"add_tags"`
+	RemoveTags []string `json:"remove_tags"`
+}
+
+type FieldChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type ApplyItem struct {
+	HostID   string                 `json:"host_id"`
+	Protocol string                 `json:"protocol"`
+	Port     int                    `json:"port"`
+	Title    string                 `json:"title,omitempty"`
+	Changes  map[string]FieldChange `json:"changes"`
+	Pending  bool                   `json:"pending_approval,omitempty"` // risk change awaits approval
+}
+
+type ApplyResult struct {
+	Matched int         `json:"matched"`
+	Changed int         `json:"changed"`
+	DryRun  bool        `json:"dry_run"`
+	Items   []ApplyItem `json:"items"`
+}
+
+func applyToPorts(c *gin.Context) {
+	var req ApplyRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Filter) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filter is required (use * to match every port)"})
+		return
+	}
+	op := req.Set
+	if op.Owner == nil && op.RiskLevel == nil && op.Tags == nil && len(op.AddTags) == 0 && len(op.RemoveTags) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "set needs owner, risk_level, tags, add_tags or remove_tags"})
+		return
+	}
+	if op.RiskLevel != nil && !slices.Contains([]RiskLevel{RiskTrusted, RiskExpected, RiskSuspicious}, RiskLevel(*op.RiskLevel)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "risk_level must be trusted, expected or suspicious"})
+		return
+	}
+	filter := req.Filter
+	if filter == "*" {
+		filter = ""
+	}
+	q, err := parsePortQuery(filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filter: " + err.Error()})
+		return
+	}
+
+	var runtimes []PortRuntime
+	var notes []PortNote
+	DB.Find(&runtimes)
+	DB.Find(&notes)
+	matched := q.filter(mergePortItems(runtimes, notes))
+	slices.SortFunc(matched, func(a, b MergedPortItem) int {
+		return strings.Compare(fmtKey(a.HostID, a.Protocol, a.Port), fmtKey(b.HostID, b.Protocol, b.Port))
+	})
+	existing := map[string]PortNote{}
+	for _, n := range notes {
+		existing[fmtKey(n.HostID, n.Protocol, n.Port)] = n
+	}
+	// Risk changes already waiting for approval are not asked for again
+	var pending []RiskApproval
+	DB.Where("status = ?", ApprovalPending).Find(&pending)
+	requested := map[string]bool{}
+	for _, a := range pending {
+		requested[fmtKey(a.HostID, a.Protocol, a.Port)+" "+a.FromRisk+" "+a.ToRisk] = true
+	}
+
+	actor := actorName(c)
+	res := ApplyResult{Matched: len(matched), DryRun: req.DryRun, Items: []ApplyItem{}}
+	var changed []PortNote
+	var approvals []PortNote // notes whose risk change needs approval
+	for _, item := range matched {
+		note, ok := existing[fmtKey(item.HostID, item.Protocol, item.Port)]
+		if !ok {
+			// Setting an owner or tags is no review: the new note stays
+			// suspicious until someone sets a risk level
+			note = PortNote{HostID: item.HostID, Protocol: item.Protocol, Port: item.Port, RiskLevel: string(RiskSuspicious), AutoGenerated: true}
+		}
+		ai := ApplyItem{HostID: item.HostID, Protocol: item.Protocol, Port: item.Port, Title: item.Title, Changes: map[string]FieldChange{}}
+		before := note
+
+		if op.Owner != nil && note.Owner != *op.Owner {
+			ai.Changes["owner"] = FieldChange{note.Owner, *op.Owner}
+			note.Owner = *op.Owner
+		}
+		if tags := applyTags(note.Tags, op); tags != note.Tags {
+			ai.Changes["tags"] = FieldChange{note.Tags, tags}
+			note.Tags = tags
+		}
+		if op.RiskLevel != nil && note.RiskLevel != *op.RiskLevel &&
+			!requested[fmtKey(note.HostID, note.Protocol, note.Port)+" "+note.RiskLevel+" "+*op.RiskLevel] {
+			ai.Changes["risk_level"] = FieldChange{note.RiskLevel, *op.RiskLevel}
+			if needsApproval(note.RiskLevel, *op.RiskLevel) {
+				ai.Pending = true
+				approvals = append(approvals, before)
+			} else {
+				note.RiskLevel = *op.RiskLevel
+			}
+		}
+		if len(ai.Changes) == 0 {
+			continue
+		}
+		note.UpdatedBy = actor
+		if op.RiskLevel != nil {
+			note.AutoGenerated = false // a risk decision confirms a guessed note
+		}
+		changed = append(changed, note)
+		res.Items = append(res.Items, ai)
+	}
+	res.Changed = len(res.Items)
+
+	if req.DryRun || res.Changed == 0 {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if len(approvals) > 0 && actor == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "risk downgrades need an authenticated user", "result": res})
+		return
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		for i := range changed {
+			if err := saveNote(tx, &changed[i]); err != nil {
+				return err
+			}
+			prev := existing[fmtKey(changed[i].HostID, changed[i].Protocol, changed[i].Port)]
+			if err := recordNoteRevision(tx, prev, changed[i], actor); err != nil {
+				return err
+			}
+			if err := syncNoteTags(tx, &changed[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errNoteConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "a matched note changed meanwhile, nothing was applied; try again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, n := range approvals {
+		if _, err := requestRiskApproval(n, *op.RiskLevel, actor, actorOwner(c)); err != nil {
+			log.Printf("Error filing risk approval for %s/%d: %v", n.Protocol, n.Port, err)
+		}
+	}
+	for i := range changed {
+		saved := changed[i]
+		publish(BusEvent{Topic: TopicNoteEdited, Note: &saved, Actor: actor})
+	}
+	log.Printf("🏷️ Bulk update of %d port(s) by %s (filter %q)", res.Changed, actor, req.Filter)
+	c.JSON(http.StatusOK, res)
+}
+
+// applyTags returns the note's tags after the operation, normalized.
+func applyTags(current string, op ApplyOperation) string {
+	tags := splitList(current)
+	if op.Tags != nil {
+		tags = splitList(*op.Tags)
+	}
+	for _, t := range op.AddTags {
+		if t = strings.TrimSpace(t); t != "" && !slices.Contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	tags = slices.DeleteFunc(tags, func(t string) bool { return slices.Contains(op.RemoveTags, t) })
+	return strings.Join(tags, ",")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestApplyPendingRiskIsIdempotent(t *testing.T) {
+	openTestDB(t)
+	prev := requireApproval
+	requireApproval = true
+	t.Cleanup(func() { requireApproval = prev })
+
+	now := time.Now()
+	DB.Create(&PortRuntime{HostID: HostID, Protocol: "tcp", Port: 22, CurrentState: string(StateActive), FirstSeenAt: now, LastSeenAt: now})
+	DB.Create(&PortNote{HostID: HostID, Protocol: "tcp", Port: 22, RiskLevel: string(RiskSuspicious)})
+
+	apply := func() ApplyResult {
+		t.Helper()
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/apply", strings.NewReader(`{"filter":"*","set":{"risk_level":"trusted"}}`))
+		c.Set("principal", "user:alice")
+		applyToPorts(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("apply: %d %s", w.Code, w.Body)
+		}
+		var res ApplyResult
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return res
+	}
+
+	if res := apply(); res.Changed != 1 || !res.Items[0].Pending {
+		t.Fatalf("first apply = %+v, want one pending change", res)
+	}
+	if res := apply(); res.Changed != 0 {
+		t.Errorf("second apply changed %d port(s), want 0", res.Changed)
+	}
+	var approvals int64
+	DB.Model(&RiskApproval{}).Count(&approvals)
+	if approvals != 1 {
+		t.Errorf("%d approvals queued, want 1", approvals)
+	}
+}
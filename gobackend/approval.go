@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Four-eyes rule for risk downgrades: with PORTMONOTE_REQUIRE_APPROVAL=true,
+// making a note trusted (from any other level, so going through expected
+// doesn't dodge it) only files a request; a different user has to approve
+// it before the risk level changes. The requester's own API keys count as
+// the requester. Needs per-user identities (session login or API keys),
+// otherwise nobody can approve anything.
+var requireApproval = envBool("PORTMONOTE_REQUIRE_APPROVAL", false)
+
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+)
+
+type RiskApproval struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	HostID      string     `gorm:"index:idx_approval_key" json:"host_id"`
+	Protocol    string     `gorm:"index:idx_approval_key" json:"protocol"`
+	Port        int        `gorm:"index:idx_approval_key" json:"port"`
+	FromRisk    string     `json:"from_risk"`
+	ToRisk      string     `json:"to_risk"`
+	Status      string     `gorm:"index;default:pending" json:"status"`
+	RequestedBy string     `json:"requested_by"`
+	Requester   string     `json:"requester,omitempty"` // the user behind RequestedBy (actorOwner)
+	RequestedAt time.Time  `json:"requested_at"`
+	DecidedBy   string     `json:"decided_by,omitempty"`
+	DecidedAt   *time.Time `json:"decided_at,omitempty"`
+}
+
+func (RiskApproval) TableName() string {
+	return "risk_approval"
+}
+
+func needsApproval(from, to string) bool {
+	return requireApproval && to == string(RiskTrusted) && from != to
+}
+
+// requestRiskApproval files (or refreshes) the pending request for a note.
+func requestRiskApproval(note PortNote, to, actor, owner string) (RiskApproval, error) {
+	if actor == "" {
+		return RiskApproval{}, errors.New("risk downgrades need an authenticated user")
+	}
+	var a RiskApproval
+	err := DB.Where("host_id = ? AND protocol = ? AND port = ? AND status = ?", note.HostID, note.Protocol, note.Port, ApprovalPending).First(&a).Error
+	if err == nil {
+		return a, nil
+	}
+	a = RiskApproval{
+		HostID: note.HostID, Protocol: note.Protocol, Port: note.Port,
+		FromRisk: note.RiskLevel, ToRisk: to,
+		Status: ApprovalPending, RequestedBy: actor, Requester: owner, RequestedAt: time.Now(),
+	}
+	if err := DB.Create(&a).Error; err != nil {
+		return a, err
+	}
+	recordApprovalEvent(a, actor, fmt.Sprintf("Requested risk change %s → %s", a.FromRisk, a.ToRisk))
+	return a, nil
+}
+
+// recordApprovalEvent puts the request/decision on the port's timeline, if
+// the port has a runtime.
+func recordApprovalEvent(a RiskApproval, actor, detail string) {
+	var rt PortRuntime
+	if DB.Where("host_id = ? AND protocol = ? AND port = ?", a.HostID, a.Protocol, a.Port).First(&rt).Error != nil {
+		return
+	}
+	recordEvent(&rt, PortEvent{
+		PortRuntimeID: rt.ID,
+		EventType:     string(EventRiskApproval),
+		Timestamp:     time.Now(),
+		PID:           rt.CurrentPID,
+		ProcessName:   rt.ProcessName,
+		Detail:        detail,
+		Actor:         actor,
+	})
+}
+
+// GET /approvals?status=pending (default) | approved | rejected | all
+func listApprovals(c *gin.Context) {
+	status := c.DefaultQuery("status", ApprovalPending)
+	q := DB.Order("requested_at desc")
+	if status != "all" {
+		q = q.Where("status = ?", status)
+	}
+	var approvals []RiskApproval
+	q.Find(&approvals)
+	c.JSON(http.StatusOK, approvals)
+}
+
+// POST /approvals/:id/approve
+func approveRiskChange(c *gin.Context) {
+	decideApproval(c, ApprovalApproved)
+}
+
+// POST /approvals/:id/reject
+func rejectRiskChange(c *gin.Context) {
+	decideApproval(c, ApprovalRejected)
+}
+
+var (
+	errNotPending    = errors.New("approval is no longer pending")
+	errStaleApproval = errors.New("the note's risk level changed since this was requested")
+)
+
+func decideApproval(c *gin.Context, decision string) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	actor := actorName(c)
+	var a RiskApproval
+	if err := DB.First(&a, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Approval not found"})
+		return
+	}
+	if actor == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Approvals need an authenticated user"})
+		return
+	}
+	if decision == ApprovalApproved && (actor == a.RequestedBy || actorOwner(c) == a.Requester) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "A different user must approve this change"})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Model(&RiskApproval{}).Where("id = ? AND status = ?", a.ID, ApprovalPending).
+			Updates(map[string]any{"status": decision, "decided_by": actor, "decided_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errNotPending
+		}
+		a.Status, a.DecidedBy, a.DecidedAt = decision, actor, &now
+		if decision != ApprovalApproved {
+			return nil
+		}
+		var prev PortNote
+		tx.Where("host_id = ? AND protocol = ? AND port = ?", a.HostID, a.Protocol, a.Port).First(&prev)
+		// Only if nobody changed the risk in the meantime
+		res = tx.Model(&PortNote{}).
+			Where("host_id = ? AND protocol = ? AND port = ? AND risk_level = ?", a.HostID, a.Protocol, a.Port, a.FromRisk).
+			Updates(map[string]any{"risk_level": a.ToRisk, "updated_by": actor, "version": gorm.Expr("version + 1")})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errStaleApproval
+		}
+		next := prev
+		next.RiskLevel = a.ToRisk
+		return recordNoteRevision(tx, prev, next, actor)
+	})
+	if errors.Is(err, errNotPending) || errors.Is(err, errStaleApproval) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("Risk change %s → %s on %s/%d %s by %s", a.FromRisk, a.ToRisk, a.Protocol, a.Port, decision, actor)
+	if decision == ApprovalApproved {
+		var note PortNote
+		if DB.Where("host_id = ? AND protocol = ? AND port = ?", a.HostID, a.Protocol, a.Port).First(&note).Error == nil {
+			publish(BusEvent{Topic: TopicNoteEdited, Note: &note, Actor: actor})
+		}
+	}
+	recordApprovalEvent(a, actor, fmt.Sprintf("Risk change %s → %s %s (requested by %s)", a.FromRisk, a.ToRisk, decision, a.RequestedBy))
+	c.JSON(http.StatusOK, a)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /events.atom: the event timeline as an Atom feed for feed readers and
+// RSS-to-chat bridges. Filters:
+//
+//	?host=build-03          only this host
+//	?severity=warning       info (default), warning or critical and above
+//	?limit=50               entries, max 500
+//
+// Like the calendar feed, it accepts ?api_key=pmk_... for readers that can't
+// send headers.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title    string       `xml:"title"`
+	ID       string       `xml:"id"`
+	Updated  string       `xml:"updated"`
+	Link     atomLink     `xml:"link"`
+	Category atomCategory `xml:"category"`
+	Content  atomContent  `xml:"content"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func handleEventsAtom(c *gin.Context) {
+	host := c.Query("host")
+	minSeverity := c.DefaultQuery("severity", SeverityInfo)
+	if severityRank(minSeverity) < 0 {
+		c.String(http.StatusBadRequest, "severity must be info, warning or critical")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		c.String(http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	limit = min(limit, 500)
+
+	base := notifyDashURL
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+	base = strings.TrimSuffix(base, "/")
+
+	feed := atomFeed{
+		Title:   "Portmonote events",
+		ID:      base + "/events.atom",
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Link:    atomLink{Href: base + "/"},
+	}
+	if host != "" {
+		feed.Title += " on " + host
+	}
+
+	// Severity depends on the note, so filter in Go and page through events
+	notes := map[PortKey]*PortNote{}
+	const batch = 200
+	for offset := 0; len(feed.Entries) < limit; offset += batch {
+		var rows []struct {
+			PortEvent
+			HostID   string
+			Protocol string
+			Port     int
+		}
+		q := DB.Table("port_event").
+			Select("port_event.*, port_runtime.host_id, port_runtime.protocol, port_runtime.port").
+			Joins("JOIN port_runtime ON port_runtime.id = port_event.port_runtime_id")
+		if host != "" {
+			q = q.Where("port_runtime.host_id = ?", host)
+		}
+		q.Order("port_event.timestamp desc, port_event.id desc").Limit(batch).Offset(offset).Scan(&rows)
+
+		for _, r := range rows {
+			key := PortKey{HostID: r.HostID, Protocol: r.Protocol, Port: r.Port}
+			note, seen := notes[key]
+			if !seen {
+				var n PortNote
+				if DB.Where("host_id = ? AND protocol = ? AND port = ?", r.HostID, r.Protocol, r.Port).First(&n).Error == nil {
+					note = &n
+				}
+				notes[key] = note
+			}
+
+			data := NotificationData{Event: r.PortEvent, Runtime: PortRuntime{HostID: r.HostID, Protocol: r.Protocol, Port: r.Port}}
+			if note != nil {
+				data.Note, data.HasNote = *note, true
+			}
+			severity := eventSeverity(data)
+			if severityRank(severity) < severityRank(minSeverity) {
+				continue
+			}
+			feed.Entries = append(feed.Entries, atomEntryFor(base, data, severity))
+			if len(feed.Entries) == limit {
+				break
+			}
+		}
+		if len(rows) < batch {
+			break
+		}
+	}
+	if len(feed.Entries) > 0 {
+		feed.Updated = feed.Entries[0].Updated
+	}
+
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Data(http.StatusOK, "application/atom+xml; charset=utf-8", append([]byte(xml.Header), out...))
+}
+
+func atomEntryFor(base string, data NotificationData, severity string) atomEntry {
+	e, rt := data.Event, data.Runtime
+	title := fmt.Sprintf("%s: %s/%d on %s", strings.ToUpper(e.EventType), rt.Protocol, rt.Port, rt.HostID)
+	if data.HasNote && data.Note.Title != "" {
+		title += " (" + data.Note.Title + ")"
+	}
+
+	body := fmt.Sprintf("Process: %s (PID %d)\nSeverity: %s", e.ProcessName, e.PID, severity)
+	if e.Detail != "" {
+		body += "\n" + e.Detail
+	}
+	if e.Actor != "" {
+		body += "\nBy: " + e.Actor
+	}
+
+	return atomEntry{
+		Title:    title,
+		ID:       fmt.Sprintf("%s/events/%d", base, e.ID),
+		Updated:  e.Timestamp.UTC().Format(time.RFC3339),
+		Link:     atomLink{Href: fmt.Sprintf("%s/history?host_id=%s&protocol=%s&port=%d", base, rt.HostID, rt.Protocol, rt.Port)},
+		Category: atomCategory{Term: e.EventType},
+		Content:  atomContent{Type: "text", Body: body},
+	}
+}
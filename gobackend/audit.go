@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Every mutating request (POST, PUT, PATCH, DELETE) is written to the audit
+// log after it is handled, failed and refused ones included: who, which
+// endpoint, which port, the values before and after, and where from.
+//
+// New values default to the request body. Handlers that know better call
+// auditValues with what the change replaced and what was saved. Secrets in
+// bodies (passwords, tokens) are masked.
+//
+//	GET /audit?actor=alice&host_id=local&protocol=tcp&port=22&path=/notes&since=7d&limit=100
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Timestamp time.Time `gorm:"index" json:"timestamp"`
+	Actor     string    `gorm:"index" json:"actor"`
+	Method    string    `json:"method"`
+	Path      string    `gorm:"index" json:"path"`
+	Route     string    `json:"route,omitempty"` // e.g. /notes/:id/history
+	HostID    string    `gorm:"index:idx_audit_port" json:"host_id,omitempty"`
+	Protocol  string    `gorm:"index:idx_audit_port" json:"protocol,omitempty"`
+	Port      int       `gorm:"index:idx_audit_port" json:"port,omitempty"`
+	Status    int       `json:"status"`
+	SourceIP  string    `json:"source_ip"`
+	OldValues string    `json:"old_values,omitempty"` // JSON
+	NewValues string    `json:"new_values,omitempty"` // JSON
+}
+
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
+
+// Values longer than this are logged by size only
+const auditMaxBody = 8 << 10
+
+// auditValues records the values a change replaced and the ones it saved,
+// replacing the request body in the audit entry. Either may be nil.
+func auditValues(c *gin.Context, old, new any) {
+	if old != nil {
+		c.Set("audit_old", old)
+	}
+	if new != nil {
+		c.Set("audit_new", new)
+	}
+}
+
+// auditMiddleware writes the audit entry of mutating requests.
+func auditMiddleware(c *gin.Context) {
+	switch c.Request.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		c.Next()
+		return
+	}
+
+	var body []byte
+	if c.Request.Body != nil {
+		// MaxBytesReader (limits.go) already bounds this
+		body, _ = io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	c.Next()
+
+	entry := AuditLog{
+		Timestamp: time.Now(),
+		Actor:     actorName(c),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		Route:     c.FullPath(),
+		HostID:    c.Query("host_id"),
+		Protocol:  c.Query("protocol"),
+		Status:    c.Writer.Status(),
+		SourceIP:  c.ClientIP(),
+	}
+	entry.Port, _ = strconv.Atoi(c.Query("port"))
+	if old, ok := c.Get("audit_old"); ok {
+		entry.OldValues = auditJSON(old)
+	}
+	if v, ok := c.Get("audit_new"); ok {
+		entry.NewValues = auditJSON(v)
+	} else {
+		entry.NewValues = auditBody(body, c.ContentType())
+	}
+	if err := DB.Create(&entry).Error; err != nil {
+		log.Println("Error writing audit log:", err)
+	}
+}
+
+func auditJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	var generic any
+	if json.Unmarshal(b, &generic) == nil {
+		b, _ = json.Marshal(maskSecrets(generic))
+	}
+	if len(b) > auditMaxBody {
+		return fmt.Sprintf(`{"truncated":true,"bytes":%d}`, len(b))
+	}
+	return string(b)
+}
+
+// auditBody is the request body as logged: JSON with secrets masked, or a
+// size note for anything else.
+func auditBody(body []byte, contentType string) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		if contentType == "" {
+			contentType = "unknown type"
+		}
+		return auditJSON(fmt.Sprintf("%d bytes of %s", len(body), contentType))
+	}
+	return auditJSON(v)
+}
+
+// maskSecrets replaces values whose key looks like a credential.
+func maskSecrets(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, val := range v {
+			lk := strings.ToLower(k)
+			if strings.Contains(lk, "password") || strings.Contains(lk, "secret") || strings.Contains(lk, "token") || lk == "key" || lk == "api_key" {
+				v[k] = "***"
+				continue
+			}
+			v[k] = maskSecrets(val)
+		}
+	case []any:
+		for i := range v {
+			v[i] = maskSecrets(v[i])
+		}
+	}
+	return v
+}
+
+// GET /audit, newest first
+func listAudit(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	limit = min(limit, 1000)
+
+	q := DB.Model(&AuditLog{})
+	if s := c.Query("since"); s != "" {
+		since, err := parseSince(s, time.Now())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		q = q.Where("timestamp >= ?", since)
+	}
+	if a := c.Query("actor"); a != "" {
+		q = q.Where("actor = ?", a)
+	}
+	if p := c.Query("path"); p != "" {
+		q = q.Where("path LIKE ?", p+"%")
+	}
+	if h := c.Query("host_id"); h != "" {
+		q = q.Where("host_id = ?", h)
+	}
+	if p := c.Query("protocol"); p != "" {
+		q = q.Where("protocol = ?", p)
+	}
+	if p := c.Query("port"); p != "" {
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid port"})
+			return
+		}
+		q = q.Where("port = ?", port)
+	}
+	var entries []AuditLog
+	if err := q.Order("timestamp desc, id desc").Limit(limit).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if entries == nil {
+		entries = []AuditLog{}
+	}
+	c.JSON(http.StatusOK, entries)
+}
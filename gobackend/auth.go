@@ -0,0 +1,258 @@
+package main
+
+import (
+	"cmp"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Two kinds of callers:
+//   - the browser UI, which gets a CSRF token injected into index.html
+//     (csrf.go);
+//   - scripts/CLIs, which send an API key (Authorization: Bearer pmk_... or
+//     X-API-Key). API keys carry a scope: "read" (GET only), "write" or
+//     "admin" (see rbac.go).
+//
+// By default reads stay open like before. PORTMONOTE_API_AUTH=true requires
+// credentials on every API request, reads included: an API key, or a login
+// plus the CSRF token when PORTMONOTE_AUTH=session (session.go). The CSRF
+// token alone is handed to anyone who loads "/", so it never authenticates.
+var requireAPIAuth = envBool("PORTMONOTE_API_AUTH", false)
+
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+const apiKeyPrefix = "pmk_"
+
+// ApiKey: only a hash of the key is stored; the plaintext is shown once.
+type ApiKey struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `gorm:"index" json:"prefix"` // first characters, to recognise a key in lists
+	KeyHash    string     `gorm:"uniqueIndex;size:64" json:"-"`
+	Scope      string     `json:"scope"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+func (ApiKey) TableName() string {
+	return "api_key"
+}
+
+// Paths that never need credentials: the UI shell itself (unless logins
+// are required) and the login page.
+func isPublicPath(path string) bool {
+	if path == "/" {
+		return !sessionAuthEnabled()
+	}
+	return path == "/favicon.ico" || path == "/login" || path == "/status-page" || path == "/logout" ||
+		strings.HasPrefix(path, "/auth/oidc/") || strings.HasPrefix(path, "/static/") || strings.HasPrefix(path, "/share/") ||
+		(publicBadges && strings.HasPrefix(path, "/badge/"))
+}
+
+func authMiddleware(c *gin.Context) {
+	path := c.Request.URL.Path
+	if isPublicPath(path) {
+		c.Next()
+		return
+	}
+
+	if raw := apiKeyFromRequest(c); raw != "" {
+		key, ok := lookupAPIKey(raw, c.ClientIP())
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or revoked API key"})
+			return
+		}
+		c.Set("principal", "apikey:"+key.Name)
+		c.Set("owner", cmp.Or(key.CreatedBy, "apikey:"+key.Name))
+		if authorize(c, scopeRole(key.Scope)) {
+			c.Next()
+		}
+		return
+	}
+
+	if sessionAuthEnabled() {
+		user, ok := sessionUser(c)
+		if !ok {
+			if path == "/" {
+				c.Redirect(http.StatusSeeOther, "/login")
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Login required"})
+			return
+		}
+		c.Set("principal", "user:"+user.Username)
+		if !authorize(c, user.Role) {
+			return
+		}
+		if path == "/" {
+			c.Next()
+			return
+		}
+	} else if requireAPIAuth {
+		// Without logins there is no one to authenticate but API keys
+		c.Set("role", RoleViewer)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key required"})
+		return
+	} else {
+		// No authentication configured: the UI keeps full access
+		c.Set("role", RoleAdmin)
+	}
+
+	// Browser: reads are open unless PORTMONOTE_API_AUTH is set
+	if c.Request.Method == http.MethodGet && !requireAPIAuth {
+		c.Next()
+		return
+	}
+
+	// Verify Token
+	if !validCSRF(c) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Invalid CSRF Token. Refresh page."})
+		return
+	}
+	c.Next()
+}
+
+func isFeedPath(path string) bool {
+	return strings.HasSuffix(path, ".ics") || strings.HasSuffix(path, ".atom")
+}
+
+func apiKeyFromRequest(c *gin.Context) string {
+	if v := c.GetHeader("X-API-Key"); v != "" {
+		return v
+	}
+	if v, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(v)
+	}
+	// Feeds are fetched by calendar/feed readers that can't set headers
+	if isFeedPath(c.Request.URL.Path) {
+		return c.Query("api_key")
+	}
+	return ""
+}
+
+// requestLogger is gin's request log with ?api_key= blanked out, so feed
+// URLs don't leave usable keys in the logs.
+func requestLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(p gin.LogFormatterParams) string {
+		if path, query, ok := strings.Cut(p.Path, "?"); ok {
+			if q, err := url.ParseQuery(query); err == nil && q.Has("api_key") {
+				q.Set("api_key", "REDACTED")
+				p.Path = path + "?" + q.Encode()
+			}
+		}
+		var statusColor, methodColor, resetColor string
+		if p.IsOutputColor() {
+			statusColor, methodColor, resetColor = p.StatusCodeColor(), p.MethodColor(), p.ResetColor()
+		}
+		if p.Latency > time.Minute {
+			p.Latency = p.Latency.Truncate(time.Second)
+		}
+		return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			p.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			statusColor, p.StatusCode, resetColor,
+			p.Latency, p.ClientIP,
+			methodColor, p.Method, resetColor,
+			p.Path, p.ErrorMessage)
+	})
+}
+
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func lookupAPIKey(raw, ip string) (*ApiKey, bool) {
+	if !strings.HasPrefix(raw, apiKeyPrefix) {
+		return nil, false
+	}
+	var key ApiKey
+	if err := DB.Where("key_hash = ? AND revoked_at IS NULL", hashAPIKey(raw)).First(&key).Error; err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	DB.Model(&key).Updates(map[string]any{"last_used_at": now, "last_used_ip": ip})
+	return &key, true
+}
+
+type ApiKeyCreateRequest struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+}
+
+// POST /admin/apikeys returns the plaintext key exactly once.
+func createAPIKey(c *gin.Context) {
+	var req ApiKeyCreateRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = ScopeRead
+	}
+	if req.Scope != ScopeRead && req.Scope != ScopeWrite && req.Scope != ScopeAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be read, write or admin"})
+		return
+	}
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	raw := apiKeyPrefix + hex.EncodeToString(buf)
+
+	key := ApiKey{
+		Name:      req.Name,
+		Prefix:    raw[:len(apiKeyPrefix)+6],
+		KeyHash:   hashAPIKey(raw),
+		Scope:     req.Scope,
+		CreatedBy: actorName(c),
+	}
+	if err := DB.Create(&key).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"key": raw, "api_key": key})
+}
+
+func listAPIKeys(c *gin.Context) {
+	var keys []ApiKey
+	DB.Order("id").Find(&keys)
+	c.JSON(http.StatusOK, keys)
+}
+
+// DELETE /admin/apikeys/:id revokes; the row stays for the record.
+func revokeAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		return
+	}
+	res := DB.Model(&ApiKey{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", time.Now())
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found or already revoked"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "revoked"})
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Events that flip a runtime between listening and not listening.
+var upEvents = []string{string(EventAppeared), string(EventReappeared), string(EventInherited)}
+
+func isUpEvent(t string) bool {
+	return slices.Contains(upEvents, t)
+}
+
+// Availability summarizes a runtime's listening history over a window.
+type Availability struct {
+	RuntimeID            uint      `json:"runtime_id"`
+	From                 time.Time `json:"from"`
+	To                   time.Time `json:"to"`
+	UptimePercent        float64   `json:"uptime_percent"`
+	UptimeSeconds        int       `json:"uptime_seconds"`
+	DowntimeSeconds      int       `json:"downtime_seconds"`
+	Outages              int       `json:"outages"`
+	LongestOutageSeconds int       `json:"longest_outage_seconds"`
+	CurrentlyDown        bool      `json:"currently_down"`
+	Target               *float64  `json:"target,omitempty"`
+	MeetsTarget          *bool     `json:"meets_target,omitempty"`
+}
+
+// availability returns the share of [since, now] the runtime was listening.
+func availability(rt *PortRuntime, since, now time.Time) (pct float64, ok bool) {
+	a, ok := availabilityReport(rt, since, now)
+	return a.UptimePercent, ok
+}
+
+// availabilityReport reconstructs [since, now] from the runtime's
+// appeared/disappeared timeline. Time before the port was first seen
+// doesn't count; an outage already running at the start of the window is
+// counted, clipped to the window. ok is false when the window is empty.
+func availabilityReport(rt *PortRuntime, since, now time.Time) (a Availability, ok bool) {
+	a.RuntimeID = rt.ID
+	start := since
+	if rt.FirstSeenAt.After(start) {
+		start = rt.FirstSeenAt
+	}
+	a.From, a.To = start, now
+	if !now.After(start) {
+		return a, false
+	}
+
+	types := append([]string{string(EventDisappeared)}, upEvents...)
+
+	// State at the start of the window: the last transition before it
+	up := true
+	var before PortEvent
+	if err := DB.Where("port_runtime_id = ? AND event_type IN ? AND timestamp < ?", rt.ID, types, start).
+		Order("timestamp desc").First(&before).Error; err == nil {
+		up = isUpEvent(before.EventType)
+	}
+
+	var events []PortEvent
+	DB.Where("port_runtime_id = ? AND event_type IN ? AND timestamp >= ? AND timestamp < ?", rt.ID, types, start, now).
+		Order("timestamp").Find(&events)
+
+	var upTime, downTime, longest time.Duration
+	if !up {
+		a.Outages++
+	}
+	cursor := start
+	span := func(until time.Time) {
+		d := until.Sub(cursor)
+		if up {
+			upTime += d
+		} else {
+			downTime += d
+			longest = max(longest, d)
+		}
+	}
+	for _, e := range events {
+		nowUp := isUpEvent(e.EventType)
+		if nowUp == up {
+			continue // repeated transition, nothing changes
+		}
+		span(e.Timestamp)
+		cursor = e.Timestamp
+		up = nowUp
+		if !up {
+			a.Outages++
+		}
+	}
+	span(now)
+
+	a.UptimeSeconds = int(upTime.Seconds())
+	a.DowntimeSeconds = int(downTime.Seconds())
+	a.LongestOutageSeconds = int(longest.Seconds())
+	a.CurrentlyDown = !up
+	a.UptimePercent = float64(upTime) / float64(now.Sub(start)) * 100
+	return a, true
+}
+
+// GET /ports/:id/availability?window=30d&target=99.9
+func getAvailability(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var rt PortRuntime
+	if err := DB.First(&rt, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Runtime not found"})
+		return
+	}
+	window, err := parseWindow(c.DefaultQuery("window", "30d"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now()
+	a, ok := availabilityReport(&rt, now.Add(-window), now)
+	if !ok {
+		c.JSON(http.StatusOK, a)
+		return
+	}
+	if v := c.Query("target"); v != "" {
+		target, err := strconv.ParseFloat(v, 64)
+		if err != nil || target <= 0 || target > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target must be a percentage"})
+			return
+		}
+		meets := a.UptimePercent >= target
+		a.Target, a.MeetsTarget = &target, &meets
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+// parseWindow accepts Go durations plus whole days, e.g. "30d", "12h".
+func parseWindow(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid window %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid window %q", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Shields-style SVG badges for noted ports and tag groups:
+//
+//	GET /badge/:host/:proto/:port   e.g. /badge/local/tcp/5432
+//	GET /badge/tag/:tag             all noted ports carrying the tag
+//
+// The badge shows up/down and 30-day availability. Unnoted ports get no
+// badge. Set PORTMONOTE_PUBLIC_BADGES=true to serve them without credentials
+// (for wikis and READMEs) even when logins are required.
+var publicBadges = envBool("PORTMONOTE_PUBLIC_BADGES", false)
+
+const badgeWindow = 30 * 24 * time.Hour
+
+func handlePortBadge(c *gin.Context) {
+	port, err := strconv.Atoi(c.Param("port"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid port")
+		return
+	}
+	var note PortNote
+	if err := DB.Where("host_id = ? AND protocol = ? AND port = ?", c.Param("host"), c.Param("proto"), port).First(&note).Error; err != nil {
+		c.String(http.StatusNotFound, "No note for this port")
+		return
+	}
+	label := note.Title
+	if label == "" {
+		label = fmt.Sprintf("%s/%d", note.Protocol, note.Port)
+	}
+
+	now := time.Now()
+	var rt PortRuntime
+	up, pct := false, (*float64)(nil)
+	if err := DB.Where("host_id = ? AND protocol = ? AND port = ?", note.HostID, note.Protocol, note.Port).First(&rt).Error; err == nil {
+		up = rt.CurrentState == string(StateActive)
+		pct = availabilityPtr(&rt, now.Add(-badgeWindow), now)
+	}
+	writeBadge(c, label, up, pct)
+}
+
+func handleTagBadge(c *gin.Context) {
+	tag := c.Param("tag")
+	notes := notesTagged(tag)
+
+	now := time.Now()
+	found, allUp := false, true
+	var sum float64
+	var n int
+	for _, note := range notes {
+		found = true
+		var rt PortRuntime
+		if err := DB.Where("host_id = ? AND protocol = ? AND port = ?", note.HostID, note.Protocol, note.Port).First(&rt).Error; err != nil {
+			allUp = false
+			continue
+		}
+		allUp = allUp && rt.CurrentState == string(StateActive)
+		if pct, ok := availability(&rt, now.Add(-badgeWindow), now); ok {
+			sum += pct
+			n++
+		}
+	}
+	if !found {
+		c.String(http.StatusNotFound, "No noted ports with this tag")
+		return
+	}
+	var pct *float64
+	if n > 0 {
+		avg := sum / float64(n)
+		pct = &avg
+	}
+	writeBadge(c, tag, allUp, pct)
+}
+
+func writeBadge(c *gin.Context, label string, up bool, pct *float64) {
+	status, color := "down", "#e05d44"
+	if up {
+		status, color = "up", "#4c1"
+	}
+	if pct != nil {
+		status += fmt.Sprintf(" · %.2f%%", *pct)
+		if up && *pct < 99 {
+			color = "#dfb317"
+		}
+	}
+	c.Header("Cache-Control", "no-cache, max-age=0")
+	c.Data(http.StatusOK, "image/svg+xml; charset=utf-8", []byte(renderBadge(label, status, color)))
+}
+
+// renderBadge draws a flat shields.io-style badge. Text width is estimated
+// at ~6.5px per character of 11px Verdana, which is close enough.
+func renderBadge(label, status, color string) string {
+	lw := textWidth(label) + 10
+	sw := textWidth(status) + 10
+	w := lw + sw
+	label, status = html.EscapeString(label), html.EscapeString(status)
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">
+<title>%[4]s: %[5]s</title>
+<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="%[7]d" y="14">%[4]s</text><text x="%[8]d" y="14">%[5]s</text>
+</g>
+</svg>
+`, w, lw, sw, label, status, color, lw/2, lw+sw/2)
+}
+
+func textWidth(s string) int {
+	return int(float64(len([]rune(s))) * 6.5)
+}
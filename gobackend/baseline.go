@@ -0,0 +1,361 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Baselines: a named copy of the listening ports at one moment ("after
+// hardening, 2024-05"). While a baseline is active, every cycle compares the
+// runtimes of its hosts against it; a port that listens without being in
+// the baseline, or a baseline port that stopped listening, gets a drift
+// event once when it starts drifting.
+type Baseline struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `json:"name"`
+	HostIDs   string    `json:"-"` // hosts the baseline covers, comma-separated
+	Active    bool      `gorm:"index;default:false" json:"active"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Hosts     []string       `gorm:"-" json:"host_ids"`
+	PortCount int            `gorm:"-" json:"port_count"`
+	Ports     []BaselinePort `gorm:"foreignKey:BaselineID;constraint:OnDelete:CASCADE;" json:"ports,omitempty"`
+}
+
+func (Baseline) TableName() string {
+	return "baseline"
+}
+
+func (b *Baseline) AfterFind(*gorm.DB) error {
+	b.Hosts = splitList(b.HostIDs)
+	return nil
+}
+
+type BaselinePort struct {
+	ID          uint   `gorm:"primaryKey" json:"-"`
+	BaselineID  uint   `gorm:"index" json:"-"`
+	HostID      string `json:"host_id"`
+	Protocol    string `json:"protocol"`
+	Port        int    `json:"port"`
+	ProcessName string `json:"process_name"`
+}
+
+func (BaselinePort) TableName() string {
+	return "baseline_port"
+}
+
+const (
+	DriftUnexpected = "unexpected" // listening, not in the baseline
+	DriftMissing    = "missing"    // in the baseline, not listening
+)
+
+// POST /baselines {"name": "...", "host_ids": [...], "activate": true}
+// captures the ports listening now (on the given hosts, default all).
+func createBaseline(c *gin.Context) {
+	var req struct {
+		Name     string   `json:"name"`
+		HostIDs  []string `json:"host_ids"`
+		Activate *bool    `json:"activate"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	q := DB.Where("current_state = ?", StateActive)
+	if len(req.HostIDs) > 0 {
+		q = q.Where("host_id IN ?", req.HostIDs)
+	}
+	var runtimes []PortRuntime
+	q.Order("host_id, protocol, port").Find(&runtimes)
+
+	hosts := slices.Clone(req.HostIDs)
+	b := Baseline{Name: req.Name, CreatedBy: actorName(c), Active: req.Activate == nil || *req.Activate}
+	for _, rt := range runtimes {
+		b.Ports = append(b.Ports, BaselinePort{HostID: rt.HostID, Protocol: rt.Protocol, Port: rt.Port, ProcessName: rt.ProcessName})
+		if len(req.HostIDs) == 0 && !slices.Contains(hosts, rt.HostID) {
+			hosts = append(hosts, rt.HostID)
+		}
+	}
+	b.HostIDs = strings.Join(hosts, ",")
+	b.Hosts = hosts
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if b.Active {
+			if err := tx.Model(&Baseline{}).Where("active = ?", true).Update("active", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&b).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	b.PortCount = len(b.Ports)
+	log.Printf("📸 Baseline %q captured with %d ports", b.Name, b.PortCount)
+	c.JSON(http.StatusCreated, b)
+}
+
+// GET /baselines
+func listBaselines(c *gin.Context) {
+	var baselines []Baseline
+	DB.Order("created_at desc").Find(&baselines)
+	var counts []struct {
+		BaselineID uint
+		Count      int
+	}
+	DB.Model(&BaselinePort{}).Select("baseline_id, COUNT(*) AS count").Group("baseline_id").Scan(&counts)
+	for i := range baselines {
+		for _, n := range counts {
+			if n.BaselineID == baselines[i].ID {
+				baselines[i].PortCount = n.Count
+			}
+		}
+	}
+	c.JSON(http.StatusOK, baselines)
+}
+
+// GET /baselines/:id
+func getBaseline(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var b Baseline
+	if err := DB.Preload("Ports").First(&b, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Baseline not found"})
+		return
+	}
+	b.PortCount = len(b.Ports)
+	c.JSON(http.StatusOK, b)
+}
+
+// POST /baselines/:id/activate makes this the baseline the collector
+// compares against; POST /baselines/:id/deactivate stops drift checks.
+func activateBaseline(c *gin.Context) {
+	setBaselineActive(c, true)
+}
+
+func deactivateBaseline(c *gin.Context) {
+	setBaselineActive(c, false)
+}
+
+func setBaselineActive(c *gin.Context, active bool) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var b Baseline
+	if err := DB.First(&b, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Baseline not found"})
+		return
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if active {
+			if err := tx.Model(&Baseline{}).Where("active = ? AND id <> ?", true, b.ID).Update("active", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&b).Update("active", active).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !active {
+		// Nothing drifts without a baseline
+		DB.Model(&PortRuntime{}).Where("drift <> ''").Update("drift", "")
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+// DELETE /baselines/:id
+func deleteBaseline(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var b Baseline
+	if err := DB.First(&b, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Baseline not found"})
+		return
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("baseline_id = ?", b.ID).Delete(&BaselinePort{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&b).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if b.Active {
+		DB.Model(&PortRuntime{}).Where("drift <> ''").Update("drift", "")
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+// DriftItem is one difference between a baseline and now.
+type DriftItem struct {
+	HostID      string `json:"host_id"`
+	Protocol    string `json:"protocol"`
+	Port        int    `json:"port"`
+	RuntimeID   uint   `json:"runtime_id,omitempty"`
+	ProcessName string `json:"process_name,omitempty"` // now, or in the baseline if missing
+	Expected    string `json:"expected_process,omitempty"`
+}
+
+type DriftReport struct {
+	Baseline       Baseline    `json:"baseline"`
+	Unexpected     []DriftItem `json:"unexpected"`
+	Missing        []DriftItem `json:"missing"`
+	ProcessChanged []DriftItem `json:"process_changed"`
+	InSync         bool        `json:"in_sync"`
+}
+
+// GET /baselines/:id/drift compares any baseline, active or not, with the
+// current runtimes.
+func getBaselineDrift(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var b Baseline
+	if err := DB.Preload("Ports").First(&b, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Baseline not found"})
+		return
+	}
+	report, err := baselineDrift(b)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	report.Baseline.Ports = nil
+	report.Baseline.PortCount = len(b.Ports)
+	c.JSON(http.StatusOK, report)
+}
+
+func baselineDrift(b Baseline) (DriftReport, error) {
+	r := DriftReport{Baseline: b, Unexpected: []DriftItem{}, Missing: []DriftItem{}, ProcessChanged: []DriftItem{}}
+	var runtimes []PortRuntime
+	if err := DB.Where("host_id IN ?", b.Hosts).Order("host_id, protocol, port").Find(&runtimes).Error; err != nil {
+		return r, err
+	}
+	expected := map[PortKey]BaselinePort{}
+	for _, p := range b.Ports {
+		expected[PortKey{HostID: p.HostID, Protocol: p.Protocol, Port: p.Port}] = p
+	}
+	listening := map[PortKey]bool{}
+	for _, rt := range runtimes {
+		key := PortKey{HostID: rt.HostID, Protocol: rt.Protocol, Port: rt.Port}
+		if rt.CurrentState != string(StateActive) {
+			continue
+		}
+		listening[key] = true
+		item := DriftItem{HostID: rt.HostID, Protocol: rt.Protocol, Port: rt.Port, RuntimeID: rt.ID, ProcessName: rt.ProcessName}
+		p, ok := expected[key]
+		switch {
+		case !ok:
+			r.Unexpected = append(r.Unexpected, item)
+		case p.ProcessName != "" && p.ProcessName != rt.ProcessName:
+			item.Expected = p.ProcessName
+			r.ProcessChanged = append(r.ProcessChanged, item)
+		}
+	}
+	ids := map[PortKey]uint{}
+	for _, rt := range runtimes {
+		ids[PortKey{HostID: rt.HostID, Protocol: rt.Protocol, Port: rt.Port}] = rt.ID
+	}
+	for _, p := range b.Ports {
+		key := PortKey{HostID: p.HostID, Protocol: p.Protocol, Port: p.Port}
+		if !listening[key] {
+			r.Missing = append(r.Missing, DriftItem{HostID: p.HostID, Protocol: p.Protocol, Port: p.Port, RuntimeID: ids[key], Expected: p.ProcessName})
+		}
+	}
+	r.InSync = len(r.Unexpected) == 0 && len(r.Missing) == 0 && len(r.ProcessChanged) == 0
+	return r, nil
+}
+
+var errNoActiveBaseline = errors.New("no active baseline")
+
+func activeBaseline() (Baseline, error) {
+	var b Baseline
+	err := DB.Preload("Ports").Where("active = ?", true).First(&b).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return b, errNoActiveBaseline
+	}
+	return b, err
+}
+
+// evaluateDriftCycle updates each runtime's drift state against the active
+// baseline and records a drift event when a runtime starts drifting.
+func evaluateDriftCycle() {
+	b, err := activeBaseline()
+	if errors.Is(err, errNoActiveBaseline) {
+		return
+	}
+	if err != nil {
+		log.Println("Error loading baseline:", err)
+		return
+	}
+	report, err := baselineDrift(b)
+	if err != nil {
+		log.Println("Error evaluating drift:", err)
+		return
+	}
+
+	current := map[uint]string{}
+	details := map[uint]string{}
+	for _, d := range report.Unexpected {
+		current[d.RuntimeID] = DriftUnexpected
+		details[d.RuntimeID] = fmt.Sprintf("Not in baseline %q", b.Name)
+	}
+	for _, d := range report.Missing {
+		if d.RuntimeID != 0 {
+			current[d.RuntimeID] = DriftMissing
+			details[d.RuntimeID] = fmt.Sprintf("Expected by baseline %q but not listening", b.Name)
+		}
+	}
+
+	now := time.Now()
+	var runtimes []PortRuntime
+	DB.Where("drift <> '' OR id IN ?", mapKeys(current)).Find(&runtimes)
+	for _, rt := range runtimes {
+		state := current[rt.ID]
+		if state == rt.Drift {
+			continue
+		}
+		// Drift starting inside a maintenance window waits until it ends
+		if state != "" && inMaintenance(&rt, now) {
+			continue
+		}
+		DB.Model(&rt).Update("drift", state)
+		if state == "" {
+			continue
+		}
+		log.Printf("Drift on %s/%d (%s): %s", rt.Protocol, rt.Port, rt.HostID, details[rt.ID])
+		recordEvent(&rt, PortEvent{
+			PortRuntimeID: rt.ID,
+			EventType:     string(EventDrift),
+			Timestamp:     now,
+			PID:           rt.CurrentPID,
+			ProcessName:   rt.ProcessName,
+			Detail:        details[rt.ID],
+		})
+	}
+}
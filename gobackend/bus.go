@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Internal event bus. Producers (collector, note edits) publish; features
+// that react to changes subscribe instead of being called directly. The
+// notifier is one subscriber, PORTMONOTE_HOOKS_DIR another.
+type Topic string
+
+const (
+	TopicPortEvent    Topic = "port.event"         // every timeline event
+	TopicStateChanged Topic = "port.state_changed" // active <-> disappeared
+	TopicNoteEdited   Topic = "note.edited"
+)
+
+type BusEvent struct {
+	Topic     Topic        `json:"topic"`
+	Time      time.Time    `json:"time"`
+	Event     *PortEvent   `json:"event,omitempty"`
+	Runtime   *PortRuntime `json:"runtime,omitempty"`
+	Note      *PortNote    `json:"note,omitempty"`
+	FromState string       `json:"from_state,omitempty"`
+	ToState   string       `json:"to_state,omitempty"`
+	Actor     string       `json:"actor,omitempty"`
+}
+
+type subscriber struct {
+	name   string
+	topics []Topic // empty = all
+	fn     func(BusEvent)
+}
+
+var (
+	busMu       sync.RWMutex
+	subscribers []subscriber
+)
+
+// subscribe registers fn for the given topics (all when none are given).
+// Handlers run synchronously on the publisher's goroutine, so anything slow
+// must hand off to its own queue.
+func subscribe(name string, fn func(BusEvent), topics ...Topic) {
+	busMu.Lock()
+	defer busMu.Unlock()
+	subscribers = append(subscribers, subscriber{name: name, topics: topics, fn: fn})
+}
+
+func publish(evt BusEvent) {
+	if evt.Time.IsZero() {
+		evt.Time = time.Now()
+	}
+	busMu.RLock()
+	subs := subscribers
+	busMu.RUnlock()
+	for _, s := range subs {
+		if len(s.topics) > 0 && !slices.Contains(s.topics, evt.Topic) {
+			continue
+		}
+		deliverBusEvent(s, evt)
+	}
+}
+
+func deliverBusEvent(s subscriber, evt BusEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("⚠️ Subscriber %s panicked on %s: %v", s.name, evt.Topic, r)
+		}
+	}()
+	s.fn(evt)
+}
+
+// publishPortEvent announces a stored timeline event, plus a state change
+// when the event moved the port between listening and gone.
+func publishPortEvent(evt PortEvent, runtime PortRuntime) {
+	if isSelfTestRuntime(runtime) {
+		return
+	}
+	// Planned restarts stay quiet; user actions still go out
+	if evt.Actor == "" && inMaintenance(&runtime, evt.Timestamp) {
+		return
+	}
+	publish(BusEvent{Topic: TopicPortEvent, Time: evt.Timestamp, Event: &evt, Runtime: &runtime, Actor: evt.Actor})
+
+	from, to := "", ""
+	switch EventType(evt.EventType) {
+	case EventAppeared:
+		to = string(StateActive)
+	case EventReappeared, EventInherited:
+		from, to = string(StateDisappeared), string(StateActive)
+	case EventDisappeared:
+		from, to = string(StateActive), string(StateDisappeared)
+	default:
+		return
+	}
+	publish(BusEvent{Topic: TopicStateChanged, Time: evt.Timestamp, Event: &evt, Runtime: &runtime, FromState: from, ToState: to})
+}
+
+// Hooks: every executable in PORTMONOTE_HOOKS_DIR is run for every bus
+// event with the event as JSON on stdin and PORTMONOTE_TOPIC set. Hooks run
+// as background jobs, so failures are retried and show up in GET /jobs.
+// Sensitive note descriptions are not passed on.
+var hooksDir = envString("PORTMONOTE_HOOKS_DIR", "")
+
+type hookJob struct {
+	Script string   `json:"script"`
+	Event  BusEvent `json:"event"`
+}
+
+func init() {
+	registerJob("hook", JobKind{Run: runHookJob, Concurrency: 2})
+}
+
+func startHooks() {
+	if hooksDir == "" {
+		return
+	}
+	subscribe("hooks", func(evt BusEvent) {
+		if evt.Note != nil && evt.Note.Sensitive {
+			redacted := *evt.Note
+			redacted.Description, redacted.DescriptionRedacted = "", true
+			evt.Note = &redacted
+		}
+		for _, script := range hookScripts() {
+			if _, err := enqueueJob("hook", hookJob{Script: script, Event: evt}); err != nil {
+				log.Printf("Error queueing hook %s: %v", script, err)
+			}
+		}
+	})
+	log.Printf("🪝 Hooks: running scripts in %s", hooksDir)
+}
+
+func hookScripts() []string {
+	entries, err := os.ReadDir(hooksDir)
+	if err != nil {
+		log.Println("Error reading hooks dir:", err)
+		return nil
+	}
+	var scripts []string
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() || e.Name()[0] == '.' || info.Mode()&0o111 == 0 {
+			continue
+		}
+		scripts = append(scripts, filepath.Join(hooksDir, e.Name()))
+	}
+	return scripts
+}
+
+func runHookJob(ctx context.Context, payload string) (string, error) {
+	var job hookJob
+	if err := json.Unmarshal([]byte(payload), &job); err != nil {
+		return "", err
+	}
+	event, _ := json.Marshal(job.Event)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, job.Script)
+	cmd.Stdin = bytes.NewReader(event)
+	cmd.Env = append(os.Environ(), "PORTMONOTE_TOPIC="+string(job.Event.Topic))
+	out := &cappedBuffer{limit: 4096}
+	cmd.Stdout, cmd.Stderr = out, out
+	if err := cmd.Run(); err != nil {
+		return out.String(), fmt.Errorf("%s: %w", filepath.Base(job.Script), err)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Capability describes one optional subsystem and whether it is active.
+type Capability struct {
+	Enabled bool   `json:"enabled"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// currentCapabilities is the single place clients look to adapt their UI.
+// Subsystems that don't exist in this build are listed as disabled so clients
+// can rely on the key being present.
+func currentCapabilities() map[string]Capability {
+	_, witrErr := exec.LookPath("witr")
+
+	authDetail := "CSRF token or API key"
+	if sessionAuthEnabled() {
+		authDetail = "login required"
+		if oidcEnabled() {
+			authDetail += ", SSO via " + oidcIssuer
+		}
+	}
+
+	// Agents need no setup (POST /hosts/:id/report); say how many report
+	var agents int64
+	DB.Model(&Host{}).Where("source = ?", "agent").Count(&agents)
+
+	retention := retentionPolicy.KeepDays > 0 || retentionPolicy.MaxEventsPerRuntime > 0
+
+	return map[string]Capability{
+		"auth":            {Enabled: requireAPIAuth || sessionAuthEnabled(), Detail: authDetail},
+		"api_keys":        {Enabled: true},
+		"agents":          {Enabled: true, Detail: fmt.Sprintf("%d agent host(s)", agents)},
+		"notifications":   {Enabled: len(notifyChannels) > 0, Detail: fmt.Sprintf("%d channel(s)", len(notifyChannels))},
+		"ebpf_scanner":    {Enabled: false},
+		"listener_watch":  {Enabled: watchMode == "netlink", Detail: watchInterval.String()},
+		"docker":          {Enabled: false},
+		"kubernetes":      {Enabled: k8sEnabled, Detail: k8sKubeletURL},
+		"witr":            {Enabled: witrErr == nil},
+		"status_page":     {Enabled: len(statusPageTags) > 0, Detail: strings.Join(statusPageTags, ",")},
+		"tls":             {Enabled: tlsMode != "plain", Detail: tlsMode},
+		"protocol_probe":  {Enabled: protocolProbe},
+		"snmp":            {Enabled: len(snmpTargets) > 0, Detail: fmt.Sprintf("%d target(s)", len(snmpTargets))},
+		"risk_approval":   {Enabled: requireApproval},
+		"sensitive_notes": {Enabled: noteKey != nil, Detail: "readable by " + sensitiveRole + " and above"},
+		"retention":       {Enabled: retention},
+		"power_save":      {Enabled: powerSaveMode == "auto" || powerSaveMode == "on" || powerSaveMode == "true", Detail: powerSaveMode},
+		"export":          {Enabled: true},
+		"policy":          {Enabled: policyFile != "", Detail: policyFile},
+		"ignore_rules":    {Enabled: len(ignoreRules) > 0, Detail: fmt.Sprintf("%d rule(s)", len(ignoreRules))},
+		"database":        {Enabled: true, Detail: dbDriver},
+	}
+}
+
+func getCapabilities(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"version":      Version,
+		"capabilities": currentCapabilities(),
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// runCheck implements `portmonote check --policy policy.yaml`: scan once,
+// compare against the policy and exit 1 when something is listening that
+// shouldn't be. Exit code 2 means the check itself could not run.
+func runCheck(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	policyFile := fs.String("policy", "policy.yaml", "Allowed-ports policy file (YAML)")
+	format := fs.String("format", "text", "Output format: text or json")
+	fs.Parse(args)
+
+	policy, err := loadPolicy(*policyFile)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		os.Exit(2)
+	}
+
+	items, err := scanOnce(nil)
+	if err != nil {
+		log.Printf("❌ Scan failed: %v", err)
+		os.Exit(2)
+	}
+	violations := policy.Evaluate(items)
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if violations == nil {
+			violations = []PolicyViolation{}
+		}
+		enc.Encode(violations)
+	} else {
+		for _, v := range violations {
+			rule := ""
+			if v.Rule != "" {
+				rule = " [" + v.Rule + "]"
+			}
+			fmt.Printf("VIOLATION %s/%d pid=%d process=%s: %s%s\n", v.Protocol, v.Port, v.PID, v.ProcessName, v.Reason, rule)
+		}
+		fmt.Printf("%d listeners checked, %d violations\n", len(items), len(violations))
+	}
+
+	if len(violations) > 0 {
+		os.Exit(1)
+	}
+}
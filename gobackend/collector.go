@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math/rand/v2"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/shirou/gopsutil/v4/net"
-	"github.com/shirou/gopsutil/v4/process"
+	"gorm.io/gorm"
 )
 
 type PortKey struct {
@@ -18,67 +24,208 @@ type ScanResult struct {
 	PID         int
 	ProcessName string
 	Cmdline     string
-	State       string // LISTEN, ESTABLISHED, etc.
+	Username    string
+	ExePath     string
+	Unit        string
+	Slice       string
+	ExeSHA256   string         // filled in by hashExecutables
+	Connections int            // ESTABLISHED, filled in by countEstablished
+	Peers       map[string]int // remote IP -> connections
+	State       string         // LISTEN, ESTABLISHED, etc.
+	Pod         PodInfo
 }
 
 // Global host ID
 const HostID = "local"
 
-func RunCollectionCycle() {
+// cycles tracks in-flight collection cycles so shutdown can wait for them.
+var cycles sync.WaitGroup
+
+// cycleMu keeps the ticker and /trigger-scan from running cycles in
+// parallel, which would create duplicate runtimes and events.
+var cycleMu sync.Mutex
+
+// Random delay before each scheduled cycle, so a fleet of hosts doesn't scan
+// (and write to a shared database) in lockstep.
+var collectJitter = envDuration("PORTMONOTE_COLLECT_JITTER", 0)
+
+// RunCollectionCycle scans once. If a cycle is already running it logs and
+// returns false instead of starting a second one.
+func RunCollectionCycle() bool {
+	if !cycleMu.TryLock() {
+		log.Println("⏭️ Collection cycle still running, skipping this one")
+		collectorState.skip()
+		return false
+	}
+	defer cycleMu.Unlock()
+	cycles.Add(1)
+	defer cycles.Done()
 	log.Println("Starting collection cycle...")
 
+	started := time.Now()
+	collectorState.begin()
+	seen, err := collect()
+	collectorState.finish(started, seen, err)
+	if err != nil {
+		log.Println("Collection cycle failed:", err)
+		return true
+	}
+	log.Println("Cycle complete.")
+	return true
+}
+
+// collect runs one scan and applies it to the database. All runtime changes
+// and events of a cycle are written in one transaction, so a failed cycle
+// leaves nothing half-applied; notifications go out only after commit.
+// It returns the number of listening ports seen.
+func collect() (int, error) {
 	// 1. Scan Current Ports
 	currentOpenPorts, err := scanPorts()
 	if err != nil {
-		log.Println("Error scanning ports:", err)
-		return
+		return 0, fmt.Errorf("scanning ports: %w", err)
+	}
+	mergeWatched(currentOpenPorts)
+	dropIgnored(currentOpenPorts)
+	held := heldProtocols()
+	for k := range currentOpenPorts {
+		if k.HostID == HostID && held[k.Protocol] {
+			delete(currentOpenPorts, k)
+		}
+	}
+	podsResolved := k8sEnabled && attributePods(currentOpenPorts)
+	hashExecutables(currentOpenPorts)
+	countEstablished(currentOpenPorts)
+
+	// Only hosts that were actually scanned this cycle can lose ports
+	scannedHosts := map[string]bool{HostID: true}
+	var polled []string
+	if len(snmpTargets) > 0 {
+		var remote map[PortKey]ScanResult
+		remote, polled = pollSNMPTargets()
+		dropIgnored(remote)
+		for k, v := range remote {
+			currentOpenPorts[k] = v
+		}
+		for _, h := range polled {
+			scannedHosts[h] = true
+		}
 	}
 
+	var batch *eventBatch
+	var listening []*PortRuntime // for resource sampling
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		batch = &eventBatch{}
+		var err error
+		listening, err = applyScan(tx, currentOpenPorts, scannedHosts, held, podsResolved, batch)
+		if err != nil {
+			return err
+		}
+		if err := batch.flush(tx); err != nil {
+			return fmt.Errorf("saving events: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	batch.notify()
+	scheduleReinspections(batch)
+	applyNoteTemplates(batch)
+	seedAutoNotes(batch)
+	reportScannedHosts(polled)
+
+	sampleRuntimes(listening)
+	probeRuntimes(listening)
+	recordPeers(listening, currentOpenPorts)
+	evaluatePolicyCycle()
+	evaluateDriftCycle()
+	recordSnapshot(time.Now())
+
+	return len(currentOpenPorts), nil
+}
+
+// applyScan reconciles the scan with the stored runtimes of the scanned hosts
+// inside tx and queues the resulting events. It returns the runtimes that
+// are listening now. Local runtimes of held protocols were not scanned and
+// stay untouched, and so do stored pod fields unless podsResolved.
+func applyScan(tx *gorm.DB, currentOpenPorts map[PortKey]ScanResult, scannedHosts, held map[string]bool, podsResolved bool, batch *eventBatch) ([]*PortRuntime, error) {
 	// 2. Load DB State (Active Runtimes)
 	var activeRuntimes []PortRuntime
 	// Get all runtimes that are currently tracked
-	if err := DB.Find(&activeRuntimes).Error; err != nil {
-		log.Println("Error loading runtimes:", err)
-		return
+	if err := tx.Where("host_id IN ?", mapKeys(scannedHosts)).Find(&activeRuntimes).Error; err != nil {
+		return nil, fmt.Errorf("loading runtimes: %w", err)
 	}
 
 	// Turn DB list into Map for fast lookup
 	dbMap := make(map[PortKey]*PortRuntime)
 	for i := range activeRuntimes {
 		r := &activeRuntimes[i]
+		if r.HostID == HostID && held[r.Protocol] {
+			continue
+		}
 		key := PortKey{HostID: r.HostID, Protocol: r.Protocol, Port: r.Port}
 		dbMap[key] = r
 	}
 
 	// 3. Process Appearances and Updates
 	seenKeys := make(map[PortKey]bool)
+	var listening []*PortRuntime
+	now := time.Now()
 
 	for key, scanRes := range currentOpenPorts {
 		seenKeys[key] = true
 
 		runtime, exists := dbMap[key]
+		fingerprint := processFingerprint(scanRes.ProcessName, scanRes.Cmdline)
+
+		if !exists {
+			inherited, err := inheritArchivedRuntime(tx, key, scanRes, fingerprint, podsResolved, batch)
+			if err != nil {
+				return nil, err
+			}
+			if inherited != nil {
+				// Reinstalled service picked up its old runtime and note
+				listening = append(listening, inherited)
+				continue
+			}
+		}
 
 		if !exists {
 			// NEW PORT APPEARED
-			newRuntime := PortRuntime{
+			newRuntime := &PortRuntime{
 				HostID:         key.HostID,
 				Protocol:       key.Protocol,
 				Port:           key.Port,
-				FirstSeenAt:    time.Now(),
-				LastSeenAt:     time.Now(),
+				FirstSeenAt:    now,
+				LastSeenAt:     now,
+				UpSince:        &now,
 				CurrentState:   string(StateActive),
 				CurrentPID:     scanRes.PID,
 				ProcessName:    scanRes.ProcessName,
 				Cmdline:        scanRes.Cmdline,
+				Username:       scanRes.Username,
+				ExePath:        scanRes.ExePath,
+				SystemdUnit:    scanRes.Unit,
+				SystemdSlice:   scanRes.Slice,
+				ConnCount:      scanRes.Connections,
+				PeakConnCount:  scanRes.Connections,
+				ExeSHA256:      scanRes.ExeSHA256,
+				Fingerprint:    fingerprint,
+				PodName:        scanRes.Pod.Name,
+				PodNamespace:   scanRes.Pod.Namespace,
+				PodContainer:   scanRes.Pod.Container,
 				TotalSeenCount: 1,
 			}
-			DB.Create(&newRuntime)
+			if err := tx.Create(newRuntime).Error; err != nil {
+				return nil, fmt.Errorf("creating runtime for %s/%d: %w", key.Protocol, key.Port, err)
+			}
+			listening = append(listening, newRuntime)
 
 			// Log Event: Appeared
-			DB.Create(&PortEvent{
+			batch.add(newRuntime, PortEvent{
 				PortRuntimeID: newRuntime.ID,
 				EventType:     string(EventAppeared),
-				Timestamp:     time.Now(),
+				Timestamp:     now,
 				PID:           scanRes.PID,
 				ProcessName:   scanRes.ProcessName,
 			})
@@ -94,28 +241,76 @@ func RunCollectionCycle() {
 
 				// Log Event: Process Change
 				log.Printf("Process Change Detected on Port %d: %s -> %s", key.Port, runtime.ProcessName, scanRes.ProcessName)
-				DB.Create(&PortEvent{
+				batch.add(runtime, PortEvent{
 					PortRuntimeID: runtime.ID,
 					EventType:     string(EventProcessChange),
-					Timestamp:     time.Now(),
+					Timestamp:     now,
 					PID:           scanRes.PID,
 					ProcessName:   scanRes.ProcessName,
 				})
 			}
 
+			// Same process name, different binary: replaced or patched on disk
+			if runtime.ProcessName == scanRes.ProcessName &&
+				runtime.ExeSHA256 != "" &&
+				scanRes.ExeSHA256 != "" &&
+				runtime.ExeSHA256 != scanRes.ExeSHA256 {
+
+				log.Printf("Binary Change Detected on Port %d: %s (%s)", key.Port, scanRes.ProcessName, scanRes.ExePath)
+				batch.add(runtime, PortEvent{
+					PortRuntimeID: runtime.ID,
+					EventType:     string(EventBinaryChange),
+					Timestamp:     now,
+					PID:           scanRes.PID,
+					ProcessName:   scanRes.ProcessName,
+					Detail:        fmt.Sprintf("%s sha256 %s -> %s", scanRes.ExePath, shortHash(runtime.ExeSHA256), shortHash(scanRes.ExeSHA256)),
+				})
+			}
+
+			wasGone := runtime.CurrentState == string(StateDisappeared)
+			accrueUptime(runtime, wasGone, now)
+
 			// Update Runtime
-			runtime.LastSeenAt = time.Now()
+			runtime.LastSeenAt = now
 			runtime.CurrentState = string(StateActive)
 			runtime.CurrentPID = scanRes.PID
 			runtime.ProcessName = scanRes.ProcessName
 			runtime.Cmdline = scanRes.Cmdline
+			runtime.Username = scanRes.Username
+			runtime.ExePath = scanRes.ExePath
+			runtime.SystemdUnit = scanRes.Unit
+			runtime.SystemdSlice = scanRes.Slice
+			runtime.ConnCount = scanRes.Connections
+			runtime.PeakConnCount = max(runtime.PeakConnCount, scanRes.Connections)
+			if scanRes.ExeSHA256 != "" {
+				runtime.ExeSHA256 = scanRes.ExeSHA256
+			}
+			runtime.Fingerprint = fingerprint
+			if podsResolved {
+				runtime.PodName = scanRes.Pod.Name
+				runtime.PodNamespace = scanRes.Pod.Namespace
+				runtime.PodContainer = scanRes.Pod.Container
+			}
 			runtime.TotalSeenCount++
+			if wasGone {
+				recordFlap(runtime, now)
+			}
 
-			// Calculate Uptime (approx)
-			uptime := runtime.LastSeenAt.Sub(runtime.FirstSeenAt).Seconds()
-			runtime.TotalUptimeSeconds = int(uptime)
+			if err := tx.Save(runtime).Error; err != nil {
+				return nil, fmt.Errorf("updating runtime #%d: %w", runtime.ID, err)
+			}
+			listening = append(listening, runtime)
 
-			DB.Save(runtime)
+			// Log Event: Reappeared (marks the end of an outage)
+			if wasGone {
+				batch.add(runtime, PortEvent{
+					PortRuntimeID: runtime.ID,
+					EventType:     string(EventReappeared),
+					Timestamp:     now,
+					PID:           scanRes.PID,
+					ProcessName:   scanRes.ProcessName,
+				})
+			}
 		}
 	}
 
@@ -125,15 +320,18 @@ func RunCollectionCycle() {
 			// It was in DB, but not in current scan -> Disappeared
 			if runtime.CurrentState == string(StateActive) {
 				runtime.CurrentState = string(StateDisappeared)
-				now := time.Now()
 				runtime.LastDisappearedAt = &now
-				DB.Save(runtime)
+				runtime.ConnCount = 0
+				recordFlap(runtime, now)
+				if err := tx.Save(runtime).Error; err != nil {
+					return nil, fmt.Errorf("updating runtime #%d: %w", runtime.ID, err)
+				}
 
 				// Log Event: Disappeared
-				DB.Create(&PortEvent{
+				batch.add(runtime, PortEvent{
 					PortRuntimeID: runtime.ID,
 					EventType:     string(EventDisappeared),
-					Timestamp:     time.Now(),
+					Timestamp:     now,
 					PID:           runtime.CurrentPID,
 					ProcessName:   runtime.ProcessName,
 				})
@@ -141,60 +339,200 @@ func RunCollectionCycle() {
 		}
 	}
 
-	log.Println("Cycle complete.")
+	return listening, nil
 }
 
-func scanPorts() (map[PortKey]ScanResult, error) {
-	results := make(map[PortKey]ScanResult)
+// eventBatch collects a cycle's events for a single batched insert. Each
+// event keeps a snapshot of its runtime as it was when the event happened,
+// for the notifier.
+type eventBatch struct {
+	events   []PortEvent
+	runtimes []PortRuntime
+}
 
-	// Get Connections (inet, all protocols)
-	conns, err := net.Connections("inet")
-	if err != nil {
-		return nil, err
+func (b *eventBatch) add(runtime *PortRuntime, evt PortEvent) {
+	b.events = append(b.events, evt)
+	b.runtimes = append(b.runtimes, *runtime)
+}
+
+func (b *eventBatch) flush(tx *gorm.DB) error {
+	if len(b.events) == 0 {
+		return nil
+	}
+	tagDeploymentEvents(tx, b)
+	tagMaintenanceEvents(b)
+	return tx.CreateInBatches(b.events, 200).Error
+}
+
+// notify publishes the stored events on the bus (bus.go). Call only after commit.
+func (b *eventBatch) notify() {
+	for i, evt := range b.events {
+		publishPortEvent(evt, b.runtimes[i])
 	}
+}
 
-	for _, c := range conns {
-		// Filter only LISTEN for TCP, and maybe establish for others if needed, usually monitor LISTEN
-		isListen := c.Status == "LISTEN"
-		// For UDP, status is usually blank/NONE, treat as open
-		isUDP := c.Type == 2 // SOCK_DGRAM
+// collectInterval is the normal cadence of scheduled cycles.
+const collectInterval = time.Minute
 
-		if !isListen && !isUDP {
-			continue
-		}
+// runCollector runs a cycle now and then every minute until ctx is done,
+// plus whenever the listener watch (watch.go) sees a change, at most once
+// per watchDebounce.
+// Cycles are skipped while the collector is paused.
+func runCollector(ctx context.Context) {
+	if !collectorState.isPaused() && powerSaveDue(time.Now()) {
+		RunCollectionCycle()
+	}
 
-		pid := int(c.Pid)
-		if pid == 0 {
-			continue // System idle or permission denied
+	ticker := time.NewTicker(collectInterval)
+	defer ticker.Stop()
+	collectorState.scheduleNext(time.Now().Add(collectInterval))
+	var lastTriggered time.Time
+	var deferred <-chan time.Time // a trigger waiting out the debounce
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-watchTrigger:
+			if wait := watchDebounce - time.Since(lastTriggered); wait > 0 {
+				if deferred == nil {
+					deferred = time.After(wait)
+				}
+				continue
+			}
+			if !collectorState.isPaused() {
+				lastTriggered = time.Now()
+				RunCollectionCycle()
+			}
+		case <-deferred:
+			deferred = nil
+			if !collectorState.isPaused() {
+				lastTriggered = time.Now()
+				RunCollectionCycle()
+			}
+		case now := <-ticker.C:
+			collectorState.scheduleNext(now.Add(collectInterval))
+			if collectorState.isPaused() || !powerSaveDue(now) {
+				continue
+			}
+			if collectJitter > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(rand.N(collectJitter)):
+				}
+			}
+			RunCollectionCycle()
 		}
+	}
+}
 
-		procName := ""
-		cmdLine := ""
+// waitForCycles blocks until running cycles finish or the timeout passes.
+func waitForCycles(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		cycles.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
 
-		// Get Process Info
-		if p, err := process.NewProcess(int32(pid)); err == nil {
-			procName, _ = p.Name()
-			cmdLine, _ = p.Cmdline()
-		}
+// recordEvent stores a timeline event and publishes it.
+func recordEvent(runtime *PortRuntime, evt PortEvent) {
+	if err := DB.Create(&evt).Error; err != nil {
+		log.Println("Error recording event:", err)
+		return
+	}
+	publishPortEvent(evt, *runtime)
+}
 
-		protocol := "tcp"
-		if isUDP {
-			protocol = "udp"
-		}
+// processFingerprint identifies "the same service" across PIDs and reinstalls:
+// process name plus the executable basename from the cmdline.
+func processFingerprint(name, cmdline string) string {
+	if name == "" {
+		return ""
+	}
+	exe := ""
+	if fields := strings.Fields(cmdline); len(fields) > 0 {
+		exe = filepath.Base(fields[0])
+	}
+	return name + "|" + exe
+}
 
-		key := PortKey{
-			HostID:   HostID,
-			Protocol: protocol,
-			Port:     int(c.Laddr.Port),
-		}
+// inheritArchivedRuntime looks for an archived (deleted) runtime with the same
+// key and fingerprint and brings it back, together with its note and history.
+// Only runtimes that had disappeared before they were archived qualify: one
+// archived while still listening shows up in the next scan, and bringing it
+// back would undo the delete. It returns nil if there is nothing to inherit.
+func inheritArchivedRuntime(tx *gorm.DB, key PortKey, scanRes ScanResult, fingerprint string, podsResolved bool, batch *eventBatch) (*PortRuntime, error) {
+	if fingerprint == "" {
+		return nil, nil
+	}
+
+	var archived PortRuntime
+	err := tx.Unscoped().
+		Where("host_id = ? AND protocol = ? AND port = ? AND fingerprint = ? AND deleted_at IS NOT NULL AND current_state <> ?",
+			key.HostID, key.Protocol, key.Port, fingerprint, StateActive).
+		Order("deleted_at desc").
+		First(&archived).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("looking up archived runtime: %w", err)
+	}
 
-		results[key] = ScanResult{
-			PID:         pid,
-			ProcessName: procName,
-			Cmdline:     cmdLine,
-			State:       c.Status,
+	now := time.Now()
+	archived.DeletedAt = gorm.DeletedAt{}
+	accrueUptime(&archived, true, now)
+	archived.LastSeenAt = now
+	archived.CurrentState = string(StateActive)
+	archived.CurrentPID = scanRes.PID
+	archived.ProcessName = scanRes.ProcessName
+	archived.Cmdline = scanRes.Cmdline
+	archived.Username = scanRes.Username
+	archived.ExePath = scanRes.ExePath
+	archived.SystemdUnit = scanRes.Unit
+	archived.SystemdSlice = scanRes.Slice
+	archived.ConnCount = scanRes.Connections
+	archived.PeakConnCount = max(archived.PeakConnCount, scanRes.Connections)
+	archived.ExeSHA256 = scanRes.ExeSHA256
+	if podsResolved {
+		archived.PodName = scanRes.Pod.Name
+		archived.PodNamespace = scanRes.Pod.Namespace
+		archived.PodContainer = scanRes.Pod.Container
+	}
+	archived.TotalSeenCount++
+	if err := tx.Unscoped().Save(&archived).Error; err != nil {
+		return nil, fmt.Errorf("restoring archived runtime #%d: %w", archived.ID, err)
+	}
+
+	// Re-link the note unless the user already wrote a fresh one
+	var liveNotes int64
+	tx.Model(&PortNote{}).Where("host_id = ? AND protocol = ? AND port = ?", key.HostID, key.Protocol, key.Port).Count(&liveNotes)
+	if liveNotes == 0 {
+		var note PortNote
+		if err := tx.Unscoped().
+			Where("host_id = ? AND protocol = ? AND port = ? AND deleted_at IS NOT NULL", key.HostID, key.Protocol, key.Port).
+			Order("deleted_at desc").
+			First(&note).Error; err == nil {
+			if err := tx.Unscoped().Model(&note).Update("deleted_at", nil).Error; err != nil {
+				return nil, fmt.Errorf("restoring note #%d: %w", note.ID, err)
+			}
 		}
 	}
 
-	return results, nil
+	log.Printf("Inherited archived runtime #%d for %s/%d (%s)", archived.ID, key.Protocol, key.Port, fingerprint)
+	batch.add(&archived, PortEvent{
+		PortRuntimeID: archived.ID,
+		EventType:     string(EventInherited),
+		Timestamp:     now,
+		PID:           scanRes.PID,
+		ProcessName:   scanRes.ProcessName,
+	})
+	return &archived, nil
 }
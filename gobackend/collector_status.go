@@ -0,0 +1,135 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CollectorStatus tells operators whether the port data is fresh.
+type CollectorStatus struct {
+	Paused         bool       `json:"paused"`
+	Running        bool       `json:"running"`
+	LastRunAt      *time.Time `json:"last_run_at"`
+	LastDurationMs int64      `json:"last_duration_ms"`
+	PortsSeen      int        `json:"ports_seen"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastErrorAt    *time.Time `json:"last_error_at,omitempty"`
+	NextRunAt      *time.Time `json:"next_run_at"`
+	Cycles         int        `json:"cycles"`
+	Failures       int        `json:"failures"`
+	Skipped        int        `json:"skipped"`              // cycles not started because one was still running
+	PowerSave      string     `json:"power_save,omitempty"` // battery, idle or forced (power.go)
+}
+
+type collectorTracker struct {
+	mu sync.Mutex
+	s  CollectorStatus
+}
+
+var collectorState = &collectorTracker{}
+
+func (t *collectorTracker) begin() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.s.Running = true
+}
+
+func (t *collectorTracker) finish(started time.Time, seen int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.s.Running = false
+	t.s.LastRunAt = &started
+	t.s.LastDurationMs = time.Since(started).Milliseconds()
+	t.s.Cycles++
+	if err != nil {
+		now := time.Now()
+		t.s.LastError = err.Error()
+		t.s.LastErrorAt = &now
+		t.s.Failures++
+		return
+	}
+	t.s.PortsSeen = seen
+	t.s.LastError = ""
+}
+
+func (t *collectorTracker) skip() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.s.Skipped++
+}
+
+func (t *collectorTracker) scheduleNext(at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.s.NextRunAt = &at
+}
+
+func (t *collectorTracker) setPowerSave(reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if reason != t.s.PowerSave {
+		if reason == "" {
+			log.Println("🔌 Power save off, back to the normal scan interval")
+		} else {
+			log.Printf("🔋 Power save (%s): scanning every %s", reason, powerSaveInterval)
+		}
+	}
+	t.s.PowerSave = reason
+}
+
+func (t *collectorTracker) isPaused() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.s.Paused
+}
+
+// setPaused is remembered across restarts via app_setting.
+func (t *collectorTracker) setPaused(paused bool) {
+	t.mu.Lock()
+	t.s.Paused = paused
+	t.mu.Unlock()
+
+	value := "false"
+	if paused {
+		value = "true"
+	}
+	DB.Save(&AppSetting{Key: "collector_paused", Value: value})
+}
+
+func (t *collectorTracker) snapshot() CollectorStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.s
+	if s.Paused {
+		s.NextRunAt = nil
+	}
+	return s
+}
+
+func loadCollectorState() {
+	var s AppSetting
+	if DB.First(&s, "key = ?", "collector_paused").Error == nil && s.Value == "true" {
+		collectorState.s.Paused = true
+	}
+}
+
+// GET /collector/status
+func getCollectorStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, collectorState.snapshot())
+}
+
+// POST /collector/pause stops scheduled cycles; manual /trigger-scan still works.
+func pauseCollector(c *gin.Context) {
+	collectorState.setPaused(true)
+	c.JSON(http.StatusOK, collectorState.snapshot())
+}
+
+// POST /collector/resume
+func resumeCollector(c *gin.Context) {
+	collectorState.setPaused(false)
+	c.JSON(http.StatusOK, collectorState.snapshot())
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApplyScanKeepsPodsWhenUnresolved(t *testing.T) {
+	openTestDB(t)
+	now := time.Now()
+	rt := PortRuntime{HostID: HostID, Protocol: "tcp", Port: 8080, CurrentState: string(StateActive),
+		FirstSeenAt: now, LastSeenAt: now, ProcessName: "app",
+		PodName: "web-1", PodNamespace: "shop", PodContainer: "web"}
+	if err := DB.Create(&rt).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	scan := func(podsResolved bool) PortRuntime {
+		t.Helper()
+		open := map[PortKey]ScanResult{tcpKey(8080): {PID: fixturePID, ProcessName: "app", State: "LISTEN"}}
+		if _, err := applyScan(DB, open, map[string]bool{HostID: true}, nil, podsResolved, &eventBatch{}); err != nil {
+			t.Fatal(err)
+		}
+		var got PortRuntime
+		DB.First(&got, rt.ID)
+		return got
+	}
+
+	// Kubelet unreachable: the pod we knew stays
+	if got := scan(false); got.PodName != "web-1" || got.PodNamespace != "shop" || got.PodContainer != "web" {
+		t.Errorf("pod lost on failed lookup: %q/%q/%q", got.PodNamespace, got.PodName, got.PodContainer)
+	}
+	// Kubelet answered and the process is no longer in a pod
+	if got := scan(true); got.PodName != "" || got.PodNamespace != "" || got.PodContainer != "" {
+		t.Errorf("pod kept after lookup: %q/%q/%q", got.PodNamespace, got.PodName, got.PodContainer)
+	}
+}
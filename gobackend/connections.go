@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// countEstablished fills in Connections and Peers for local TCP listeners:
+// the ESTABLISHED connections whose local end is the listening port, and
+// their remote addresses. It runs independently of the scanner backend.
+func countEstablished(results map[PortKey]ScanResult) {
+	conns, err := net.Connections("tcp")
+	if err != nil {
+		log.Println("Error counting connections:", err)
+		return
+	}
+	counts := make(map[int]int)
+	peers := make(map[int]map[string]int)
+	for _, c := range conns {
+		if c.Status != "ESTABLISHED" {
+			continue
+		}
+		port := int(c.Laddr.Port)
+		counts[port]++
+		if peers[port] == nil {
+			peers[port] = make(map[string]int)
+		}
+		peers[port][c.Raddr.IP]++
+	}
+	for key, res := range results {
+		if key.HostID == HostID && key.Protocol == "tcp" {
+			res.Connections = counts[key.Port]
+			res.Peers = peers[key.Port]
+			results[key] = res
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSpec is a standard 5-field cron expression (minute hour day-of-month
+// month day-of-week) with *, lists, ranges and steps, e.g. "0 3 * * 0" or
+// "*/15 9-17 * * 1-5". As in cron, a restricted day-of-month and
+// day-of-week match if either does.
+type cronSpec struct {
+	minute, hour, dom, month, dow [64]bool
+	domAny, dowAny                bool
+}
+
+func parseCron(expr string) (*cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q: want 5 fields, got %d", expr, len(fields))
+	}
+	c := &cronSpec{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	specs := []struct {
+		set    *[64]bool
+		lo, hi int
+	}{
+		{&c.minute, 0, 59}, {&c.hour, 0, 23}, {&c.dom, 1, 31}, {&c.month, 1, 12}, {&c.dow, 0, 7},
+	}
+	for i, s := range specs {
+		if err := parseCronField(fields[i], s.lo, s.hi, s.set); err != nil {
+			return nil, fmt.Errorf("cron %q: %w", expr, err)
+		}
+	}
+	c.dow[0] = c.dow[0] || c.dow[7] // 7 is Sunday too
+	return c, nil
+}
+
+func parseCronField(field string, lo, hi int, set *[64]bool) error {
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid step %q", part)
+			}
+			step = n
+		}
+		from, to := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if from, err = strconv.Atoi(a); err != nil {
+				return fmt.Errorf("invalid value %q", part)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(b); err != nil {
+					return fmt.Errorf("invalid range %q", part)
+				}
+			} else if hasStep {
+				to = hi
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return fmt.Errorf("%q out of range %d-%d", part, lo, hi)
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+	return nil
+}
+
+// matches reports whether the minute of t is a scheduled start.
+func (c *cronSpec) matches(t time.Time) bool {
+	if !c.minute[t.Minute()] || !c.hour[t.Hour()] || !c.month[int(t.Month())] {
+		return false
+	}
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// lastStart returns the latest scheduled start in (t-within, t], if any.
+func (c *cronSpec) lastStart(t time.Time, within time.Duration) (time.Time, bool) {
+	t = t.Truncate(time.Minute)
+	for m := t; t.Sub(m) < within; m = m.Add(-time.Minute) {
+		if c.matches(m) {
+			return m, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// starts lists the scheduled starts in [from, to).
+func (c *cronSpec) starts(from, to time.Time, limit int) []time.Time {
+	var out []time.Time
+	for m := from.Truncate(time.Minute); m.Before(to) && len(out) < limit; m = m.Add(time.Minute) {
+		if !m.Before(from) && c.matches(m) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
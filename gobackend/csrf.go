@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CSRF protection uses a signed double-submit cookie. Each browser gets a
+// random token signed with a secret kept in the database; it is injected
+// into index.html and set as a cookie, and write requests must echo it in
+// X-CSRF-Token. Later page loads reuse the cookie's token while it verifies,
+// so every open tab holds the same one. Because the secret survives restarts, open tabs keep
+// working after an upgrade. Rotating the secret keeps the previous one valid
+// so tabs opened before the rotation don't break.
+const csrfCookie = "portmonote_csrf"
+
+// AppSetting is a small key/value store for server-generated state.
+type AppSetting struct {
+	Key   string `gorm:"primaryKey;size:64"`
+	Value string
+}
+
+func (AppSetting) TableName() string {
+	return "app_setting"
+}
+
+var (
+	csrfMu      sync.RWMutex
+	csrfSecrets [][]byte // current first, then the previous one
+)
+
+func loadCSRFSecrets() {
+	current := getOrCreateSetting("csrf_secret", func() string { return randomHex(32) })
+	secrets := [][]byte{[]byte(current)}
+	var prev AppSetting
+	if err := DB.First(&prev, "key = ?", "csrf_secret_prev").Error; err == nil && prev.Value != "" {
+		secrets = append(secrets, []byte(prev.Value))
+	}
+	csrfMu.Lock()
+	csrfSecrets = secrets
+	csrfMu.Unlock()
+}
+
+func getOrCreateSetting(key string, gen func() string) string {
+	var s AppSetting
+	err := DB.First(&s, "key = ?", key).Error
+	if err == nil {
+		return s.Value
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("⚠️ Could not read setting %s: %v", key, err)
+	}
+	s = AppSetting{Key: key, Value: gen()}
+	DB.Create(&s)
+	return s.Value
+}
+
+// rotateCSRFSecret makes a new secret current; the old one stays valid.
+func rotateCSRFSecret() error {
+	var current AppSetting
+	if err := DB.First(&current, "key = ?", "csrf_secret").Error; err != nil {
+		return err
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&AppSetting{Key: "csrf_secret_prev", Value: current.Value}).Error; err != nil {
+			return err
+		}
+		return tx.Save(&AppSetting{Key: "csrf_secret", Value: randomHex(32)}).Error
+	})
+	if err != nil {
+		return err
+	}
+	loadCSRFSecrets()
+	return nil
+}
+
+func signCSRF(secret []byte, nonce string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// issueCSRFToken returns the token of the browser's cookie if it still
+// verifies, so tabs opened earlier keep working, and otherwise sets a fresh
+// one.
+func issueCSRFToken(c *gin.Context) string {
+	if cookie, err := c.Cookie(csrfCookie); err == nil && signedCSRF(cookie) {
+		return cookie
+	}
+
+	csrfMu.RLock()
+	secret := csrfSecrets[0]
+	csrfMu.RUnlock()
+
+	nonce := randomHex(16)
+	token := nonce + "." + signCSRF(secret, nonce)
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(csrfCookie, token, 0, "/", "", c.Request.TLS != nil, true)
+	return token
+}
+
+// validCSRF checks that the header matches the cookie and carries a valid
+// signature.
+func validCSRF(c *gin.Context) bool {
+	token := c.GetHeader("X-CSRF-Token")
+	cookie, err := c.Cookie(csrfCookie)
+	if err != nil || token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(cookie)) != 1 {
+		return false
+	}
+	return signedCSRF(token)
+}
+
+// signedCSRF reports whether a token is signed by the current or previous
+// secret.
+func signedCSRF(token string) bool {
+	nonce, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+
+	csrfMu.RLock()
+	defer csrfMu.RUnlock()
+	for _, secret := range csrfSecrets {
+		if hmac.Equal([]byte(sig), []byte(signCSRF(secret, nonce))) {
+			return true
+		}
+	}
+	return false
+}
+
+// POST /admin/csrf/rotate
+func handleRotateCSRF(c *gin.Context) {
+	if err := rotateCSRFSecret(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "rotated"})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCSRFTokenSurvivesSecondTab(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := csrfSecrets
+	csrfSecrets = [][]byte{[]byte("test-secret")}
+	t.Cleanup(func() { csrfSecrets = prev })
+
+	// loadIndex is GET / with the browser's cookies; it returns the
+	// injected token and the cookies the browser holds afterwards.
+	jar := map[string]*http.Cookie{}
+	loadIndex := func() string {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, ck := range jar {
+			c.Request.AddCookie(ck)
+		}
+		token := issueCSRFToken(c)
+		for _, ck := range w.Result().Cookies() {
+			jar[ck.Name] = ck
+		}
+		return token
+	}
+	valid := func(token string) bool {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/notes", nil)
+		c.Request.Header.Set("X-CSRF-Token", token)
+		for _, ck := range jar {
+			c.Request.AddCookie(ck)
+		}
+		return validCSRF(c)
+	}
+
+	first := loadIndex()
+	if !valid(first) {
+		t.Fatal("fresh token rejected")
+	}
+	second := loadIndex()
+	if !valid(first) {
+		t.Error("first tab's token rejected after a second tab loaded")
+	}
+	if second != first {
+		t.Errorf("second load issued %q, want the cookie's %q", second, first)
+	}
+
+	// A cookie that doesn't verify is replaced
+	jar[csrfCookie] = &http.Cookie{Name: csrfCookie, Value: "forged.deadbeef"}
+	if fresh := loadIndex(); fresh == "forged.deadbeef" || !valid(fresh) {
+		t.Errorf("forged cookie not replaced (got %q)", fresh)
+	}
+	if valid("forged.deadbeef") {
+		t.Error("forged token accepted")
+	}
+}
@@ -4,7 +4,12 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -12,7 +17,135 @@ import (
 
 var DB *gorm.DB
 
+// Database selection: DB_DRIVER=sqlite (default, ./data/portmonote.db),
+// DB_DRIVER=postgres with DB_DSN="host=... dbname=portmonote sslmode=disable",
+// or DB_DRIVER=mysql with DB_DSN="user:pass@tcp(host:3306)/portmonote?parseTime=true".
+var (
+	dbDriver = envString("DB_DRIVER", "sqlite")
+	dbDSN    = envString("DB_DSN", "")
+)
+
+// DBOptions tunes the connection. The collector and HTTP handlers write
+// concurrently, so SQLite runs in WAL mode with a busy timeout by default
+// instead of failing with "database is locked".
+type DBOptions struct {
+	SQLiteWAL    bool
+	BusyTimeout  time.Duration
+	MaxOpenConns int
+	MaxIdleConns int
+}
+
+var dbOptions = DBOptions{
+	SQLiteWAL:    envBool("DB_SQLITE_WAL", true),
+	BusyTimeout:  envDuration("DB_BUSY_TIMEOUT", 5*time.Second),
+	MaxOpenConns: envInt("DB_MAX_OPEN_CONNS", 4),
+	MaxIdleConns: envInt("DB_MAX_IDLE_CONNS", 2),
+}
+
 func InitDB(ignoredDSN string) {
+	dialector := openDialector()
+
+	var err error
+	DB, err = gorm.Open(dialector, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Error),
+	})
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		log.Fatal("Failed to access connection pool:", err)
+	}
+	if dbOptions.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(dbOptions.MaxOpenConns)
+	}
+	if dbOptions.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(dbOptions.MaxIdleConns)
+	}
+
+	// Auto Migrate
+	err = DB.AutoMigrate(&PortRuntime{}, &PortEvent{}, &PortNote{}, &ApiKey{}, &PendingNotification{}, &User{}, &Session{}, &Escalation{}, &AppSetting{}, &ProcessSample{}, &DeviceConfig{}, &RiskApproval{}, &PortPeer{}, &Job{}, &ScanSnapshot{}, &ShareLink{}, &Incident{}, &Deployment{}, &Baseline{}, &BaselinePort{}, &MaintenanceWindow{}, &Acknowledgement{}, &PortMute{}, &Tag{}, &Application{}, &ApplicationPort{}, &Host{}, &NoteRevision{}, &AuditLog{}, &NoteTemplate{}, &SavedView{}, &PortTicket{})
+	if err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+	backfillTags()
+	backfillHosts()
+	seedNoteTemplates()
+	initSearchIndex()
+}
+
+// CloseDB flushes the SQLite WAL into the main file and closes the pool. In
+// memory mode it writes the final checkpoint first (dbmemory.go).
+func CloseDB() {
+	if DB == nil {
+		return
+	}
+	if DB.Dialector.Name() == "sqlite" {
+		if err := DB.Exec("PRAGMA wal_checkpoint(TRUNCATE)").Error; err != nil {
+			log.Println("WAL checkpoint failed:", err)
+		}
+		closeMemoryDB()
+	}
+	if sqlDB, err := DB.DB(); err == nil {
+		sqlDB.Close()
+	}
+	removeMemoryCopy()
+}
+
+func openDialector() gorm.Dialector {
+	switch dbDriver {
+	case "postgres", "postgresql":
+		if dbDSN == "" {
+			log.Fatal("❌ DB_DRIVER=postgres requires DB_DSN")
+		}
+		log.Printf("🐘 Using PostgreSQL database")
+		return postgres.Open(dbDSN)
+	case "mysql":
+		if dbDSN == "" {
+			log.Fatal("❌ DB_DRIVER=mysql requires DB_DSN")
+		}
+		log.Printf("🐬 Using MySQL database")
+		// Indexed string columns need a bounded VARCHAR on MySQL
+		return mysql.New(mysql.Config{DSN: dbDSN, DefaultStringSize: 191})
+	case "sqlite", "":
+		dsn := sqliteDSN()
+		if dbMemory {
+			dsn = memoryWorkingCopy(dsn)
+		}
+		return sqlite.Open(withSQLitePragmas(dsn))
+	default:
+		log.Fatalf("❌ Unsupported DB_DRIVER %q (expected sqlite, postgres or mysql)", dbDriver)
+		return nil
+	}
+}
+
+// withSQLitePragmas appends go-sqlite3 connection parameters so every pooled
+// connection gets the same journal mode and busy timeout.
+func withSQLitePragmas(dsn string) string {
+	params := []string{}
+	if dbOptions.SQLiteWAL && !strings.Contains(dsn, "_journal_mode=") {
+		params = append(params, "_journal_mode=WAL")
+	}
+	if dbOptions.BusyTimeout > 0 && !strings.Contains(dsn, "_busy_timeout=") {
+		params = append(params, "_busy_timeout="+strconv.FormatInt(dbOptions.BusyTimeout.Milliseconds(), 10))
+	}
+	if len(params) == 0 {
+		return dsn
+	}
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + strings.Join(params, "&")
+}
+
+func sqliteDSN() string {
+	if dbDSN != "" {
+		return dbDSN
+	}
+
 	// Strict Path Logic:
 	// Always look in ./data/portmonote.db for the database.
 	// We create the directory if it doesn't exist.
@@ -35,18 +168,5 @@ func InitDB(ignoredDSN string) {
 	} else {
 		log.Printf("✅ Found database file: %s", finalDSN)
 	}
-
-	var err error
-	DB, err = gorm.Open(sqlite.Open(finalDSN), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Error),
-	})
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
-	}
-
-	// Auto Migrate
-	err = DB.AutoMigrate(&PortRuntime{}, &PortEvent{}, &PortNote{})
-	if err != nil {
-		log.Fatal("Failed to migrate database:", err)
-	}
+	return finalDSN
 }
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// openTestDB points DB at a fresh, migrated SQLite file for one test.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	prevDB, prevDSN := DB, dbDSN
+	dbDSN = filepath.Join(t.TempDir(), "portmonote.db")
+	InitDB("")
+	t.Cleanup(func() {
+		CloseDB()
+		DB, dbDSN = prevDB, prevDSN
+	})
+}
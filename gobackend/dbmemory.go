@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Memory mode for SD-card hosts (Raspberry Pi & co): with
+// PORTMONOTE_DB_MEMORY=true the SQLite database runs from a working copy in
+// RAM (PORTMONOTE_DB_MEMORY_DIR, default /dev/shm) and is written back to
+// ./data/portmonote.db only every PORTMONOTE_DB_CHECKPOINT_INTERVAL
+// (default 10m) and on shutdown, instead of on every collection cycle.
+//
+// A checkpoint is a VACUUM INTO a temporary file next to the database,
+// fsynced and renamed over it, so the file on disk is always a complete
+// snapshot: a crash or power loss loses at most the last interval, never the
+// database. Only the server uses it; CLI commands write to disk directly.
+var (
+	dbMemoryRequested    = envBool("PORTMONOTE_DB_MEMORY", false)
+	dbMemoryDir          = envString("PORTMONOTE_DB_MEMORY_DIR", "/dev/shm")
+	dbCheckpointInterval = envDuration("PORTMONOTE_DB_CHECKPOINT_INTERVAL", 10*time.Minute)
+)
+
+var (
+	dbMemory      bool   // set by runServe
+	dbPersistPath string // on-disk database, set when memory mode is active
+	dbWorkingPath string // RAM copy the pool is connected to
+	checkpointMu  sync.Mutex
+)
+
+// memoryWorkingCopy copies the on-disk database into RAM and returns the
+// path to open instead. Falls back to the disk file if that isn't possible.
+func memoryWorkingCopy(persist string) string {
+	if strings.Contains(persist, "?") || strings.HasPrefix(persist, "file:") || strings.Contains(persist, ":memory:") {
+		log.Printf("⚠️ PORTMONOTE_DB_MEMORY needs a plain database path, got %q; using it directly", persist)
+		return persist
+	}
+	dir := dbMemoryDir
+	if st, err := os.Stat(dir); err != nil || !st.IsDir() {
+		dir = os.TempDir()
+	}
+
+	abs, _ := filepath.Abs(persist)
+	sum := sha256.Sum256([]byte(abs))
+	working := filepath.Join(dir, "portmonote-"+hex.EncodeToString(sum[:6])+".db")
+
+	// Leftovers of a crashed run or an interrupted checkpoint
+	removeSQLiteFiles(working)
+	os.Remove(persist + ".checkpoint")
+
+	if _, err := os.Stat(persist); err == nil {
+		if err := foldWAL(persist); err != nil {
+			log.Printf("⚠️ Cannot fold the WAL of %s: %v; using it directly", persist, err)
+			return persist
+		}
+		if err := copyFile(persist, working); err != nil {
+			log.Printf("⚠️ Cannot copy database into memory: %v; using %s directly", err, persist)
+			return persist
+		}
+	}
+	dbPersistPath, dbWorkingPath = persist, working
+	log.Printf("🧠 Database runs in memory (%s), checkpointing to %s every %s", working, persist, dbCheckpointInterval)
+	return working
+}
+
+// foldWAL merges a write-ahead log left by a run without memory mode into the
+// main file, so copying that file alone doesn't lose the last writes.
+func foldWAL(path string) error {
+	if st, err := os.Stat(path + "-wal"); err != nil || st.Size() == 0 {
+		return nil
+	}
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Error)})
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+	return db.Exec("PRAGMA wal_checkpoint(TRUNCATE)").Error
+}
+
+// checkpointDB writes the in-memory database back to disk.
+func checkpointDB() error {
+	if dbPersistPath == "" {
+		return nil
+	}
+	checkpointMu.Lock()
+	defer checkpointMu.Unlock()
+
+	start := time.Now()
+	tmp := dbPersistPath + ".checkpoint"
+	os.Remove(tmp) // VACUUM INTO refuses to overwrite
+	if err := DB.Exec("VACUUM INTO ?", tmp).Error; err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("vacuum into %s: %w", tmp, err)
+	}
+	if err := syncFile(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dbPersistPath); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// Stale journal files would be replayed onto the new snapshot
+	os.Remove(dbPersistPath + "-wal")
+	os.Remove(dbPersistPath + "-shm")
+	syncFile(filepath.Dir(dbPersistPath))
+	log.Printf("💾 Checkpointed database to %s in %s", dbPersistPath, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// closeMemoryDB runs the final checkpoint; the pool must still be open.
+func closeMemoryDB() {
+	if dbPersistPath == "" {
+		return
+	}
+	if err := checkpointDB(); err != nil {
+		log.Printf("❌ Final database checkpoint failed, keeping %s: %v", dbWorkingPath, err)
+		dbWorkingPath = ""
+	}
+}
+
+// removeMemoryCopy deletes the RAM copy after the pool is closed.
+func removeMemoryCopy() {
+	if dbWorkingPath != "" {
+		removeSQLiteFiles(dbWorkingPath)
+	}
+}
+
+func init() {
+	registerJob("db_checkpoint", JobKind{Run: func(ctx context.Context, _ string) (string, error) {
+		if err := checkpointDB(); err != nil {
+			return "", err
+		}
+		return "checkpointed to " + dbPersistPath, nil
+	}, MaxAttempts: 1})
+}
+
+// startDBCheckpoints schedules the periodic checkpoint in memory mode.
+func startDBCheckpoints(ctx context.Context) {
+	if dbPersistPath == "" || dbCheckpointInterval <= 0 {
+		return
+	}
+	scheduleJob(ctx, "db_checkpoint", dbCheckpointInterval)
+}
+
+func removeSQLiteFiles(path string) {
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		os.Remove(path + suffix)
+	}
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Deployments mark a time window on some hosts (empty = all), typically
+// posted by CI before and after a deploy. Port changes inside the window
+// are tagged with the deployment ID as they happen, and
+// GET /deployments/:id returns the changeset of the window.
+//
+//	POST /deployments {"name": "api v2.3", "ref": "a1b2c3", "host_ids": ["build-03"]}
+//	POST /deployments/7/finish
+//
+// A deployment left open counts as finished after PORTMONOTE_DEPLOY_MAX_OPEN
+// (default 2h), so a crashed pipeline doesn't tag changes forever.
+var deployMaxOpen = envDuration("PORTMONOTE_DEPLOY_MAX_OPEN", 2*time.Hour)
+
+type Deployment struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	Name      string     `json:"name"`
+	Ref       string     `json:"ref,omitempty"` // commit, tag or version
+	URL       string     `json:"url,omitempty"` // CI run
+	HostIDs   string     `json:"-"`             // comma-separated, empty = all hosts
+	StartedAt time.Time  `gorm:"index" json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	Hosts []string `gorm:"-" json:"host_ids"`
+}
+
+func (Deployment) TableName() string {
+	return "deployment"
+}
+
+func (d *Deployment) AfterFind(*gorm.DB) error {
+	d.Hosts = splitList(d.HostIDs)
+	return nil
+}
+
+// end is when the window closes, now for a running deployment.
+func (d *Deployment) end(now time.Time) time.Time {
+	if d.EndedAt != nil {
+		return *d.EndedAt
+	}
+	if limit := d.StartedAt.Add(deployMaxOpen); now.After(limit) {
+		return limit
+	}
+	return now
+}
+
+func (d *Deployment) covers(hostID string, at time.Time) bool {
+	if at.Before(d.StartedAt) || at.After(d.end(at)) {
+		return false
+	}
+	return len(d.Hosts) == 0 || slices.Contains(d.Hosts, hostID)
+}
+
+// Events that count as a port change for deployments.
+var deployEventTypes = append([]string{
+	string(EventDisappeared), string(EventProcessChange), string(EventBinaryChange),
+}, upEvents...)
+
+// tagDeploymentEvents sets DeploymentID on the batch's port changes that
+// fall into a running deployment. Called before the batch is stored.
+func tagDeploymentEvents(tx *gorm.DB, b *eventBatch) {
+	if len(b.events) == 0 {
+		return
+	}
+	now := time.Now()
+	var running []Deployment
+	tx.Where("started_at <= ? AND started_at >= ? AND (ended_at IS NULL OR ended_at >= ?)", now, now.Add(-deployMaxOpen), now).
+		Order("started_at desc").Find(&running)
+	if len(running) == 0 {
+		return
+	}
+	for i := range b.events {
+		evt := &b.events[i]
+		if !slices.Contains(deployEventTypes, evt.EventType) {
+			continue
+		}
+		for _, d := range running {
+			if d.covers(b.runtimes[i].HostID, evt.Timestamp) {
+				evt.DeploymentID = &d.ID
+				break
+			}
+		}
+	}
+}
+
+// tagDeploymentWindow tags the untagged port changes of the whole window,
+// for deployments posted after the fact or events stored outside a cycle.
+func tagDeploymentWindow(tx *gorm.DB, d *Deployment, now time.Time) error {
+	q := tx.Model(&PortEvent{}).
+		Where("deployment_id IS NULL AND event_type IN ? AND timestamp >= ? AND timestamp <= ?", deployEventTypes, d.StartedAt, d.end(now))
+	if len(d.Hosts) > 0 {
+		q = q.Where("port_runtime_id IN (?)", tx.Model(&PortRuntime{}).Unscoped().Select("id").Where("host_id IN ?", d.Hosts))
+	}
+	return q.Update("deployment_id", d.ID).Error
+}
+
+type DeploymentRequest struct {
+	Name      string     `json:"name"`
+	Ref       string     `json:"ref"`
+	URL       string     `json:"url"`
+	HostIDs   []string   `json:"host_ids"`
+	StartedAt *time.Time `json:"started_at"` // default now
+	EndedAt   *time.Time `json:"ended_at"`   // omit to finish later
+}
+
+// POST /deployments
+func createDeployment(c *gin.Context) {
+	var req DeploymentRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	now := time.Now()
+	d := Deployment{
+		Name: req.Name, Ref: req.Ref, URL: req.URL,
+		HostIDs:   strings.Join(req.HostIDs, ","),
+		Hosts:     req.HostIDs,
+		StartedAt: now,
+		EndedAt:   req.EndedAt,
+		CreatedBy: actorName(c),
+	}
+	if req.StartedAt != nil {
+		d.StartedAt = *req.StartedAt
+	}
+	if d.EndedAt != nil && d.EndedAt.Before(d.StartedAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ended_at must be after started_at"})
+		return
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&d).Error; err != nil {
+			return err
+		}
+		return tagDeploymentWindow(tx, &d, now)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("🚀 Deployment #%d %q started by %s", d.ID, d.Name, d.CreatedBy)
+	c.JSON(http.StatusCreated, d)
+}
+
+// POST /deployments/:id/finish closes a running deployment now.
+func finishDeployment(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var d Deployment
+	if err := DB.First(&d, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deployment not found"})
+		return
+	}
+	if d.EndedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Deployment already finished"})
+		return
+	}
+	now := time.Now()
+	d.EndedAt = &now
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&d).Update("ended_at", now).Error; err != nil {
+			return err
+		}
+		return tagDeploymentWindow(tx, &d, now)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+// GET /deployments, newest first
+func listDeployments(c *gin.Context) {
+	var deployments []Deployment
+	DB.Order("started_at desc").Limit(200).Find(&deployments)
+	c.JSON(http.StatusOK, deployments)
+}
+
+// GET /deployments/:id returns the deployment, the changeset of its window
+// and the tagged events.
+func getDeployment(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var d Deployment
+	if err := DB.First(&d, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deployment not found"})
+		return
+	}
+	cs, err := computeChangeset(d.StartedAt, d.end(time.Now()), d.Hosts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var events []TimelineEvent
+	DB.Table("port_event").
+		Select("port_event.*, port_runtime.host_id, port_runtime.protocol, port_runtime.port, port_note.title AS note_title, port_note.owner, port_note.risk_level").
+		Joins("JOIN port_runtime ON port_runtime.id = port_event.port_runtime_id").
+		Joins(noteJoin).
+		Where("port_event.deployment_id = ?", d.ID).
+		Order("port_event.timestamp, port_event.id").
+		Scan(&events)
+	for i := range events {
+		events[i].WitrOutput = ""
+	}
+	if events == nil {
+		events = []TimelineEvent{}
+	}
+	c.JSON(http.StatusOK, gin.H{"deployment": d, "changes": cs, "events": events})
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Diagnostics: an unprivileged portmonote still finds listeners but can't
+// tell who owns them, so they show up with PID 0 and no process. Instead of
+// leaving that to guesswork, the missing privileges are checked at startup
+// (logged) and on GET /diagnostics, each with a concrete fix.
+type DiagnosticCheck struct {
+	Name        string `json:"name"`
+	Status      string `json:"status"` // ok, warning, error, skipped
+	Detail      string `json:"detail,omitempty"`
+	Impact      string `json:"impact,omitempty"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+const (
+	DiagOK      = "ok"
+	DiagWarning = "warning"
+	DiagError   = "error"
+	DiagSkipped = "skipped"
+)
+
+type DiagnosticsReport struct {
+	OK      bool              `json:"ok"` // no warnings or errors
+	OS      string            `json:"os"`
+	UID     int               `json:"uid"`
+	Scanner string            `json:"scanner"`
+	Checks  []DiagnosticCheck `json:"checks"`
+	RunAt   time.Time         `json:"run_at"`
+}
+
+var dockerSocket = envString("PORTMONOTE_DOCKER_SOCKET", "/var/run/docker.sock")
+
+func runDiagnostics() DiagnosticsReport {
+	r := DiagnosticsReport{OK: true, OS: runtime.GOOS, UID: os.Geteuid(), Scanner: activeScannerName(), RunAt: time.Now()}
+	r.Checks = append(r.Checks, scannerCheck())
+	r.Checks = append(r.Checks, platformDiagnostics()...)
+	r.Checks = append(r.Checks, dockerCheck(), ownerlessCheck())
+	for _, c := range r.Checks {
+		if c.Status == DiagWarning || c.Status == DiagError {
+			r.OK = false
+		}
+	}
+	return r
+}
+
+func scannerCheck() DiagnosticCheck {
+	c := DiagnosticCheck{Name: "scanner"}
+	if name := activeScannerName(); name != "" {
+		c.Status, c.Detail = DiagOK, "using "+name
+		return c
+	}
+	c.Status = DiagError
+	c.Detail = "no scanner backend available for PORTMONOTE_SCANNER=" + scannerPreference
+	c.Impact = "no ports are collected"
+	c.Remediation = "install ss (iproute2) or lsof, or set PORTMONOTE_SCANNER=gopsutil"
+	return c
+}
+
+// dockerCheck only matters when there is a Docker daemon: container
+// attribution (witr) talks to its socket.
+func dockerCheck() DiagnosticCheck {
+	c := DiagnosticCheck{Name: "docker_socket"}
+	if _, err := os.Stat(dockerSocket); err != nil {
+		c.Status, c.Detail = DiagSkipped, dockerSocket+" not present"
+		return c
+	}
+	conn, err := net.DialTimeout("unix", dockerSocket, time.Second)
+	if err == nil {
+		conn.Close()
+		c.Status, c.Detail = DiagOK, dockerSocket+" readable"
+		return c
+	}
+	c.Status = DiagWarning
+	c.Detail = fmt.Sprintf("%s: %v", dockerSocket, err)
+	c.Impact = "ports published by containers are not attributed to their container"
+	c.Remediation = "add the portmonote user to the docker group (usermod -aG docker <user>), or mount the socket into its container"
+	return c
+}
+
+// ownerlessCheck is the symptom: local listeners we couldn't attribute.
+func ownerlessCheck() DiagnosticCheck {
+	c := DiagnosticCheck{Name: "ownerless_ports"}
+	var total, ownerless int64
+	DB.Model(&PortRuntime{}).Where("host_id = ? AND current_state = ?", HostID, StateActive).Count(&total)
+	DB.Model(&PortRuntime{}).Where("host_id = ? AND current_state = ? AND current_p_id = 0", HostID, StateActive).Count(&ownerless)
+	if ownerless == 0 {
+		c.Status, c.Detail = DiagOK, fmt.Sprintf("all %d active local ports have an owning process", total)
+		return c
+	}
+	c.Status = DiagWarning
+	c.Detail = fmt.Sprintf("%d of %d active local ports have PID 0", ownerless, total)
+	c.Impact = "notes, risk checks and process-change events can't use the process of these ports"
+	c.Remediation = "see the process visibility check above"
+	return c
+}
+
+// GET /diagnostics
+func getDiagnostics(c *gin.Context) {
+	c.JSON(http.StatusOK, runDiagnostics())
+}
+
+// logDiagnostics reports problems and their fixes at startup.
+func logDiagnostics() {
+	r := runDiagnostics()
+	for _, c := range r.Checks {
+		if c.Status != DiagWarning && c.Status != DiagError {
+			continue
+		}
+		log.Printf("⚠️ Diagnostics %s: %s", c.Name, c.Detail)
+		if c.Impact != "" {
+			log.Printf("   Impact: %s", c.Impact)
+		}
+		if c.Remediation != "" {
+			log.Printf("   Fix: %s", c.Remediation)
+		}
+	}
+	if r.OK {
+		log.Println("🩺 Diagnostics: all checks passed")
+	}
+}
+
+var ownerlessOnce sync.Once
+
+// warnOwnerless points at /diagnostics the first time a scan returns
+// listeners without a PID.
+func warnOwnerless(results map[PortKey]ScanResult) {
+	n := 0
+	for k, r := range results {
+		if k.HostID == HostID && r.PID == 0 {
+			n++
+		}
+	}
+	if n > 0 {
+		ownerlessOnce.Do(func() {
+			log.Printf("⚠️ %d listener(s) without an owning process, probably missing privileges; see GET /diagnostics", n)
+		})
+	}
+}
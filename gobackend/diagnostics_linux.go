@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Capability bits from <linux/capability.h>
+const (
+	capDacReadSearch = 2
+	capNetAdmin      = 12
+	capSysPtrace     = 19
+	capBPF           = 39
+)
+
+func platformDiagnostics() []DiagnosticCheck {
+	caps := effectiveCaps()
+	return []DiagnosticCheck{procVisibilityCheck(caps), netlinkCheck(caps)}
+}
+
+// effectiveCaps reads CapEff of this process.
+func effectiveCaps() uint64 {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if v, ok := strings.CutPrefix(sc.Text(), "CapEff:"); ok {
+			caps, _ := strconv.ParseUint(strings.TrimSpace(v), 16, 64)
+			return caps
+		}
+	}
+	return 0
+}
+
+func hasCap(caps uint64, bit uint) bool {
+	return caps&(1<<bit) != 0
+}
+
+// procVisibilityCheck: sockets are mapped to processes through
+// /proc/<pid>/fd, which needs root or CAP_DAC_READ_SEARCH + CAP_SYS_PTRACE
+// for processes of other users.
+func procVisibilityCheck(caps uint64) DiagnosticCheck {
+	c := DiagnosticCheck{Name: "process_visibility"}
+	uid := os.Getuid()
+	dirs, _ := filepath.Glob("/proc/[0-9]*")
+	foreign, denied := 0, 0
+	for _, dir := range dirs {
+		info, err := os.Stat(dir)
+		if err != nil {
+			continue
+		}
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok || int(st.Uid) == uid {
+			continue
+		}
+		foreign++
+		if _, err := os.ReadDir(filepath.Join(dir, "fd")); os.IsPermission(err) {
+			denied++
+		}
+	}
+
+	if hidepid := procHidepid(); hidepid != "" && uid != 0 {
+		c.Status = DiagWarning
+		c.Detail = "/proc is mounted with " + hidepid + ", processes of other users are hidden"
+		c.Impact = "ports of other users' processes show PID 0 and no process name"
+		c.Remediation = "run portmonote as root, or add its user to the group named by gid= in the /proc mount options"
+		return c
+	}
+	if denied == 0 {
+		c.Status = DiagOK
+		c.Detail = fmt.Sprintf("can read the sockets of %d processes of other users", foreign)
+		return c
+	}
+	missing := []string{}
+	if !hasCap(caps, capDacReadSearch) {
+		missing = append(missing, "CAP_DAC_READ_SEARCH")
+	}
+	if !hasCap(caps, capSysPtrace) {
+		missing = append(missing, "CAP_SYS_PTRACE")
+	}
+	exe, _ := os.Executable()
+	if exe == "" {
+		exe = "portmonote"
+	}
+	c.Status = DiagWarning
+	c.Detail = fmt.Sprintf("can't read /proc/<pid>/fd of %d of %d processes of other users (missing %s)", denied, foreign, strings.Join(missing, ", "))
+	c.Impact = "their listening ports show PID 0 and no process name"
+	c.Remediation = fmt.Sprintf("run as root, or grant the capabilities: sudo setcap cap_dac_read_search,cap_sys_ptrace+ep %s (systemd: AmbientCapabilities=CAP_DAC_READ_SEARCH CAP_SYS_PTRACE)", exe)
+	return c
+}
+
+// procHidepid returns the hidepid option of the /proc mount, if restrictive.
+func procHidepid() string {
+	data, err := os.ReadFile("/proc/self/mounts")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[1] != "/proc" {
+			continue
+		}
+		for _, opt := range strings.Split(fields[3], ",") {
+			if v, ok := strings.CutPrefix(opt, "hidepid="); ok && v != "0" && v != "off" {
+				return opt
+			}
+		}
+	}
+	return ""
+}
+
+// netlinkCheck tries the sock_diag dump the listener watch uses.
+func netlinkCheck(caps uint64) DiagnosticCheck {
+	c := DiagnosticCheck{Name: "netlink"}
+	capList := []string{}
+	for _, cp := range []struct {
+		bit  uint
+		name string
+	}{{capNetAdmin, "CAP_NET_ADMIN"}, {capBPF, "CAP_BPF"}} {
+		if hasCap(caps, cp.bit) {
+			capList = append(capList, cp.name)
+		}
+	}
+	held := "none of CAP_NET_ADMIN, CAP_BPF"
+	if len(capList) > 0 {
+		held = strings.Join(capList, ", ")
+	}
+
+	if _, err := kernelListeners(); err != nil {
+		c.Status = DiagWarning
+		if watchMode == "netlink" {
+			c.Status = DiagError
+		}
+		c.Detail = fmt.Sprintf("sock_diag dump failed: %v (held: %s)", err, held)
+		c.Impact = "PORTMONOTE_WATCH=netlink can't see short-lived listeners"
+		c.Remediation = "grant CAP_NET_ADMIN (sudo setcap cap_net_admin+ep <binary>, systemd: AmbientCapabilities=CAP_NET_ADMIN), or check that a seccomp profile doesn't block AF_NETLINK"
+		return c
+	}
+	c.Status = DiagOK
+	c.Detail = "sock_diag dump works (held: " + held + ")"
+	return c
+}
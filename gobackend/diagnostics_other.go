@@ -0,0 +1,22 @@
+//go:build !linux
+
+package main
+
+import (
+	"os"
+	"runtime"
+)
+
+func platformDiagnostics() []DiagnosticCheck {
+	c := DiagnosticCheck{Name: "process_visibility"}
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		c.Status, c.Detail = DiagOK, "running with full privileges"
+	} else {
+		c.Status = DiagWarning
+		c.Detail = "not running as root, lsof/netstat only report the sockets of this user"
+		c.Impact = "ports of other users' processes show PID 0 and no process name"
+		c.Remediation = "run portmonote as root, e.g. as a launchd daemon in /Library/LaunchDaemons"
+	}
+	netlink := DiagnosticCheck{Name: "netlink", Status: DiagSkipped, Detail: "Linux only"}
+	return []DiagnosticCheck{c, netlink}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DiffPort is one port in a changeset, with its process at the relevant
+// end of the range.
+type DiffPort struct {
+	RuntimeID   uint      `json:"runtime_id"`
+	HostID      string    `json:"host_id"`
+	Protocol    string    `json:"protocol"`
+	Port        int       `json:"port"`
+	ProcessName string    `json:"process_name"`
+	PID         int       `json:"pid"`
+	At          time.Time `json:"at"` // last transition inside the range
+}
+
+type DiffChange struct {
+	DiffPort
+	FromProcess    string `json:"from_process"`
+	FromPID        int    `json:"from_pid"`
+	ProcessChanges int    `json:"process_changes"` // process_change events in the range
+}
+
+type Changeset struct {
+	From      time.Time    `json:"from"`
+	To        time.Time    `json:"to"`
+	Added     []DiffPort   `json:"added"`
+	Removed   []DiffPort   `json:"removed"`
+	Changed   []DiffChange `json:"changed"`
+	Transient []DiffPort   `json:"transient"` // came and went inside the range
+}
+
+// portSnapshot is a runtime's reconstructed state at one point in time.
+type portSnapshot struct {
+	up      bool
+	process string
+	pid     int
+}
+
+// GET /diff?from=2024-05-01T00:00:00Z&to=now[&host_id=]
+//
+// Replays the lifecycle events (appeared, reappeared, inherited,
+// disappeared, process_change) to get each port's state at both ends and
+// reports what was added, removed or changed its process in between.
+// from also accepts a window like 7d.
+func getDiff(c *gin.Context) {
+	now := time.Now()
+	fromParam := c.Query("from")
+	if fromParam == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from is required"})
+		return
+	}
+	from, err := parseSince(fromParam, now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to := now
+	if s := c.DefaultQuery("to", "now"); s != "now" {
+		if to, err = parseSince(s, now); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if !to.After(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be after from"})
+		return
+	}
+
+	var hosts []string
+	if h := c.Query("host_id"); h != "" {
+		hosts = []string{h}
+	}
+	cs, err := computeChangeset(from, to, hosts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, cs)
+}
+
+// computeChangeset replays the lifecycle events up to to, limited to hosts
+// if given.
+func computeChangeset(from, to time.Time, hosts []string) (Changeset, error) {
+	var rows []struct {
+		PortRuntimeID uint
+		EventType     string
+		Timestamp     time.Time
+		PID           int `gorm:"column:pid"`
+		ProcessName   string
+		HostID        string
+		Protocol      string
+		Port          int
+	}
+	types := append([]string{string(EventDisappeared), string(EventProcessChange)}, upEvents...)
+	q := DB.Table("port_event").
+		Select("port_event.port_runtime_id, port_event.event_type, port_event.timestamp, port_event.p_id AS pid, port_event.process_name, port_runtime.host_id, port_runtime.protocol, port_runtime.port").
+		Joins("JOIN port_runtime ON port_runtime.id = port_event.port_runtime_id").
+		Where("port_event.event_type IN ? AND port_event.timestamp <= ?", types, to)
+	if len(hosts) > 0 {
+		q = q.Where("port_runtime.host_id IN ?", hosts)
+	}
+	if err := q.Order("port_event.timestamp, port_event.id").Scan(&rows).Error; err != nil {
+		return Changeset{}, err
+	}
+
+	type track struct {
+		port           DiffPort
+		atFrom, atTo   portSnapshot
+		appearedInside bool
+		lastChange     time.Time
+		processChanges int
+	}
+	tracks := map[uint]*track{}
+	var order []uint
+	for _, r := range rows {
+		t := tracks[r.PortRuntimeID]
+		if t == nil {
+			t = &track{port: DiffPort{RuntimeID: r.PortRuntimeID, HostID: r.HostID, Protocol: r.Protocol, Port: r.Port}}
+			tracks[r.PortRuntimeID] = t
+			order = append(order, r.PortRuntimeID)
+		}
+		inside := r.Timestamp.After(from)
+		if !inside {
+			t.atFrom = applyDiffEvent(t.atFrom, r.EventType, r.PID, r.ProcessName)
+			t.atTo = t.atFrom
+			continue
+		}
+		t.atTo = applyDiffEvent(t.atTo, r.EventType, r.PID, r.ProcessName)
+		t.lastChange = r.Timestamp
+		switch {
+		case isUpEvent(r.EventType):
+			t.appearedInside = true
+		case r.EventType == string(EventProcessChange):
+			t.processChanges++
+		}
+	}
+
+	cs := Changeset{From: from, To: to, Added: []DiffPort{}, Removed: []DiffPort{}, Changed: []DiffChange{}, Transient: []DiffPort{}}
+	for _, id := range order {
+		t := tracks[id]
+		if t.lastChange.IsZero() {
+			continue // nothing happened in the range
+		}
+		p := t.port
+		p.At = t.lastChange
+		switch {
+		case !t.atFrom.up && t.atTo.up:
+			p.ProcessName, p.PID = t.atTo.process, t.atTo.pid
+			cs.Added = append(cs.Added, p)
+		case t.atFrom.up && !t.atTo.up:
+			p.ProcessName, p.PID = t.atFrom.process, t.atFrom.pid
+			cs.Removed = append(cs.Removed, p)
+		case t.atFrom.up && t.atTo.up:
+			if t.processChanges == 0 && t.atFrom.process == t.atTo.process {
+				continue // restarted under the same process name
+			}
+			p.ProcessName, p.PID = t.atTo.process, t.atTo.pid
+			cs.Changed = append(cs.Changed, DiffChange{
+				DiffPort: p, FromProcess: t.atFrom.process, FromPID: t.atFrom.pid, ProcessChanges: t.processChanges,
+			})
+		case t.appearedInside:
+			p.ProcessName, p.PID = t.atTo.process, t.atTo.pid
+			cs.Transient = append(cs.Transient, p)
+		}
+	}
+	sortDiffPorts(cs.Added)
+	sortDiffPorts(cs.Removed)
+	sortDiffPorts(cs.Transient)
+	sort.Slice(cs.Changed, func(i, j int) bool { return diffLess(cs.Changed[i].DiffPort, cs.Changed[j].DiffPort) })
+
+	return cs, nil
+}
+
+func applyDiffEvent(s portSnapshot, eventType string, pid int, process string) portSnapshot {
+	switch {
+	case eventType == string(EventDisappeared):
+		s.up = false
+	case isUpEvent(eventType), eventType == string(EventProcessChange):
+		s.up = true
+		s.pid, s.process = pid, process
+	}
+	return s
+}
+
+func sortDiffPorts(ports []DiffPort) {
+	sort.Slice(ports, func(i, j int) bool { return diffLess(ports[i], ports[j]) })
+}
+
+func diffLess(a, b DiffPort) bool {
+	if a.HostID != b.HostID {
+		return a.HostID < b.HostID
+	}
+	if a.Port != b.Port {
+		return a.Port < b.Port
+	}
+	return a.Protocol < b.Protocol
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Escalation chains live in the notification config:
+//
+//	escalations:
+//	  - name: oncall
+//	    events: [disappeared, appeared]   # default: any event
+//	    min_severity: warning             # default: warning
+//	    steps:
+//	      - after: 15m
+//	        channels: [pager]
+//	      - after: 1h
+//	        channels: [manager-mail]
+//
+// An event that matches starts an escalation. Each step fires when the
+// previous one (or the original alert) has gone unacknowledged for `after`.
+// Acknowledging the port, or the port recovering, ends the chain.
+type EscalationPolicy struct {
+	Name        string           `yaml:"name"`
+	Events      []string         `yaml:"events"`
+	MinSeverity string           `yaml:"min_severity"`
+	Steps       []EscalationStep `yaml:"steps"`
+}
+
+type EscalationStep struct {
+	After    time.Duration `yaml:"after"`
+	Channels []string      `yaml:"channels"`
+}
+
+// Escalation tracks one running chain.
+type Escalation struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Policy        string     `json:"policy"`
+	PortRuntimeID uint       `gorm:"index" json:"port_runtime_id"`
+	PortEventID   uint       `json:"port_event_id"` // the alert being escalated
+	EventType     string     `json:"event_type"`
+	NextStep      int        `json:"next_step"`
+	NextAt        time.Time  `gorm:"index" json:"next_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ResolvedAt    *time.Time `gorm:"index" json:"resolved_at"`
+	Resolution    string     `json:"resolution"` // acknowledged, recovered, exhausted
+}
+
+func (Escalation) TableName() string {
+	return "escalation"
+}
+
+var escalationPolicies []EscalationPolicy
+
+func validateEscalations(policies []EscalationPolicy, channels []*notifyChannel) error {
+	for i := range policies {
+		p := &policies[i]
+		if p.MinSeverity == "" {
+			p.MinSeverity = SeverityWarning
+		}
+		if severityRank(p.MinSeverity) < 0 {
+			return fmt.Errorf("escalation %q: unknown min_severity %q", p.Name, p.MinSeverity)
+		}
+		if len(p.Steps) == 0 {
+			return fmt.Errorf("escalation %q: no steps", p.Name)
+		}
+		for _, step := range p.Steps {
+			if step.After <= 0 {
+				return fmt.Errorf("escalation %q: every step needs after > 0", p.Name)
+			}
+			for _, name := range step.Channels {
+				if findChannel(channels, name) == nil {
+					return fmt.Errorf("escalation %q: unknown channel %q", p.Name, name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func findChannel(channels []*notifyChannel, name string) *notifyChannel {
+	for _, ch := range channels {
+		if ch.cfg.Name == name {
+			return ch
+		}
+	}
+	return nil
+}
+
+// startEscalations opens a chain for each policy the event matches.
+func startEscalations(data NotificationData) {
+	severity := eventSeverity(data)
+	for _, p := range escalationPolicies {
+		if len(p.Events) > 0 && !slices.Contains(p.Events, data.Event.EventType) {
+			continue
+		}
+		if severityRank(severity) < severityRank(p.MinSeverity) {
+			continue
+		}
+		// One open chain per policy and port is enough
+		var open int64
+		DB.Model(&Escalation{}).Where("policy = ? AND port_runtime_id = ? AND resolved_at IS NULL", p.Name, data.Runtime.ID).Count(&open)
+		if open > 0 {
+			continue
+		}
+		DB.Create(&Escalation{
+			Policy:        p.Name,
+			PortRuntimeID: data.Runtime.ID,
+			PortEventID:   data.Event.ID,
+			EventType:     data.Event.EventType,
+			NextAt:        data.Event.Timestamp.Add(p.Steps[0].After),
+		})
+	}
+}
+
+// resolveEscalations closes all open chains for a runtime.
+func resolveEscalations(runtimeID uint, resolution string) {
+	DB.Model(&Escalation{}).Where("port_runtime_id = ? AND resolved_at IS NULL", runtimeID).
+		Updates(map[string]any{"resolved_at": time.Now(), "resolution": resolution})
+}
+
+// runEscalations fires due steps. Called from the notifier's minute ticker.
+func runEscalations(now time.Time) {
+	var due []Escalation
+	DB.Where("resolved_at IS NULL AND next_at <= ?", now).Find(&due)
+	for _, esc := range due {
+		idx := slices.IndexFunc(escalationPolicies, func(p EscalationPolicy) bool { return p.Name == esc.Policy })
+		if idx < 0 || esc.NextStep >= len(escalationPolicies[idx].Steps) {
+			closeEscalation(&esc, "exhausted")
+			continue
+		}
+		policy := escalationPolicies[idx]
+
+		var runtime PortRuntime
+		var alert PortEvent
+		if DB.First(&runtime, esc.PortRuntimeID).Error != nil || DB.First(&alert, esc.PortEventID).Error != nil {
+			closeEscalation(&esc, "exhausted")
+			continue
+		}
+		if (esc.EventType == string(EventDisappeared)) == (runtime.CurrentState == "active") {
+			closeEscalation(&esc, "recovered") // back up, or the unexpected listener went away
+			continue
+		}
+
+		step := policy.Steps[esc.NextStep]
+		data := NotificationData{Event: alert, Runtime: runtime, Link: notifyDashURL, PortLink: portLink(runtime)}
+		if DB.Where("host_id = ? AND protocol = ? AND port = ?", runtime.HostID, runtime.Protocol, runtime.Port).
+			First(&data.Note).Error == nil {
+			data.HasNote = true
+		}
+		for _, name := range step.Channels {
+			ch := findChannel(notifyChannels, name)
+			if ch == nil {
+				continue
+			}
+			n, err := ch.render(data)
+			if err != nil {
+				log.Printf("Notification template error on channel %s: %v", name, err)
+				continue
+			}
+			n.Title = fmt.Sprintf("[escalated %d/%d] %s", esc.NextStep+1, len(policy.Steps), n.Title)
+			n.Severity = eventSeverity(data)
+			if err := ch.sender.Send(n); err != nil {
+				log.Printf("Escalation to %s failed: %v", name, err)
+			}
+		}
+
+		DB.Create(&PortEvent{
+			PortRuntimeID: runtime.ID,
+			EventType:     string(EventEscalated),
+			Timestamp:     now,
+			PID:           runtime.CurrentPID,
+			ProcessName:   runtime.ProcessName,
+			Detail:        fmt.Sprintf("%s step %d: %s", policy.Name, esc.NextStep+1, strings.Join(step.Channels, ", ")),
+		})
+
+		esc.NextStep++
+		if esc.NextStep >= len(policy.Steps) {
+			closeEscalation(&esc, "exhausted")
+			continue
+		}
+		esc.NextAt = now.Add(policy.Steps[esc.NextStep].After)
+		DB.Save(&esc)
+	}
+}
+
+func closeEscalation(esc *Escalation, resolution string) {
+	now := time.Now()
+	esc.ResolvedAt = &now
+	esc.Resolution = resolution
+	DB.Save(esc)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimelineEvent is a port event with the port and note it belongs to.
+type TimelineEvent struct {
+	PortEvent
+	HostID    string `json:"host_id"`
+	Protocol  string `json:"protocol"`
+	Port      int    `json:"port"`
+	NoteTitle string `json:"note_title,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+	RiskLevel string `json:"risk_level,omitempty"`
+}
+
+// GET /events: the event timeline across all ports, newest first.
+//
+//	?since=7d | 2024-05-01T00:00:00Z    window or start time
+//	?until=2024-05-08T00:00:00Z
+//	?types=appeared,process_change
+//	?host_id=&protocol=&port=
+//	?limit=100                          max 500
+//	?cursor=...                         next_cursor of the previous page
+//
+// Witr output is left out; GET /history has it.
+func listEvents(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	limit = min(limit, 500)
+
+	q := DB.Table("port_event").
+		Select("port_event.*, port_runtime.host_id, port_runtime.protocol, port_runtime.port, port_note.title AS note_title, port_note.owner, port_note.risk_level").
+		Joins("JOIN port_runtime ON port_runtime.id = port_event.port_runtime_id").
+		Joins(noteJoin)
+
+	now := time.Now()
+	if s := c.Query("since"); s != "" {
+		since, err := parseSince(s, now)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		q = q.Where("port_event.timestamp >= ?", since)
+	}
+	if s := c.Query("until"); s != "" {
+		until, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "until must be RFC 3339"})
+			return
+		}
+		q = q.Where("port_event.timestamp < ?", until)
+	}
+	if types := splitList(c.Query("types")); len(types) > 0 {
+		q = q.Where("port_event.event_type IN ?", types)
+	}
+	if h := c.Query("host_id"); h != "" {
+		q = q.Where("port_runtime.host_id = ?", h)
+	}
+	if p := c.Query("protocol"); p != "" {
+		q = q.Where("port_runtime.protocol = ?", p)
+	}
+	if p := c.Query("port"); p != "" {
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid port"})
+			return
+		}
+		q = q.Where("port_runtime.port = ?", port)
+	}
+	if cur := c.Query("cursor"); cur != "" {
+		ts, id, err := decodeEventCursor(cur)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		q = q.Where("port_event.timestamp < ? OR (port_event.timestamp = ? AND port_event.id < ?)", ts, ts, id)
+	}
+
+	var events []TimelineEvent
+	if err := q.Order("port_event.timestamp desc, port_event.id desc").Limit(limit + 1).Scan(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	next := ""
+	if len(events) > limit {
+		events = events[:limit]
+		last := events[limit-1]
+		next = encodeEventCursor(last.Timestamp, last.ID)
+	}
+	for i := range events {
+		events[i].WitrOutput = ""
+	}
+	if events == nil {
+		events = []TimelineEvent{}
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events, "next_cursor": next})
+}
+
+// parseSince accepts a window back from now ("7d", "12h") or RFC 3339.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := parseWindow(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("since must be a window like 7d or RFC 3339")
+	}
+	return now.Add(-d), nil
+}
+
+// The cursor is the (timestamp, id) of the last event returned, so pages
+// stay stable while new events arrive.
+func encodeEventCursor(ts time.Time, id uint) string {
+	return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d:%d", ts.UnixNano(), id))
+}
+
+func decodeEventCursor(s string) (time.Time, uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid cursor")
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	n, err1 := strconv.ParseInt(nanos, 10, 64)
+	i, err2 := strconv.ParseUint(id, 10, 64)
+	if !ok || err1 != nil || err2 != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid cursor")
+	}
+	return time.Unix(0, n), uint(i), nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Executables are hashed once per (path, size, mtime), so a steady state
+// costs a stat per listener rather than a full read every minute.
+type exeStamp struct {
+	path  string
+	size  int64
+	mtime time.Time
+}
+
+var (
+	exeHashMu    sync.Mutex
+	exeHashCache = map[exeStamp]string{}
+)
+
+// hashExecutables fills in ExeSHA256 for every scan result with a readable
+// executable path.
+func hashExecutables(results map[PortKey]ScanResult) {
+	live := map[exeStamp]bool{}
+	for key, res := range results {
+		if res.ExePath == "" {
+			continue
+		}
+		fi, err := os.Stat(res.ExePath)
+		if err != nil {
+			continue
+		}
+		stamp := exeStamp{path: res.ExePath, size: fi.Size(), mtime: fi.ModTime()}
+		live[stamp] = true
+
+		exeHashMu.Lock()
+		sum, ok := exeHashCache[stamp]
+		exeHashMu.Unlock()
+		if !ok {
+			if sum, err = fileSHA256(res.ExePath); err != nil {
+				continue
+			}
+			exeHashMu.Lock()
+			exeHashCache[stamp] = sum
+			exeHashMu.Unlock()
+		}
+		res.ExeSHA256 = sum
+		results[key] = res
+	}
+
+	exeHashMu.Lock()
+	for stamp := range exeHashCache {
+		if !live[stamp] {
+			delete(exeHashCache, stamp)
+		}
+	}
+	exeHashMu.Unlock()
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func shortHash(sum string) string {
+	if len(sum) > 12 {
+		return sum[:12]
+	}
+	return sum
+}
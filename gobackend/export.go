@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// writeExport streams runtimes, notes and events in the legacy_export.json
+// layout (see ExportData), so an export can be fed back into
+// `portmonote import --json`. Archived rows are left out.
+func writeExport(out io.Writer) error {
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+
+	if _, err := w.WriteString(`{"runtimes":[`); err != nil {
+		return err
+	}
+	first := true
+	var runtimes []PortRuntime
+	err := DB.Order("id").FindInBatches(&runtimes, 500, func(tx *gorm.DB, batch int) error {
+		for _, r := range runtimes {
+			row := ExportRuntime{
+				ID:                 r.ID,
+				HostID:             r.HostID,
+				Protocol:           r.Protocol,
+				Port:               r.Port,
+				FirstSeenAt:        CustomTime{r.FirstSeenAt},
+				LastSeenAt:         CustomTime{r.LastSeenAt},
+				CurrentState:       r.CurrentState,
+				CurrentPID:         r.CurrentPID,
+				ProcessName:        r.ProcessName,
+				Cmdline:            r.Cmdline,
+				TotalSeenCount:     r.TotalSeenCount,
+				TotalUptimeSeconds: r.TotalUptimeSeconds,
+			}
+			if r.LastDisappearedAt != nil {
+				row.LastDisappearedAt = &CustomTime{*r.LastDisappearedAt}
+			}
+			if err := writeExportItem(w, enc, &first, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.WriteString(`],"notes":[`); err != nil {
+		return err
+	}
+	first = true
+	var notes []PortNote
+	err = DB.Order("id").FindInBatches(&notes, 500, func(tx *gorm.DB, batch int) error {
+		for _, n := range notes {
+			if err := writeExportItem(w, enc, &first, n); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.WriteString(`],"events":[`); err != nil {
+		return err
+	}
+	first = true
+	var events []PortEvent
+	liveRuntimes := DB.Model(&PortRuntime{}).Select("id")
+	err = DB.Where("port_runtime_id IN (?)", liveRuntimes).Order("id").FindInBatches(&events, 1000, func(tx *gorm.DB, batch int) error {
+		for _, e := range events {
+			row := ExportEvent{
+				ID:            e.ID,
+				PortRuntimeID: e.PortRuntimeID,
+				EventType:     e.EventType,
+				Timestamp:     CustomTime{e.Timestamp},
+				PID:           e.PID,
+				ProcessName:   e.ProcessName,
+			}
+			if err := writeExportItem(w, enc, &first, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.WriteString("]}\n"); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func writeExportItem(w *bufio.Writer, enc *json.Encoder, first *bool, v any) error {
+	if !*first {
+		if err := w.WriteByte(','); err != nil {
+			return err
+		}
+	}
+	*first = false
+	return enc.Encode(v)
+}
+
+// GET /export downloads the whole database as JSON.
+func handleExport(c *gin.Context) {
+	filename := fmt.Sprintf("portmonote_export_%s.json", time.Now().Format("20060102_150405"))
+	c.Header("Content-Type", "application/json")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	if err := writeExport(c.Writer); err != nil {
+		// Headers are already sent; the truncated body makes the JSON invalid
+		c.Error(err)
+	}
+}
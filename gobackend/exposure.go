@@ -0,0 +1,606 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// End-to-end exposure: router/firewall configs are stored as device configs
+// and their NAT and ACL rules are matched against our listeners, so the port
+// detail can say "listening on 10.0.0.5:8080, NATed from 203.0.113.4:8080 on
+// edge-fw, permitted by ...".
+//
+// Two formats are understood: iptables-save output and Cisco IOS running
+// configs (static NAT, numbered and named extended ACLs). Configs are
+// uploaded with PUT /admin/device-configs/:name, or dropped into
+// PORTMONOTE_DEVICE_CONFIG_DIR by a nightly pull job (*.iptables, *.ios, or
+// anything else for auto-detection); that directory is re-read daily.
+//
+// ACL evaluation is first-match over all of a device's rules, ignoring
+// interface bindings; good enough to spot an open path, not a full firewall
+// simulator.
+var deviceConfigDir = envString("PORTMONOTE_DEVICE_CONFIG_DIR", "")
+
+const (
+	DeviceFormatIPTables = "iptables"
+	DeviceFormatIOS      = "ios"
+
+	maxDeviceConfigSize = 4 << 20
+)
+
+type DeviceConfig struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"uniqueIndex" json:"name"`
+	Format    string    `json:"format"`
+	Content   string    `json:"-"`
+	Source    string    `json:"source"` // "upload" or the file it was read from
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (DeviceConfig) TableName() string {
+	return "device_config"
+}
+
+// NATRule forwards ExtAddr:ExtPort to IntAddr:IntPort. Empty addresses and
+// zero ports mean "any" / "unchanged".
+type NATRule struct {
+	Proto   string
+	ExtAddr string
+	ExtPort int
+	IntAddr string
+	IntPort int
+	Line    string
+}
+
+// ACLRule matches traffic to Dst:DstPort (up to DstPortMax for a range);
+// the Not flags invert a criterion, as iptables' "!" does.
+type ACLRule struct {
+	Permit     bool
+	Proto      string // "ip" matches everything
+	Dst        *net.IPNet
+	DstPort    int
+	DstPortMax int
+	NotProto   bool
+	NotDst     bool
+	NotDstPort bool
+	Line       string
+}
+
+func (a ACLRule) matches(proto string, addrs []string, port int) bool {
+	if a.Proto != "ip" && a.Proto != "all" && (a.Proto == proto) == a.NotProto {
+		return false
+	}
+	if a.DstPort != 0 && (port >= a.DstPort && port <= max(a.DstPort, a.DstPortMax)) == a.NotDstPort {
+		return false
+	}
+	if a.Dst == nil {
+		return true
+	}
+	in := false
+	for _, s := range addrs {
+		if ip := net.ParseIP(s); ip != nil && a.Dst.Contains(ip) {
+			in = true
+			break
+		}
+	}
+	return in != a.NotDst
+}
+
+type deviceRules struct {
+	NAT []NATRule
+	ACL []ACLRule
+	// Verdict when no ACL matches; nil means there is no filtering at all
+	DefaultPermit *bool
+}
+
+// evaluate returns whether traffic is let through and why.
+func (d deviceRules) evaluate(proto string, addrs []string, port int) (bool, string) {
+	for _, a := range d.ACL {
+		if a.matches(proto, addrs, port) {
+			return a.Permit, a.Line
+		}
+	}
+	if d.DefaultPermit == nil {
+		return true, "no ACL"
+	}
+	if *d.DefaultPermit {
+		return true, "default policy ACCEPT"
+	}
+	return false, "default deny"
+}
+
+func detectDeviceFormat(content string) string {
+	if strings.Contains(content, "\n-A ") || strings.HasPrefix(content, "*") || strings.Contains(content, "\n*nat") {
+		return DeviceFormatIPTables
+	}
+	return DeviceFormatIOS
+}
+
+func parseDeviceConfig(format, content string) deviceRules {
+	if format == DeviceFormatIPTables {
+		return parseIPTables(content)
+	}
+	return parseIOS(content)
+}
+
+// parseIPTables reads iptables-save output: DNAT rules from the nat table
+// and FORWARD/INPUT rules from the filter table. "!" is honored on the
+// protocol, destination and port. Rules that can't open a path from outside
+// are left out: loopback ones (-i lo) and conntrack/state matches without
+// NEW (the usual RELATED,ESTABLISHED accept). So are rules with match
+// modules we don't model, and DNATs with any negation.
+func parseIPTables(content string) deviceRules {
+	var d deviceRules
+	table := ""
+	sc := bufio.NewScanner(strings.NewReader(content))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, "*"):
+			table = line[1:]
+			continue
+		case strings.HasPrefix(line, ":FORWARD ") && table == "filter":
+			permit := strings.Fields(line)[1] == "ACCEPT"
+			d.DefaultPermit = &permit
+			continue
+		case !strings.HasPrefix(line, "-A "):
+			continue
+		}
+
+		f := strings.Fields(line)
+		chain, r := f[1], parseIPTablesRule(f[2:])
+		if !r.modeled {
+			continue
+		}
+		proto := r.opts["-p"]
+		if proto == "" {
+			proto = "all"
+		}
+
+		switch {
+		case table == "nat" && chain == "PREROUTING" && r.opts["-j"] == "DNAT":
+			if len(r.not) > 0 {
+				continue
+			}
+			dport, _ := strconv.Atoi(r.opts["--dport"])
+			n := NATRule{Proto: proto, ExtAddr: strings.TrimSuffix(r.opts["-d"], "/32"), ExtPort: dport, Line: line}
+			to := r.opts["--to-destination"]
+			if host, port, err := net.SplitHostPort(to); err == nil {
+				n.IntAddr = host
+				n.IntPort, _ = strconv.Atoi(port)
+			} else {
+				n.IntAddr = to
+			}
+			d.NAT = append(d.NAT, n)
+		case table == "filter" && (chain == "FORWARD" || chain == "INPUT"):
+			target := r.opts["-j"]
+			if target != "ACCEPT" && target != "DROP" && target != "REJECT" {
+				continue
+			}
+			if r.opts["-i"] == "lo" && !r.not["-i"] {
+				continue
+			}
+			base := ACLRule{Permit: target == "ACCEPT", Proto: proto, NotProto: r.not["-p"], Line: line}
+			if dst := r.opts["-d"]; dst != "" {
+				base.Dst, base.NotDst = parseCIDR(dst), r.not["-d"]
+			}
+			ports := r.opts["--dport"]
+			if ports == "" {
+				ports = r.opts["--dports"] // multiport
+			}
+			if ports == "" {
+				d.ACL = append(d.ACL, base)
+				continue
+			}
+			if r.not["--dport"] || r.not["--dports"] {
+				if strings.Contains(ports, ",") {
+					continue // "none of these ports" doesn't split into rules
+				}
+				base.NotDstPort = true
+			}
+			for _, p := range strings.Split(ports, ",") {
+				lo, hi, _ := strings.Cut(p, ":")
+				a := base
+				a.DstPort, _ = strconv.Atoi(lo)
+				a.DstPortMax, _ = strconv.Atoi(hi)
+				if a.DstPort == 0 {
+					continue
+				}
+				d.ACL = append(d.ACL, a)
+			}
+		}
+	}
+	return d
+}
+
+// iptablesRule is the options of one rule; not marks the negated ones.
+type iptablesRule struct {
+	opts    map[string]string
+	not     map[string]bool
+	modeled bool
+}
+
+// Match modules whose options parseIPTables understands (or can ignore)
+var iptablesModules = []string{"tcp", "udp", "comment", "multiport", "conntrack", "state"}
+
+func parseIPTablesRule(f []string) iptablesRule {
+	r := iptablesRule{opts: map[string]string{}, not: map[string]bool{}, modeled: true}
+	neg := false
+	for i := 0; i < len(f); i++ {
+		if f[i] == "!" {
+			neg = true
+			continue
+		}
+		if !strings.HasPrefix(f[i], "-") || i+1 >= len(f) {
+			continue
+		}
+		name := f[i]
+		if f[i+1] == "!" && i+2 < len(f) { // old "-d ! 10.0.0.1" form
+			neg = true
+			i++
+		}
+		i++
+		if name == "-m" && !slices.Contains(iptablesModules, f[i]) {
+			r.modeled = false
+		}
+		r.opts[name] = f[i]
+		if neg {
+			r.not[name] = true
+		}
+		neg = false
+	}
+	state := r.opts["--ctstate"] + r.opts["--state"]
+	if state != "" && (!strings.Contains(state, "NEW") || r.not["--ctstate"] || r.not["--state"]) {
+		r.modeled = false
+	}
+	return r
+}
+
+// parseIOS reads "ip nat inside source static" and extended access lists
+// from a Cisco IOS config.
+func parseIOS(content string) deviceRules {
+	var d deviceRules
+	inACL := false
+	sc := bufio.NewScanner(strings.NewReader(content))
+	for sc.Scan() {
+		raw := sc.Text()
+		line := strings.TrimSpace(raw)
+		f := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "ip nat inside source static ") && len(f) >= 10:
+			// ip nat inside source static tcp 10.0.0.5 8080 203.0.113.4 8080
+			// ip nat inside source static tcp 10.0.0.5 8080 interface Gi0/0 8080
+			r := NATRule{Proto: f[5], IntAddr: f[6], Line: line}
+			r.IntPort, _ = strconv.Atoi(f[7])
+			if f[8] == "interface" && len(f) >= 11 {
+				r.ExtAddr = "interface " + f[9]
+				r.ExtPort, _ = strconv.Atoi(f[10])
+			} else {
+				r.ExtAddr = f[8]
+				r.ExtPort, _ = strconv.Atoi(f[9])
+			}
+			d.NAT = append(d.NAT, r)
+		case strings.HasPrefix(line, "access-list ") && len(f) > 3:
+			if a, ok := parseIOSACE(f[2:], line); ok {
+				d.ACL = append(d.ACL, a)
+			}
+		case strings.HasPrefix(line, "ip access-list extended "):
+			inACL = true
+		case inACL && (strings.HasPrefix(raw, " ") || strings.HasPrefix(raw, "\t")):
+			if a, ok := parseIOSACE(f, line); ok {
+				d.ACL = append(d.ACL, a)
+			}
+		default:
+			inACL = false
+		}
+	}
+	if len(d.ACL) > 0 {
+		deny := false // implicit deny at the end of every IOS ACL
+		d.DefaultPermit = &deny
+	}
+	return d
+}
+
+// parseIOSACE parses "permit|deny proto SRC [eq N] DST [eq N]".
+func parseIOSACE(f []string, line string) (ACLRule, bool) {
+	if len(f) > 0 && isNumber(f[0]) {
+		f = f[1:] // sequence number in named ACLs
+	}
+	if len(f) < 4 || (f[0] != "permit" && f[0] != "deny") {
+		return ACLRule{}, false
+	}
+	a := ACLRule{Permit: f[0] == "permit", Proto: f[1], Line: line}
+	rest := f[2:]
+
+	// Source: any | host A | A WILDCARD, optionally followed by a port
+	_, rest = parseIOSAddr(rest)
+	if len(rest) >= 2 && rest[0] == "eq" {
+		rest = rest[2:]
+	}
+	a.Dst, rest = parseIOSAddr(rest)
+	if len(rest) >= 2 && rest[0] == "eq" {
+		a.DstPort = iosPort(rest[1])
+	}
+	return a, true
+}
+
+func parseIOSAddr(f []string) (*net.IPNet, []string) {
+	switch {
+	case len(f) == 0:
+		return nil, f
+	case f[0] == "any":
+		return nil, f[1:]
+	case f[0] == "host" && len(f) >= 2:
+		return parseCIDR(f[1]), f[2:]
+	case len(f) >= 2:
+		ip, wildcard := net.ParseIP(f[0]).To4(), net.ParseIP(f[1]).To4()
+		if ip == nil || wildcard == nil {
+			return nil, f[1:]
+		}
+		mask := make(net.IPMask, 4)
+		for i := range mask {
+			mask[i] = ^wildcard[i]
+		}
+		return &net.IPNet{IP: ip.Mask(mask), Mask: mask}, f[2:]
+	}
+	return nil, f[1:]
+}
+
+var iosPortNames = map[string]int{"www": 80, "http": 80, "https": 443, "ssh": 22, "telnet": 23, "ftp": 21, "smtp": 25, "domain": 53}
+
+func iosPort(s string) int {
+	if p, err := strconv.Atoi(s); err == nil {
+		return p
+	}
+	return iosPortNames[s]
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// parseCIDR accepts a bare address as a host route.
+func parseCIDR(s string) *net.IPNet {
+	if !strings.Contains(s, "/") {
+		if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+			s += "/32"
+		} else {
+			s += "/128"
+		}
+	}
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil
+	}
+	return n
+}
+
+func isAnyNet(n *net.IPNet) bool {
+	ones, _ := n.Mask.Size()
+	return ones == 0
+}
+
+// hostAddresses lists the IPs a host's listeners can be reached on: the
+// local interfaces for this host, the polled address for SNMP targets.
+func hostAddresses(hostID string) []string {
+	if hostID == HostID {
+		var addrs []string
+		ifaceAddrs, _ := net.InterfaceAddrs()
+		for _, a := range ifaceAddrs {
+			if n, ok := a.(*net.IPNet); ok && !n.IP.IsLoopback() {
+				addrs = append(addrs, n.IP.String())
+			}
+		}
+		return addrs
+	}
+	for _, t := range snmpTargets {
+		if t.Name != hostID {
+			continue
+		}
+		if net.ParseIP(t.Host) != nil {
+			return []string{t.Host}
+		}
+		addrs, _ := net.LookupHost(t.Host)
+		return addrs
+	}
+	return nil
+}
+
+type Exposure struct {
+	Device   string `json:"device"`
+	Internal string `json:"internal"`
+	External string `json:"external,omitempty"` // empty when routed without NAT
+	Exposed  bool   `json:"exposed"`
+	Rule     string `json:"rule"` // NAT rule or the ACL line that permits routed traffic
+	ACL      string `json:"acl"`  // ACL verdict for the NATed path
+	Summary  string `json:"summary"`
+}
+
+func computeExposure(rt PortRuntime) []Exposure {
+	addrs := hostAddresses(rt.HostID)
+	if len(addrs) == 0 {
+		return nil
+	}
+	var configs []DeviceConfig
+	DB.Order("name").Find(&configs)
+
+	var out []Exposure
+	for _, cfg := range configs {
+		rules := parseDeviceConfig(cfg.Format, cfg.Content)
+
+		nated := false
+		for _, n := range rules.NAT {
+			intPort := n.IntPort
+			if intPort == 0 {
+				intPort = n.ExtPort
+			}
+			if (n.Proto != rt.Protocol && n.Proto != "all") || intPort != rt.Port || !slices.Contains(addrs, n.IntAddr) {
+				continue
+			}
+			nated = true
+			ext := n.ExtAddr
+			if ext == "" {
+				ext = "*"
+			}
+			if n.ExtPort != 0 {
+				ext = net.JoinHostPort(ext, strconv.Itoa(n.ExtPort))
+			}
+			internal := net.JoinHostPort(n.IntAddr, strconv.Itoa(rt.Port))
+			permit, why := rules.evaluate(rt.Protocol, []string{n.IntAddr, n.ExtAddr}, rt.Port)
+			e := Exposure{Device: cfg.Name, Internal: internal, External: ext, Exposed: permit, Rule: n.Line, ACL: why}
+			e.Summary = fmt.Sprintf("listening on %s, NATed from %s on %s", internal, ext, cfg.Name)
+			if !permit {
+				e.Summary += " (blocked: " + why + ")"
+			}
+			out = append(out, e)
+		}
+		if nated {
+			continue
+		}
+
+		// Routed without NAT: only explicit permits naming this host count
+		for _, a := range rules.ACL {
+			if a.Dst == nil || isAnyNet(a.Dst) || !a.matches(rt.Protocol, addrs, rt.Port) {
+				continue
+			}
+			if permit, _ := rules.evaluate(rt.Protocol, addrs, rt.Port); !permit {
+				break
+			}
+			internal := net.JoinHostPort(addrs[0], strconv.Itoa(rt.Port))
+			out = append(out, Exposure{
+				Device: cfg.Name, Internal: internal, Exposed: true, Rule: a.Line, ACL: a.Line,
+				Summary: fmt.Sprintf("listening on %s, routed through %s", internal, cfg.Name),
+			})
+			break
+		}
+	}
+	return out
+}
+
+// GET /ports/exposure?host_id=&protocol=&port=
+func getExposure(c *gin.Context) {
+	port, _ := strconv.Atoi(c.Query("port"))
+	var rt PortRuntime
+	if err := DB.Where("host_id = ? AND protocol = ? AND port = ?", c.Query("host_id"), c.Query("protocol"), port).First(&rt).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Runtime not found"})
+		return
+	}
+	exposures := computeExposure(rt)
+	if exposures == nil {
+		exposures = []Exposure{}
+	}
+	c.JSON(http.StatusOK, exposures)
+}
+
+// GET /admin/device-configs
+func listDeviceConfigs(c *gin.Context) {
+	var configs []DeviceConfig
+	DB.Order("name").Find(&configs)
+	c.JSON(http.StatusOK, configs)
+}
+
+// PUT /admin/device-configs/:name?format=iptables|ios with the raw config
+// as the body.
+func putDeviceConfig(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxDeviceConfigSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(body) > maxDeviceConfigSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Config too large"})
+		return
+	}
+	format := c.Query("format")
+	if format != "" && format != DeviceFormatIPTables && format != DeviceFormatIOS {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be iptables or ios"})
+		return
+	}
+	cfg, err := saveDeviceConfig(c.Param("name"), format, string(body), "upload")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rules := parseDeviceConfig(cfg.Format, cfg.Content)
+	c.JSON(http.StatusOK, gin.H{"config": cfg, "nat_rules": len(rules.NAT), "acl_rules": len(rules.ACL)})
+}
+
+// DELETE /admin/device-configs/:name
+func deleteDeviceConfig(c *gin.Context) {
+	res := DB.Where("name = ?", c.Param("name")).Delete(&DeviceConfig{})
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device config not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+func saveDeviceConfig(name, format, content, source string) (DeviceConfig, error) {
+	if format == "" {
+		format = detectDeviceFormat(content)
+	}
+	var cfg DeviceConfig
+	DB.Where("name = ?", name).First(&cfg)
+	cfg.Name, cfg.Format, cfg.Content, cfg.Source = name, format, content, source
+	return cfg, DB.Save(&cfg).Error
+}
+
+// startDeviceConfigSync loads PORTMONOTE_DEVICE_CONFIG_DIR now and daily.
+func init() {
+	registerJob("device_config_sync", JobKind{Run: func(ctx context.Context, _ string) (string, error) {
+		loadDeviceConfigDir(deviceConfigDir)
+		return "", nil
+	}})
+}
+
+func startDeviceConfigSync(ctx context.Context) {
+	if deviceConfigDir == "" {
+		return
+	}
+	scheduleJob(ctx, "device_config_sync", 24*time.Hour)
+}
+
+func loadDeviceConfigDir(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Println("Error reading device configs:", err)
+		return
+	}
+	n := 0
+	for _, e := range entries {
+		if e.IsDir() || e.Name()[0] == '.' {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		content, err := os.ReadFile(path)
+		if err != nil || len(content) > maxDeviceConfigSize {
+			log.Printf("Skipping device config %s: %v", path, err)
+			continue
+		}
+		ext := filepath.Ext(e.Name())
+		format := strings.TrimPrefix(ext, ".")
+		if format != DeviceFormatIPTables && format != DeviceFormatIOS {
+			format = ""
+		}
+		if _, err := saveDeviceConfig(strings.TrimSuffix(e.Name(), ext), format, string(content), path); err != nil {
+			log.Printf("Error saving device config %s: %v", path, err)
+			continue
+		}
+		n++
+	}
+	log.Printf("🧱 Loaded %d device config(s) from %s", n, dir)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Flapping: a listener that keeps disappearing and coming back. Every
+// appear/disappear transition is remembered for PORTMONOTE_FLAP_WINDOW;
+// with PORTMONOTE_FLAP_THRESHOLD or more in the window the port's derived
+// status is "flapping".
+var (
+	flapWindow    = envDuration("PORTMONOTE_FLAP_WINDOW", time.Hour)
+	flapThreshold = envInt("PORTMONOTE_FLAP_THRESHOLD", 4)
+)
+
+// recordFlap adds a transition at now and drops the ones outside the window.
+func recordFlap(rt *PortRuntime, now time.Time) {
+	if inMaintenance(rt, now) {
+		return
+	}
+	times := append(flapTimes(rt.FlapTimes, now), now.Unix())
+	parts := make([]string, len(times))
+	for i, t := range times {
+		parts[i] = strconv.FormatInt(t, 10)
+	}
+	rt.FlapTimes = strings.Join(parts, ",")
+	rt.FlapCount = len(times)
+}
+
+// currentFlapCount is the number of transitions still inside the window.
+func currentFlapCount(rt *PortRuntime, now time.Time) int {
+	return len(flapTimes(rt.FlapTimes, now))
+}
+
+func flapTimes(s string, now time.Time) []int64 {
+	cutoff := now.Add(-flapWindow).Unix()
+	var times []int64
+	for _, part := range strings.Split(s, ",") {
+		if t, err := strconv.ParseInt(part, 10, 64); err == nil && t > cutoff {
+			times = append(times, t)
+		}
+	}
+	return times
+}
+
+func isFlapping(flapCount int) bool {
+	return flapThreshold > 0 && flapCount >= flapThreshold
+}
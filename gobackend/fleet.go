@@ -0,0 +1,182 @@
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /fleet rolls every host up into one row for a wall dashboard: open
+// ports, suspicious ones, when it was last scanned and whether its
+// collector is keeping up. Counting is one grouped query, so it stays cheap
+// with dozens of hosts.
+type FleetHost struct {
+	HostID         string     `json:"host_id"`
+	OpenPorts      int        `json:"open_ports"`
+	TCP            int        `json:"tcp"`
+	UDP            int        `json:"udp"`
+	Suspicious     int        `json:"suspicious"`
+	Ghosts         int        `json:"ghosts"`
+	Drifting       int        `json:"drifting"`
+	LastScanAt     *time.Time `json:"last_scan_at"`
+	LastScanAgeSec int64      `json:"last_scan_age_seconds"`
+	AgentStatus    string     `json:"agent_status"` // online, stale, offline, paused, error
+}
+
+type FleetTotals struct {
+	Hosts      int `json:"hosts"`
+	Offline    int `json:"offline"` // stale, offline or failing
+	OpenPorts  int `json:"open_ports"`
+	TCP        int `json:"tcp"`
+	UDP        int `json:"udp"`
+	Suspicious int `json:"suspicious"`
+	Ghosts     int `json:"ghosts"`
+	Drifting   int `json:"drifting"`
+}
+
+type FleetOverview struct {
+	Hosts       []FleetHost `json:"hosts"`
+	Totals      FleetTotals `json:"totals"`
+	GeneratedAt time.Time   `json:"generated_at"`
+}
+
+// A host whose ports haven't been confirmed for this long is stale, and
+// offline after fleetOfflineAfter. Both stretch in power save (power.go).
+var (
+	fleetStaleAfter   = envDuration("PORTMONOTE_FLEET_STALE_AFTER", 3*time.Minute)
+	fleetOfflineAfter = envDuration("PORTMONOTE_FLEET_OFFLINE_AFTER", 15*time.Minute)
+)
+
+// aggTime scans MAX()/MIN() of a timestamp column, which SQLite returns as
+// text because the aggregate has no declared type.
+type aggTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (t *aggTime) Scan(v any) error {
+	switch v := v.(type) {
+	case nil:
+		t.Valid = false
+		return nil
+	case time.Time:
+		t.Time, t.Valid = v, true
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	}
+	return fmt.Errorf("aggTime: unsupported type %T", v)
+}
+
+func (t aggTime) Value() (driver.Value, error) {
+	if !t.Valid {
+		return nil, nil
+	}
+	return t.Time, nil
+}
+
+func (t *aggTime) parse(s string) error {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999"} {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			t.Time, t.Valid = parsed, true
+			return nil
+		}
+	}
+	return fmt.Errorf("aggTime: cannot parse %q", s)
+}
+
+func getFleet(c *gin.Context) {
+	now := time.Now()
+	var rows []struct {
+		HostID     string
+		OpenPorts  int
+		TCP        int
+		UDP        int
+		Suspicious int
+		Ghosts     int
+		Drifting   int
+		LastScanAt aggTime
+	}
+	err := DB.Model(&PortRuntime{}).
+		Select(`port_runtime.host_id,
+			SUM(CASE WHEN port_runtime.current_state = 'active' THEN 1 ELSE 0 END) AS open_ports,
+			SUM(CASE WHEN port_runtime.current_state = 'active' AND port_runtime.protocol = 'tcp' THEN 1 ELSE 0 END) AS tcp,
+			SUM(CASE WHEN port_runtime.current_state = 'active' AND port_runtime.protocol = 'udp' THEN 1 ELSE 0 END) AS udp,
+			SUM(CASE WHEN port_runtime.current_state = 'active' AND (port_note.id IS NULL OR port_note.auto_generated OR port_note.risk_level = 'suspicious') THEN 1 ELSE 0 END) AS suspicious,
+			SUM(CASE WHEN port_runtime.current_state = 'disappeared' THEN 1 ELSE 0 END) AS ghosts,
+			SUM(CASE WHEN port_runtime.drift <> '' THEN 1 ELSE 0 END) AS drifting,
+			MAX(port_runtime.last_seen_at) AS last_scan_at`).
+		Joins(noteJoin).
+		Group("port_runtime.host_id").
+		Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	f := FleetOverview{Hosts: []FleetHost{}, GeneratedAt: now}
+	local := collectorState.snapshot()
+	for _, r := range rows {
+		h := FleetHost{
+			HostID: r.HostID, OpenPorts: r.OpenPorts, TCP: r.TCP, UDP: r.UDP,
+			Suspicious: r.Suspicious, Ghosts: r.Ghosts, Drifting: r.Drifting,
+		}
+		if r.LastScanAt.Valid {
+			h.LastScanAt = &r.LastScanAt.Time
+		}
+		if r.HostID == HostID && local.LastRunAt != nil {
+			// The collector's own clock is better than the newest runtime
+			h.LastScanAt = local.LastRunAt
+		}
+		if h.LastScanAt != nil {
+			h.LastScanAgeSec = int64(now.Sub(*h.LastScanAt).Seconds())
+		}
+		h.AgentStatus = fleetStatus(&h, local, now)
+
+		f.Hosts = append(f.Hosts, h)
+		f.Totals.Hosts++
+		if h.AgentStatus != "online" {
+			f.Totals.Offline++
+		}
+		f.Totals.OpenPorts += h.OpenPorts
+		f.Totals.TCP += h.TCP
+		f.Totals.UDP += h.UDP
+		f.Totals.Suspicious += h.Suspicious
+		f.Totals.Ghosts += h.Ghosts
+		f.Totals.Drifting += h.Drifting
+	}
+	// Worst first: that's what a wall dashboard should show at the top
+	sort.SliceStable(f.Hosts, func(i, j int) bool {
+		a, b := f.Hosts[i], f.Hosts[j]
+		if a.Suspicious != b.Suspicious {
+			return a.Suspicious > b.Suspicious
+		}
+		return a.HostID < b.HostID
+	})
+	c.JSON(http.StatusOK, f)
+}
+
+func fleetStatus(h *FleetHost, local CollectorStatus, now time.Time) string {
+	if h.HostID == HostID {
+		switch {
+		case local.Paused:
+			return "paused"
+		case local.LastError != "":
+			return "error"
+		}
+	}
+	staleAfter, offlineAfter := hostStaleness(h.HostID)
+	switch {
+	case h.LastScanAt == nil || now.Sub(*h.LastScanAt) > offlineAfter:
+		return "offline"
+	case now.Sub(*h.LastScanAt) > staleAfter:
+		return "stale"
+	}
+	return "online"
+}
@@ -1,54 +1,157 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
-var CSRF_TOKEN string
-
 func InitHandlers(r *gin.Engine) {
-	// Generate CSRF Token on startup
-	CSRF_TOKEN = uuid.New().String()
-	log.Printf("CSRF Token: %s", CSRF_TOKEN)
-
-	// Middleware for CSRF
-	r.Use(func(c *gin.Context) {
-		// Public routes
-		if c.Request.Method == "GET" || c.Request.URL.Path == "/" {
-			c.Next()
-			return
-		}
+	// CSRF secret persists across restarts (csrf.go)
+	loadCSRFSecrets()
 
-		// Verify Token
-		token := c.GetHeader("X-CSRF-Token")
-		if token != CSRF_TOKEN {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Invalid CSRF Token. Refresh page."})
-			return
-		}
-		c.Next()
-	})
+	// Concurrency and body size limits (limits.go)
+	r.Use(limitsMiddleware())
+
+	// Audit log of mutating requests, refused ones included (audit.go)
+	r.Use(auditMiddleware)
+
+	// Middleware for CSRF / API keys (auth.go)
+	r.Use(authMiddleware)
 
 	// Routes
 	r.GET("/", handleIndex)
 	r.GET("/favicon.ico", handleFavicon)
+	r.GET("/login", handleLoginPage)
+	r.POST("/login", handleLogin)
+	r.POST("/logout", handleLogout)
+	r.GET("/me", handleMe)
+	r.GET("/auth/oidc/login", handleOIDCLogin)
+	r.GET(oidcCallbackPath, handleOIDCCallback)
 
 	r.GET("/ports", getPorts)
+	r.GET("/search", searchPorts)
+	r.GET("/views", listViews)
+	r.POST("/views", createView)
+	r.PUT("/views/:id", updateView)
+	r.DELETE("/views/:id", deleteView)
 	r.GET("/history", getHistory)
+	r.GET("/events", listEvents)
+	r.GET("/diff", getDiff)
+	r.GET("/fleet", getFleet)
+	r.GET("/audit", listAudit)
+	r.GET("/baselines", listBaselines)
+	r.POST("/baselines", createBaseline)
+	r.GET("/baselines/:id", getBaseline)
+	r.GET("/baselines/:id/drift", getBaselineDrift)
+	r.POST("/baselines/:id/activate", activateBaseline)
+	r.POST("/baselines/:id/deactivate", deactivateBaseline)
+	r.DELETE("/baselines/:id", deleteBaseline)
+	r.GET("/deployments", listDeployments)
+	r.POST("/deployments", createDeployment)
+	r.GET("/deployments/:id", getDeployment)
+	r.POST("/deployments/:id/finish", finishDeployment)
+	r.GET("/diagnostics", getDiagnostics)
+	r.GET("/maintenance", listMaintenance)
+	r.POST("/maintenance", createMaintenance)
+	r.GET("/maintenance/:id", getMaintenance)
+	r.PUT("/maintenance/:id", updateMaintenance)
+	r.DELETE("/maintenance/:id", deleteMaintenance)
+	r.GET("/incidents", listIncidents)
+	r.POST("/incidents", createIncident)
+	r.GET("/incidents/:id", getIncident)
+	r.POST("/incidents/:id", updateIncident)
+	r.DELETE("/incidents/:id", deleteIncident)
+	r.DELETE("/incidents/:id/events/:event_id", detachIncidentEvent)
+	r.GET("/diagnoses", listDiagnoses)
+	r.GET("/samples", getSamples)
+	r.GET("/ports/exposure", getExposure)
+	r.GET("/ports/:id/peers", getPortPeers)
+	r.GET("/ports/:id/availability", getAvailability)
+	r.GET("/ports/:id/shares", listShareLinks)
+	r.POST("/ports/:id/share", createShareLink)
+	r.DELETE("/shares/:id", revokeShareLink)
+	r.GET("/share/:token", handleShare)
+	r.GET("/units", getUnits)
+	r.GET("/processes", getProcesses)
+	r.GET("/approvals", listApprovals)
+	r.POST("/approvals/:id/approve", approveRiskChange)
+	r.POST("/approvals/:id/reject", rejectRiskChange)
 	r.POST("/notes", updateNote)
+	r.GET("/notes/:id/history", noteHistory)
+	r.GET("/note-templates", listNoteTemplates)
+	r.POST("/note-templates", createNoteTemplate)
+	r.PUT("/note-templates/:id", updateNoteTemplate)
+	r.DELETE("/note-templates/:id", deleteNoteTemplate)
+	r.POST("/notes/:id/revert/:revision", revertNote)
 	r.DELETE("/ports", deletePort)
+	r.POST("/ports/hidden", setPortHidden)
+	r.POST("/ports/apply", applyToPorts)
+	r.POST("/ports/mute", mutePort)
+	r.DELETE("/ports/mute", unmutePort)
+	r.GET("/mutes", listMutes)
+	r.GET("/applications", listApplications)
+	r.POST("/applications", createApplication)
+	r.GET("/applications/:id", getApplication)
+	r.PUT("/applications/:id", updateApplication)
+	r.DELETE("/applications/:id", deleteApplication)
+	r.GET("/tags", listTags)
+	r.POST("/tags", createTag)
+	r.DELETE("/tags/:id", deleteTag)
 	r.POST("/acknowledge", acknowledgeWarning)
 	r.POST("/trigger-scan", triggerScan)
+	r.GET("/hosts", listHosts)
+	r.GET("/hosts/:id", getHost)
+	r.PUT("/hosts/:id", updateHost)
+	r.POST("/hosts/:id/report", reportHostHandler)
+	r.POST("/hosts/:id/trigger-scan", triggerHostScan)
+	r.POST("/ports/recheck", recheckPort)
+	r.POST("/selftest", handleSelfTest)
+	r.GET("/collector/status", getCollectorStatus)
+	r.GET("/stats/summary", getStatsSummary)
+	r.GET("/stats/timeline", getStatsTimeline)
+	r.GET("/jobs", listJobs)
+	r.GET("/jobs/:id", getJob)
+	r.POST("/collector/pause", pauseCollector)
+	r.POST("/collector/resume", resumeCollector)
 	r.GET("/inspect/:port", runWitr)
+	r.GET("/export", handleExport)
+	r.GET("/reports/latest", getLatestReport)
+	r.GET("/tickets", listTickets)
+	r.GET("/version", getVersion)
+	r.GET("/capabilities", getCapabilities)
+	r.GET("/violations", getViolations)
+	r.GET("/status-page", handleStatusPage)
+	r.GET("/calendar.ics", handleCalendar)
+	r.GET("/events.atom", handleEventsAtom)
+	r.GET("/badge/tag/:tag", handleTagBadge)
+	r.GET("/badge/:host/:proto/:port", handlePortBadge)
+
+	r.POST("/admin/rehost", handleRehost)
+	r.POST("/admin/prune", handlePrune)
+	r.GET("/admin/apikeys", listAPIKeys)
+	r.POST("/admin/apikeys", createAPIKey)
+	r.DELETE("/admin/apikeys/:id", revokeAPIKey)
+	r.GET("/admin/users", listUsers)
+	r.POST("/admin/users/:id/role", updateUserRole)
+	r.DELETE("/admin/users/:id/sessions", logoutUser)
+	r.GET("/admin/sessions", listSessions)
+	r.POST("/admin/jobs/:id/retry", retryJob)
+	r.DELETE("/admin/sessions/:id", revokeSession)
+	r.POST("/admin/csrf/rotate", handleRotateCSRF)
+	r.GET("/admin/notifications", listNotificationConfig)
+	r.POST("/admin/notifications/test", testNotification)
+	r.GET("/admin/device-configs", listDeviceConfigs)
+	r.PUT("/admin/device-configs/:name", putDeviceConfig)
+	r.DELETE("/admin/device-configs/:name", deleteDeviceConfig)
 }
 
 func handleFavicon(c *gin.Context) {
@@ -79,7 +182,7 @@ func handleIndex(c *gin.Context) {
 
 	// Inject Token
 	html := string(content)
-	injection := `<script>window.PORTMONOTE_CSRF_TOKEN = "` + CSRF_TOKEN + `";</script>`
+	injection := `<script>window.PORTMONOTE_CSRF_TOKEN = "` + issueCSRFToken(c) + `";</script>`
 	if strings.Contains(html, "<head>") {
 		html = strings.Replace(html, "<head>", "<head>\n"+injection, 1)
 	} else {
@@ -94,12 +197,69 @@ func getPorts(c *gin.Context) {
 	var runtimes []PortRuntime
 	var notes []PortNote
 
-	DB.Find(&runtimes)
-	DB.Find(&notes)
+	// ?q=status:suspicious proto:tcp port:>1024 (query.go)
+	query, err := parsePortQuery(c.Query("q"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q: " + err.Error()})
+		return
+	}
+	// ?sort=-last_seen,port (views.go)
+	order, err := parsePortSort(c.Query("sort"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort: " + err.Error()})
+		return
+	}
+	if err := query.runtimeScope(DB).Find(&runtimes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	query.noteScope(DB).Find(&notes)
 
+	for i := range notes {
+		revealNote(c, &notes[i])
+	}
+	result := mergePortItems(runtimes, notes)
+	if tag := c.Query("tag"); tag != "" {
+		tagged := map[uint]bool{}
+		for _, n := range notesTagged(tag) {
+			tagged[n.ID] = true
+		}
+		result = slices.DeleteFunc(result, func(item MergedPortItem) bool { return !tagged[item.NoteID] })
+	}
+	if statuses := splitList(c.Query("status")); len(statuses) > 0 {
+		result = slices.DeleteFunc(result, func(item MergedPortItem) bool { return !slices.Contains(statuses, item.DerivedStatus) })
+	}
+	result = query.filter(result)
+	if c.Query("include_hidden") != "true" && !query.mentions("hidden") {
+		result = slices.DeleteFunc(result, func(item MergedPortItem) bool { return item.Hidden })
+	}
+	if order != nil {
+		slices.SortStableFunc(result, order)
+	}
+
+	// Get latest event type (lazy load or join query preferred, but simple loop ok for small tool)
+	now := time.Now()
+	for i := range result {
+		item := &result[i]
+		if item.RuntimeID != 0 {
+			var evt PortEvent
+			// Get latest event
+			if err := DB.Where("port_runtime_id = ?", item.RuntimeID).Order("timestamp desc").First(&evt).Error; err == nil {
+				applyAcknowledgement(item, evt, now)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// mergePortItems joins runtimes and notes on (host_id, protocol, port) and
+// derives the status of each item.
+func mergePortItems(runtimes []PortRuntime, notes []PortNote) []MergedPortItem {
 	// Merge logic (host_id, protocol, port)
 	// Similar to Python map logic
 	mergedMap := make(map[string]*MergedPortItem)
+	now := time.Now()
 
 	// 1. Process Runtimes
 	for _, r := range runtimes {
@@ -114,14 +274,35 @@ func getPorts(c *gin.Context) {
 			CurrentPID:        r.CurrentPID,
 			ProcessName:       r.ProcessName,
 			Cmdline:           r.Cmdline,
+			PodName:           r.PodName,
+			PodNamespace:      r.PodNamespace,
+			PodContainer:      r.PodContainer,
+			CPUPercent:        r.CPUPercent,
+			RSSBytes:          r.RSSBytes,
+			Runaway:           r.Runaway,
+			WireProtocol:      r.WireProtocol,
+			HTTPStatus:        r.HTTPStatus,
+			HTTPServer:        r.HTTPServer,
+			HTTPTitle:         r.HTTPTitle,
+			Username:          r.Username,
+			ExePath:           r.ExePath,
+			ExeSHA256:         r.ExeSHA256,
+			SystemdUnit:       r.SystemdUnit,
+			SystemdSlice:      r.SystemdSlice,
+			ConnCount:         r.ConnCount,
+			PeakConnCount:     r.PeakConnCount,
+			FlapCount:         currentFlapCount(&r, now),
+			Drift:             r.Drift,
+			Hidden:            r.Hidden,
 			RiskLevel:         "unknown",
 			DerivedStatus:     "unknown",
 		}
 		// Calculate UptimeHuman if active
 		if r.CurrentState == "active" {
-			duration := time.Since(r.FirstSeenAt)
-			item.UptimeHuman = formatDuration(duration)
+			item.UptimeHuman = formatDuration(now.Sub(upSince(&r)))
 		}
+		item.UptimeSeconds = r.TotalUptimeSeconds
+		item.DowntimeSeconds, item.UptimePercent = uptimeStats(&r, now)
 		mergedMap[key] = item
 	}
 
@@ -132,41 +313,53 @@ func getPorts(c *gin.Context) {
 			item.NoteID = n.ID
 			item.Title = n.Title
 			item.Description = n.Description
+			item.Sensitive = n.Sensitive
+			item.DescriptionRedacted = n.DescriptionRedacted
 			item.Owner = n.Owner
 			item.RiskLevel = n.RiskLevel
 			item.IsPinned = n.IsPinned
+			item.NoteUpdatedBy = n.UpdatedBy
+			item.NoteVersion = n.Version
+			item.NoteAuto = n.AutoGenerated
+			item.ReviewAfter = n.ReviewAfter
+			item.Tags = n.Tags
+			item.NotifyMuted = n.NotifyMuted
+			item.NotifyEvents = n.NotifyEvents
+			item.NotifyChannels = n.NotifyChannels
+			item.TLSMismatch = tlsMismatch(item.WireProtocol, n)
 		} else {
 			// Note without runtime (Ghost/Forgotten)
 			mergedMap[key] = &MergedPortItem{
 				HostID: n.HostID, Protocol: n.Protocol, Port: n.Port,
-				NoteID:        n.ID,
-				Title:         n.Title,
-				Description:   n.Description,
-				Owner:         n.Owner,
-				RiskLevel:     n.RiskLevel,
-				IsPinned:      n.IsPinned,
-				DerivedStatus: "unknown",
+				NoteID:              n.ID,
+				Title:               n.Title,
+				Description:         n.Description,
+				Sensitive:           n.Sensitive,
+				DescriptionRedacted: n.DescriptionRedacted,
+				Owner:               n.Owner,
+				RiskLevel:           n.RiskLevel,
+				IsPinned:            n.IsPinned,
+				NoteVersion:         n.Version,
+				NoteAuto:            n.AutoGenerated,
+				ReviewAfter:         n.ReviewAfter,
+				Tags:                n.Tags,
+				NotifyMuted:         n.NotifyMuted,
+				DerivedStatus:       "unknown",
 			}
 		}
 	}
 
-	// 3. Finalize Status & Events
+	// 3. Finalize Status
+	mutes := activeMutes(now)
 	result := make([]MergedPortItem, 0, len(mergedMap))
-	for _, item := range mergedMap {
-		calculateStatus(item)
-		// Get latest event type (lazy load or join query preferred, but simple loop ok for small tool)
-		if item.RuntimeID != 0 {
-			var evt PortEvent
-			// Get latest event
-			if err := DB.Where("port_runtime_id = ?", item.RuntimeID).Order("timestamp desc").First(&evt).Error; err == nil {
-				item.LatestEventType = evt.EventType
-				item.LatestEventTimestamp = &evt.Timestamp
-			}
+	for key, item := range mergedMap {
+		if m, ok := mutes[key]; ok {
+			item.MutedUntil = &m.Until
 		}
+		calculateStatus(item)
 		result = append(result, *item)
 	}
-
-	c.JSON(http.StatusOK, result)
+	return result
 }
 
 func getHistory(c *gin.Context) {
@@ -199,10 +392,30 @@ func updateNote(c *gin.Context) {
 		return
 	}
 
+	var reviewAfter *time.Time
+	if req.ReviewAfter != nil {
+		t, err := parseReviewAfter(*req.ReviewAfter, time.Now())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		reviewAfter = t
+	}
+
 	var note PortNote
 	err := DB.Where("host_id = ? AND protocol = ? AND port = ?", hostID, proto, port).First(&note).Error
+	prev := note
 
-	if err != nil {
+	if err == nil {
+		if req.Version == nil {
+			noteConflict(c, http.StatusPreconditionRequired, hostID, proto, port)
+			return
+		}
+		if *req.Version != note.Version {
+			noteConflict(c, http.StatusConflict, hostID, proto, port)
+			return
+		}
+	} else {
 		// Create new
 		note = PortNote{
 			HostID: hostID, Protocol: proto, Port: port,
@@ -214,20 +427,97 @@ func updateNote(c *gin.Context) {
 	if req.Title != nil {
 		note.Title = *req.Title
 	}
-	if req.Description != nil {
-		note.Description = *req.Description
+	if req.Description != nil || req.Sensitive != nil {
+		// Users who can't read a sensitive description can't change it
+		// or its flag either; the redacted form they saw is not an edit.
+		if !note.Sensitive || canReadSensitive(c) {
+			plain, err := decryptNoteText(note.Description)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot decrypt description: " + err.Error()})
+				return
+			}
+			if req.Description != nil {
+				plain = *req.Description
+			}
+			if req.Sensitive != nil && canReadSensitive(c) {
+				note.Sensitive = *req.Sensitive
+			}
+			note.Description = plain
+			if note.Sensitive {
+				if note.Description, err = encryptNoteText(plain); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+			}
+		}
 	}
 	if req.Owner != nil {
 		note.Owner = *req.Owner
 	}
+	var approval *RiskApproval
 	if req.RiskLevel != nil {
-		note.RiskLevel = *req.RiskLevel
+		if needsApproval(note.RiskLevel, *req.RiskLevel) {
+			a, err := requestRiskApproval(note, *req.RiskLevel, actorName(c), actorOwner(c))
+			if err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			approval = &a // other fields still apply; the risk waits
+		} else {
+			note.RiskLevel = *req.RiskLevel
+		}
 	}
 	if req.IsPinned != nil {
 		note.IsPinned = *req.IsPinned
 	}
+	if req.Tags != nil {
+		note.Tags = strings.Join(splitList(*req.Tags), ",")
+	}
+	if req.NotifyMuted != nil {
+		note.NotifyMuted = *req.NotifyMuted
+	}
+	if req.NotifyEvents != nil {
+		note.NotifyEvents = strings.Join(splitList(*req.NotifyEvents), ",")
+	}
+	if req.NotifyChannels != nil {
+		note.NotifyChannels = strings.Join(splitList(*req.NotifyChannels), ",")
+	}
+	if req.ReviewAfter != nil {
+		note.ReviewAfter = reviewAfter
+	} else if note.RiskLevel == string(RiskTrusted) && prev.RiskLevel != string(RiskTrusted) {
+		note.ReviewAfter = defaultReviewAfter(time.Now())
+	}
+	note.UpdatedBy = actorName(c)
+	note.AutoGenerated = false // saved by a person: confirmed
 
-	DB.Save(&note)
+	if err := saveNote(DB, &note); errors.Is(err, errNoteConflict) {
+		noteConflict(c, http.StatusConflict, hostID, proto, port)
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := recordNoteRevision(DB, prev, note, note.UpdatedBy); err != nil {
+		log.Println("Error recording note revision:", err)
+	}
+	if prev.ID != 0 {
+		auditValues(c, prev, note)
+	} else {
+		auditValues(c, nil, note)
+	}
+	if req.Tags != nil {
+		if err := syncNoteTags(DB, &note); err != nil {
+			log.Println("Error linking note tags:", err)
+		}
+	}
+	saved := note
+	publish(BusEvent{Topic: TopicNoteEdited, Note: &saved, Actor: note.UpdatedBy})
+	if approval != nil {
+		revealNote(c, &note)
+		c.JSON(http.StatusAccepted, gin.H{"note": note, "pending_approval": approval})
+		return
+	}
+	revealNote(c, &note)
 	c.JSON(http.StatusOK, note)
 }
 
@@ -237,94 +527,56 @@ func deletePort(c *gin.Context) {
 	portStr := c.Query("port")
 	port, _ := strconv.Atoi(portStr)
 
+	var oldRuntime PortRuntime
+	var oldNote PortNote
+	DB.Where("host_id = ? AND protocol = ? AND port = ?", hostID, proto, port).First(&oldRuntime)
+	DB.Where("host_id = ? AND protocol = ? AND port = ?", hostID, proto, port).First(&oldNote)
+	auditValues(c, gin.H{"runtime": oldRuntime, "note": oldNote}, nil)
+
+	// Archive by default so a reinstall can inherit note and history (only
+	// of a port that is gone: a live one comes back fresh and unreviewed on
+	// the next scan); ?purge=true removes the rows for good.
+	tx := DB
+	status := "deleted"
+	if c.Query("purge") == "true" {
+		tx = DB.Unscoped()
+		status = "purged"
+
+		runtimeIDs := tx.Model(&PortRuntime{}).Select("id").Where("host_id = ? AND protocol = ? AND port = ?", hostID, proto, port)
+		DB.Where("port_runtime_id IN (?)", runtimeIDs).Delete(&PortEvent{})
+	}
+
 	// Delete Runtime
-	DB.Where("host_id = ? AND protocol = ? AND port = ?", hostID, proto, port).Delete(&PortRuntime{})
+	tx.Where("host_id = ? AND protocol = ? AND port = ?", hostID, proto, port).Delete(&PortRuntime{})
 	// Delete Note
-	DB.Where("host_id = ? AND protocol = ? AND port = ?", hostID, proto, port).Delete(&PortNote{})
+	tx.Where("host_id = ? AND protocol = ? AND port = ?", hostID, proto, port).Delete(&PortNote{})
 
-	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+	c.JSON(http.StatusOK, gin.H{"status": status})
 }
 
-func acknowledgeWarning(c *gin.Context) {
-	hostID := c.Query("host_id")
-	proto := c.Query("protocol")
-	portStr := c.Query("port")
-	port, _ := strconv.Atoi(portStr)
-
-	var runtime PortRuntime
-	if err := DB.Where("host_id = ? AND protocol = ? AND port = ?", hostID, proto, port).First(&runtime).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Runtime not found"})
+func triggerScan(c *gin.Context) {
+	if collectorState.snapshot().Running {
+		c.JSON(http.StatusOK, gin.H{"status": "already_running"})
 		return
 	}
-
-	// Add Acknowledged Event
-	evt := PortEvent{
-		PortRuntimeID: runtime.ID,
-		EventType:     "acknowledged",
-		Timestamp:     time.Now(),
-		PID:           runtime.CurrentPID,
-		ProcessName:   runtime.ProcessName,
-	}
-	DB.Create(&evt)
-	c.JSON(http.StatusOK, gin.H{"status": "acknowledged"})
-}
-
-func triggerScan(c *gin.Context) {
 	go RunCollectionCycle()
 	c.JSON(http.StatusOK, gin.H{"status": "triggered"})
 }
 
-func runWitr(c *gin.Context) {
-	portStr := c.Param("port")
-	// Use exec.Command
-	// Security: Validate port is integer
-	if _, err := strconv.Atoi(portStr); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid port"})
-		return
-	}
-
-	path, err := exec.LookPath("witr")
-	if err != nil {
-		c.JSON(http.StatusOK, gin.H{"output": "witr not found on path", "error": true})
-		return
-	}
+// Helpers
 
-	cmd := exec.Command(path, "--port", portStr)
-	// Timeout logic?
-	out, err := cmd.CombinedOutput()
-	output := string(out)
+// paramID parses the numeric :id path parameter, answering 400 if it isn't
+// one. Never hand the raw parameter to First/Delete: GORM runs a string
+// condition as SQL.
+func paramID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		output += "\nError: " + err.Error()
-	}
-
-	// Save to History (DB)
-	// 1. Find active runtime for this port (HostID=local, Protocol=?, Port=?)
-	// Note: runWitr assumes TCP/UDP? Witr tool usually autodetects or we assume from context.
-	// Since runWitr param is only :port, we might have ambiguity if same port on tcp/udp.
-	// But usually it's unique enough or we pick the first active one.
-	portNum, _ := strconv.Atoi(portStr)
-	var runtime PortRuntime
-	// Try to find the active runtime associated with this port
-	if err := DB.Where("host_id = ? AND port = ? AND current_state = ?", "local", portNum, "active").First(&runtime).Error; err == nil {
-		// Create Event
-		evt := PortEvent{
-			PortRuntimeID: runtime.ID,
-			EventType:     string(EventDiagnosis),
-			Timestamp:     time.Now(),
-			PID:           runtime.CurrentPID,
-			ProcessName:   runtime.ProcessName,
-			WitrOutput:    output,
-		}
-		DB.Create(&evt)
-	} else {
-		// Log error or ignore if not found (maybe ghost port?)
-		log.Printf("Could not log witr event for port %d: %v", portNum, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		return 0, false
 	}
-
-	c.JSON(http.StatusOK, gin.H{"output": output, "error": err != nil})
+	return uint(id), true
 }
 
-// Helpers
 func fmtKey(h, p string, port int) string {
 	return h + "_" + p + "_" + strconv.Itoa(port)
 }
@@ -334,27 +586,28 @@ func calculateStatus(item *MergedPortItem) {
 
 	isActive := item.CurrentState == "active"
 	isDisappeared := item.CurrentState == "disappeared"
-	hasNote := item.NoteID != 0
-	isTrusted := hasNote && item.RiskLevel == "trusted"
+	hasNote := item.NoteID != 0 && !item.NoteAuto // a guessed title is no review
 
 	if isActive {
-		if isTrusted {
-			item.DerivedStatus = "healthy"
-			return
-		}
-		if !hasNote {
-			item.DerivedStatus = "suspicious"
-			return
-		}
-		if item.RiskLevel == "suspicious" {
+		if !hasNote || item.RiskLevel == "suspicious" {
 			item.DerivedStatus = "suspicious"
+			if item.MutedUntil != nil {
+				item.DerivedStatus = "muted" // snoozed (mute.go)
+			}
 			return
 		}
-		item.DerivedStatus = "healthy" // Default active+note
+		item.DerivedStatus = "healthy" // Default active+note, trusted included
 	} else if isDisappeared {
 		// ghost logic
 		item.DerivedStatus = "ghost"
 	}
+	// Unstable beats healthy/ghost, but suspicious stays on top
+	if item.DerivedStatus != "suspicious" && isFlapping(item.FlapCount) {
+		item.DerivedStatus = "flapping"
+	}
+	if item.DerivedStatus == "healthy" && reviewOverdue(item.ReviewAfter, time.Now()) {
+		item.DerivedStatus = StatusNeedsReview // trust expires too (review.go)
+	}
 }
 
 func formatDuration(d time.Duration) string {
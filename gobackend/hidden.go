@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Hidden ports are well-understood noise (sshd, systemd-resolved, ...) that
+// nobody wants to look at again. Unlike deleting, hiding keeps collecting
+// and notifying as usual; the port is only left out of GET /ports unless
+// ?include_hidden=true.
+//
+//	POST /ports/hidden?host_id=local&protocol=tcp&port=53 {"hidden": true}
+func setPortHidden(c *gin.Context) {
+	hostID := c.Query("host_id")
+	proto := c.Query("protocol")
+	port, _ := strconv.Atoi(c.Query("port"))
+
+	var req struct {
+		Hidden *bool `json:"hidden"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Hidden == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hidden is required"})
+		return
+	}
+
+	var runtime PortRuntime
+	if err := DB.Where("host_id = ? AND protocol = ? AND port = ?", hostID, proto, port).First(&runtime).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Runtime not found"})
+		return
+	}
+	hiddenBy := ""
+	if *req.Hidden {
+		hiddenBy = actorName(c)
+	}
+	if err := DB.Model(&runtime).Updates(map[string]any{"hidden": *req.Hidden, "hidden_by": hiddenBy}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"hidden": *req.Hidden})
+}
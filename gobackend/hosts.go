@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirou/gopsutil/v4/host"
+)
+
+// Hosts are the machines ports are recorded for. The local collector
+// reports its own host and the SNMP targets it polled every cycle; remote
+// agents report through POST /hosts/:id/report. A host that hasn't reported
+// for PORTMONOTE_FLEET_OFFLINE_AFTER is marked offline by the "host_offline"
+// job and back online by its next report.
+//
+//	GET  /hosts                 all hosts with status and open port count
+//	GET  /hosts/:id
+//	PUT  /hosts/:id             {"display_name": "...", "tags": "prod,db"}
+//	POST /hosts/:id/report      {"display_name", "os", "ips": "10.0.0.5,fe80::1"}
+//
+// The report's answer carries "recheck": true once after someone asked for
+// an on-demand scan of the host (recheck.go); the agent should scan and
+// report its ports right away.
+type Host struct {
+	HostID       string     `gorm:"primaryKey;size:128" json:"host_id"`
+	DisplayName  string     `json:"display_name,omitempty"`
+	OS           string     `json:"os,omitempty"`
+	IPs          string     `json:"ips,omitempty"`  // comma-separated
+	Tags         string     `json:"tags,omitempty"` // comma-separated
+	Source       string     `json:"source"`         // local, snmp or agent
+	Offline      bool       `json:"offline"`
+	OfflineSince *time.Time `json:"offline_since,omitempty"`
+	LastReportAt *time.Time `json:"last_report_at"`
+	RecheckAt    *time.Time `json:"recheck_requested_at,omitempty"` // pending on-demand scan
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// Filled in for responses
+	Status    string `gorm:"-" json:"status"` // online, stale, offline
+	OpenPorts int    `gorm:"-" json:"open_ports"`
+	Recheck   bool   `gorm:"-" json:"recheck,omitempty"`
+}
+
+func (Host) TableName() string {
+	return "host"
+}
+
+// reportHost records a report from a host: reported metadata replaces the
+// stored one, and a host marked offline comes back online. The display name
+// is only taken when none is set, so renames made in the UI stick.
+func reportHost(id, source string, meta Host) error {
+	now := time.Now()
+	h := Host{HostID: id}
+	if err := DB.Where("host_id = ?", id).FirstOrInit(&h).Error; err != nil {
+		return err
+	}
+	if h.Offline {
+		log.Printf("📶 Host %s is reporting again", id)
+	}
+	if h.DisplayName == "" {
+		h.DisplayName = meta.DisplayName
+	}
+	if meta.OS != "" {
+		h.OS = meta.OS
+	}
+	if meta.IPs != "" {
+		h.IPs = meta.IPs
+	}
+	h.Source, h.Offline, h.OfflineSince, h.LastReportAt = source, false, nil, &now
+	return DB.Save(&h).Error
+}
+
+var (
+	localOSOnce sync.Once
+	localOS     string
+)
+
+// localHostMeta describes the machine the collector runs on.
+func localHostMeta() Host {
+	localOSOnce.Do(func() {
+		localOS = runtime.GOOS
+		if info, err := host.Info(); err == nil && info.Platform != "" {
+			localOS = strings.TrimSpace(fmt.Sprintf("%s %s %s", info.OS, info.Platform, info.PlatformVersion))
+		}
+	})
+	meta := Host{OS: localOS}
+	meta.DisplayName, _ = os.Hostname()
+	var ips []string
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && !ipnet.IP.IsLinkLocalUnicast() {
+				ips = append(ips, ipnet.IP.String())
+			}
+		}
+	}
+	meta.IPs = strings.Join(ips, ",")
+	return meta
+}
+
+// reportScannedHosts records the hosts a collection cycle reached.
+func reportScannedHosts(polled []string) {
+	if err := reportHost(HostID, "local", localHostMeta()); err != nil {
+		log.Println("Error recording local host:", err)
+	}
+	for _, t := range snmpTargets {
+		if slices.Contains(polled, t.Name) {
+			if err := reportHost(t.Name, "snmp", Host{OS: "snmp", IPs: t.Host}); err != nil {
+				log.Println("Error recording SNMP host:", err)
+			}
+		}
+	}
+}
+
+// markOfflineHosts flags hosts that stopped reporting.
+func markOfflineHosts(now time.Time) (int, error) {
+	var stale []Host
+	if err := DB.Where("offline = ? AND last_report_at < ?", false, now.Add(-fleetOfflineAfter)).Find(&stale).Error; err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, h := range stale {
+		if _, offlineAfter := hostStaleness(h.HostID); now.Sub(*h.LastReportAt) <= offlineAfter {
+			continue // scanned less often in power save
+		}
+		n++
+		log.Printf("📴 Host %s stopped reporting (last report %s)", h.HostID, h.LastReportAt.Format(time.RFC3339))
+		if err := DB.Model(&h).Updates(map[string]any{"offline": true, "offline_since": now}).Error; err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func init() {
+	registerJob("host_offline", JobKind{Run: func(ctx context.Context, _ string) (string, error) {
+		n, err := markOfflineHosts(time.Now())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d marked offline", n), nil
+	}})
+}
+
+// startHostOfflineCheck schedules the offline sweep.
+func startHostOfflineCheck(ctx context.Context) {
+	scheduleJob(ctx, "host_offline", time.Minute)
+}
+
+// backfillHosts registers hosts that have ports but no host row yet
+// (databases from before the registry). Runs at startup.
+func backfillHosts() {
+	var rows []struct {
+		HostID   string
+		LastSeen aggTime
+	}
+	DB.Model(&PortRuntime{}).
+		Select("host_id, MAX(last_seen_at) AS last_seen").
+		Where("host_id NOT IN (?)", DB.Model(&Host{}).Select("host_id")).
+		Group("host_id").
+		Scan(&rows)
+	for _, r := range rows {
+		h := Host{HostID: r.HostID, Source: "agent"}
+		if r.HostID == HostID {
+			h.Source = "local"
+		}
+		if r.LastSeen.Valid {
+			h.LastReportAt = &r.LastSeen.Time
+		}
+		if err := DB.Create(&h).Error; err != nil {
+			log.Println("Error registering host:", err)
+			return
+		}
+	}
+	if len(rows) > 0 {
+		log.Printf("🖥️ Registered %d host(s) from recorded ports", len(rows))
+	}
+}
+
+// withStatus fills in status and open port counts.
+func withStatus(hosts []Host) {
+	var counts []struct {
+		HostID string
+		Count  int
+	}
+	DB.Model(&PortRuntime{}).
+		Select("host_id, COUNT(*) AS count").
+		Where("current_state = ?", StateActive).
+		Group("host_id").
+		Scan(&counts)
+	now := time.Now()
+	for i := range hosts {
+		h := &hosts[i]
+		for _, n := range counts {
+			if n.HostID == h.HostID {
+				h.OpenPorts = n.Count
+			}
+		}
+		staleAfter, offlineAfter := hostStaleness(h.HostID)
+		switch {
+		case h.Offline || h.LastReportAt == nil || now.Sub(*h.LastReportAt) > offlineAfter:
+			h.Status = "offline"
+		case now.Sub(*h.LastReportAt) > staleAfter:
+			h.Status = "stale"
+		default:
+			h.Status = "online"
+		}
+	}
+}
+
+// GET /hosts
+func listHosts(c *gin.Context) {
+	var hosts []Host
+	DB.Order("host_id").Find(&hosts)
+	if hosts == nil {
+		hosts = []Host{}
+	}
+	withStatus(hosts)
+	c.JSON(http.StatusOK, hosts)
+}
+
+// GET /hosts/:id
+func getHost(c *gin.Context) {
+	var h Host
+	if err := DB.Where("host_id = ?", c.Param("id")).First(&h).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Host not found"})
+		return
+	}
+	hosts := []Host{h}
+	withStatus(hosts)
+	c.JSON(http.StatusOK, hosts[0])
+}
+
+type HostUpdateRequest struct {
+	DisplayName *string `json:"display_name"`
+	Tags        *string `json:"tags"`
+}
+
+// PUT /hosts/:id edits the metadata people own: display name and tags.
+func updateHost(c *gin.Context) {
+	var h Host
+	if err := DB.Where("host_id = ?", c.Param("id")).First(&h).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Host not found"})
+		return
+	}
+	var req HostUpdateRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.DisplayName != nil {
+		h.DisplayName = strings.TrimSpace(*req.DisplayName)
+	}
+	if req.Tags != nil {
+		h.Tags = strings.Join(splitList(*req.Tags), ",")
+	}
+	if err := DB.Save(&h).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	hosts := []Host{h}
+	withStatus(hosts)
+	c.JSON(http.StatusOK, hosts[0])
+}
+
+// POST /hosts/:id/report is an agent's heartbeat. The first report
+// registers the host.
+func reportHostHandler(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" || len(id) > 128 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid host id"})
+		return
+	}
+	if id == HostID {
+		c.JSON(http.StatusConflict, gin.H{"error": "The local host is reported by this server's collector"})
+		return
+	}
+	var meta Host
+	if err := c.ShouldBindJSON(&meta); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	meta.IPs = strings.Join(splitList(meta.IPs), ",")
+	if err := reportHost(id, "agent", meta); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var h Host
+	DB.Where("host_id = ?", id).First(&h)
+	if h.RecheckAt != nil {
+		h.Recheck, h.RecheckAt = true, nil
+		DB.Model(&h).Update("recheck_at", nil)
+	}
+	hosts := []Host{h}
+	withStatus(hosts)
+	c.JSON(http.StatusOK, hosts[0])
+}
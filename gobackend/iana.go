@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Auto-notes: with PORTMONOTE_AUTO_NOTES=true a port appearing for the
+// first time without a note gets one titled after its well-known service
+// (22 -> "SSH", 5432 -> "PostgreSQL"). The guess is only a title: the note
+// is flagged auto_generated and the port stays suspicious until someone
+// saves the note, which confirms it.
+//
+// The built-in table below covers the common IANA assignments and de facto
+// ports; PORTMONOTE_SERVICES_FILE adds or overrides entries from a file in
+// /etc/services format ("name  port/proto  [aliases]  # comment").
+var (
+	autoNotes        = envBool("PORTMONOTE_AUTO_NOTES", false)
+	servicesFilePath = envString("PORTMONOTE_SERVICES_FILE", "")
+)
+
+// wellKnownService is an entry of the dataset. Proto "" matches tcp and udp.
+type wellKnownService struct {
+	Port  int
+	Proto string
+	Name  string
+}
+
+var wellKnownServices = []wellKnownService{
+	{7, "", "Echo"},
+	{20, "tcp", "FTP data"},
+	{21, "tcp", "FTP"},
+	{22, "tcp", "SSH"},
+	{23, "tcp", "Telnet"},
+	{25, "tcp", "SMTP"},
+	{53, "", "DNS"},
+	{67, "udp", "DHCP server"},
+	{68, "udp", "DHCP client"},
+	{69, "udp", "TFTP"},
+	{80, "tcp", "HTTP"},
+	{88, "", "Kerberos"},
+	{110, "tcp", "POP3"},
+	{111, "", "RPC portmapper"},
+	{119, "tcp", "NNTP"},
+	{123, "udp", "NTP"},
+	{135, "tcp", "MS RPC"},
+	{137, "udp", "NetBIOS name"},
+	{138, "udp", "NetBIOS datagram"},
+	{139, "tcp", "NetBIOS session"},
+	{143, "tcp", "IMAP"},
+	{161, "udp", "SNMP"},
+	{162, "udp", "SNMP trap"},
+	{179, "tcp", "BGP"},
+	{389, "", "LDAP"},
+	{443, "tcp", "HTTPS"},
+	{443, "udp", "HTTP/3 (QUIC)"},
+	{445, "tcp", "SMB"},
+	{465, "tcp", "SMTP over TLS"},
+	{500, "udp", "IPsec IKE"},
+	{514, "udp", "Syslog"},
+	{515, "tcp", "LPD printing"},
+	{546, "udp", "DHCPv6 client"},
+	{547, "udp", "DHCPv6 server"},
+	{548, "tcp", "AFP"},
+	{554, "", "RTSP"},
+	{587, "tcp", "SMTP submission"},
+	{631, "", "IPP / CUPS"},
+	{636, "tcp", "LDAPS"},
+	{853, "", "DNS over TLS"},
+	{873, "tcp", "rsync"},
+	{989, "tcp", "FTPS data"},
+	{990, "tcp", "FTPS"},
+	{993, "tcp", "IMAPS"},
+	{995, "tcp", "POP3S"},
+	{1080, "tcp", "SOCKS proxy"},
+	{1194, "", "OpenVPN"},
+	{1433, "tcp", "Microsoft SQL Server"},
+	{1521, "tcp", "Oracle database"},
+	{1701, "udp", "L2TP"},
+	{1723, "tcp", "PPTP"},
+	{1812, "udp", "RADIUS"},
+	{1813, "udp", "RADIUS accounting"},
+	{1883, "tcp", "MQTT"},
+	{1900, "udp", "SSDP / UPnP"},
+	{2049, "", "NFS"},
+	{2375, "tcp", "Docker API"},
+	{2376, "tcp", "Docker API (TLS)"},
+	{2379, "tcp", "etcd client"},
+	{2380, "tcp", "etcd peer"},
+	{3000, "tcp", "Grafana / dev server"},
+	{3128, "tcp", "Squid proxy"},
+	{3306, "tcp", "MySQL"},
+	{3389, "", "RDP"},
+	{3478, "", "STUN / TURN"},
+	{4369, "tcp", "Erlang port mapper"},
+	{4500, "udp", "IPsec NAT traversal"},
+	{5000, "tcp", "Docker registry / dev server"},
+	{5044, "tcp", "Logstash Beats"},
+	{5060, "", "SIP"},
+	{5061, "tcp", "SIP over TLS"},
+	{5353, "udp", "mDNS"},
+	{5355, "udp", "LLMNR"},
+	{5432, "tcp", "PostgreSQL"},
+	{5601, "tcp", "Kibana"},
+	{5672, "tcp", "AMQP (RabbitMQ)"},
+	{5900, "tcp", "VNC"},
+	{5984, "tcp", "CouchDB"},
+	{6379, "tcp", "Redis"},
+	{6443, "tcp", "Kubernetes API"},
+	{6514, "tcp", "Syslog over TLS"},
+	{6660, "tcp", "IRC"},
+	{6667, "tcp", "IRC"},
+	{6697, "tcp", "IRC over TLS"},
+	{7000, "tcp", "Cassandra"},
+	{7001, "tcp", "Cassandra (TLS)"},
+	{8000, "tcp", "HTTP (alternate)"},
+	{8008, "tcp", "HTTP (alternate)"},
+	{8080, "tcp", "HTTP proxy / alternate"},
+	{8086, "tcp", "InfluxDB"},
+	{8088, "tcp", "HTTP (alternate)"},
+	{8200, "tcp", "Vault"},
+	{8300, "tcp", "Consul server"},
+	{8301, "", "Consul LAN gossip"},
+	{8443, "tcp", "HTTPS (alternate)"},
+	{8500, "tcp", "Consul HTTP"},
+	{8883, "tcp", "MQTT over TLS"},
+	{8888, "tcp", "HTTP (alternate)"},
+	{9000, "tcp", "PHP-FPM / MinIO"},
+	{9042, "tcp", "Cassandra CQL"},
+	{9090, "tcp", "Prometheus"},
+	{9092, "tcp", "Kafka"},
+	{9093, "tcp", "Alertmanager"},
+	{9100, "tcp", "Node exporter / raw printing"},
+	{9200, "tcp", "Elasticsearch"},
+	{9300, "tcp", "Elasticsearch transport"},
+	{9418, "tcp", "Git"},
+	{9443, "tcp", "HTTPS (alternate)"},
+	{10250, "tcp", "Kubelet API"},
+	{11211, "", "Memcached"},
+	{15672, "tcp", "RabbitMQ management"},
+	{25565, "tcp", "Minecraft"},
+	{27017, "tcp", "MongoDB"},
+	{51820, "udp", "WireGuard"},
+}
+
+// serviceNames maps "proto/port" to a title; loaded on first use.
+var serviceNames = sync.OnceValue(loadServiceNames)
+
+func loadServiceNames() map[string]string {
+	names := map[string]string{}
+	for _, s := range wellKnownServices {
+		for _, proto := range []string{"tcp", "udp"} {
+			if s.Proto == "" || s.Proto == proto {
+				names[proto+"/"+strconv.Itoa(s.Port)] = s.Name
+			}
+		}
+	}
+	if servicesFilePath != "" {
+		n, err := readServicesFile(servicesFilePath, names)
+		if err != nil {
+			log.Printf("⚠️ Cannot read PORTMONOTE_SERVICES_FILE: %v", err)
+		} else {
+			log.Printf("📖 Loaded %d service names from %s", n, servicesFilePath)
+		}
+	}
+	return names
+}
+
+// readServicesFile adds the entries of an /etc/services style file.
+func readServicesFile(path string, names map[string]string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n := 0
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		portStr, proto, ok := strings.Cut(fields[1], "/")
+		if _, err := strconv.Atoi(portStr); !ok || err != nil || (proto != "tcp" && proto != "udp") {
+			continue
+		}
+		names[proto+"/"+portStr] = fields[0]
+		n++
+	}
+	return n, sc.Err()
+}
+
+// serviceName returns the well-known service on the port, if any.
+func serviceName(proto string, port int) string {
+	return serviceNames()[proto+"/"+strconv.Itoa(port)]
+}
+
+// seedAutoNotes gives first-time ports of a cycle a guessed title.
+func seedAutoNotes(b *eventBatch) {
+	if !autoNotes {
+		return
+	}
+	for i, evt := range b.events {
+		if evt.EventType != string(EventAppeared) {
+			continue
+		}
+		rt := b.runtimes[i]
+		name := serviceName(rt.Protocol, rt.Port)
+		if name == "" {
+			continue
+		}
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			var n int64
+			tx.Unscoped().Model(&PortNote{}).Where("host_id = ? AND protocol = ? AND port = ?", rt.HostID, rt.Protocol, rt.Port).Count(&n)
+			if n > 0 {
+				return nil // somebody's note, possibly archived, wins
+			}
+			note := PortNote{HostID: rt.HostID, Protocol: rt.Protocol, Port: rt.Port, Title: name, AutoGenerated: true, UpdatedBy: "auto"}
+			return saveNote(tx, &note)
+		})
+		if err != nil {
+			log.Printf("Error creating auto-note for %s/%d: %v", rt.Protocol, rt.Port, err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /calendar.ics: significant events as an iCalendar feed, so they show
+// up next to the team's other calendars. Included:
+//   - outages of noted ports lasting at least PORTMONOTE_ICAL_MIN_OUTAGE
+//   - process changes on trusted ports
+//
+// ?days=N sets the lookback (default 90). Calendar apps can't send headers,
+// so the feed also accepts ?api_key=pmk_... (see apiKeyFromRequest).
+var icalMinOutage = envDuration("PORTMONOTE_ICAL_MIN_OUTAGE", 10*time.Minute)
+
+type calendarEntry struct {
+	UID         string
+	Start, End  time.Time
+	Summary     string
+	Description string
+}
+
+func handleCalendar(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "90"))
+	if err != nil || days <= 0 {
+		c.String(http.StatusBadRequest, "Invalid days")
+		return
+	}
+	now := time.Now()
+	entries := calendarEntries(now.AddDate(0, 0, -days), now)
+	// Planned maintenance is useful ahead of time too
+	entries = append(entries, maintenanceEntries(now.AddDate(0, 0, -days), now.AddDate(0, 0, 30))...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Start.Before(entries[j].Start) })
+
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	c.Header("Content-Disposition", `inline; filename="portmonote.ics"`)
+	c.String(http.StatusOK, renderICal(entries, now))
+}
+
+func calendarEntries(since, now time.Time) []calendarEntry {
+	entries := append(outageEntries(since, now), processChangeEntries(since)...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Start.Before(entries[j].Start) })
+	return entries
+}
+
+// portLabel names a port by its note title when there is one.
+func portLabel(rt *PortRuntime, note *PortNote) string {
+	if note != nil && note.Title != "" {
+		return fmt.Sprintf("%s (%s/%d)", note.Title, rt.Protocol, rt.Port)
+	}
+	return fmt.Sprintf("%s/%d", rt.Protocol, rt.Port)
+}
+
+func outageEntries(since, now time.Time) []calendarEntry {
+	var downs []PortEvent
+	DB.Where("event_type = ? AND timestamp >= ?", EventDisappeared, since).Order("timestamp").Find(&downs)
+
+	var entries []calendarEntry
+	for _, down := range downs {
+		var rt PortRuntime
+		if DB.First(&rt, down.PortRuntimeID).Error != nil {
+			continue
+		}
+		var note PortNote
+		if DB.Where("host_id = ? AND protocol = ? AND port = ?", rt.HostID, rt.Protocol, rt.Port).First(&note).Error != nil {
+			continue // only services someone cared to document
+		}
+
+		end, ongoing := now, true
+		var up PortEvent
+		if err := DB.Where("port_runtime_id = ? AND event_type IN ? AND timestamp > ?", rt.ID, upEvents, down.Timestamp).
+			Order("timestamp").First(&up).Error; err == nil {
+			end, ongoing = up.Timestamp, false
+		}
+		if end.Sub(down.Timestamp) < icalMinOutage {
+			continue
+		}
+
+		summary := "Outage: " + portLabel(&rt, &note) + " on " + rt.HostID
+		if ongoing {
+			summary += " (ongoing)"
+		}
+		entries = append(entries, calendarEntry{
+			UID:         fmt.Sprintf("outage-%d@portmonote", down.ID),
+			Start:       down.Timestamp,
+			End:         end,
+			Summary:     summary,
+			Description: fmt.Sprintf("%s stopped listening (last process: %s), down for %s.", portLabel(&rt, &note), down.ProcessName, formatDuration(end.Sub(down.Timestamp))),
+		})
+	}
+	return entries
+}
+
+func processChangeEntries(since time.Time) []calendarEntry {
+	type row struct {
+		PortEvent
+		HostID   string
+		Protocol string
+		Port     int
+		Title    string
+	}
+	var rows []row
+	DB.Table("port_event").
+		Select("port_event.*, port_runtime.host_id, port_runtime.protocol, port_runtime.port, port_note.title").
+		Joins("JOIN port_runtime ON port_runtime.id = port_event.port_runtime_id").
+		Joins("JOIN port_note ON port_note.host_id = port_runtime.host_id AND port_note.protocol = port_runtime.protocol AND port_note.port = port_runtime.port AND port_note.deleted_at IS NULL").
+		Where("port_event.event_type = ? AND port_event.timestamp >= ? AND port_note.risk_level = ?", EventProcessChange, since, RiskTrusted).
+		Scan(&rows)
+
+	var entries []calendarEntry
+	for _, r := range rows {
+		rt := PortRuntime{Protocol: r.Protocol, Port: r.Port}
+		label := portLabel(&rt, &PortNote{Title: r.Title})
+		entries = append(entries, calendarEntry{
+			UID:         fmt.Sprintf("process-change-%d@portmonote", r.ID),
+			Start:       r.Timestamp,
+			End:         r.Timestamp.Add(time.Minute),
+			Summary:     "Process change: " + label + " on " + r.HostID,
+			Description: fmt.Sprintf("Trusted port %s is now served by %s (PID %d).", label, r.ProcessName, r.PID),
+		})
+	}
+	return entries
+}
+
+func renderICal(entries []calendarEntry, now time.Time) string {
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(foldICalLine(s))
+		b.WriteString("\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//portmonote//events//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:Portmonote")
+	for _, e := range entries {
+		line("BEGIN:VEVENT")
+		line("UID:" + e.UID)
+		line("DTSTAMP:" + icalTime(now))
+		line("DTSTART:" + icalTime(e.Start))
+		line("DTEND:" + icalTime(e.End))
+		line("SUMMARY:" + icalEscape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION:" + icalEscape(e.Description))
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+func icalTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func icalEscape(s string) string {
+	return icalEscaper.Replace(s)
+}
+
+// foldICalLine wraps lines at 75 octets as RFC 5545 requires, without
+// splitting UTF-8 sequences.
+func foldICalLine(s string) string {
+	if len(s) <= 75 {
+		return s
+	}
+	var b strings.Builder
+	width := 0
+	for _, r := range s {
+		n := len(string(r))
+		if width+n > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += n
+	}
+	return b.String()
+}
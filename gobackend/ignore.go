@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// PORTMONOTE_IGNORE keeps noise out of the runtime table: listeners
+// matching any rule are dropped right after the scan, as if they weren't
+// there. Rules are separated by ";" and use the policy rule fields (host,
+// protocol, ports, process glob) as key=value pairs:
+//
+//	PORTMONOTE_IGNORE="protocol=udp ports=32768-60999; process=node* ports=5173,3000-3010"
+//
+// A port already being tracked that becomes ignored is reported as
+// disappeared once, then left alone.
+var ignoreRules = parseIgnoreRules(envString("PORTMONOTE_IGNORE", ""))
+
+func parseIgnoreRules(spec string) []PolicyRule {
+	var rules []PolicyRule
+	for i, text := range strings.Split(spec, ";") {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		r := PolicyRule{Name: text}
+		valid := true
+		for _, field := range strings.Fields(text) {
+			k, v, _ := strings.Cut(field, "=")
+			switch k {
+			case "host":
+				r.Host = v
+			case "protocol":
+				r.Protocol = v
+			case "ports":
+				r.Ports = v
+			case "process":
+				r.Process = v
+			default:
+				log.Printf("⚠️ Ignore rule #%d: unknown field %q", i+1, k)
+				valid = false
+			}
+		}
+		p := Policy{Forbid: []PolicyRule{r}}
+		if err := p.compile(); err != nil {
+			log.Printf("⚠️ Ignore rule #%d: %v", i+1, err)
+			valid = false
+		}
+		if valid {
+			rules = append(rules, p.Forbid[0])
+		}
+	}
+	return rules
+}
+
+// dropIgnored removes listeners matched by an ignore rule.
+func dropIgnored(results map[PortKey]ScanResult) {
+	for key, res := range results {
+		item := MergedPortItem{HostID: key.HostID, Protocol: key.Protocol, Port: key.Port, ProcessName: res.ProcessName}
+		for i := range ignoreRules {
+			if ignoreRules[i].Matches(&item) {
+				delete(results, key)
+				break
+			}
+		}
+	}
+}
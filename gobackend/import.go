@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"gorm.io/gorm"
+)
+
+const importBatchSize = 100
+
+// executeImportPlan writes the plan one batch per transaction. A crash midway
+// leaves whole batches behind; re-running the import plans those rows as
+// existing and only writes what is missing, which makes imports resumable.
+func executeImportPlan(plan *importPlan) error {
+	steps := []struct {
+		name string
+		run  func(tx *gorm.DB, from, to int) error
+		size int
+	}{
+		{"runtimes created", func(tx *gorm.DB, from, to int) error { return tx.Create(plan.CreateRuntimes[from:to]).Error }, len(plan.CreateRuntimes)},
+		{"runtimes updated", func(tx *gorm.DB, from, to int) error { return saveAll(tx, plan.UpdateRuntimes[from:to]) }, len(plan.UpdateRuntimes)},
+		{"notes created", func(tx *gorm.DB, from, to int) error { return tx.Create(plan.CreateNotes[from:to]).Error }, len(plan.CreateNotes)},
+		{"notes updated", func(tx *gorm.DB, from, to int) error { return saveAll(tx, plan.UpdateNotes[from:to]) }, len(plan.UpdateNotes)},
+		{"events created", func(tx *gorm.DB, from, to int) error { return tx.Create(plan.CreateEvents[from:to]).Error }, len(plan.CreateEvents)},
+		{"events updated", func(tx *gorm.DB, from, to int) error { return saveAll(tx, plan.UpdateEvents[from:to]) }, len(plan.UpdateEvents)},
+	}
+
+	for _, step := range steps {
+		for from := 0; from < step.size; from += importBatchSize {
+			to := min(from+importBatchSize, step.size)
+			err := DB.Transaction(func(tx *gorm.DB) error {
+				return step.run(tx.Unscoped(), from, to)
+			})
+			if err != nil {
+				return fmt.Errorf("%s (rows %d-%d): %w", step.name, from, to, err)
+			}
+		}
+		if step.size > 0 {
+			log.Printf("✅ %d %s", step.size, step.name)
+		}
+	}
+	return nil
+}
+
+func saveAll[T any](tx *gorm.DB, rows []T) error {
+	for i := range rows {
+		if err := tx.Save(&rows[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runImport implements `portmonote import` for the legacy JSON export and the
+// legacy SQLite database. Rows are validated first; broken event -> runtime
+// links or ID conflicts abort the import before anything is written.
+// Rows already imported are skipped (or updated), so it can be re-run.
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	jsonPath := fs.String("json", "", "Path to legacy_export.json (from export_legacy_db.py)")
+	legacyDB := fs.String("legacy-db", "", "Path to the legacy Python app's SQLite database")
+	tz := fs.String("tz", "Local", "Timezone of the legacy naive timestamps (e.g. UTC, Europe/Berlin)")
+	dryRun := fs.Bool("dry-run", false, "Only report rows to be created/updated/skipped/conflicted, write nothing")
+	onExisting := fs.String("on-existing", "skip", "Rows whose ID already exists: skip or update")
+	keepHosts := fs.Bool("keep-host-ids", false, "Keep host_id values (e.g. restoring a GET /export backup) instead of forcing \"local\"")
+	fs.Parse(args)
+
+	var (
+		data   *LegacyData
+		source string
+		err    error
+	)
+	switch {
+	case *jsonPath != "":
+		source = *jsonPath
+		data, err = loadLegacyJSON(*jsonPath)
+		if err != nil {
+			log.Fatalf("❌ Failed to read JSON: %v", err)
+		}
+	case *legacyDB != "":
+		source = *legacyDB
+		data, err = loadLegacyDB(*legacyDB, *tz)
+		if err != nil {
+			log.Fatalf("❌ Failed to read legacy DB: %v", err)
+		}
+	default:
+		fmt.Println("Usage: portmonote import (--json legacy_export.json | --legacy-db old.sqlite [--tz UTC]) [--dry-run]")
+		os.Exit(1)
+	}
+	log.Printf("📦 Loaded %d runtimes, %d notes, %d events from %s",
+		len(data.Runtimes), len(data.Notes), len(data.Events), source)
+	if !*keepHosts {
+		data.unifyHost()
+	}
+
+	mode := ImportMode(*onExisting)
+	if mode != ImportSkip && mode != ImportUpdate {
+		log.Fatalf("❌ Invalid --on-existing %q (expected skip or update)", *onExisting)
+	}
+
+	InitDB("portmonote.db")
+
+	report, plan, err := planLegacyImport(data, mode)
+	if err != nil {
+		log.Fatalf("❌ Validation failed: %v", err)
+	}
+	report.DryRun = *dryRun
+	report.Log()
+	if *dryRun {
+		return
+	}
+	if report.HasInvalid() {
+		log.Fatal("❌ Import rejected: fix the invalid rows above (nothing was written)")
+	}
+	if report.HasConflicts() {
+		log.Fatal("❌ Import rejected: rows conflict with existing data (nothing was written)")
+	}
+
+	log.Println("🚀 Importing data...")
+	if err := executeImportPlan(plan); err != nil {
+		log.Fatalf("❌ Import failed: %v (completed batches were kept; re-run to resume)", err)
+	}
+	log.Println("✨ Import SUCCESS!")
+}
@@ -2,16 +2,8 @@ package main
 
 import (
 	"encoding/json"
-	"flag"
-	"fmt"
-	"io"
-	"log"
 	"os"
 	"time"
-
-	"gorm.io/driver/sqlite"
-	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 // CustomTime unmarshaling to handle formats without timezone
@@ -48,158 +40,83 @@ func (ct *CustomTime) UnmarshalJSON(b []byte) error {
 }
 
 // Define structures matching JSON export
-type ExportData struct {
-	Runtimes []struct {
-		ID                 uint        `json:"id"`
-		HostID             string      `json:"host_id"`
-		Protocol           string      `json:"protocol"`
-		Port               int         `json:"port"`
-		FirstSeenAt        CustomTime  `json:"first_seen_at"`
-		LastSeenAt         CustomTime  `json:"last_seen_at"`
-		LastDisappearedAt  *CustomTime `json:"last_disappeared_at"`
-		CurrentState       string      `json:"current_state"`
-		CurrentPID         int         `json:"current_pid"`
-		ProcessName        string      `json:"process_name"`
-		Cmdline            string      `json:"cmdline"`
-		TotalSeenCount     int         `json:"total_seen_count"`
-		TotalUptimeSeconds int         `json:"total_uptime_seconds"`
-	} `json:"runtimes"`
-
-	Notes []PortNote `json:"notes"`
-
-	Events []struct {
-		ID            uint       `json:"id"`
-		PortRuntimeID uint       `json:"port_runtime_id"`
-		EventType     string     `json:"event_type"`
-		Timestamp     CustomTime `json:"timestamp"`
-		PID           int        `json:"pid"`
-		ProcessName   string     `json:"process_name"`
-	} `json:"events"`
+type ExportRuntime struct {
+	ID                 uint        `json:"id"`
+	HostID             string      `json:"host_id"`
+	Protocol           string      `json:"protocol"`
+	Port               int         `json:"port"`
+	FirstSeenAt        CustomTime  `json:"first_seen_at"`
+	LastSeenAt         CustomTime  `json:"last_seen_at"`
+	LastDisappearedAt  *CustomTime `json:"last_disappeared_at"`
+	CurrentState       string      `json:"current_state"`
+	CurrentPID         int         `json:"current_pid"`
+	ProcessName        string      `json:"process_name"`
+	Cmdline            string      `json:"cmdline"`
+	TotalSeenCount     int         `json:"total_seen_count"`
+	TotalUptimeSeconds int         `json:"total_uptime_seconds"`
 }
 
-func main() {
-	jsonPath := flag.String("import", "", "Path to legacy_export.json file")
-	dbPath := flag.String("db", "data/portmonote.db", "Path to SQLite database to write to")
-	flag.Parse()
-
-	if *jsonPath == "" {
-		fmt.Println("Usage: go run import_legacy.go -import ../legacy_export.json")
-		os.Exit(1)
-	}
-
-	// 1. Read JSON file
-	file, err := os.Open(*jsonPath)
-	if err != nil {
-		log.Fatalf("❌ Failed to open JSON file: %v", err)
-	}
-	defer file.Close()
-
-	byteValue, _ := io.ReadAll(file)
-	var data ExportData
-	if err := json.Unmarshal(byteValue, &data); err != nil {
-		log.Fatalf("❌ Failed to parse JSON: %v", err)
-	}
-
-	log.Printf("📦 Loaded %d runtimes, %d notes, %d events from JSON",
-		len(data.Runtimes), len(data.Notes), len(data.Events))
+type ExportEvent struct {
+	ID            uint       `json:"id"`
+	PortRuntimeID uint       `json:"port_runtime_id"`
+	EventType     string     `json:"event_type"`
+	Timestamp     CustomTime `json:"timestamp"`
+	PID           int        `json:"pid"`
+	ProcessName   string     `json:"process_name"`
+}
 
-	// 2. Connect to Output DB
-	// Ensure directory exists
-	// (omitted detailed mkdir logic, assuming data folder exists or is handled by user)
+type ExportData struct {
+	Runtimes []ExportRuntime `json:"runtimes"`
+	Notes    []PortNote      `json:"notes"`
+	Events   []ExportEvent   `json:"events"`
+}
 
-	db, err := gorm.Open(sqlite.Open(*dbPath), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
+// loadLegacyJSON reads a legacy_export.json file into the same shape the
+// legacy DB reader produces, so both paths share planning and import.
+func loadLegacyJSON(path string) (*LegacyData, error) {
+	byteValue, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatalf("❌ Failed to connect to DB: %v", err)
+		return nil, err
 	}
-
-	// 3. Migrate Schema (Ensure tables exist)
-	log.Println("🔄 Migrating schema...")
-	if err := db.AutoMigrate(&PortRuntime{}, &PortNote{}, &PortEvent{}); err != nil {
-		log.Fatalf("❌ Migration failed: %v", err)
+	var export ExportData
+	if err := json.Unmarshal(byteValue, &export); err != nil {
+		return nil, err
 	}
 
-	// 4. Import Data
-	log.Println("🚀 Importing data...")
-
-	// Transaction to ensure integrity
-	err = db.Transaction(func(tx *gorm.DB) error {
-		// Clear existing data? Uncomment if needed
-		// tx.Exec("DELETE FROM port_runtime")
-		// tx.Exec("DELETE FROM port_note")
-		// tx.Exec("DELETE FROM port_event")
-
-		if len(data.Runtimes) > 0 {
-			// Convert CustomTime structs back to models.PortRuntime
-			var runtimes []PortRuntime
-			for _, r := range data.Runtimes {
-				var lastDisappearedAt *time.Time
-				if r.LastDisappearedAt != nil && !r.LastDisappearedAt.IsZero() {
-					t := r.LastDisappearedAt.Time
-					lastDisappearedAt = &t
-				}
-
-				runtimes = append(runtimes, PortRuntime{
-					ID:                 r.ID,
-					HostID:             "local", // Force unify to "local"
-					Protocol:           r.Protocol,
-					Port:               r.Port,
-					FirstSeenAt:        r.FirstSeenAt.Time,
-					LastSeenAt:         r.LastSeenAt.Time,
-					LastDisappearedAt:  lastDisappearedAt,
-					CurrentState:       r.CurrentState,
-					CurrentPID:         r.CurrentPID,
-					ProcessName:        r.ProcessName,
-					Cmdline:            r.Cmdline,
-					TotalSeenCount:     r.TotalSeenCount,
-					TotalUptimeSeconds: r.TotalUptimeSeconds,
-				})
-			}
-
-			if err := tx.CreateInBatches(runtimes, 100).Error; err != nil {
-				return err
-			}
-			log.Println("✅ Imported Runtimes")
-		}
-
-		if len(data.Notes) > 0 {
-			// Unify notes host_id to "local" too
-			for i := range data.Notes {
-				data.Notes[i].HostID = "local"
-			}
-			if err := tx.CreateInBatches(data.Notes, 100).Error; err != nil {
-				return err
-			}
-			log.Println("✅ Imported Notes")
+	data := &LegacyData{Notes: export.Notes}
+	for _, r := range export.Runtimes {
+		var lastDisappearedAt *time.Time
+		if r.LastDisappearedAt != nil && !r.LastDisappearedAt.IsZero() {
+			t := r.LastDisappearedAt.Time
+			lastDisappearedAt = &t
 		}
 
-		if len(data.Events) > 0 {
-			// Convert CustomTime structs back to models.PortEvent
-			var events []PortEvent
-			for _, e := range data.Events {
-				events = append(events, PortEvent{
-					ID:            e.ID,
-					PortRuntimeID: e.PortRuntimeID,
-					EventType:     e.EventType,
-					Timestamp:     e.Timestamp.Time,
-					PID:           e.PID,
-					ProcessName:   e.ProcessName,
-				})
-			}
-
-			if err := tx.CreateInBatches(events, 100).Error; err != nil {
-				return err
-			}
-			log.Println("✅ Imported Events")
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		log.Fatalf("❌ Import failed: %v", err)
+		data.Runtimes = append(data.Runtimes, PortRuntime{
+			ID:                 r.ID,
+			HostID:             r.HostID,
+			Protocol:           r.Protocol,
+			Port:               r.Port,
+			FirstSeenAt:        r.FirstSeenAt.Time,
+			LastSeenAt:         r.LastSeenAt.Time,
+			LastDisappearedAt:  lastDisappearedAt,
+			CurrentState:       r.CurrentState,
+			CurrentPID:         r.CurrentPID,
+			ProcessName:        r.ProcessName,
+			Cmdline:            r.Cmdline,
+			Fingerprint:        processFingerprint(r.ProcessName, r.Cmdline),
+			TotalSeenCount:     r.TotalSeenCount,
+			TotalUptimeSeconds: r.TotalUptimeSeconds,
+		})
 	}
-
-	log.Println("✨ Import SUCCESS!")
+	for _, e := range export.Events {
+		data.Events = append(data.Events, PortEvent{
+			ID:            e.ID,
+			PortRuntimeID: e.PortRuntimeID,
+			EventType:     e.EventType,
+			Timestamp:     e.Timestamp.Time,
+			PID:           e.PID,
+			ProcessName:   e.ProcessName,
+		})
+	}
+	return data, nil
 }
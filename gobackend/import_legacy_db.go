@@ -0,0 +1,213 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Reads the legacy Python (SQLAlchemy) database directly, so migrating does
+// not need the export_legacy_db.py -> JSON -> import_legacy.go round trip.
+
+// SQLAlchemy writes naive timestamps as "2006-01-02 15:04:05.999999"; older
+// rows may use the ISO "T" separator or lack fractional seconds.
+var legacyTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999",
+	"2006-01-02T15:04:05.999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339Nano,
+}
+
+// parseLegacyTime interprets naive timestamps in loc. Values carrying their own
+// offset (RFC3339) keep it.
+func parseLegacyTime(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range legacyTimeLayouts {
+		if layout == time.RFC3339Nano {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, nil
+			}
+			continue
+		}
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", s)
+}
+
+func legacyNullTime(ns sql.NullString, loc *time.Location) (*time.Time, error) {
+	if !ns.Valid || ns.String == "" {
+		return nil, nil
+	}
+	t, err := parseLegacyTime(ns.String, loc)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func legacyTime(ns sql.NullString, loc *time.Location) (time.Time, error) {
+	t, err := legacyNullTime(ns, loc)
+	if err != nil || t == nil {
+		return time.Time{}, err
+	}
+	return *t, nil
+}
+
+type LegacyData struct {
+	Runtimes []PortRuntime
+	Notes    []PortNote
+	Events   []PortEvent
+}
+
+// unifyHost forces every row onto the local host_id; the legacy app was
+// single-host and used inconsistent ids.
+func (d *LegacyData) unifyHost() {
+	for i := range d.Runtimes {
+		d.Runtimes[i].HostID = HostID
+	}
+	for i := range d.Notes {
+		d.Notes[i].HostID = HostID
+	}
+}
+
+// readLegacyDB loads all rows from a legacy database. Timestamps are selected
+// as TEXT so the driver does not guess a timezone for us.
+func readLegacyDB(src *gorm.DB, loc *time.Location) (*LegacyData, error) {
+	data := &LegacyData{}
+
+	rows, err := src.Raw(`SELECT id, host_id, protocol, port,
+		CAST(first_seen_at AS TEXT), CAST(last_seen_at AS TEXT), CAST(last_disappeared_at AS TEXT),
+		current_state, current_pid, process_name, cmdline, total_seen_count, total_uptime_seconds
+		FROM port_runtime`).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("reading port_runtime: %w", err)
+	}
+	for rows.Next() {
+		var (
+			id, port                             int64
+			hostID, proto, state, name, cmdline  sql.NullString
+			firstSeen, lastSeen, lastDisappeared sql.NullString
+			pid, seen, uptime                    sql.NullInt64
+		)
+		if err := rows.Scan(&id, &hostID, &proto, &port, &firstSeen, &lastSeen, &lastDisappeared,
+			&state, &pid, &name, &cmdline, &seen, &uptime); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		r := PortRuntime{
+			ID:                 uint(id),
+			HostID:             hostID.String,
+			Protocol:           proto.String,
+			Port:               int(port),
+			CurrentState:       state.String,
+			CurrentPID:         int(pid.Int64),
+			ProcessName:        name.String,
+			Cmdline:            cmdline.String,
+			TotalSeenCount:     int(seen.Int64),
+			TotalUptimeSeconds: int(uptime.Int64),
+		}
+		if r.FirstSeenAt, err = legacyTime(firstSeen, loc); err == nil {
+			if r.LastSeenAt, err = legacyTime(lastSeen, loc); err == nil {
+				r.LastDisappearedAt, err = legacyNullTime(lastDisappeared, loc)
+			}
+		}
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("runtime #%d: %w", id, err)
+		}
+		r.Fingerprint = processFingerprint(r.ProcessName, r.Cmdline)
+		data.Runtimes = append(data.Runtimes, r)
+	}
+	rows.Close()
+
+	rows, err = src.Raw(`SELECT id, host_id, protocol, port, title, description, owner, risk_level, is_pinned
+		FROM port_note`).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("reading port_note: %w", err)
+	}
+	for rows.Next() {
+		var (
+			id, port                                int64
+			hostID, proto, title, desc, owner, risk sql.NullString
+			pinned                                  sql.NullInt64
+		)
+		if err := rows.Scan(&id, &hostID, &proto, &port, &title, &desc, &owner, &risk, &pinned); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		riskLevel := risk.String
+		if riskLevel == "" {
+			riskLevel = string(RiskExpected)
+		}
+		data.Notes = append(data.Notes, PortNote{
+			ID:          uint(id),
+			HostID:      hostID.String,
+			Protocol:    proto.String,
+			Port:        int(port),
+			Title:       title.String,
+			Description: desc.String,
+			Owner:       owner.String,
+			RiskLevel:   riskLevel,
+			IsPinned:    pinned.Int64 != 0,
+		})
+	}
+	rows.Close()
+
+	rows, err = src.Raw(`SELECT id, port_runtime_id, event_type, CAST(timestamp AS TEXT), pid, process_name
+		FROM port_event`).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("reading port_event: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id                     int64
+			runtimeID, pid         sql.NullInt64
+			eventType, ts, process sql.NullString
+		)
+		if err := rows.Scan(&id, &runtimeID, &eventType, &ts, &pid, &process); err != nil {
+			return nil, err
+		}
+		t, err := legacyTime(ts, loc)
+		if err != nil {
+			return nil, fmt.Errorf("event #%d: %w", id, err)
+		}
+		data.Events = append(data.Events, PortEvent{
+			ID:            uint(id),
+			PortRuntimeID: uint(runtimeID.Int64),
+			EventType:     eventType.String,
+			Timestamp:     t,
+			PID:           int(pid.Int64),
+			ProcessName:   process.String,
+		})
+	}
+	return data, rows.Err()
+}
+
+func loadLegacyDB(path, tz string) (*LegacyData, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", tz, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	src, err := gorm.Open(sqlite.Open("file:"+path+"?mode=ro"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Error),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return readLegacyDB(src, loc)
+}
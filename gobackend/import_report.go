@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// TableReport counts what an import would do (or did) to one table.
+type TableReport struct {
+	Create   int      `json:"create"`
+	Update   int      `json:"update"`
+	Skip     int      `json:"skip"`
+	Conflict int      `json:"conflict"`
+	Invalid  int      `json:"invalid"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+// ImportReport is returned by every import path, for --dry-run and real runs.
+type ImportReport struct {
+	DryRun   bool        `json:"dry_run"`
+	Runtimes TableReport `json:"runtimes"`
+	Notes    TableReport `json:"notes"`
+	Events   TableReport `json:"events"`
+}
+
+const maxReportedProblems = 50
+
+func (t *TableReport) problem(format string, args ...any) {
+	if len(t.Problems) < maxReportedProblems {
+		t.Problems = append(t.Problems, fmt.Sprintf(format, args...))
+	}
+}
+
+func (r *ImportReport) HasInvalid() bool {
+	return r.Runtimes.Invalid+r.Notes.Invalid+r.Events.Invalid > 0
+}
+
+func (r *ImportReport) HasConflicts() bool {
+	return r.Runtimes.Conflict+r.Notes.Conflict+r.Events.Conflict > 0
+}
+
+func (r *ImportReport) Log() {
+	mode := "Import"
+	if r.DryRun {
+		mode = "Dry run"
+	}
+	for _, t := range []struct {
+		name string
+		rep  TableReport
+	}{{"runtimes", r.Runtimes}, {"notes", r.Notes}, {"events", r.Events}} {
+		log.Printf("📋 %s %-8s create=%d update=%d skip=%d conflict=%d invalid=%d",
+			mode, t.name, t.rep.Create, t.rep.Update, t.rep.Skip, t.rep.Conflict, t.rep.Invalid)
+		for _, p := range t.rep.Problems {
+			log.Printf("   - %s", p)
+		}
+	}
+}
+
+// ImportMode decides what happens to rows whose ID is already present.
+type ImportMode string
+
+const (
+	ImportSkip   ImportMode = "skip"   // leave the existing row alone
+	ImportUpdate ImportMode = "update" // overwrite it with the imported values
+)
+
+// importPlan is the per-row outcome of planLegacyImport.
+type importPlan struct {
+	CreateRuntimes, UpdateRuntimes []PortRuntime
+	CreateNotes, UpdateNotes       []PortNote
+	CreateEvents, UpdateEvents     []PortEvent
+}
+
+// planLegacyImport checks legacy rows against DB without writing anything.
+// Rows keep their original IDs: an ID that exists for the same port key (or
+// the same runtime, for events) is the same row and is skipped or updated
+// depending on mode, so re-running an import is safe. An ID that exists for
+// something else is a conflict. Events must reference a runtime that is
+// either imported or already present.
+func planLegacyImport(data *LegacyData, mode ImportMode) (ImportReport, *importPlan, error) {
+	var rep ImportReport
+	plan := &importPlan{}
+
+	var runtimes []PortRuntime
+	var notes []PortNote
+	var events []PortEvent
+	if err := DB.Unscoped().Select("id, host_id, protocol, port").Find(&runtimes).Error; err != nil {
+		return rep, nil, err
+	}
+	if err := DB.Unscoped().Select("id, host_id, protocol, port").Find(&notes).Error; err != nil {
+		return rep, nil, err
+	}
+	if err := DB.Select("id, port_runtime_id").Find(&events).Error; err != nil {
+		return rep, nil, err
+	}
+
+	runtimeByID := make(map[uint]string)
+	runtimeByKey := make(map[string]uint)
+	for _, r := range runtimes {
+		key := fmtKey(r.HostID, r.Protocol, r.Port)
+		runtimeByID[r.ID] = key
+		runtimeByKey[key] = r.ID
+	}
+	noteByID := make(map[uint]string)
+	noteByKey := make(map[string]uint)
+	for _, n := range notes {
+		key := fmtKey(n.HostID, n.Protocol, n.Port)
+		noteByID[n.ID] = key
+		noteByKey[key] = n.ID
+	}
+	eventRuntime := make(map[uint]uint)
+	for _, e := range events {
+		eventRuntime[e.ID] = e.PortRuntimeID
+	}
+
+	importedRuntimes := make(map[uint]bool)
+	for _, r := range data.Runtimes {
+		key := fmtKey(r.HostID, r.Protocol, r.Port)
+		existingKey, idTaken := runtimeByID[r.ID]
+		otherID, keyTaken := runtimeByKey[key]
+		switch {
+		case r.Protocol == "" || r.Port <= 0 || r.Port > 65535:
+			rep.Runtimes.Invalid++
+			rep.Runtimes.problem("runtime #%d has invalid key %s/%d", r.ID, r.Protocol, r.Port)
+			continue
+		case idTaken && existingKey != key:
+			rep.Runtimes.Conflict++
+			rep.Runtimes.problem("runtime #%d already exists for %s, not %s", r.ID, existingKey, key)
+			continue
+		case !idTaken && keyTaken:
+			rep.Runtimes.Conflict++
+			rep.Runtimes.problem("runtime #%d: %s is already tracked as runtime #%d", r.ID, key, otherID)
+			continue
+		case idTaken && mode == ImportUpdate:
+			rep.Runtimes.Update++
+			plan.UpdateRuntimes = append(plan.UpdateRuntimes, r)
+		case idTaken:
+			rep.Runtimes.Skip++
+		default:
+			rep.Runtimes.Create++
+			plan.CreateRuntimes = append(plan.CreateRuntimes, r)
+		}
+		importedRuntimes[r.ID] = true
+	}
+
+	for _, n := range data.Notes {
+		key := fmtKey(n.HostID, n.Protocol, n.Port)
+		existingKey, idTaken := noteByID[n.ID]
+		if !idTaken {
+			// Same port already noted under another ID: that note is the match
+			if otherID, ok := noteByKey[key]; ok {
+				n.ID = otherID
+				existingKey, idTaken = key, true
+			}
+		}
+		switch {
+		case idTaken && existingKey != key:
+			rep.Notes.Conflict++
+			rep.Notes.problem("note #%d already exists for %s, not %s", n.ID, existingKey, key)
+		case idTaken && mode == ImportUpdate:
+			rep.Notes.Update++
+			plan.UpdateNotes = append(plan.UpdateNotes, n)
+		case idTaken:
+			rep.Notes.Skip++
+		default:
+			rep.Notes.Create++
+			plan.CreateNotes = append(plan.CreateNotes, n)
+		}
+	}
+
+	for _, e := range data.Events {
+		existingRuntime, idTaken := eventRuntime[e.ID]
+		_, runtimeExists := runtimeByID[e.PortRuntimeID]
+		switch {
+		case !importedRuntimes[e.PortRuntimeID] && !runtimeExists:
+			rep.Events.Invalid++
+			rep.Events.problem("event #%d references missing runtime #%d", e.ID, e.PortRuntimeID)
+		case idTaken && existingRuntime != e.PortRuntimeID:
+			rep.Events.Conflict++
+			rep.Events.problem("event #%d already exists for runtime #%d", e.ID, existingRuntime)
+		case idTaken && mode == ImportUpdate:
+			rep.Events.Update++
+			plan.UpdateEvents = append(plan.UpdateEvents, e)
+		case idTaken:
+			rep.Events.Skip++
+		default:
+			rep.Events.Create++
+			plan.CreateEvents = append(plan.CreateEvents, e)
+		}
+	}
+	return rep, plan, nil
+}
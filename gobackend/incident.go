@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Incidents bundle related events across ports and hosts ("build-03
+// rebooted") into one record with a status and a postmortem. An event
+// belongs to at most one incident; its incident_id shows up in the port's
+// history, so each affected port links back to the bundle.
+const (
+	IncidentOpen     = "open"
+	IncidentResolved = "resolved"
+)
+
+type Incident struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Title      string     `json:"title"`
+	Status     string     `gorm:"index;default:open" json:"status"`
+	Postmortem string     `json:"postmortem"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+
+	EventCount int `gorm:"-" json:"event_count"`
+}
+
+func (Incident) TableName() string {
+	return "incident"
+}
+
+// IncidentRequest creates or edits an incident. Events are picked by ID
+// and/or by host and time range, e.g. everything on build-03 during the
+// reboot.
+type IncidentRequest struct {
+	Title      *string    `json:"title"`
+	Status     *string    `json:"status"`
+	Postmortem *string    `json:"postmortem"`
+	EventIDs   []uint     `json:"event_ids"`
+	HostID     string     `json:"host_id"`
+	From       *time.Time `json:"from"`
+	To         *time.Time `json:"to"`
+}
+
+// GET /incidents?status=open|resolved
+func listIncidents(c *gin.Context) {
+	q := DB.Order("created_at desc")
+	if s := c.Query("status"); s != "" {
+		q = q.Where("status = ?", s)
+	}
+	var incidents []Incident
+	q.Find(&incidents)
+
+	var counts []struct {
+		IncidentID uint
+		Count      int
+	}
+	DB.Model(&PortEvent{}).Select("incident_id, COUNT(*) AS count").Where("incident_id IS NOT NULL").Group("incident_id").Scan(&counts)
+	byID := map[uint]int{}
+	for _, n := range counts {
+		byID[n.IncidentID] = n.Count
+	}
+	for i := range incidents {
+		incidents[i].EventCount = byID[incidents[i].ID]
+	}
+	c.JSON(http.StatusOK, incidents)
+}
+
+// POST /incidents
+func createIncident(c *gin.Context) {
+	var req IncidentRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Title == nil || *req.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+		return
+	}
+	inc := Incident{Title: *req.Title, Status: IncidentOpen, CreatedBy: actorName(c)}
+	if req.Postmortem != nil {
+		inc.Postmortem = *req.Postmortem
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&inc).Error; err != nil {
+			return err
+		}
+		n, err := attachEvents(tx, inc.ID, req)
+		inc.EventCount = n
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, inc)
+}
+
+// GET /incidents/:id returns the incident with its timeline and the ports
+// it touched.
+func getIncident(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var inc Incident
+	if err := DB.First(&inc, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Incident not found"})
+		return
+	}
+	var events []TimelineEvent
+	DB.Table("port_event").
+		Select("port_event.*, port_runtime.host_id, port_runtime.protocol, port_runtime.port, port_note.title AS note_title, port_note.owner, port_note.risk_level").
+		Joins("JOIN port_runtime ON port_runtime.id = port_event.port_runtime_id").
+		Joins(noteJoin).
+		Where("port_event.incident_id = ?", inc.ID).
+		Order("port_event.timestamp, port_event.id").
+		Scan(&events)
+
+	ports := []PortKey{}
+	seen := map[PortKey]bool{}
+	for i := range events {
+		events[i].WitrOutput = ""
+		key := PortKey{HostID: events[i].HostID, Protocol: events[i].Protocol, Port: events[i].Port}
+		if !seen[key] {
+			seen[key] = true
+			ports = append(ports, key)
+		}
+	}
+	inc.EventCount = len(events)
+	c.JSON(http.StatusOK, gin.H{"incident": inc, "events": events, "ports": ports})
+}
+
+// POST /incidents/:id edits title, status or postmortem and attaches more
+// events.
+func updateIncident(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var inc Incident
+	if err := DB.First(&inc, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Incident not found"})
+		return
+	}
+	var req IncidentRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Title != nil {
+		if *req.Title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+			return
+		}
+		inc.Title = *req.Title
+	}
+	if req.Postmortem != nil {
+		inc.Postmortem = *req.Postmortem
+	}
+	if req.Status != nil {
+		switch *req.Status {
+		case IncidentOpen:
+			inc.ResolvedAt = nil
+		case IncidentResolved:
+			if inc.Status != IncidentResolved {
+				now := time.Now()
+				inc.ResolvedAt = &now
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be open or resolved"})
+			return
+		}
+		inc.Status = *req.Status
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&inc).Error; err != nil {
+			return err
+		}
+		_, err := attachEvents(tx, inc.ID, req)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var n int64
+	DB.Model(&PortEvent{}).Where("incident_id = ?", inc.ID).Count(&n)
+	inc.EventCount = int(n)
+	c.JSON(http.StatusOK, inc)
+}
+
+// DELETE /incidents/:id/events/:event_id detaches one event.
+func detachIncidentEvent(c *gin.Context) {
+	res := DB.Model(&PortEvent{}).Where("id = ? AND incident_id = ?", c.Param("event_id"), c.Param("id")).Update("incident_id", nil)
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event is not part of this incident"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"detached": true})
+}
+
+// DELETE /incidents/:id removes the incident; its events stay.
+func deleteIncident(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&PortEvent{}).Where("incident_id = ?", id).Update("incident_id", nil).Error; err != nil {
+			return err
+		}
+		res := tx.Delete(&Incident{}, id)
+		if res.Error == nil && res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return res.Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Incident not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+// attachEvents links the events selected by req to the incident, taking
+// them from any incident they were in before. Returns how many were linked.
+func attachEvents(tx *gorm.DB, incidentID uint, req IncidentRequest) (int, error) {
+	total := 0
+	if len(req.EventIDs) > 0 {
+		res := tx.Model(&PortEvent{}).Where("id IN ?", req.EventIDs).Update("incident_id", incidentID)
+		if res.Error != nil {
+			return 0, res.Error
+		}
+		total += int(res.RowsAffected)
+	}
+	if req.HostID != "" || req.From != nil || req.To != nil {
+		if req.From == nil || req.To == nil {
+			return 0, errors.New("from and to are both required to select events by time")
+		}
+		sub := tx.Model(&PortRuntime{}).Unscoped().Select("id")
+		if req.HostID != "" {
+			sub = sub.Where("host_id = ?", req.HostID)
+		}
+		res := tx.Model(&PortEvent{}).
+			Where("timestamp >= ? AND timestamp <= ? AND port_runtime_id IN (?)", *req.From, *req.To, sub).
+			Update("incident_id", incidentID)
+		if res.Error != nil {
+			return 0, res.Error
+		}
+		total += int(res.RowsAffected)
+	}
+	return total, nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Background jobs: work that shouldn't block a request or the collector
+// (pruning, inspections, config syncs, ...) is queued in the job table and
+// run by a small worker pool. Jobs survive restarts, failed ones are retried
+// with backoff, and each kind can limit how many of it run at once.
+//
+//	PORTMONOTE_JOB_WORKERS=2        jobs running at once, all kinds
+//	PORTMONOTE_JOB_RETENTION=168h   finished jobs kept for GET /jobs
+var (
+	jobWorkers   = envInt("PORTMONOTE_JOB_WORKERS", 2)
+	jobRetention = envDuration("PORTMONOTE_JOB_RETENTION", 7*24*time.Hour)
+)
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+type Job struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Kind        string     `gorm:"index" json:"kind"`
+	Payload     string     `json:"payload,omitempty"` // JSON, kind-specific
+	Status      string     `gorm:"index;default:queued" json:"status"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	RunAfter    time.Time  `gorm:"index" json:"run_after"`
+	Result      string     `json:"result,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+func (Job) TableName() string {
+	return "job"
+}
+
+// JobKind describes how to run one kind of job.
+type JobKind struct {
+	Run         func(ctx context.Context, payload string) (string, error)
+	MaxAttempts int // default 3
+	Concurrency int // per kind, default 1
+}
+
+var (
+	jobKinds   = map[string]JobKind{}
+	jobWake    = make(chan struct{}, 1)
+	jobsActive sync.WaitGroup
+
+	jobMu      sync.Mutex
+	jobRunning = map[string]int{} // kind -> running count
+)
+
+func registerJob(kind string, k JobKind) {
+	if k.MaxAttempts <= 0 {
+		k.MaxAttempts = 3
+	}
+	if k.Concurrency <= 0 {
+		k.Concurrency = 1
+	}
+	jobKinds[kind] = k
+}
+
+// enqueueJob queues a job; payload is marshalled to JSON unless nil.
+func enqueueJob(kind string, payload any) (*Job, error) {
+	k, ok := jobKinds[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown job kind %q", kind)
+	}
+	job := Job{Kind: kind, Status: JobQueued, MaxAttempts: k.MaxAttempts, RunAfter: time.Now()}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		job.Payload = string(data)
+	}
+	if err := DB.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	wakeJobs()
+	return &job, nil
+}
+
+// enqueueUnique queues a job unless one of that kind and payload is
+// already waiting or running.
+func enqueueUnique(kind string, payload any) {
+	data := ""
+	if payload != nil {
+		b, _ := json.Marshal(payload)
+		data = string(b)
+	}
+	var n int64
+	DB.Model(&Job{}).Where("kind = ? AND payload = ? AND status IN ?", kind, data, []string{JobQueued, JobRunning}).Count(&n)
+	if n > 0 {
+		return
+	}
+	if _, err := enqueueJob(kind, payload); err != nil {
+		log.Printf("Error queueing %s job: %v", kind, err)
+	}
+}
+
+// scheduleJob queues a job now and then every interval until ctx is done.
+func scheduleJob(ctx context.Context, kind string, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			enqueueUnique(kind, nil)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func wakeJobs() {
+	select {
+	case jobWake <- struct{}{}:
+	default:
+	}
+}
+
+// startJobs requeues jobs interrupted by the last shutdown and starts the
+// workers.
+func startJobs(ctx context.Context) {
+	DB.Model(&Job{}).Where("status = ?", JobRunning).Update("status", JobQueued)
+	for range max(jobWorkers, 1) {
+		jobsActive.Add(1)
+		go func() {
+			defer jobsActive.Done()
+			runJobWorker(ctx)
+		}()
+	}
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				DB.Where("status IN ? AND finished_at < ?", []string{JobSucceeded, JobFailed}, time.Now().Add(-jobRetention)).Delete(&Job{})
+			}
+		}
+	}()
+}
+
+// waitForJobs blocks until the workers have stopped or the timeout passes.
+func waitForJobs(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		jobsActive.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func runJobWorker(ctx context.Context) {
+	for {
+		if job, ok := claimJob(); ok {
+			runJob(ctx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-jobWake:
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// claimJob takes the oldest due job whose kind has a free slot. Saturated
+// kinds are left out of the query, so a burst of one kind can't hide the
+// others behind it.
+func claimJob() (*Job, bool) {
+	jobMu.Lock()
+	defer jobMu.Unlock()
+	var free []string
+	for kind, k := range jobKinds {
+		if jobRunning[kind] < k.Concurrency {
+			free = append(free, kind)
+		}
+	}
+	if len(free) == 0 {
+		return nil, false
+	}
+	var due []Job
+	DB.Where("status = ? AND run_after <= ? AND kind IN ?", JobQueued, time.Now(), free).Order("id").Limit(20).Find(&due)
+
+	for i := range due {
+		job := &due[i]
+		now := time.Now()
+		res := DB.Model(&Job{}).Where("id = ? AND status = ?", job.ID, JobQueued).
+			Updates(map[string]any{"status": JobRunning, "started_at": now, "attempts": job.Attempts + 1})
+		if res.Error != nil || res.RowsAffected == 0 {
+			continue
+		}
+		job.Status, job.StartedAt = JobRunning, &now
+		job.Attempts++
+		jobRunning[job.Kind]++
+		return job, true
+	}
+	return nil, false
+}
+
+func runJob(ctx context.Context, job *Job) {
+	defer func() {
+		jobMu.Lock()
+		jobRunning[job.Kind]--
+		jobMu.Unlock()
+	}()
+
+	result, err := runJobSafely(ctx, job)
+	now := time.Now()
+	updates := map[string]any{"finished_at": now, "result": result, "error": ""}
+	switch {
+	case err == nil:
+		updates["status"] = JobSucceeded
+	case ctx.Err() != nil:
+		// Shutting down: run it again after the restart
+		updates = map[string]any{"status": JobQueued, "attempts": job.Attempts - 1}
+	case job.Attempts < job.MaxAttempts:
+		updates["status"] = JobQueued
+		updates["error"] = err.Error()
+		updates["run_after"] = now.Add(time.Duration(job.Attempts*job.Attempts) * 30 * time.Second)
+		log.Printf("Job #%d (%s) failed, retrying: %v", job.ID, job.Kind, err)
+	default:
+		updates["status"] = JobFailed
+		updates["error"] = err.Error()
+		log.Printf("❌ Job #%d (%s) failed: %v", job.ID, job.Kind, err)
+	}
+	DB.Model(&Job{}).Where("id = ?", job.ID).Updates(updates)
+}
+
+func runJobSafely(ctx context.Context, job *Job) (result string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return jobKinds[job.Kind].Run(ctx, job.Payload)
+}
+
+// GET /jobs?status=&kind= lists the latest 100 jobs.
+func listJobs(c *gin.Context) {
+	q := DB.Order("id desc").Limit(100)
+	if v := c.Query("status"); v != "" {
+		q = q.Where("status = ?", v)
+	}
+	if v := c.Query("kind"); v != "" {
+		q = q.Where("kind = ?", v)
+	}
+	var jobs []Job
+	q.Find(&jobs)
+	c.JSON(http.StatusOK, jobs)
+}
+
+// GET /jobs/:id
+func getJob(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var job Job
+	if err := DB.First(&job, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// POST /admin/jobs/:id/retry puts a failed job back in the queue.
+func retryJob(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var job Job
+	if err := DB.First(&job, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	res := DB.Model(&Job{}).Where("id = ? AND status = ?", job.ID, JobFailed).
+		Updates(map[string]any{"status": JobQueued, "attempts": 0, "run_after": time.Now(), "error": ""})
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only failed jobs can be retried"})
+		return
+	}
+	wakeJobs()
+	DB.First(&job, job.ID)
+	c.JSON(http.StatusOK, job)
+}
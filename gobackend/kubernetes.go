@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Optional Kubernetes attribution: map listening PIDs to pod/namespace/container.
+// Enabled with PORTMONOTE_K8S=true. Pod metadata comes from the kubelet's /pods
+// endpoint (read-only port by default), the PID -> container link from cgroups.
+
+var (
+	k8sEnabled    = envBool("PORTMONOTE_K8S", false)
+	k8sKubeletURL = envString("PORTMONOTE_KUBELET_URL", "http://127.0.0.1:10255/pods")
+	k8sTokenFile  = envString("PORTMONOTE_KUBELET_TOKEN_FILE", "")
+)
+
+var (
+	cgroupPodUID      = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+	cgroupContainerID = regexp.MustCompile(`([0-9a-f]{64})`)
+)
+
+type PodInfo struct {
+	Name      string
+	Namespace string
+	Container string
+}
+
+type kubeletPodList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+			UID       string `json:"uid"`
+		} `json:"metadata"`
+		Status struct {
+			ContainerStatuses []struct {
+				Name        string `json:"name"`
+				ContainerID string `json:"containerID"` // e.g. containerd://<id>
+			} `json:"containerStatuses"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// podResolver holds one kubelet snapshot; build a new one per collection cycle.
+type podResolver struct {
+	pods       map[string]PodInfo // pod UID -> pod (Container empty)
+	containers map[string]PodInfo // container ID -> pod + container
+}
+
+func newPodResolver() (*podResolver, error) {
+	req, err := http.NewRequest(http.MethodGet, k8sKubeletURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if k8sTokenFile != "" {
+		token, err := os.ReadFile(k8sTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kubelet returned %s", resp.Status)
+	}
+
+	var list kubeletPodList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	r := &podResolver{
+		pods:       make(map[string]PodInfo),
+		containers: make(map[string]PodInfo),
+	}
+	for _, item := range list.Items {
+		pod := PodInfo{Name: item.Metadata.Name, Namespace: item.Metadata.Namespace}
+		r.pods[item.Metadata.UID] = pod
+		for _, cs := range item.Status.ContainerStatuses {
+			id := cs.ContainerID
+			if i := strings.Index(id, "://"); i >= 0 {
+				id = id[i+3:]
+			}
+			r.containers[id] = PodInfo{Name: pod.Name, Namespace: pod.Namespace, Container: cs.Name}
+		}
+	}
+	return r, nil
+}
+
+// Resolve reads /proc/<pid>/cgroup and matches container ID or pod UID.
+func (r *podResolver) Resolve(pid int) (PodInfo, bool) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return PodInfo{}, false
+	}
+	cgroup := string(data)
+
+	for _, m := range cgroupContainerID.FindAllStringSubmatch(cgroup, -1) {
+		if info, ok := r.containers[m[1]]; ok {
+			return info, true
+		}
+	}
+	if m := cgroupPodUID.FindStringSubmatch(cgroup); m != nil {
+		// systemd cgroup driver writes the UID with underscores
+		if info, ok := r.pods[strings.ReplaceAll(m[1], "_", "-")]; ok {
+			return info, true
+		}
+	}
+	return PodInfo{}, false
+}
+
+// attributePods fills pod fields on scan results in place. It returns false
+// when the kubelet could not be asked, so callers keep the pods they knew.
+func attributePods(results map[PortKey]ScanResult) bool {
+	resolver, err := newPodResolver()
+	if err != nil {
+		log.Println("Kubernetes attribution unavailable:", err)
+		return false
+	}
+	for key, res := range results {
+		if info, ok := resolver.Resolve(res.PID); ok {
+			res.Pod = info
+			results[key] = res
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Resource limits so the monitor can't destabilize the small host it runs
+// on. Zero disables a limit.
+//
+//	PORTMONOTE_MAX_CONCURRENT=32      requests served at once; the rest wait
+//	                                  up to PORTMONOTE_QUEUE_TIMEOUT, then 503
+//	PORTMONOTE_MAX_BODY_BYTES=10MiB   request bodies (imports included)
+//	PORTMONOTE_WITR_MAX_OUTPUT=64KiB  witr output kept in the response and DB
+//	PORTMONOTE_WITR_TIMEOUT=30s
+var (
+	maxConcurrent = envInt("PORTMONOTE_MAX_CONCURRENT", 32)
+	queueTimeout  = envDuration("PORTMONOTE_QUEUE_TIMEOUT", 5*time.Second)
+	maxBodyBytes  = int64(envInt("PORTMONOTE_MAX_BODY_BYTES", 10<<20))
+	witrMaxOutput = envInt("PORTMONOTE_WITR_MAX_OUTPUT", 64<<10)
+	witrTimeout   = envDuration("PORTMONOTE_WITR_TIMEOUT", 30*time.Second)
+	requestSlots  chan struct{}
+)
+
+// limitsMiddleware enforces the concurrency and body size limits.
+func limitsMiddleware() gin.HandlerFunc {
+	if maxConcurrent > 0 {
+		requestSlots = make(chan struct{}, maxConcurrent)
+	}
+	return func(c *gin.Context) {
+		if requestSlots != nil {
+			timer := time.NewTimer(queueTimeout)
+			select {
+			case requestSlots <- struct{}{}:
+				timer.Stop()
+				defer func() { <-requestSlots }()
+			case <-timer.C:
+				c.Header("Retry-After", strconv.Itoa(max(1, int(queueTimeout.Seconds()))))
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Server busy, try again"})
+				return
+			case <-c.Request.Context().Done():
+				timer.Stop()
+				c.Abort()
+				return
+			}
+		}
+		if maxBodyBytes > 0 && c.Request.Body != nil {
+			if c.Request.ContentLength > maxBodyBytes {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+				return
+			}
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes)
+		}
+		c.Next()
+	}
+}
+
+// cappedBuffer keeps the first limit bytes written to it and drops the
+// rest, so a chatty child process can't grow memory without bound.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.limit > 0 {
+		room := b.limit - b.buf.Len()
+		if room <= 0 {
+			b.truncated = true
+			return len(p), nil
+		}
+		if len(p) > room {
+			b.buf.Write(p[:room])
+			b.truncated = true
+			return len(p), nil
+		}
+	}
+	return b.buf.Write(p)
+}
+
+func (b *cappedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n… output truncated"
+	}
+	return b.buf.String()
+}
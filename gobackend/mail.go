@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outgoing mail (reports, email notifications) goes through one SMTP
+// server:
+//
+//	PORTMONOTE_SMTP_HOST=mail.example.com
+//	PORTMONOTE_SMTP_PORT=587              STARTTLS when offered
+//	PORTMONOTE_SMTP_TLS=true              TLS from the start (port 465)
+//	PORTMONOTE_SMTP_USERNAME / PORTMONOTE_SMTP_PASSWORD
+//	PORTMONOTE_SMTP_FROM=portmonote@example.com
+var smtpSettings = struct {
+	Host     string
+	Port     int
+	TLS      bool
+	Username string
+	Password string
+	From     string
+}{
+	Host:     envString("PORTMONOTE_SMTP_HOST", ""),
+	Port:     envInt("PORTMONOTE_SMTP_PORT", 587),
+	TLS:      envBool("PORTMONOTE_SMTP_TLS", false),
+	Username: envString("PORTMONOTE_SMTP_USERNAME", ""),
+	Password: envString("PORTMONOTE_SMTP_PASSWORD", ""),
+	From:     envString("PORTMONOTE_SMTP_FROM", "portmonote@localhost"),
+}
+
+const smtpTimeout = 30 * time.Second
+
+var errSMTPUnconfigured = errors.New("SMTP is not configured (PORTMONOTE_SMTP_HOST)")
+
+// sendMail sends a message with a plain text body and, if html is set, an
+// HTML alternative.
+func sendMail(to []string, subject, text, html string) error {
+	if smtpSettings.Host == "" {
+		return errSMTPUnconfigured
+	}
+	if len(to) == 0 {
+		return errors.New("no recipients")
+	}
+	addr := net.JoinHostPort(smtpSettings.Host, strconv.Itoa(smtpSettings.Port))
+	var conn net.Conn
+	var err error
+	if smtpSettings.TLS {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: smtpTimeout}, "tcp", addr, &tls.Config{ServerName: smtpSettings.Host})
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, smtpTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+	client, err := smtp.NewClient(conn, smtpSettings.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && !smtpSettings.TLS {
+		if err := client.StartTLS(&tls.Config{ServerName: smtpSettings.Host}); err != nil {
+			return err
+		}
+	}
+	if smtpSettings.Username != "" {
+		// PlainAuth refuses to send the password without TLS, except to localhost
+		if err := client.Auth(smtp.PlainAuth("", smtpSettings.Username, smtpSettings.Password, smtpSettings.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(smtpSettings.From); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("%s: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildMessage(to, subject, text, html)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMessage renders the MIME message: text/plain, or
+// multipart/alternative with an HTML part.
+func buildMessage(to []string, subject, text, html string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", smtpSettings.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	part := func(contentType, body string) {
+		fmt.Fprintf(&b, "Content-Type: %s; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n", contentType)
+		qp := quotedprintable.NewWriter(&b)
+		qp.Write([]byte(body))
+		qp.Close()
+		b.WriteString("\r\n")
+	}
+	if html == "" {
+		part("text/plain", text)
+		return b.Bytes()
+	}
+	var raw [12]byte
+	rand.Read(raw[:])
+	boundary := "portmonote-" + hex.EncodeToString(raw[:])
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	part("text/plain", text)
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	part("text/html", html)
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes()
+}
+
+// emailSender is the email notification channel. Messages arriving within
+// the batch window go out as one mail, so a burst of new ports doesn't
+// flood the operators' inboxes.
+type emailSender struct {
+	to     []string
+	window time.Duration
+
+	mu      sync.Mutex
+	pending []Notification
+	timer   *time.Timer
+}
+
+func newEmailSender(cfg ChannelConfig) (Sender, error) {
+	if len(cfg.To) == 0 {
+		return nil, fmt.Errorf("to is required")
+	}
+	if smtpSettings.Host == "" {
+		return nil, errSMTPUnconfigured
+	}
+	s := &emailSender{to: cfg.To, window: 5 * time.Minute}
+	if cfg.Batch != "" {
+		d, err := time.ParseDuration(cfg.Batch)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid batch %q", cfg.Batch)
+		}
+		s.window = d
+	}
+	return s, nil
+}
+
+// Send queues n for the next batch; digests and unbatched channels mail
+// right away.
+func (s *emailSender) Send(n Notification) error {
+	if s.window == 0 || n.Digest > 0 {
+		return s.mail([]Notification{n})
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, n)
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.window, s.flush)
+	}
+	return nil
+}
+
+// sendNow mails n at once (test notifications).
+func (s *emailSender) sendNow(n Notification) error {
+	return s.mail([]Notification{n})
+}
+
+func (s *emailSender) flush() {
+	s.mu.Lock()
+	batch := s.pending
+	s.pending, s.timer = nil, nil
+	s.mu.Unlock()
+	if err := s.mail(batch); err != nil {
+		log.Printf("Email to %s failed, %d message(s) lost: %v", strings.Join(s.to, ", "), len(batch), err)
+	}
+}
+
+func (s *emailSender) mail(batch []Notification) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	if len(batch) == 1 {
+		return sendMail(s.to, batch[0].Title, batch[0].Body, "")
+	}
+	var body strings.Builder
+	for i, n := range batch {
+		if i > 0 {
+			body.WriteString("\n\n----\n\n")
+		}
+		fmt.Fprintf(&body, "%s [%s]\n\n%s", n.Title, n.Severity, n.Body)
+	}
+	return sendMail(s.to, fmt.Sprintf("[portmonote] %d notifications", len(batch)), body.String(), "")
+}
@@ -1,32 +1,123 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// command is one `portmonote <name>` subcommand. Each parses its own flags.
+type command struct {
+	summary string
+	run     func(args []string)
+}
+
+var commands = map[string]command{
+	"serve":   {"Run the collector and web UI (default)", runServe},
+	"import":  {"Import legacy JSON exports or the legacy SQLite database", runImport},
+	"export":  {"Write the database as JSON (same format as GET /export)", runExport},
+	"scan":    {"Scan listening ports once and print them (no server)", runScan},
+	"check":   {"Check listening ports against a policy file, exit 1 on violations", runCheck},
+	"merge":   {"Merge another instance's SQLite database into this one", runMerge},
+	"admin":   {"Maintenance commands (rehost)", runAdmin},
+	"version": {"Print version and build information", runVersion},
+}
+
 func main() {
+	// No subcommand keeps the historical behaviour: run the server
+	if len(os.Args) < 2 {
+		runServe(nil)
+		return
+	}
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", name)
+		printUsage()
+		os.Exit(1)
+	}
+	cmd.run(os.Args[2:])
+}
+
+func printUsage() {
+	fmt.Println("Usage: portmonote <command> [flags]")
+	fmt.Println()
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Println()
+	fmt.Println("Run 'portmonote <command> -h' for command flags.")
+}
+
+// How long shutdown waits for requests and a running collection cycle
+var shutdownTimeout = envDuration("PORTMONOTE_SHUTDOWN_TIMEOUT", 15*time.Second)
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", envString("PORTMONOTE_ADDR", ":2008"), "Listen address")
+	tlsOpts := tlsOptionsFromEnv()
+	fs.StringVar(&tlsOpts.CertFile, "tls-cert", tlsOpts.CertFile, "TLS certificate file")
+	fs.StringVar(&tlsOpts.KeyFile, "tls-key", tlsOpts.KeyFile, "TLS private key file")
+	autocertHosts := fs.String("autocert", strings.Join(tlsOpts.AutocertHosts, ","), "Hostnames for Let's Encrypt certificates (comma-separated)")
+	fs.Parse(args)
+	tlsOpts.AutocertHosts = splitList(*autocertHosts)
+
 	// 1. Initialize DB
 	// Try looking for DB in current dir first (Deployment), then parent (Dev)
+	dbMemory = dbMemoryRequested
 	InitDB("portmonote.db")
 
-	// 2. Start Collector (Background)
-	go func() {
-		// Run immediately
-		RunCollectionCycle()
+	// Missing privileges, with fixes (diagnostics.go)
+	logDiagnostics()
 
-		// Run every 1 minute
-		ticker := time.NewTicker(1 * time.Minute)
-		for range ticker.C {
-			RunCollectionCycle()
-		}
-	}()
+	// Login users/sessions (no-op unless PORTMONOTE_AUTH=session)
+	startSessionAuth()
+
+	// Notification channels (no-op unless configured)
+	startNotifier()
+	startHooks()
+
+	// SIGINT/SIGTERM stop the collector and drain the server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 2. Start Collector (Background): now, then every minute
+	loadCollectorState()
+	startJobs(ctx)
+	go runCollector(ctx)
+	go runListenWatch(ctx)
+
+	// Event retention (no-op unless configured)
+	startPruneLoop(ctx)
+	startDeviceConfigSync(ctx)
+	startDBCheckpoints(ctx)
+	startHostOfflineCheck(ctx)
+	startReports(ctx)
+	startTickets(ctx)
 
 	// 3. Setup Web Server
-	r := gin.Default()
+	r := gin.New()
+	r.Use(requestLogger(), gin.Recovery())
 
 	// Serve Static Files (Frontend assets except index.html)
 	// We handle index.html manually for CSRF injection
@@ -39,8 +130,44 @@ func main() {
 	InitHandlers(r) // Defined in handlers.go
 
 	// Start Server
-	log.Println("Portmonote Go Backend running on :2008")
-	if err := r.Run(":2008"); err != nil {
-		log.Fatal(err)
+	log.Printf("Portmonote Go Backend %s running on %s", Version, *addr)
+	if err := serve(ctx, r, *addr, tlsOpts, shutdownTimeout); err != nil {
+		log.Println("❌ Server error:", err)
+	}
+
+	log.Println("🛑 Shutting down...")
+	stop()
+	if !waitForJobs(shutdownTimeout) {
+		log.Println("⚠️ Background jobs still running after timeout, closing anyway")
+	}
+	if !waitForCycles(shutdownTimeout) {
+		log.Println("⚠️ Collection cycle still running after timeout, closing anyway")
+	}
+	CloseDB()
+	log.Println("👋 Bye")
+}
+
+// runExport implements `portmonote export [--out file]`.
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	out := fs.String("out", "-", "Output file (- for stdout)")
+	fs.Parse(args)
+
+	InitDB("portmonote.db")
+
+	w := os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("❌ Failed to create %s: %v", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := writeExport(w); err != nil {
+		log.Fatalf("❌ Export failed: %v", err)
+	}
+	if *out != "-" {
+		log.Printf("✅ Exported to %s", *out)
 	}
 }
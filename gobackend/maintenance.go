@@ -0,0 +1,304 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Maintenance windows keep planned restarts quiet. A window is a one-off
+// (starts_at/ends_at) or recurring (cron + duration, local time), and
+// matches ports like a policy rule (host glob, protocol, ports, process
+// glob; empty matches everything). During a window events are still
+// recorded, tagged with the window's ID, but they don't go out on the bus
+// (no notifications, no hooks), don't count as flaps and don't raise drift.
+type MaintenanceWindow struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	Name     string `json:"name"`
+	Host     string `json:"host,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	Ports    string `json:"ports,omitempty"`
+	Process  string `json:"process,omitempty"`
+
+	StartsAt *time.Time `json:"starts_at,omitempty"` // one-off
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+	Cron     string     `json:"cron,omitempty"`     // recurring, e.g. "0 3 * * 0"
+	Duration string     `json:"duration,omitempty"` // length of each recurrence, e.g. "2h"
+
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Active bool `gorm:"-" json:"active"` // right now
+
+	rule     PolicyRule
+	cron     *cronSpec
+	duration time.Duration
+}
+
+func (MaintenanceWindow) TableName() string {
+	return "maintenance_window"
+}
+
+const maxMaintenanceDuration = 7 * 24 * time.Hour
+
+// compile validates the window and prepares matching.
+func (w *MaintenanceWindow) compile() error {
+	p := Policy{Forbid: []PolicyRule{{Name: w.Name, Host: w.Host, Protocol: w.Protocol, Ports: w.Ports, Process: w.Process}}}
+	if err := p.compile(); err != nil {
+		return err
+	}
+	w.rule = p.Forbid[0]
+
+	switch {
+	case w.Cron != "":
+		c, err := parseCron(w.Cron)
+		if err != nil {
+			return err
+		}
+		d, err := parseWindow(w.Duration)
+		if err != nil {
+			return errors.New("recurring windows need a duration, e.g. 2h")
+		}
+		if d > maxMaintenanceDuration {
+			return fmt.Errorf("duration exceeds %s", maxMaintenanceDuration)
+		}
+		w.cron, w.duration = c, d
+	case w.StartsAt != nil && w.EndsAt != nil:
+		if !w.EndsAt.After(*w.StartsAt) {
+			return errors.New("ends_at must be after starts_at")
+		}
+	default:
+		return errors.New("set starts_at and ends_at, or cron and duration")
+	}
+	return nil
+}
+
+// activeAt reports whether the window is open at t.
+func (w *MaintenanceWindow) activeAt(t time.Time) bool {
+	if w.cron != nil {
+		_, ok := w.cron.lastStart(t, w.duration)
+		return ok
+	}
+	return !t.Before(*w.StartsAt) && t.Before(*w.EndsAt)
+}
+
+func (w *MaintenanceWindow) covers(rt *PortRuntime, t time.Time) bool {
+	item := MergedPortItem{HostID: rt.HostID, Protocol: rt.Protocol, Port: rt.Port, ProcessName: rt.ProcessName}
+	return w.rule.Matches(&item) && w.activeAt(t)
+}
+
+// Windows only change through the API, so they are cached until then.
+var (
+	maintenanceMu     sync.Mutex
+	maintenanceCache  []MaintenanceWindow
+	maintenanceLoaded bool
+)
+
+func maintenanceWindows() []MaintenanceWindow {
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+	if !maintenanceLoaded {
+		var windows []MaintenanceWindow
+		DB.Order("id").Find(&windows)
+		maintenanceCache = maintenanceCache[:0]
+		for _, w := range windows {
+			if w.compile() == nil {
+				maintenanceCache = append(maintenanceCache, w)
+			}
+		}
+		maintenanceLoaded = true
+	}
+	return maintenanceCache
+}
+
+func invalidateMaintenance() {
+	maintenanceMu.Lock()
+	maintenanceLoaded = false
+	maintenanceMu.Unlock()
+}
+
+// maintenanceFor returns the window covering rt at t, or nil.
+func maintenanceFor(rt *PortRuntime, t time.Time) *MaintenanceWindow {
+	windows := maintenanceWindows()
+	for i := range windows {
+		if windows[i].covers(rt, t) {
+			return &windows[i]
+		}
+	}
+	return nil
+}
+
+// inMaintenance is true if a window covers rt at t.
+func inMaintenance(rt *PortRuntime, t time.Time) bool {
+	return maintenanceFor(rt, t) != nil
+}
+
+// tagMaintenanceEvents marks the batch's events that happen inside a window.
+func tagMaintenanceEvents(b *eventBatch) {
+	for i := range b.events {
+		if w := maintenanceFor(&b.runtimes[i], b.events[i].Timestamp); w != nil {
+			id := w.ID
+			b.events[i].MaintenanceID = &id
+		}
+	}
+}
+
+// GET /maintenance
+func listMaintenance(c *gin.Context) {
+	now := time.Now()
+	var windows []MaintenanceWindow
+	DB.Order("id").Find(&windows)
+	for i := range windows {
+		if windows[i].compile() == nil {
+			windows[i].Active = windows[i].activeAt(now)
+		}
+	}
+	c.JSON(http.StatusOK, windows)
+}
+
+// POST /maintenance
+func createMaintenance(c *gin.Context) {
+	var w MaintenanceWindow
+	if err := c.BindJSON(&w); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	w.ID = 0
+	w.CreatedBy = actorName(c)
+	saveMaintenance(c, &w, http.StatusCreated)
+}
+
+// GET /maintenance/:id, with the next starts of a recurring window
+func getMaintenance(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var w MaintenanceWindow
+	if err := DB.First(&w, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance window not found"})
+		return
+	}
+	now := time.Now()
+	var next []time.Time
+	if w.compile() == nil {
+		w.Active = w.activeAt(now)
+		if w.cron != nil {
+			next = w.cron.starts(now, now.AddDate(0, 0, 31), 5)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"window": w, "next_starts": next})
+}
+
+// PUT /maintenance/:id replaces a window.
+func updateMaintenance(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var existing MaintenanceWindow
+	if err := DB.First(&existing, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance window not found"})
+		return
+	}
+	var w MaintenanceWindow
+	if err := c.BindJSON(&w); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	w.ID, w.CreatedBy, w.CreatedAt = existing.ID, existing.CreatedBy, existing.CreatedAt
+	saveMaintenance(c, &w, http.StatusOK)
+}
+
+func saveMaintenance(c *gin.Context, w *MaintenanceWindow, status int) {
+	if w.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if err := w.compile(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := DB.Save(w).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	invalidateMaintenance()
+	w.Active = w.activeAt(time.Now())
+	c.JSON(status, w)
+}
+
+// DELETE /maintenance/:id
+func deleteMaintenance(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	res := DB.Delete(&MaintenanceWindow{}, id)
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = gorm.ErrRecordNotFound
+	}
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance window not found"})
+		return
+	}
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
+		return
+	}
+	invalidateMaintenance()
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+// maintenanceEntries lists the windows in [since, until] for the calendar.
+func maintenanceEntries(since, until time.Time) []calendarEntry {
+	var entries []calendarEntry
+	for _, w := range maintenanceWindows() {
+		desc := "Maintenance window, alerts suppressed"
+		if scope := maintenanceScope(&w); scope != "" {
+			desc += " for " + scope
+		}
+		if w.cron == nil {
+			if w.EndsAt.Before(since) || w.StartsAt.After(until) {
+				continue
+			}
+			entries = append(entries, calendarEntry{
+				UID:   fmt.Sprintf("maintenance-%d@portmonote", w.ID),
+				Start: *w.StartsAt, End: *w.EndsAt,
+				Summary: "Maintenance: " + w.Name, Description: desc,
+			})
+			continue
+		}
+		for _, start := range w.cron.starts(since, until, 500) {
+			entries = append(entries, calendarEntry{
+				UID:   fmt.Sprintf("maintenance-%d-%d@portmonote", w.ID, start.Unix()),
+				Start: start, End: start.Add(w.duration),
+				Summary: "Maintenance: " + w.Name, Description: desc + " (" + w.Cron + ")",
+			})
+		}
+	}
+	return entries
+}
+
+func maintenanceScope(w *MaintenanceWindow) string {
+	scope := ""
+	add := func(label, v string) {
+		if v == "" {
+			return
+		}
+		if scope != "" {
+			scope += ", "
+		}
+		scope += label + " " + v
+	}
+	add("host", w.Host)
+	add("protocol", w.Protocol)
+	add("ports", w.Ports)
+	add("process", w.Process)
+	return scope
+}
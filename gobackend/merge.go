@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// MergeOptions controls how another instance's database is folded into DB.
+type MergeOptions struct {
+	HostID      string // override host_id of every source row ("" keeps it)
+	PreferNotes string // "local" keeps our note on conflict, "remote" takes theirs
+}
+
+type MergeResult struct {
+	RuntimesCreated int `json:"runtimes_created"`
+	RuntimesMerged  int `json:"runtimes_merged"`
+	EventsCreated   int `json:"events_created"`
+	EventsSkipped   int `json:"events_skipped"` // duplicates or orphaned
+	NotesCreated    int `json:"notes_created"`
+	NotesReplaced   int `json:"notes_replaced"`
+	NotesKept       int `json:"notes_kept"`
+}
+
+// mergeDatabase copies runtimes, events and notes from src into DB.
+// Runtime IDs are remapped; rows sharing a (host, proto, port) key are merged
+// rather than duplicated, so running it twice does not double the history.
+func mergeDatabase(src *gorm.DB, opts MergeOptions) (MergeResult, error) {
+	var res MergeResult
+	if opts.PreferNotes != "local" && opts.PreferNotes != "remote" {
+		return res, fmt.Errorf("invalid note preference %q (expected local or remote)", opts.PreferNotes)
+	}
+
+	var runtimes []PortRuntime
+	var events []PortEvent
+	var notes []PortNote
+	if err := src.Unscoped().Find(&runtimes).Error; err != nil {
+		return res, err
+	}
+	if err := src.Order("timestamp asc").Find(&events).Error; err != nil {
+		return res, err
+	}
+	if err := src.Unscoped().Find(&notes).Error; err != nil {
+		return res, err
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped().Session(&gorm.Session{}) // a new session, so conditions don't pile up
+		idMap := make(map[uint]uint)                // source runtime ID -> target runtime ID
+
+		for _, r := range runtimes {
+			if opts.HostID != "" {
+				r.HostID = opts.HostID
+			}
+
+			var existing PortRuntime
+			err := tx.Where("host_id = ? AND protocol = ? AND port = ?", r.HostID, r.Protocol, r.Port).First(&existing).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				srcID := r.ID
+				r.ID = 0
+				if err := tx.Create(&r).Error; err != nil {
+					return err
+				}
+				idMap[srcID] = r.ID
+				res.RuntimesCreated++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			mergeRuntimeFacts(&existing, &r)
+			if err := tx.Save(&existing).Error; err != nil {
+				return err
+			}
+			idMap[r.ID] = existing.ID
+			res.RuntimesMerged++
+		}
+
+		for _, e := range events {
+			targetID, ok := idMap[e.PortRuntimeID]
+			if !ok {
+				res.EventsSkipped++
+				continue
+			}
+
+			var dup int64
+			tx.Model(&PortEvent{}).
+				Where("port_runtime_id = ? AND event_type = ? AND timestamp = ?", targetID, e.EventType, e.Timestamp).
+				Count(&dup)
+			if dup > 0 {
+				res.EventsSkipped++
+				continue
+			}
+
+			e.ID = 0
+			e.PortRuntimeID = targetID
+			if err := tx.Create(&e).Error; err != nil {
+				return err
+			}
+			res.EventsCreated++
+		}
+
+		for _, n := range notes {
+			if opts.HostID != "" {
+				n.HostID = opts.HostID
+			}
+
+			var existing PortNote
+			err := tx.Where("host_id = ? AND protocol = ? AND port = ?", n.HostID, n.Protocol, n.Port).First(&existing).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				n.ID = 0
+				if err := tx.Create(&n).Error; err != nil {
+					return err
+				}
+				res.NotesCreated++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			if opts.PreferNotes == "local" {
+				res.NotesKept++
+				continue
+			}
+			n.ID, n.Version = existing.ID, existing.Version+1
+			if err := tx.Save(&n).Error; err != nil {
+				return err
+			}
+			res.NotesReplaced++
+		}
+		return nil
+	})
+	return res, err
+}
+
+// mergeRuntimeFacts folds src into dst: widest observation window, the
+// larger of each counter, and the current process/state of whichever side
+// saw it last. Taking the larger counter rather than the sum keeps a second
+// merge of the same source from doubling them.
+func mergeRuntimeFacts(dst, src *PortRuntime) {
+	if !src.FirstSeenAt.IsZero() && (dst.FirstSeenAt.IsZero() || src.FirstSeenAt.Before(dst.FirstSeenAt)) {
+		dst.FirstSeenAt = src.FirstSeenAt
+	}
+	if src.LastSeenAt.After(dst.LastSeenAt) {
+		dst.LastSeenAt = src.LastSeenAt
+		dst.LastDisappearedAt = src.LastDisappearedAt
+		dst.CurrentState = src.CurrentState
+		dst.CurrentPID = src.CurrentPID
+		dst.ProcessName = src.ProcessName
+		dst.Cmdline = src.Cmdline
+		dst.Username = src.Username
+		dst.ExePath = src.ExePath
+		dst.ExeSHA256 = src.ExeSHA256
+		dst.SystemdUnit = src.SystemdUnit
+		dst.SystemdSlice = src.SystemdSlice
+		dst.Fingerprint = src.Fingerprint
+	}
+	dst.PeakConnCount = max(dst.PeakConnCount, src.PeakConnCount)
+	dst.TotalSeenCount = max(dst.TotalSeenCount, src.TotalSeenCount)
+	dst.TotalUptimeSeconds = max(dst.TotalUptimeSeconds, src.TotalUptimeSeconds)
+	dst.TotalDowntimeSeconds = max(dst.TotalDowntimeSeconds, src.TotalDowntimeSeconds)
+}
+
+// runMerge implements `portmonote merge --from other.db`.
+func runMerge(args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	from := fs.String("from", "", "Path to the other instance's SQLite database")
+	host := fs.String("host", "", "Rewrite host_id of merged rows (e.g. the other machine's name)")
+	prefer := fs.String("prefer", "local", "Note conflict resolution: local or remote")
+	fs.Parse(args)
+
+	if *from == "" {
+		fmt.Println("Usage: portmonote merge --from other.db [--host build-03] [--prefer local|remote]")
+		os.Exit(1)
+	}
+	if _, err := os.Stat(*from); err != nil {
+		log.Fatalf("❌ Source database not found: %v", err)
+	}
+
+	src, err := gorm.Open(sqlite.Open("file:"+*from+"?mode=ro"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Error),
+	})
+	if err != nil {
+		log.Fatalf("❌ Failed to open source DB: %v", err)
+	}
+
+	InitDB("portmonote.db")
+	res, err := mergeDatabase(src, MergeOptions{HostID: *host, PreferNotes: *prefer})
+	if err != nil {
+		log.Fatalf("❌ Merge failed: %v", err)
+	}
+	log.Printf("✅ Merge complete: runtimes %d created / %d merged, events %d created / %d skipped, notes %d created / %d replaced / %d kept",
+		res.RuntimesCreated, res.RuntimesMerged, res.EventsCreated, res.EventsSkipped,
+		res.NotesCreated, res.NotesReplaced, res.NotesKept)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMergeDatabaseTwice(t *testing.T) {
+	openTestDB(t)
+
+	src, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "other.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.AutoMigrate(&PortRuntime{}, &PortEvent{}, &PortNote{}); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := time.Now().Add(-time.Hour).Truncate(time.Second)
+	local := PortRuntime{HostID: "build-03", Protocol: "tcp", Port: 8080, CurrentState: "active",
+		FirstSeenAt: seen, LastSeenAt: seen, TotalSeenCount: 3, TotalUptimeSeconds: 500, TotalDowntimeSeconds: 20}
+	remote := PortRuntime{HostID: "build-03", Protocol: "tcp", Port: 8080, CurrentState: "active",
+		FirstSeenAt: seen.Add(-time.Hour), LastSeenAt: seen.Add(time.Minute), TotalSeenCount: 5, TotalUptimeSeconds: 300, TotalDowntimeSeconds: 60}
+	if err := DB.Create(&local).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Create(&remote).Error; err != nil {
+		t.Fatal(err)
+	}
+	src.Create(&PortEvent{PortRuntimeID: remote.ID, EventType: string(EventAppeared), Timestamp: seen.Add(-time.Hour)})
+
+	for run := 1; run <= 2; run++ {
+		res, err := mergeDatabase(src, MergeOptions{PreferNotes: "local"})
+		if err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		if res.RuntimesMerged != 1 || res.RuntimesCreated != 0 {
+			t.Errorf("run %d: %+v", run, res)
+		}
+
+		var got PortRuntime
+		DB.First(&got, local.ID)
+		if got.TotalSeenCount != 5 || got.TotalUptimeSeconds != 500 || got.TotalDowntimeSeconds != 60 {
+			t.Errorf("run %d: counters seen=%d up=%d down=%d, want 5/500/60",
+				run, got.TotalSeenCount, got.TotalUptimeSeconds, got.TotalDowntimeSeconds)
+		}
+		if !got.FirstSeenAt.Equal(remote.FirstSeenAt) {
+			t.Errorf("run %d: first seen %s, want %s", run, got.FirstSeenAt, remote.FirstSeenAt)
+		}
+		var events int64
+		DB.Model(&PortEvent{}).Where("port_runtime_id = ?", local.ID).Count(&events)
+		if events != 1 {
+			t.Errorf("run %d: %d events, want 1", run, events)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Protocol string
@@ -21,12 +23,21 @@ const (
 type EventType string
 
 const (
-	EventAppeared      EventType = "appeared"
-	EventAlive         EventType = "alive"
-	EventDisappeared   EventType = "disappeared"
-	EventProcessChange EventType = "process_change"
-	EventAcknowledged  EventType = "acknowledged"
-	EventDiagnosis     EventType = "diagnosis" // New type for witr
+	EventAppeared        EventType = "appeared"
+	EventAlive           EventType = "alive"
+	EventDisappeared     EventType = "disappeared"
+	EventReappeared      EventType = "reappeared" // Known runtime listening again
+	EventProcessChange   EventType = "process_change"
+	EventAcknowledged    EventType = "acknowledged"
+	EventDiagnosis       EventType = "diagnosis" // New type for witr
+	EventInherited       EventType = "inherited" // Archived runtime re-linked on reappearance
+	EventPolicyViolation EventType = "policy_violation"
+	EventRunaway         EventType = "runaway"       // Listener exceeded CPU/RSS thresholds
+	EventBinaryChange    EventType = "binary_change" // Same process, different executable hash
+	EventRiskApproval    EventType = "risk_approval" // Risk downgrade requested, approved or rejected
+	EventTLSMismatch     EventType = "tls_mismatch"  // Noted as HTTPS/TLS but serving plaintext
+	EventEscalated       EventType = "escalated"     // Unacknowledged alert re-sent to the next escalation step
+	EventDrift           EventType = "drift"         // Deviates from the active baseline (baseline.go)
 )
 
 type RiskLevel string
@@ -53,9 +64,51 @@ type PortRuntime struct {
 	CurrentPID  int    `json:"current_pid"`
 	ProcessName string `json:"process_name"`
 	Cmdline     string `json:"cmdline"`
+	Username    string `json:"username"` // user the process runs as
+	ExePath     string `json:"exe_path"`
+	ExeSHA256   string `gorm:"column:exe_sha256" json:"exe_sha256"`
+
+	SystemdUnit  string `gorm:"index" json:"systemd_unit,omitempty"` // e.g. nginx.service
+	SystemdSlice string `json:"systemd_slice,omitempty"`
+
+	ConnCount     int    `json:"conn_count"`               // ESTABLISHED connections in the last cycle
+	PeakConnCount int    `json:"peak_conn_count"`          // highest ConnCount ever seen
+	Fingerprint   string `gorm:"index" json:"fingerprint"` // process identity, used to re-link archived runtimes
+
+	// Kubernetes attribution (PORTMONOTE_K8S), empty for non-pod listeners
+	PodName      string `json:"pod_name"`
+	PodNamespace string `json:"pod_namespace"`
+	PodContainer string `json:"pod_container"`
+
+	PolicyViolation string `json:"policy_violation,omitempty"`  // current violation of PORTMONOTE_POLICY, empty if compliant
+	Drift           string `json:"drift,omitempty"`             // unexpected or missing against the active baseline
+	Hidden          bool   `gorm:"default:false" json:"hidden"` // left out of GET /ports (hidden.go)
+	HiddenBy        string `json:"hidden_by,omitempty"`
+
+	// Latest resource sample of the listening process (sampling.go)
+	CPUPercent float64 `json:"cpu_percent"`
+	RSSBytes   uint64  `json:"rss_bytes"`
+	Runaway    bool    `gorm:"default:false" json:"runaway"`
 
-	TotalSeenCount     int `gorm:"default:1" json:"total_seen_count"`
-	TotalUptimeSeconds int `gorm:"default:0" json:"total_uptime_seconds"`
+	// What the listener speaks on the wire (probe.go), probed once per PID
+	WireProtocol string     `json:"wire_protocol"`
+	ProbedPID    int        `gorm:"column:probed_pid" json:"-"`
+	ProbedAt     *time.Time `json:"probed_at,omitempty"`
+	HTTPStatus   int        `json:"http_status,omitempty"`
+	HTTPServer   string     `json:"http_server,omitempty"`
+	HTTPTitle    string     `json:"http_title,omitempty"`
+
+	// Appear/disappear transitions within PORTMONOTE_FLAP_WINDOW (flap.go)
+	FlapCount int    `gorm:"default:0" json:"flap_count"`
+	FlapTimes string `json:"-"` // unix seconds, comma-separated
+
+	TotalSeenCount       int        `gorm:"default:1" json:"total_seen_count"`
+	TotalUptimeSeconds   int        `gorm:"default:0" json:"total_uptime_seconds"`   // uptime.go
+	TotalDowntimeSeconds int        `gorm:"default:0" json:"total_downtime_seconds"` // between disappearing and reappearing
+	UpSince              *time.Time `json:"up_since"`                                // start of the current up-interval
+
+	// Archived (deleted from the UI). Kept so a reinstall can inherit history.
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
 	Events []PortEvent `gorm:"foreignKey:PortRuntimeID;constraint:OnDelete:CASCADE;" json:"events,omitempty"`
 }
@@ -74,6 +127,16 @@ type PortEvent struct {
 	PID           int       `json:"pid"`
 	ProcessName   string    `json:"process_name"`
 	WitrOutput    string    `json:"witr_output,omitempty"` // Store diagnosis result
+	// Parsed from WitrOutput (witr.go)
+	WitrTree      string `json:"witr_tree,omitempty"` // "systemd (pid 1) → nginx (pid 812)"
+	WitrSource    string `json:"witr_source,omitempty"`
+	WitrUnit      string `gorm:"index" json:"witr_unit,omitempty"`
+	WitrContainer string `gorm:"index" json:"witr_container,omitempty"`
+	Detail        string `json:"detail,omitempty"`                      // Free-form context, e.g. the violated policy rule
+	Actor         string `json:"actor,omitempty"`                       // Who triggered it (acknowledgements)
+	IncidentID    *uint  `gorm:"index" json:"incident_id,omitempty"`    // incident.go
+	DeploymentID  *uint  `gorm:"index" json:"deployment_id,omitempty"`  // deploy.go
+	MaintenanceID *uint  `gorm:"index" json:"maintenance_id,omitempty"` // maintenance.go
 }
 
 func (PortEvent) TableName() string {
@@ -91,7 +154,36 @@ type PortNote struct {
 	Description string `json:"description"`
 	Owner       string `json:"owner"`
 	RiskLevel   string `gorm:"default:expected" json:"risk_level"`
-	IsPinned    bool   `gorm:"default:false" json:"is_pinned"`
+
+	// Description is encrypted at rest and role-gated (notecrypt.go)
+	Sensitive           bool `gorm:"default:false" json:"sensitive"`
+	DescriptionRedacted bool `gorm:"-" json:"description_redacted,omitempty"`
+
+	IsPinned bool `gorm:"default:false" json:"is_pinned"`
+
+	// Past this date the port needs review (review.go)
+	ReviewAfter *time.Time `json:"review_after,omitempty"`
+
+	// Titled from the well-known port number, not yet confirmed (iana.go)
+	AutoGenerated bool `gorm:"default:false" json:"auto_generated"`
+
+	Tags    string `json:"tags"`                              // Comma-separated, e.g. "public,web"
+	TagRefs []Tag  `gorm:"many2many:port_note_tag;" json:"-"` // the same, as rows (tags.go)
+
+	// Per-port notification overrides (notify.go). Lists are comma-separated.
+	NotifyMuted    bool   `gorm:"default:false" json:"notify_muted"`
+	NotifyEvents   string `json:"notify_events"`   // replaces the channels' event filter, e.g. "process_change"
+	NotifyChannels string `json:"notify_channels"` // only these channels
+
+	// Version counts saves; an edit must name the version it started from
+	// (noteversion.go)
+	Version   int       `gorm:"not null;default:1" json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by,omitempty"` // Login name of the last editor
+
+	// Archived together with its runtime, restored on inheritance
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (PortNote) TableName() string {
@@ -114,27 +206,72 @@ type MergedPortItem struct {
 	CurrentPID        int        `json:"current_pid"`
 	ProcessName       string     `json:"process_name"`
 	Cmdline           string     `json:"cmdline"`
+	Username          string     `json:"username,omitempty"`
+	ExePath           string     `json:"exe_path,omitempty"`
+	ExeSHA256         string     `json:"exe_sha256,omitempty"`
+	SystemdUnit       string     `json:"systemd_unit,omitempty"`
+	SystemdSlice      string     `json:"systemd_slice,omitempty"`
+	ConnCount         int        `json:"conn_count"`
+	PeakConnCount     int        `json:"peak_conn_count"`
 	UptimeHuman       string     `json:"uptime_human"`
+	PodName           string     `json:"pod_name,omitempty"`
+	PodNamespace      string     `json:"pod_namespace,omitempty"`
+	PodContainer      string     `json:"pod_container,omitempty"`
+	CPUPercent        float64    `json:"cpu_percent"`
+	RSSBytes          uint64     `json:"rss_bytes"`
+	Runaway           bool       `json:"runaway"`
+	WireProtocol      string     `json:"wire_protocol,omitempty"`
+	HTTPStatus        int        `json:"http_status,omitempty"`
+	HTTPServer        string     `json:"http_server,omitempty"`
+	HTTPTitle         string     `json:"http_title,omitempty"`
+	TLSMismatch       bool       `json:"tls_mismatch"`
+	Drift             string     `json:"drift,omitempty"`
+	Hidden            bool       `json:"hidden,omitempty"`
+	MutedUntil        *time.Time `json:"muted_until,omitempty"`
+	FlapCount         int        `json:"flap_count"`
+	UptimeSeconds     int        `json:"uptime_seconds"`
+	DowntimeSeconds   int        `json:"downtime_seconds"`
+	UptimePercent     float64    `json:"uptime_percent"`
 
 	// Note
-	NoteID      uint   `json:"note_id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Owner       string `json:"owner"`
-	RiskLevel   string `json:"risk_level"` // Default "unknown"
-	IsPinned    bool   `json:"is_pinned"`
+	NoteID              uint       `json:"note_id"`
+	Title               string     `json:"title"`
+	Description         string     `json:"description"`
+	Sensitive           bool       `json:"sensitive"`
+	DescriptionRedacted bool       `json:"description_redacted,omitempty"`
+	Owner               string     `json:"owner"`
+	RiskLevel           string     `json:"risk_level"` // Default "unknown"
+	IsPinned            bool       `json:"is_pinned"`
+	NoteUpdatedBy       string     `json:"note_updated_by,omitempty"`
+	NoteVersion         int        `json:"note_version,omitempty"`
+	NoteAuto            bool       `json:"note_auto,omitempty"` // auto-generated, unconfirmed
+	ReviewAfter         *time.Time `json:"review_after,omitempty"`
+	Tags                string     `json:"tags"`
+	NotifyMuted         bool       `json:"notify_muted"`
+	NotifyEvents        string     `json:"notify_events"`
+	NotifyChannels      string     `json:"notify_channels"`
 
 	// Derived
-	DerivedStatus        string     `json:"derived_status"`    // healthy, flapping, suspicious, ghost
-	LatestEventType      string     `json:"latest_event_type"` // For UI warning
-	LatestEventTimestamp *time.Time `json:"latest_event_timestamp"`
+	DerivedStatus        string           `json:"derived_status"`    // healthy, flapping, suspicious, ghost
+	LatestEventType      string           `json:"latest_event_type"` // For UI warning
+	LatestEventTimestamp *time.Time       `json:"latest_event_timestamp"`
+	Acknowledgement      *Acknowledgement `json:"acknowledgement,omitempty"` // current, ack.go
 }
 
 // Note Update Request
 type NoteUpdateRequest struct {
+	Version *int `json:"version"` // required once the note exists
+
 	Title       *string `json:"title"`
 	Description *string `json:"description"`
+	Sensitive   *bool   `json:"sensitive"`
 	Owner       *string `json:"owner"`
 	RiskLevel   *string `json:"risk_level"`
 	IsPinned    *bool   `json:"is_pinned"`
+	Tags        *string `json:"tags"`
+	ReviewAfter *string `json:"review_after"` // date, RFC 3339 or window; "" clears
+
+	NotifyMuted    *bool   `json:"notify_muted"`
+	NotifyEvents   *string `json:"notify_events"`
+	NotifyChannels *string `json:"notify_channels"`
 }
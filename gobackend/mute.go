@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Mutes snooze a known-noisy port for a while: until the mute expires it
+// isn't reported as suspicious (its status is "muted") and sends no
+// notifications. Unlike the note's notify_muted flag a mute always ends.
+// Mutes are never deleted, so the table doubles as the audit trail, and
+// muting/unmuting shows up on the port's timeline.
+//
+//	POST   /ports/mute?host_id=local&protocol=tcp&port=8080 {"duration": "4h", "reason": "load test"}
+//	DELETE /ports/mute?host_id=local&protocol=tcp&port=8080
+type PortMute struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	HostID    string     `gorm:"index" json:"host_id"`
+	Protocol  string     `json:"protocol"`
+	Port      int        `json:"port"`
+	Until     time.Time  `gorm:"index" json:"until"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"` // ended early
+	EndedBy   string     `json:"ended_by,omitempty"`
+
+	Active bool `gorm:"-" json:"active"`
+}
+
+func (PortMute) TableName() string {
+	return "port_mute"
+}
+
+const (
+	EventMuted   EventType = "muted"
+	EventUnmuted EventType = "unmuted"
+)
+
+const maxMuteDuration = 90 * 24 * time.Hour
+
+func (m *PortMute) activeAt(now time.Time) bool {
+	return m.EndedAt == nil && now.Before(m.Until)
+}
+
+// activeMutes returns the running mutes by port key (fmtKey).
+func activeMutes(now time.Time) map[string]PortMute {
+	var mutes []PortMute
+	DB.Where("ended_at IS NULL AND until > ?", now).Find(&mutes)
+	out := make(map[string]PortMute, len(mutes))
+	for _, m := range mutes {
+		out[fmtKey(m.HostID, m.Protocol, m.Port)] = m
+	}
+	return out
+}
+
+// portMuted reports whether the port has a running mute.
+func portMuted(hostID, proto string, port int, now time.Time) bool {
+	var n int64
+	DB.Model(&PortMute{}).Where("host_id = ? AND protocol = ? AND port = ? AND ended_at IS NULL AND until > ?", hostID, proto, port, now).Count(&n)
+	return n > 0
+}
+
+// POST /ports/mute
+func mutePort(c *gin.Context) {
+	hostID := c.Query("host_id")
+	proto := c.Query("protocol")
+	port, _ := strconv.Atoi(c.Query("port"))
+
+	var req struct {
+		Duration string `json:"duration"`
+		Reason   string `json:"reason"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	d, err := parseWindow(req.Duration)
+	if err != nil || d <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "duration is required, e.g. 4h or 7d"})
+		return
+	}
+	if d > maxMuteDuration {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("duration exceeds %s, use the note's notify_muted for good", maxMuteDuration)})
+		return
+	}
+	if hostID == "" || proto == "" || port == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "host_id, protocol and port are required"})
+		return
+	}
+
+	now := time.Now()
+	actor := actorName(c)
+	// A new mute replaces the running one
+	endMutes(hostID, proto, port, actor, now)
+	m := PortMute{HostID: hostID, Protocol: proto, Port: port, Until: now.Add(d), Reason: req.Reason, CreatedBy: actor}
+	if err := DB.Create(&m).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	detail := "Muted until " + m.Until.Format(time.RFC3339)
+	if m.Reason != "" {
+		detail += ": " + m.Reason
+	}
+	recordMuteEvent(hostID, proto, port, EventMuted, actor, detail)
+	log.Printf("🔇 %s/%d on %s muted for %s by %s", proto, port, hostID, d, actor)
+	m.Active = true
+	c.JSON(http.StatusOK, m)
+}
+
+// DELETE /ports/mute ends the running mute early.
+func unmutePort(c *gin.Context) {
+	hostID := c.Query("host_id")
+	proto := c.Query("protocol")
+	port, _ := strconv.Atoi(c.Query("port"))
+
+	actor := actorName(c)
+	if endMutes(hostID, proto, port, actor, time.Now()) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Port is not muted"})
+		return
+	}
+	recordMuteEvent(hostID, proto, port, EventUnmuted, actor, "Mute ended early")
+	c.JSON(http.StatusOK, gin.H{"status": "unmuted"})
+}
+
+func endMutes(hostID, proto string, port int, actor string, now time.Time) int64 {
+	return DB.Model(&PortMute{}).
+		Where("host_id = ? AND protocol = ? AND port = ? AND ended_at IS NULL AND until > ?", hostID, proto, port, now).
+		Updates(map[string]any{"ended_at": now, "ended_by": actor}).RowsAffected
+}
+
+func recordMuteEvent(hostID, proto string, port int, typ EventType, actor, detail string) {
+	var rt PortRuntime
+	if DB.Where("host_id = ? AND protocol = ? AND port = ?", hostID, proto, port).First(&rt).Error != nil {
+		return
+	}
+	DB.Create(&PortEvent{
+		PortRuntimeID: rt.ID,
+		EventType:     string(typ),
+		Timestamp:     time.Now(),
+		PID:           rt.CurrentPID,
+		ProcessName:   rt.ProcessName,
+		Actor:         actor,
+		Detail:        detail,
+	})
+}
+
+// GET /mutes lists mutes, newest first; ?active=true for running ones only,
+// ?host_id=&protocol=&port= for one port's history.
+func listMutes(c *gin.Context) {
+	now := time.Now()
+	q := DB.Order("created_at desc").Limit(500)
+	if c.Query("active") == "true" {
+		q = q.Where("ended_at IS NULL AND until > ?", now)
+	}
+	if h := c.Query("host_id"); h != "" {
+		q = q.Where("host_id = ?", h)
+	}
+	if p := c.Query("protocol"); p != "" {
+		q = q.Where("protocol = ?", p)
+	}
+	if p, err := strconv.Atoi(c.Query("port")); err == nil {
+		q = q.Where("port = ?", p)
+	}
+	var mutes []PortMute
+	q.Find(&mutes)
+	for i := range mutes {
+		mutes[i].Active = mutes[i].activeAt(now)
+	}
+	c.JSON(http.StatusOK, mutes)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Sensitive notes: a note's description can be marked sensitive (passwords
+// hints, vendor contracts, ...). It is then stored AES-256-GCM encrypted with
+// the server key and only shown to users with at least
+// PORTMONOTE_SENSITIVE_ROLE (default editor); everyone else sees the title
+// and owner and an empty, redacted description.
+//
+// The key is 32 bytes, hex or base64, from PORTMONOTE_NOTE_KEY or the file
+// named by PORTMONOTE_NOTE_KEY_FILE. Without it, notes can't be marked
+// sensitive. Exports carry the ciphertext, so keep the key with the backups.
+var (
+	noteKey       = loadNoteKey()
+	sensitiveRole = envString("PORTMONOTE_SENSITIVE_ROLE", RoleEditor)
+)
+
+const encPrefix = "enc:v1:"
+
+var errNoNoteKey = errors.New("no note encryption key configured (PORTMONOTE_NOTE_KEY)")
+
+func loadNoteKey() []byte {
+	raw := envString("PORTMONOTE_NOTE_KEY", "")
+	if file := envString("PORTMONOTE_NOTE_KEY_FILE", ""); raw == "" && file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			log.Printf("⚠️ Cannot read note key: %v", err)
+			return nil
+		}
+		raw = strings.TrimSpace(string(data))
+	}
+	if raw == "" {
+		return nil
+	}
+	if key, err := hex.DecodeString(raw); err == nil && len(key) == 32 {
+		return key
+	}
+	if key, err := base64.StdEncoding.DecodeString(raw); err == nil && len(key) == 32 {
+		return key
+	}
+	log.Println("⚠️ Note key must be 32 bytes, hex or base64; sensitive notes disabled")
+	return nil
+}
+
+func noteCipher() (cipher.AEAD, error) {
+	if noteKey == nil {
+		return nil, errNoNoteKey
+	}
+	block, err := aes.NewCipher(noteKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptNoteText(plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	aead, err := noteCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plain), nil)
+	return encPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptNoteText(s string) (string, error) {
+	data, ok := strings.CutPrefix(s, encPrefix)
+	if !ok {
+		return s, nil // stored before it was marked sensitive
+	}
+	aead, err := noteCipher()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(data)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("corrupt encrypted note")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func canReadSensitive(c *gin.Context) bool {
+	return roleRank(c.GetString("role")) >= roleRank(sensitiveRole)
+}
+
+// revealDescription returns what this request may see of a note's
+// description, and whether it was withheld.
+func revealDescription(c *gin.Context, n *PortNote) (string, bool) {
+	if !n.Sensitive {
+		return n.Description, false
+	}
+	if !canReadSensitive(c) {
+		return "", true
+	}
+	plain, err := decryptNoteText(n.Description)
+	if err != nil {
+		log.Printf("Cannot decrypt note #%d: %v", n.ID, err)
+		return "", true
+	}
+	return plain, false
+}
+
+// revealNote rewrites a loaded note for the response.
+func revealNote(c *gin.Context, n *PortNote) {
+	n.Description, n.DescriptionRedacted = revealDescription(c, n)
+}
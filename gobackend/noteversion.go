@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Notes use optimistic concurrency: every save bumps the version, and an
+// edit naming an older version than the stored one is refused with 409 and
+// the current note, instead of silently overwriting the other edit.
+var errNoteConflict = errors.New("the note was changed by someone else")
+
+// saveNote creates the note, or updates it if its version is still the one
+// it was loaded with, and bumps the version.
+func saveNote(tx *gorm.DB, note *PortNote) error {
+	if note.ID == 0 {
+		note.Version = 1
+		return tx.Create(note).Error
+	}
+	loaded := note.Version
+	note.Version++
+	res := tx.Model(note).Select("*").Omit("TagRefs").Where("version = ?", loaded).Updates(note)
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = errNoteConflict
+	}
+	if res.Error != nil {
+		note.Version = loaded
+	}
+	return res.Error
+}
+
+// noteConflict answers with the stored note so the client can merge.
+func noteConflict(c *gin.Context, status int, hostID, proto string, port int) {
+	var current PortNote
+	DB.Where("host_id = ? AND protocol = ? AND port = ?", hostID, proto, port).First(&current)
+	revealNote(c, &current)
+	msg := errNoteConflict.Error()
+	if status == http.StatusPreconditionRequired {
+		msg = "version is required to edit an existing note"
+	}
+	c.JSON(status, gin.H{"error": msg, "current": current})
+}
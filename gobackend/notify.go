@@ -0,0 +1,583 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Notifications are configured in a YAML file (PORTMONOTE_NOTIFY_CONFIG):
+//
+//	dashboard_url: https://portmonote.lan
+//	channels:
+//	  - name: ops
+//	    type: webhook
+//	    url: https://hooks.example.com/portmonote
+//	    events: [appeared, process_change]
+//	    title_template: "{{.Runtime.HostID}}: {{.Event.EventType}} on :{{.Runtime.Port}}"
+//	    template: |
+//	      {{.Event.ProcessName}} {{with .Note.Title}}({{.}}){{end}}
+//	      {{range links .Note.Description}}Runbook: {{.}}{{end}}
+//	    min_severity: warning
+//	    quiet_hours: {start: "22:00", end: "07:00", min_severity: critical}
+//	  - name: team
+//	    type: slack      # or discord; an incoming webhook url, or token + channel
+//	    url: https://hooks.slack.com/services/...
+//	  - name: phone
+//	    type: ntfy       # server url (default https://ntfy.sh), topic, optional token
+//	    topic: my-server-ports
+//	  - name: operators
+//	    type: email
+//	    to: [ops@example.com]
+//	    events: [appeared, process_change]
+//	    min_severity: warning   # appeared only on ports without a note
+//	    batch: 10m
+//
+// Templates are Go text/templates over NotificationData. During quiet hours
+// only messages at or above quiet_hours.min_severity go out; the rest are
+// stored and sent as one digest when the quiet period ends.
+var notifyConfigFile = envString("PORTMONOTE_NOTIFY_CONFIG", "")
+
+type NotifyConfig struct {
+	DashboardURL string          `yaml:"dashboard_url"`
+	Channels     []ChannelConfig `yaml:"channels"`
+
+	Escalations []EscalationPolicy `yaml:"escalations"` // escalation.go
+	Routes      []NotifyRoute      `yaml:"routes"`      // notify_routes.go
+}
+
+type ChannelConfig struct {
+	Name          string            `yaml:"name"`
+	Type          string            `yaml:"type"`
+	URL           string            `yaml:"url"`
+	Headers       map[string]string `yaml:"headers"`
+	Events        []string          `yaml:"events"` // empty = defaultNotifyEvents
+	TitleTemplate string            `yaml:"title_template"`
+	Template      string            `yaml:"template"`
+	MinSeverity   string            `yaml:"min_severity"` // info (default), warning, critical
+	QuietHours    *QuietHours       `yaml:"quiet_hours"`
+
+	// Slack bot token (notify_chat.go), ntfy access token or Gotify
+	// application token (notify_push.go)
+	Token   string `yaml:"token"`
+	Channel string `yaml:"channel"` // slack, with a bot token
+	Topic   string `yaml:"topic"`   // ntfy
+
+	// type: email (mail.go)
+	To    []string `yaml:"to"`
+	Batch string   `yaml:"batch"` // collect messages this long into one mail; default 5m, "0" sends each
+}
+
+// QuietHours is a daily window, e.g. 22:00-07:00 (may wrap midnight).
+type QuietHours struct {
+	Start       string `yaml:"start"`
+	End         string `yaml:"end"`
+	Timezone    string `yaml:"timezone"`     // default: server local time
+	MinSeverity string `yaml:"min_severity"` // still delivered during the window; empty = nothing
+
+	start, end int // minutes after midnight
+	loc        *time.Location
+}
+
+func (q *QuietHours) compile() error {
+	var err error
+	if q.start, err = parseClock(q.Start); err != nil {
+		return err
+	}
+	if q.end, err = parseClock(q.End); err != nil {
+		return err
+	}
+	q.loc = time.Local
+	if q.Timezone != "" {
+		if q.loc, err = time.LoadLocation(q.Timezone); err != nil {
+			return err
+		}
+	}
+	if q.MinSeverity != "" && severityRank(q.MinSeverity) < 0 {
+		return fmt.Errorf("unknown severity %q", q.MinSeverity)
+	}
+	return nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (q *QuietHours) Active(now time.Time) bool {
+	local := now.In(q.loc)
+	m := local.Hour()*60 + local.Minute()
+	if q.start <= q.end {
+		return m >= q.start && m < q.end
+	}
+	return m >= q.start || m < q.end // wraps midnight
+}
+
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+func severityRank(s string) int {
+	switch s {
+	case SeverityInfo, "":
+		return 0
+	case SeverityWarning:
+		return 1
+	case SeverityCritical:
+		return 2
+	}
+	return -1
+}
+
+// eventSeverity grades an event for channel thresholds.
+func eventSeverity(data NotificationData) string {
+	unknown := !data.HasNote || data.Note.RiskLevel == string(RiskSuspicious)
+	switch EventType(data.Event.EventType) {
+	case EventProcessChange, EventBinaryChange, EventPolicyViolation:
+		return SeverityCritical
+	case EventRunaway, EventTLSMismatch, EventDrift:
+		return SeverityWarning
+	case EventAppeared:
+		if unknown {
+			return SeverityWarning
+		}
+	case EventDisappeared:
+		if data.HasNote && data.Note.RiskLevel == string(RiskTrusted) {
+			return SeverityWarning
+		}
+	}
+	return SeverityInfo
+}
+
+// PendingNotification holds messages held back by quiet hours until the
+// channel's next digest.
+type PendingNotification struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Channel   string    `gorm:"index" json:"channel"`
+	Route     string    `gorm:"index;default:''" json:"route,omitempty"` // held by a route rather than the channel
+	Severity  string    `json:"severity"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (PendingNotification) TableName() string {
+	return "pending_notification"
+}
+
+// NotificationData is what templates see.
+type NotificationData struct {
+	Event    PortEvent
+	Runtime  PortRuntime
+	Note     PortNote
+	HasNote  bool
+	Link     string // dashboard
+	PortLink string // the port in the dashboard
+}
+
+// Notification is a rendered message handed to a sender.
+type Notification struct {
+	Title    string
+	Body     string
+	Severity string
+	Data     NotificationData
+	Digest   int // >0: a quiet-hours digest bundling this many messages
+}
+
+// Sender delivers to one kind of channel.
+type Sender interface {
+	Send(n Notification) error
+}
+
+var senderFactories = map[string]func(cfg ChannelConfig) (Sender, error){
+	"webhook": newWebhookSender,
+	"email":   newEmailSender,
+	"slack":   newSlackSender,
+	"discord": newDiscordSender,
+	"ntfy":    newNtfySender,
+	"gotify":  newGotifySender,
+}
+
+var defaultNotifyEvents = []string{
+	string(EventAppeared),
+	string(EventDisappeared),
+	string(EventProcessChange),
+	string(EventPolicyViolation),
+}
+
+const defaultTitleTemplate = `[portmonote] {{.Event.EventType}} {{.Runtime.Protocol}}/{{.Runtime.Port}} on {{.Runtime.HostID}}`
+
+const defaultBodyTemplate = `{{.Event.EventType}}: {{.Runtime.Protocol}}/{{.Runtime.Port}} on {{.Runtime.HostID}}
+Process: {{.Event.ProcessName}} (pid {{.Event.PID}})
+{{if .HasNote}}Note: {{.Note.Title}} (owner: {{or .Note.Owner "-"}}, risk: {{.Note.RiskLevel}}){{else}}No note: unknown port{{end}}
+{{- with .Event.Detail}}
+Detail: {{.}}{{end}}
+{{- with .PortLink}}
+{{.}}{{end}}`
+
+var urlPattern = regexp.MustCompile(`https?://[^\s)>\]]+`)
+
+var templateFuncs = template.FuncMap{
+	"links": func(s string) []string { return urlPattern.FindAllString(s, -1) },
+	"upper": strings.ToUpper,
+	"since": func(t time.Time) string { return formatDuration(time.Since(t)) },
+}
+
+type notifyChannel struct {
+	cfg    ChannelConfig
+	title  *template.Template
+	body   *template.Template
+	sender Sender
+}
+
+func (ch *notifyChannel) wants(eventType string) bool {
+	events := ch.cfg.Events
+	if len(events) == 0 {
+		events = defaultNotifyEvents
+	}
+	return slices.Contains(events, eventType)
+}
+
+func (ch *notifyChannel) render(data NotificationData) (Notification, error) {
+	var title, body bytes.Buffer
+	if err := ch.title.Execute(&title, data); err != nil {
+		return Notification{}, err
+	}
+	if err := ch.body.Execute(&body, data); err != nil {
+		return Notification{}, err
+	}
+	return Notification{Title: strings.TrimSpace(title.String()), Body: strings.TrimSpace(body.String()), Data: data}, nil
+}
+
+var (
+	notifyChannels []*notifyChannel
+	notifyDashURL  string
+	notifyQueue    chan notifyJob
+)
+
+type notifyJob struct {
+	event   PortEvent
+	runtime PortRuntime
+}
+
+func loadNotifyConfig(file string) (*NotifyConfig, []*notifyChannel, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cfg NotifyConfig
+	if err := yaml.UnmarshalWithOptions(raw, &cfg, yaml.Strict()); err != nil {
+		return nil, nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+
+	var channels []*notifyChannel
+	for _, cc := range cfg.Channels {
+		ch, err := buildChannel(cc)
+		if err != nil {
+			return nil, nil, err
+		}
+		channels = append(channels, ch)
+	}
+	if err := validateEscalations(cfg.Escalations, channels); err != nil {
+		return nil, nil, err
+	}
+	if _, err := validateRoutes(cfg.Routes, channels); err != nil {
+		return nil, nil, err
+	}
+	return &cfg, channels, nil
+}
+
+func buildChannel(cc ChannelConfig) (*notifyChannel, error) {
+	factory, ok := senderFactories[cc.Type]
+	if !ok {
+		return nil, fmt.Errorf("channel %q: unknown type %q", cc.Name, cc.Type)
+	}
+	sender, err := factory(cc)
+	if err != nil {
+		return nil, fmt.Errorf("channel %q: %w", cc.Name, err)
+	}
+
+	titleSrc, bodySrc := cc.TitleTemplate, cc.Template
+	if titleSrc == "" {
+		titleSrc = defaultTitleTemplate
+	}
+	if bodySrc == "" {
+		bodySrc = defaultBodyTemplate
+	}
+	title, err := template.New(cc.Name + "-title").Funcs(templateFuncs).Parse(titleSrc)
+	if err != nil {
+		return nil, fmt.Errorf("channel %q title template: %w", cc.Name, err)
+	}
+	body, err := template.New(cc.Name).Funcs(templateFuncs).Parse(bodySrc)
+	if err != nil {
+		return nil, fmt.Errorf("channel %q template: %w", cc.Name, err)
+	}
+	if severityRank(cc.MinSeverity) < 0 {
+		return nil, fmt.Errorf("channel %q: unknown min_severity %q", cc.Name, cc.MinSeverity)
+	}
+	if cc.QuietHours != nil {
+		if err := cc.QuietHours.compile(); err != nil {
+			return nil, fmt.Errorf("channel %q quiet_hours: %w", cc.Name, err)
+		}
+	}
+	return &notifyChannel{cfg: cc, title: title, body: body, sender: sender}, nil
+}
+
+// startNotifier loads the channel config and starts the delivery worker.
+// Only the server calls it; one-shot commands never send notifications.
+func startNotifier() {
+	if notifyConfigFile == "" {
+		return
+	}
+	cfg, channels, err := loadNotifyConfig(notifyConfigFile)
+	if err != nil {
+		log.Printf("⚠️ Notifications disabled: %v", err)
+		return
+	}
+	notifyChannels = channels
+	notifyDashURL = cfg.DashboardURL
+	escalationPolicies = cfg.Escalations
+	notifyRoutes, _ = validateRoutes(cfg.Routes, channels)
+	notifyQueue = make(chan notifyJob, 256)
+	subscribe("notify", func(e BusEvent) { notifyEvent(*e.Event, *e.Runtime) }, TopicPortEvent)
+	log.Printf("🔔 Notifications: %d channel(s), %d route(s) configured", len(channels), len(notifyRoutes))
+
+	go func() {
+		for job := range notifyQueue {
+			deliver(job)
+		}
+	}()
+
+	// Flush digests once quiet hours are over; fire due escalation steps
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		for now := range ticker.C {
+			flushDigests(now)
+			flushRouteDigests(now)
+			runEscalations(now)
+		}
+	}()
+}
+
+// notifyEvent queues an event; it never blocks the collector.
+func notifyEvent(evt PortEvent, runtime PortRuntime) {
+	if notifyQueue == nil {
+		return
+	}
+	select {
+	case notifyQueue <- notifyJob{event: evt, runtime: runtime}:
+	default:
+		log.Printf("⚠️ Notification queue full, dropping %s event for port %d", evt.EventType, runtime.Port)
+	}
+}
+
+// portLink opens the port's note in the dashboard, if its URL is set.
+func portLink(rt PortRuntime) string {
+	if notifyDashURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/#port=%s/%s/%d", strings.TrimSuffix(notifyDashURL, "/"), url.PathEscape(rt.HostID), rt.Protocol, rt.Port)
+}
+
+func deliver(job notifyJob) {
+	data := NotificationData{Event: job.event, Runtime: job.runtime, Link: notifyDashURL, PortLink: portLink(job.runtime)}
+	if err := DB.Where("host_id = ? AND protocol = ? AND port = ?", job.runtime.HostID, job.runtime.Protocol, job.runtime.Port).
+		First(&data.Note).Error; err == nil {
+		data.HasNote = true
+	}
+
+	// The port's note may mute it, narrow the events or pick the channels
+	if data.HasNote && data.Note.NotifyMuted {
+		return
+	}
+	if portMuted(job.runtime.HostID, job.runtime.Protocol, job.runtime.Port, time.Now()) {
+		return
+	}
+	startEscalations(data)
+
+	var onlyEvents, onlyChannels []string
+	if data.HasNote {
+		onlyEvents = splitList(data.Note.NotifyEvents)
+		onlyChannels = splitList(data.Note.NotifyChannels)
+	}
+
+	severity := eventSeverity(data)
+	if len(onlyEvents) > 0 && !slices.Contains(onlyEvents, job.event.EventType) {
+		return
+	}
+
+	// A matching route replaces the channels' own filters (notify_routes.go)
+	if route := matchRoute(data, severity); route != nil {
+		for _, name := range route.Channels {
+			ch := findChannel(notifyChannels, name)
+			if ch == nil || (len(onlyChannels) > 0 && !slices.Contains(onlyChannels, name)) {
+				continue
+			}
+			if n, ok := ch.renderFor(data, severity); ok {
+				route.dispatch(ch, n, time.Now())
+			}
+		}
+		return
+	}
+
+	for _, ch := range notifyChannels {
+		if len(onlyChannels) > 0 && !slices.Contains(onlyChannels, ch.cfg.Name) {
+			continue
+		}
+		if len(onlyEvents) == 0 && !ch.wants(job.event.EventType) {
+			continue
+		}
+		if severityRank(severity) < severityRank(ch.cfg.MinSeverity) {
+			continue
+		}
+		if n, ok := ch.renderFor(data, severity); ok {
+			ch.dispatch(n, time.Now())
+		}
+	}
+}
+
+// renderFor renders data for the channel, logging template errors.
+func (ch *notifyChannel) renderFor(data NotificationData, severity string) (Notification, bool) {
+	n, err := ch.render(data)
+	if err != nil {
+		log.Printf("Notification template error on channel %s: %v", ch.cfg.Name, err)
+		return n, false
+	}
+	n.Severity = severity
+	return n, true
+}
+
+// splitList parses "a, b,c" into trimmed, non-empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// dispatch sends now, or parks the message for the digest during quiet hours.
+func (ch *notifyChannel) dispatch(n Notification, now time.Time) {
+	if q := ch.cfg.QuietHours; q != nil && q.Active(now) {
+		if q.MinSeverity == "" || severityRank(n.Severity) < severityRank(q.MinSeverity) {
+			DB.Create(&PendingNotification{Channel: ch.cfg.Name, Severity: n.Severity, Title: n.Title, Body: n.Body})
+			return
+		}
+	}
+	if err := ch.sender.Send(n); err != nil {
+		log.Printf("Notification to %s failed: %v", ch.cfg.Name, err)
+	}
+}
+
+// flushDigests sends one digest per channel whose quiet hours have ended.
+func flushDigests(now time.Time) {
+	for _, ch := range notifyChannels {
+		if q := ch.cfg.QuietHours; q != nil && q.Active(now) {
+			continue
+		}
+		var pending []PendingNotification
+		DB.Where("channel = ? AND route = ?", ch.cfg.Name, "").Order("created_at").Find(&pending)
+		if len(pending) == 0 {
+			continue
+		}
+		if err := sendDigest(ch, pending, fmt.Sprintf("[portmonote] %d notification(s) during quiet hours", len(pending))); err != nil {
+			log.Printf("Digest to %s failed, will retry: %v", ch.cfg.Name, err)
+		}
+	}
+}
+
+// sendDigest sends the held messages as one and deletes them.
+func sendDigest(ch *notifyChannel, pending []PendingNotification, title string) error {
+	var body strings.Builder
+	for _, p := range pending {
+		fmt.Fprintf(&body, "• %s [%s] %s\n", p.CreatedAt.Format("15:04"), p.Severity, p.Title)
+	}
+	digest := Notification{
+		Title:    title,
+		Body:     strings.TrimSpace(body.String()),
+		Severity: SeverityInfo,
+		Digest:   len(pending),
+	}
+	if err := ch.sender.Send(digest); err != nil {
+		return err
+	}
+	ids := make([]uint, len(pending))
+	for i, p := range pending {
+		ids[i] = p.ID
+	}
+	return DB.Delete(&PendingNotification{}, ids).Error
+}
+
+// webhookSender POSTs a JSON document with the rendered text and raw data.
+type webhookSender struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newWebhookSender(cfg ChannelConfig) (Sender, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	return &webhookSender{url: cfg.URL, headers: cfg.Headers, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (w *webhookSender) Send(n Notification) error {
+	payload := map[string]any{
+		"title":    n.Title,
+		"text":     n.Body,
+		"severity": n.Severity,
+	}
+	if n.Digest > 0 {
+		payload["digest"] = n.Digest
+		return postJSON(w.client, w.url, w.headers, payload)
+	}
+	payload["event"] = n.Data.Event
+	payload["runtime"] = n.Data.Runtime
+	if n.Data.HasNote {
+		payload["note"] = n.Data.Note
+	}
+	return postJSON(w.client, w.url, w.headers, payload)
+}
+
+func postJSON(client *http.Client, url string, headers map[string]string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Chat channels render the notification as a card: title, host, port,
+// process, severity, the body, and a button or link to the port in the
+// dashboard (dashboard_url). Slack takes an incoming webhook url, or a bot
+// token plus channel; Discord a webhook url.
+
+// notificationFields are the facts shown on chat cards. Digests have none.
+func notificationFields(n Notification) [][2]string {
+	if n.Digest > 0 {
+		return nil
+	}
+	rt := n.Data.Runtime
+	process := n.Data.Event.ProcessName
+	if process == "" {
+		process = rt.ProcessName
+	}
+	fields := [][2]string{
+		{"Host", rt.HostID},
+		{"Port", rt.Protocol + "/" + strconv.Itoa(rt.Port)},
+		{"Process", fmt.Sprintf("%s (pid %d)", process, n.Data.Event.PID)},
+		{"Severity", n.Severity},
+	}
+	if n.Data.HasNote && n.Data.Note.Title != "" {
+		fields = append(fields, [2]string{"Note", n.Data.Note.Title})
+	}
+	return fields
+}
+
+type slackSender struct {
+	url     string
+	token   string
+	channel string
+	client  *http.Client
+}
+
+func newSlackSender(cfg ChannelConfig) (Sender, error) {
+	switch {
+	case cfg.URL == "" && cfg.Token == "":
+		return nil, fmt.Errorf("url (incoming webhook) or token is required")
+	case cfg.Token != "" && cfg.Channel == "":
+		return nil, fmt.Errorf("channel is required with a token")
+	}
+	return &slackSender{url: cfg.URL, token: cfg.Token, channel: cfg.Channel, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (s *slackSender) Send(n Notification) error {
+	blocks := []map[string]any{
+		{"type": "header", "text": map[string]any{"type": "plain_text", "text": truncate(n.Title, 150)}},
+	}
+	if fields := notificationFields(n); len(fields) > 0 {
+		var f []map[string]any
+		for _, kv := range fields {
+			f = append(f, map[string]any{"type": "mrkdwn", "text": "*" + kv[0] + "*\n" + kv[1]})
+		}
+		blocks = append(blocks, map[string]any{"type": "section", "fields": f})
+	}
+	if n.Body != "" {
+		blocks = append(blocks, map[string]any{"type": "section", "text": map[string]any{"type": "plain_text", "text": truncate(n.Body, 3000)}})
+	}
+	if link := n.Data.PortLink; link != "" {
+		blocks = append(blocks, map[string]any{"type": "actions", "elements": []map[string]any{{
+			"type": "button", "text": map[string]any{"type": "plain_text", "text": "Open in portmonote"}, "url": link,
+		}}})
+	}
+	payload := map[string]any{"text": n.Title, "blocks": blocks}
+
+	if s.token == "" {
+		return postJSON(s.client, s.url, nil, payload)
+	}
+	// The Web API answers 200 with {"ok": false} on errors
+	payload["channel"] = s.channel
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequest(http.MethodPost, "https://slack.com/api/chat.postMessage", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("slack returned %s", resp.Status)
+	}
+	if !result.OK {
+		return fmt.Errorf("slack: %s", result.Error)
+	}
+	return nil
+}
+
+type discordSender struct {
+	url    string
+	client *http.Client
+}
+
+func newDiscordSender(cfg ChannelConfig) (Sender, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	return &discordSender{url: cfg.URL, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// Embed colors by severity
+var discordColors = map[string]int{SeverityInfo: 0x3b82f6, SeverityWarning: 0xf59e0b, SeverityCritical: 0xef4444}
+
+func (d *discordSender) Send(n Notification) error {
+	embed := map[string]any{
+		"title":       truncate(n.Title, 256),
+		"description": truncate(n.Body, 4000),
+		"color":       discordColors[n.Severity],
+		"timestamp":   time.Now().UTC().Format(time.RFC3339),
+	}
+	if link := n.Data.PortLink; link != "" {
+		embed["url"] = link
+	}
+	var fields []map[string]any
+	for _, kv := range notificationFields(n) {
+		fields = append(fields, map[string]any{"name": kv[0], "value": truncate(kv[1], 1024), "inline": true})
+	}
+	if fields != nil {
+		embed["fields"] = fields
+	}
+	return postJSON(d.client, d.url, nil, map[string]any{"embeds": []any{embed}})
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
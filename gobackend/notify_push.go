@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Push channels for phones without a mail server: ntfy (ntfy.sh or a
+// self-hosted server; topic, optional access token) and Gotify (server
+// url, application token). Severity sets the push priority and the
+// notification opens the port in the dashboard.
+
+type ntfySender struct {
+	url    string
+	topic  string
+	token  string
+	client *http.Client
+}
+
+func newNtfySender(cfg ChannelConfig) (Sender, error) {
+	if cfg.Topic == "" {
+		return nil, fmt.Errorf("topic is required")
+	}
+	server := cfg.URL
+	if server == "" {
+		server = "https://ntfy.sh"
+	}
+	return &ntfySender{url: strings.TrimSuffix(server, "/"), topic: cfg.Topic, token: cfg.Token, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// ntfy priorities run from 1 (min) to 5 (max)
+var ntfyPriorities = map[string]int{SeverityInfo: 3, SeverityWarning: 4, SeverityCritical: 5}
+
+func (s *ntfySender) Send(n Notification) error {
+	payload := map[string]any{
+		"topic":   s.topic,
+		"title":   n.Title,
+		"message": n.Body,
+	}
+	if p, ok := ntfyPriorities[n.Severity]; ok {
+		payload["priority"] = p
+	}
+	if n.Severity == SeverityCritical {
+		payload["tags"] = []string{"rotating_light"}
+	}
+	if link := n.Data.PortLink; link != "" {
+		payload["click"] = link
+	}
+	var headers map[string]string
+	if s.token != "" {
+		headers = map[string]string{"Authorization": "Bearer " + s.token}
+	}
+	// Publishing JSON goes to the server root, the topic is in the body
+	return postJSON(s.client, s.url, headers, payload)
+}
+
+type gotifySender struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func newGotifySender(cfg ChannelConfig) (Sender, error) {
+	if cfg.URL == "" || cfg.Token == "" {
+		return nil, fmt.Errorf("url and token are required")
+	}
+	return &gotifySender{url: strings.TrimSuffix(cfg.URL, "/"), token: cfg.Token, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// Gotify priorities run from 0 to 10; the Android app rings from 8
+var gotifyPriorities = map[string]int{SeverityInfo: 4, SeverityWarning: 6, SeverityCritical: 8}
+
+func (g *gotifySender) Send(n Notification) error {
+	payload := map[string]any{
+		"title":    n.Title,
+		"message":  n.Body,
+		"priority": gotifyPriorities[SeverityInfo],
+	}
+	if p, ok := gotifyPriorities[n.Severity]; ok {
+		payload["priority"] = p
+	}
+	if link := n.Data.PortLink; link != "" {
+		payload["extras"] = map[string]any{"client::notification": map[string]any{"click": map[string]any{"url": link}}}
+	}
+	return postJSON(g.client, g.url+"/message", map[string]string{"X-Gotify-Key": g.token}, payload)
+}
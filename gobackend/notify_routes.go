@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"time"
+)
+
+// Routes pick the channels of an event by its type, the port's risk level
+// and host, in the notification config:
+//
+//	routes:
+//	  - name: prod-changes
+//	    events: [process_change]
+//	    hosts: ["prod-*"]
+//	    channels: [pager]
+//	  - name: dev-noise
+//	    hosts: ["dev-*"]
+//	    risk: [none, suspicious]     # none: the port has no note
+//	    channels: [team]
+//	    digest: "08:00"              # hold everything for a daily summary
+//	  - name: nights
+//	    channels: [team]
+//	    quiet_hours: {start: "22:00", end: "07:00", min_severity: critical}
+//
+// The first matching route decides, its channels' own event filters
+// aside; events no route matches go to the channels as before. A route's
+// quiet hours hold messages until the window ends, its digest (HH:MM or a
+// cron expression) until the next scheduled time. The port's note can
+// still mute it or narrow the channels.
+type NotifyRoute struct {
+	Name        string      `yaml:"name"`
+	Events      []string    `yaml:"events"` // empty = any
+	Risk        []string    `yaml:"risk"`   // trusted, expected, suspicious, none; empty = any
+	Hosts       []string    `yaml:"hosts"`  // globs; empty = any
+	MinSeverity string      `yaml:"min_severity"`
+	Channels    []string    `yaml:"channels"`
+	QuietHours  *QuietHours `yaml:"quiet_hours"`
+	Digest      string      `yaml:"digest"`
+
+	digest     *cronSpec
+	lastDigest time.Time
+}
+
+var notifyRoutes []*NotifyRoute
+
+func validateRoutes(routes []NotifyRoute, channels []*notifyChannel) ([]*NotifyRoute, error) {
+	var out []*NotifyRoute
+	for i := range routes {
+		r := &routes[i]
+		if r.Name == "" {
+			return nil, fmt.Errorf("route %d: name is required", i+1)
+		}
+		if len(r.Channels) == 0 {
+			return nil, fmt.Errorf("route %q: channels are required", r.Name)
+		}
+		for _, name := range r.Channels {
+			if findChannel(channels, name) == nil {
+				return nil, fmt.Errorf("route %q: unknown channel %q", r.Name, name)
+			}
+		}
+		for _, risk := range r.Risk {
+			if !slices.Contains([]string{string(RiskTrusted), string(RiskExpected), string(RiskSuspicious), "none"}, risk) {
+				return nil, fmt.Errorf("route %q: unknown risk %q", r.Name, risk)
+			}
+		}
+		for _, h := range r.Hosts {
+			if _, err := path.Match(h, ""); err != nil {
+				return nil, fmt.Errorf("route %q: host %q: %w", r.Name, h, err)
+			}
+		}
+		if severityRank(r.MinSeverity) < 0 {
+			return nil, fmt.Errorf("route %q: unknown min_severity %q", r.Name, r.MinSeverity)
+		}
+		if r.QuietHours != nil {
+			if err := r.QuietHours.compile(); err != nil {
+				return nil, fmt.Errorf("route %q quiet_hours: %w", r.Name, err)
+			}
+		}
+		if r.Digest != "" {
+			expr := r.Digest
+			if m, err := parseClock(r.Digest); err == nil {
+				expr = fmt.Sprintf("%d %d * * *", m%60, m/60)
+			}
+			spec, err := parseCron(expr)
+			if err != nil {
+				return nil, fmt.Errorf("route %q digest: %w", r.Name, err)
+			}
+			r.digest = spec
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// matchRoute returns the first route for the event, or nil.
+func matchRoute(data NotificationData, severity string) *NotifyRoute {
+	risk := "none"
+	if data.HasNote {
+		risk = data.Note.RiskLevel
+	}
+	for _, r := range notifyRoutes {
+		if len(r.Events) > 0 && !slices.Contains(r.Events, data.Event.EventType) {
+			continue
+		}
+		if len(r.Risk) > 0 && !slices.Contains(r.Risk, risk) {
+			continue
+		}
+		if len(r.Hosts) > 0 && !slices.ContainsFunc(r.Hosts, func(h string) bool {
+			ok, _ := path.Match(h, data.Runtime.HostID)
+			return ok
+		}) {
+			continue
+		}
+		if severityRank(severity) < severityRank(r.MinSeverity) {
+			continue
+		}
+		return r
+	}
+	return nil
+}
+
+// dispatch holds n for the route's digest or quiet hours, or hands it to
+// the channel.
+func (r *NotifyRoute) dispatch(ch *notifyChannel, n Notification, now time.Time) {
+	hold := r.digest != nil
+	if q := r.QuietHours; q != nil && q.Active(now) {
+		hold = hold || q.MinSeverity == "" || severityRank(n.Severity) < severityRank(q.MinSeverity)
+	}
+	if hold {
+		DB.Create(&PendingNotification{Channel: ch.cfg.Name, Route: r.Name, Severity: n.Severity, Title: n.Title, Body: n.Body})
+		return
+	}
+	ch.dispatch(n, now)
+}
+
+// flushRouteDigests sends what routes held once their digest is due or
+// their quiet hours are over.
+func flushRouteDigests(now time.Time) {
+	for _, r := range notifyRoutes {
+		title := "[portmonote] %d notification(s) during quiet hours"
+		if r.digest != nil {
+			start, ok := r.digest.lastStart(now, 2*time.Minute) // the ticker drifts
+			if !ok || !start.After(r.lastDigest) {
+				continue
+			}
+			r.lastDigest = start
+			title = "[portmonote] " + r.Name + " digest: %d notification(s)"
+		} else if q := r.QuietHours; q == nil || q.Active(now) {
+			continue
+		}
+		for _, name := range r.Channels {
+			ch := findChannel(notifyChannels, name)
+			if ch == nil {
+				continue
+			}
+			var pending []PendingNotification
+			DB.Where("channel = ? AND route = ?", ch.cfg.Name, r.Name).Order("created_at").Find(&pending)
+			if len(pending) == 0 {
+				continue
+			}
+			if err := sendDigest(ch, pending, fmt.Sprintf(title, len(pending))); err != nil {
+				log.Printf("Digest of route %s to %s failed, kept for the next one: %v", r.Name, ch.cfg.Name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// POST /admin/notifications/test {"channel": "ops", "event": "process_change"}
+//
+// Sends a sample message through a configured channel right away, past
+// quiet hours and email batching, and reports how delivery went: a way to
+// check webhook URLs and SMTP credentials without waiting for a real event.
+// GET /admin/notifications lists the channels and routes to pick from.
+func testNotification(c *gin.Context) {
+	var req struct {
+		Channel string `json:"channel"`
+		Event   string `json:"event"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(notifyChannels) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "No notification channels configured (PORTMONOTE_NOTIFY_CONFIG)"})
+		return
+	}
+	ch := findChannel(notifyChannels, req.Channel)
+	if ch == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown channel " + req.Channel})
+		return
+	}
+	if req.Event == "" {
+		req.Event = string(EventAppeared)
+	}
+
+	detail := "Test notification"
+	if actor := actorName(c); actor != "" {
+		detail += " sent by " + actor
+	}
+	now := time.Now()
+	rt := PortRuntime{HostID: HostID, Protocol: "tcp", Port: 8080, CurrentState: string(StateActive), CurrentPID: 4242, ProcessName: "python3", Cmdline: "python3 -m http.server 8080", FirstSeenAt: now, LastSeenAt: now}
+	data := NotificationData{
+		Event:    PortEvent{EventType: req.Event, Timestamp: now, PID: rt.CurrentPID, ProcessName: rt.ProcessName, Detail: detail},
+		Runtime:  rt,
+		Link:     notifyDashURL,
+		PortLink: portLink(rt),
+	}
+	n, err := ch.render(data)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"channel": ch.cfg.Name, "type": ch.cfg.Type, "delivered": false, "error": "template: " + err.Error()})
+		return
+	}
+	n.Title = "[test] " + n.Title
+	n.Severity = eventSeverity(data)
+
+	start := time.Now()
+	if s, ok := ch.sender.(interface{ sendNow(Notification) error }); ok {
+		err = s.sendNow(n)
+	} else {
+		err = ch.sender.Send(n)
+	}
+	res := gin.H{"channel": ch.cfg.Name, "type": ch.cfg.Type, "title": n.Title, "body": n.Body, "severity": n.Severity, "duration_ms": time.Since(start).Milliseconds()}
+	if err != nil {
+		res["delivered"], res["error"] = false, err.Error()
+		c.JSON(http.StatusBadGateway, res)
+		return
+	}
+	res["delivered"] = true
+	c.JSON(http.StatusOK, res)
+}
+
+// GET /admin/notifications
+func listNotificationConfig(c *gin.Context) {
+	type channelInfo struct {
+		Name        string   `json:"name"`
+		Type        string   `json:"type"`
+		Events      []string `json:"events"`
+		MinSeverity string   `json:"min_severity,omitempty"`
+		QuietHours  bool     `json:"quiet_hours"`
+	}
+	channels := []channelInfo{}
+	for _, ch := range notifyChannels {
+		events := ch.cfg.Events
+		if len(events) == 0 {
+			events = defaultNotifyEvents
+		}
+		channels = append(channels, channelInfo{ch.cfg.Name, ch.cfg.Type, events, ch.cfg.MinSeverity, ch.cfg.QuietHours != nil})
+	}
+	type routeInfo struct {
+		Name       string   `json:"name"`
+		Events     []string `json:"events,omitempty"`
+		Risk       []string `json:"risk,omitempty"`
+		Hosts      []string `json:"hosts,omitempty"`
+		Channels   []string `json:"channels"`
+		QuietHours bool     `json:"quiet_hours"`
+		Digest     string   `json:"digest,omitempty"`
+	}
+	routes := []routeInfo{}
+	for _, r := range notifyRoutes {
+		routes = append(routes, routeInfo{r.Name, r.Events, r.Risk, r.Hosts, r.Channels, r.QuietHours != nil, r.Digest})
+	}
+	c.JSON(http.StatusOK, gin.H{"configured": notifyConfigFile != "", "channels": channels, "routes": routes})
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+// Single sign-on via any OIDC provider (Keycloak, Okta, Google, Entra...).
+// Setting PORTMONOTE_OIDC_ISSUER turns on login (like PORTMONOTE_AUTH=session)
+// and adds a "Log in with SSO" button; local password users keep working.
+// SSO users are created on first login, with no password, and recognized
+// by the provider's issuer + subject afterwards; the username claim only
+// names the new account (suffixed if a local user already has it). To let
+// an existing local account log in through SSO, map its verified email:
+//
+//	PORTMONOTE_OIDC_LINK=alice@example.com=alice,bob@example.com=bob
+var (
+	oidcIssuer       = envString("PORTMONOTE_OIDC_ISSUER", "")
+	oidcClientID     = envString("PORTMONOTE_OIDC_CLIENT_ID", "")
+	oidcClientSecret = envString("PORTMONOTE_OIDC_CLIENT_SECRET", "")
+	// Defaults to <scheme>://<host>/auth/oidc/callback of the login request
+	oidcRedirectURL = envString("PORTMONOTE_OIDC_REDIRECT_URL", "")
+	// Claim used as the username; falls back to email, then sub
+	oidcUsernameClaim = envString("PORTMONOTE_OIDC_USERNAME_CLAIM", "preferred_username")
+	// Verified email -> local username allowed to be linked
+	oidcLinks = parseOIDCLinks(envString("PORTMONOTE_OIDC_LINK", ""))
+)
+
+func parseOIDCLinks(s string) map[string]string {
+	links := map[string]string{}
+	for _, item := range splitList(s) {
+		email, username, ok := strings.Cut(item, "=")
+		if !ok || email == "" || username == "" {
+			log.Printf("⚠️ Ignoring PORTMONOTE_OIDC_LINK entry %q (expected email=username)", item)
+			continue
+		}
+		links[strings.ToLower(email)] = username
+	}
+	return links
+}
+
+const (
+	oidcCallbackPath  = "/auth/oidc/callback"
+	oidcStateCookie   = "portmonote_oidc_state"
+	oidcStateLifetime = 10 * time.Minute
+)
+
+func oidcEnabled() bool {
+	return oidcIssuer != ""
+}
+
+var (
+	oidcMu       sync.Mutex
+	oidcProvider *oidc.Provider
+)
+
+// Discovery is lazy (and retried on failure) so a provider outage doesn't
+// block startup.
+func getOIDCProvider() (*oidc.Provider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	if oidcProvider != nil {
+		return oidcProvider, nil
+	}
+	// The provider keeps this context for fetching signing keys later
+	ctx := oidc.ClientContext(context.Background(), &http.Client{Timeout: 10 * time.Second})
+	provider, err := oidc.NewProvider(ctx, oidcIssuer)
+	if err != nil {
+		return nil, err
+	}
+	oidcProvider = provider
+	return provider, nil
+}
+
+func oidcConfig(c *gin.Context, provider *oidc.Provider) *oauth2.Config {
+	redirect := oidcRedirectURL
+	if redirect == "" {
+		scheme := "http"
+		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		redirect = scheme + "://" + c.Request.Host + oidcCallbackPath
+	}
+	return &oauth2.Config{
+		ClientID:     oidcClientID,
+		ClientSecret: oidcClientSecret,
+		RedirectURL:  redirect,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+	}
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// GET /auth/oidc/login redirects to the provider. state, nonce and the PKCE
+// verifier travel in a short-lived HttpOnly cookie.
+func handleOIDCLogin(c *gin.Context) {
+	provider, err := getOIDCProvider()
+	if err != nil {
+		log.Printf("OIDC discovery failed: %v", err)
+		c.String(http.StatusBadGateway, "SSO provider unavailable")
+		return
+	}
+	state, nonce, verifier := randomHex(16), randomHex(16), oauth2.GenerateVerifier()
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state+"."+nonce+"."+verifier, int(oidcStateLifetime.Seconds()),
+		oidcCallbackPath, "", c.Request.TLS != nil, true)
+
+	url := oidcConfig(c, provider).AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+	c.Redirect(http.StatusFound, url)
+}
+
+// GET /auth/oidc/callback finishes the code flow and starts a session.
+func handleOIDCCallback(c *gin.Context) {
+	raw, _ := c.Cookie(oidcStateCookie)
+	c.SetCookie(oidcStateCookie, "", -1, oidcCallbackPath, "", c.Request.TLS != nil, true)
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 || c.Query("state") != parts[0] {
+		c.String(http.StatusBadRequest, "Invalid or expired login attempt, please try again")
+		return
+	}
+	if e := c.Query("error"); e != "" {
+		c.String(http.StatusUnauthorized, "SSO login failed: %s", e)
+		return
+	}
+	nonce, verifier := parts[1], parts[2]
+
+	ctx := c.Request.Context()
+	provider, err := getOIDCProvider()
+	if err != nil {
+		c.String(http.StatusBadGateway, "SSO provider unavailable")
+		return
+	}
+	token, err := oidcConfig(c, provider).Exchange(ctx, c.Query("code"), oauth2.VerifierOption(verifier))
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v", err)
+		c.String(http.StatusUnauthorized, "SSO login failed")
+		return
+	}
+	id, err := verifyIDToken(ctx, provider, token, nonce)
+	if err != nil {
+		log.Printf("OIDC token rejected: %v", err)
+		c.String(http.StatusUnauthorized, "SSO login failed")
+		return
+	}
+
+	user, err := ssoUser(id)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	session, err := newSession(c, user)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	setSessionCookie(c, session, int(sessionTTL.Seconds()))
+	log.Printf("SSO login: %s", user.Username)
+	c.Redirect(http.StatusSeeOther, "/")
+}
+
+// ssoIdentity is what the ID token says about the user.
+type ssoIdentity struct {
+	Issuer        string
+	Subject       string
+	Username      string
+	Email         string
+	EmailVerified bool
+}
+
+func verifyIDToken(ctx context.Context, provider *oidc.Provider, token *oauth2.Token, nonce string) (*ssoIdentity, error) {
+	rawID, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("no id_token in token response")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: oidcClientID}).Verify(ctx, rawID)
+	if err != nil {
+		return nil, err
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+	if idToken.Subject == "" {
+		return nil, errors.New("id_token has no sub claim")
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	id := &ssoIdentity{Issuer: idToken.Issuer, Subject: idToken.Subject}
+	id.Email, _ = claims["email"].(string)
+	id.EmailVerified, _ = claims["email_verified"].(bool)
+	for _, name := range []string{oidcUsernameClaim, "email", "sub"} {
+		if v, ok := claims[name].(string); ok && v != "" {
+			id.Username = v
+			break
+		}
+	}
+	return id, nil
+}
+
+// ssoUser finds the account of an SSO identity: the one created for it
+// earlier, a local account PORTMONOTE_OIDC_LINK maps its verified email to,
+// or a new one. A username claim alone never grants an existing account.
+func ssoUser(id *ssoIdentity) (*User, error) {
+	var user User
+	err := DB.Where("sso_issuer = ? AND sso_subject = ?", id.Issuer, id.Subject).First(&user).Error
+	if err == nil {
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if local, ok := oidcLinks[strings.ToLower(id.Email)]; ok && id.EmailVerified {
+		res := DB.Model(&User{}).Where("username = ? AND sso_subject = ''", local).
+			Updates(map[string]any{"sso_issuer": id.Issuer, "sso_subject": id.Subject})
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 1 {
+			log.Printf("🔗 SSO identity %s linked to local user %s", id.Email, local)
+			DB.Where("username = ?", local).First(&user)
+			return &user, nil
+		}
+		log.Printf("⚠️ PORTMONOTE_OIDC_LINK: no unlinked local user %q for %s", local, id.Email)
+	}
+
+	user = User{Username: id.Username, Role: oidcDefaultRole, SSOIssuer: id.Issuer, SSOSubject: id.Subject}
+	for n := 2; ; n++ {
+		var taken int64
+		DB.Model(&User{}).Where("username = ?", user.Username).Count(&taken)
+		if taken == 0 {
+			break
+		}
+		user.Username = fmt.Sprintf("%s-%d", id.Username, n)
+	}
+	if err := DB.Create(&user).Error; err != nil {
+		return nil, fmt.Errorf("create user %q: %w", user.Username, err)
+	}
+	return &user, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// PortPeer records a remote address seen connected to a suspicious or
+// unnoted listener, for incident investigation. Documented ports are not
+// tracked: their peers are expected and would only bloat the table.
+type PortPeer struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	PortRuntimeID uint      `gorm:"uniqueIndex:idx_peer_runtime_ip" json:"port_runtime_id"`
+	RemoteIP      string    `gorm:"uniqueIndex:idx_peer_runtime_ip;size:64" json:"remote_ip"`
+	FirstSeenAt   time.Time `json:"first_seen_at"`
+	LastSeenAt    time.Time `json:"last_seen_at"`
+	SeenCount     int       `json:"seen_count"`       // cycles it was connected in
+	ConnCount     int       `json:"connection_count"` // connections summed over those cycles
+}
+
+func (PortPeer) TableName() string {
+	return "port_peer"
+}
+
+// recordPeers upserts this cycle's remote peers of watched listeners.
+func recordPeers(listening []*PortRuntime, results map[PortKey]ScanResult) {
+	var notes []PortNote
+	DB.Find(&notes)
+	documented := make(map[string]bool, len(notes))
+	for _, n := range notes {
+		if n.RiskLevel != string(RiskSuspicious) {
+			documented[fmtKey(n.HostID, n.Protocol, n.Port)] = true
+		}
+	}
+
+	now := time.Now()
+	var peers []PortPeer
+	for _, rt := range listening {
+		// Connection counts come from this host's socket table
+		if rt.HostID != HostID || documented[fmtKey(rt.HostID, rt.Protocol, rt.Port)] {
+			continue
+		}
+		res := results[PortKey{HostID: rt.HostID, Protocol: rt.Protocol, Port: rt.Port}]
+		for ip, n := range res.Peers {
+			peers = append(peers, PortPeer{PortRuntimeID: rt.ID, RemoteIP: ip, FirstSeenAt: now, LastSeenAt: now, SeenCount: 1, ConnCount: n})
+		}
+	}
+	if len(peers) == 0 {
+		return
+	}
+	err := DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "port_runtime_id"}, {Name: "remote_ip"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "last_seen_at"}, Value: now},
+			{Column: clause.Column{Name: "seen_count"}, Value: clause.Expr{SQL: "port_peer.seen_count + 1"}},
+			{Column: clause.Column{Name: "conn_count"}, Value: clause.Expr{SQL: "port_peer.conn_count + " + insertedValue("conn_count")}},
+		},
+	}).CreateInBatches(peers, 200).Error
+	if err != nil {
+		log.Println("Error recording peers:", err)
+	}
+}
+
+// insertedValue names the value an upsert tried to insert into column:
+// MySQL has no "excluded" row and spells it VALUES(column).
+func insertedValue(column string) string {
+	if DB.Dialector.Name() == "mysql" {
+		return "VALUES(" + column + ")"
+	}
+	return "excluded." + column
+}
+
+// GET /ports/:id/peers lists remote peers of a runtime, most recent first.
+func getPortPeers(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var rt PortRuntime
+	if err := DB.First(&rt, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Runtime not found"})
+		return
+	}
+	var peers []PortPeer
+	DB.Where("port_runtime_id = ?", rt.ID).Order("last_seen_at desc").Find(&peers)
+	c.JSON(http.StatusOK, peers)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-yaml"
+)
+
+// PORTMONOTE_POLICY enables continuous evaluation in the server: the file is
+// re-read every cycle, so edits apply without a restart.
+var policyFile = envString("PORTMONOTE_POLICY", "")
+
+// Policy is an allowed-ports file, e.g.
+//
+//	allow:
+//	  - name: web
+//	    protocol: tcp
+//	    ports: "80,443,8000-8100"
+//	    process: "nginx*"
+//	forbid:
+//	  - name: no netcat
+//	    process: "nc*"
+//	require_owner:
+//	  - name: prod services need an owner
+//	    host: "prod-*"
+//
+// A listener violates the policy when no allow rule matches it, when any
+// forbid rule does, or when a require_owner rule matches and its note has no
+// owner. Empty rule fields match everything; an empty allow list allows all.
+type Policy struct {
+	Allow        []PolicyRule `yaml:"allow" json:"allow"`
+	Forbid       []PolicyRule `yaml:"forbid" json:"forbid"`
+	RequireOwner []PolicyRule `yaml:"require_owner" json:"require_owner"`
+}
+
+type PolicyRule struct {
+	Name     string `yaml:"name" json:"name"`
+	Host     string `yaml:"host" json:"host,omitempty"`         // host_id glob
+	Protocol string `yaml:"protocol" json:"protocol,omitempty"` // tcp / udp
+	Ports    string `yaml:"ports" json:"ports,omitempty"`       // "22,80,8000-8100"
+	Process  string `yaml:"process" json:"process,omitempty"`   // process name glob
+
+	ranges []portRange
+}
+
+type portRange struct{ from, to int }
+
+type PolicyViolation struct {
+	RuntimeID   uint   `json:"runtime_id,omitempty"`
+	HostID      string `json:"host_id"`
+	Protocol    string `json:"protocol"`
+	Port        int    `json:"port"`
+	PID         int    `json:"pid"`
+	ProcessName string `json:"process_name"`
+	Rule        string `json:"rule,omitempty"`
+	Reason      string `json:"reason"`
+}
+
+func loadPolicy(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err := yaml.UnmarshalWithOptions(data, &p, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+	if err := p.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return &p, nil
+}
+
+func (p *Policy) compile() error {
+	for _, rules := range [][]PolicyRule{p.Allow, p.Forbid, p.RequireOwner} {
+		for i := range rules {
+			r := &rules[i]
+			ranges, err := parsePortRanges(r.Ports)
+			if err != nil {
+				return fmt.Errorf("rule %q: %w", r.Name, err)
+			}
+			r.ranges = ranges
+			for _, glob := range []string{r.Host, r.Process} {
+				if _, err := path.Match(glob, ""); err != nil {
+					return fmt.Errorf("rule %q: bad pattern %q", r.Name, glob)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func parsePortRanges(spec string) ([]portRange, error) {
+	var ranges []portRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", part)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil || to < from {
+				return nil, fmt.Errorf("invalid port range %q", part)
+			}
+		}
+		ranges = append(ranges, portRange{from, to})
+	}
+	return ranges, nil
+}
+
+func (r *PolicyRule) label(i int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return "#" + strconv.Itoa(i+1)
+}
+
+func (r *PolicyRule) Matches(item *MergedPortItem) bool {
+	if r.Protocol != "" && !strings.EqualFold(r.Protocol, item.Protocol) {
+		return false
+	}
+	if r.Host != "" {
+		if ok, _ := path.Match(r.Host, item.HostID); !ok {
+			return false
+		}
+	}
+	if r.Process != "" {
+		if ok, _ := path.Match(r.Process, item.ProcessName); !ok {
+			return false
+		}
+	}
+	if len(r.ranges) > 0 {
+		inRange := false
+		for _, pr := range r.ranges {
+			if item.Port >= pr.from && item.Port <= pr.to {
+				inRange = true
+				break
+			}
+		}
+		if !inRange {
+			return false
+		}
+	}
+	return true
+}
+
+// Evaluate returns one violation per offending listener. Only active ports
+// are checked.
+func (p *Policy) Evaluate(items []MergedPortItem) []PolicyViolation {
+	var violations []PolicyViolation
+	for i := range items {
+		item := &items[i]
+		if item.CurrentState != string(StateActive) {
+			continue
+		}
+		v := PolicyViolation{
+			RuntimeID: item.RuntimeID,
+			HostID:    item.HostID, Protocol: item.Protocol, Port: item.Port,
+			PID: item.CurrentPID, ProcessName: item.ProcessName,
+		}
+
+		forbidden := false
+		for j := range p.Forbid {
+			if p.Forbid[j].Matches(item) {
+				v.Rule = p.Forbid[j].label(j)
+				v.Reason = "matches forbid rule"
+				forbidden = true
+				break
+			}
+		}
+		if forbidden {
+			violations = append(violations, v)
+			continue
+		}
+
+		allowed := len(p.Allow) == 0
+		for j := range p.Allow {
+			if p.Allow[j].Matches(item) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			v.Reason = "not covered by any allow rule"
+			violations = append(violations, v)
+			continue
+		}
+
+		if item.Owner == "" {
+			for j := range p.RequireOwner {
+				if p.RequireOwner[j].Matches(item) {
+					v.Rule = p.RequireOwner[j].label(j)
+					v.Reason = "no owner on note"
+					violations = append(violations, v)
+					break
+				}
+			}
+		}
+	}
+	return violations
+}
+
+// loadActivePolicy returns the configured policy, or nil when none is set.
+func loadActivePolicy() (*Policy, error) {
+	if policyFile == "" {
+		return nil, nil
+	}
+	return loadPolicy(policyFile)
+}
+
+// currentViolations evaluates the active policy against the stored inventory.
+func currentViolations() ([]PolicyViolation, error) {
+	policy, err := loadActivePolicy()
+	if err != nil || policy == nil {
+		return nil, err
+	}
+
+	var runtimes []PortRuntime
+	var notes []PortNote
+	if err := DB.Find(&runtimes).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Find(&notes).Error; err != nil {
+		return nil, err
+	}
+	return policy.Evaluate(mergePortItems(runtimes, notes)), nil
+}
+
+// evaluatePolicyCycle runs after each collection cycle. A policy_violation
+// event is recorded when a runtime starts violating (or the reason changes),
+// not on every cycle; PolicyViolation on the runtime holds the current state.
+func evaluatePolicyCycle() {
+	if policyFile == "" {
+		return
+	}
+	violations, err := currentViolations()
+	if err != nil {
+		log.Println("Error evaluating policy:", err)
+		return
+	}
+
+	current := make(map[uint]string)
+	for _, v := range violations {
+		if v.RuntimeID != 0 {
+			current[v.RuntimeID] = violationText(v)
+		}
+	}
+
+	var runtimes []PortRuntime
+	DB.Where("policy_violation <> '' OR id IN ?", mapKeys(current)).Find(&runtimes)
+	for _, r := range runtimes {
+		text := current[r.ID]
+		if text == r.PolicyViolation {
+			continue
+		}
+		DB.Model(&r).Update("policy_violation", text)
+		if text == "" {
+			continue
+		}
+		log.Printf("Policy violation on %s/%d: %s", r.Protocol, r.Port, text)
+		recordEvent(&r, PortEvent{
+			PortRuntimeID: r.ID,
+			EventType:     string(EventPolicyViolation),
+			Timestamp:     time.Now(),
+			PID:           r.CurrentPID,
+			ProcessName:   r.ProcessName,
+			Detail:        text,
+		})
+	}
+}
+
+func violationText(v PolicyViolation) string {
+	if v.Rule == "" {
+		return v.Reason
+	}
+	return v.Reason + " [" + v.Rule + "]"
+}
+
+func mapKeys[K comparable, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// GET /violations lists what currently violates PORTMONOTE_POLICY.
+func getViolations(c *gin.Context) {
+	if policyFile == "" {
+		c.JSON(http.StatusOK, gin.H{"enabled": false, "violations": []PolicyViolation{}})
+		return
+	}
+	violations, err := currentViolations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if violations == nil {
+		violations = []PolicyViolation{}
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "policy": policyFile, "violations": violations})
+}
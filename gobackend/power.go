@@ -0,0 +1,175 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Battery-aware collection for laptops: with PORTMONOTE_POWER_SAVE=auto the
+// collector checks before every scheduled cycle whether the machine runs on
+// battery or the user has been idle, and if so scans only every
+// PORTMONOTE_POWER_SAVE_INTERVAL (default 5m) and leaves UDP alone
+// (PORTMONOTE_POWER_SAVE_SKIP_UDP, default true). The normal cadence resumes
+// within a minute of plugging in. PORTMONOTE_POWER_SAVE=on forces the mode,
+// off (default) disables the checks.
+//
+// Battery state comes from /sys/class/power_supply on Linux and pmset on
+// macOS. Idle means logind's IdleHint on all sessions (Linux) or no HID input
+// for PORTMONOTE_IDLE_AFTER (macOS, default 15m; 0 disables idle detection).
+// Manual scans (/trigger-scan) and listener-watch triggers still run anytime.
+var (
+	powerSaveMode     = envString("PORTMONOTE_POWER_SAVE", "off")
+	powerSaveInterval = envDuration("PORTMONOTE_POWER_SAVE_INTERVAL", 5*time.Minute)
+	powerSaveSkipUDP  = envBool("PORTMONOTE_POWER_SAVE_SKIP_UDP", true)
+	idleAfter         = envDuration("PORTMONOTE_IDLE_AFTER", 15*time.Minute)
+)
+
+// powerSaveReason returns why the collector should slow down ("battery",
+// "idle", "forced"), or "" for normal cadence.
+func powerSaveReason() string {
+	switch powerSaveMode {
+	case "on", "true":
+		return "forced"
+	case "auto":
+	default:
+		return ""
+	}
+	if onBattery() {
+		return "battery"
+	}
+	if idleAfter > 0 && userIdle() {
+		return "idle"
+	}
+	return ""
+}
+
+func onBattery() bool {
+	switch runtime.GOOS {
+	case "linux":
+		return linuxOnBattery("/sys/class/power_supply")
+	case "darwin":
+		out, err := exec.Command("pmset", "-g", "batt").Output()
+		return err == nil && strings.Contains(string(out), "'Battery Power'")
+	}
+	return false
+}
+
+// linuxOnBattery is true if there is a battery and no mains/USB supply is
+// online. Desktops and servers have no battery and never qualify.
+func linuxOnBattery(dir string) bool {
+	supplies, _ := filepath.Glob(filepath.Join(dir, "*"))
+	hasBattery := false
+	for _, s := range supplies {
+		typ := readTrimmed(filepath.Join(s, "type"))
+		switch typ {
+		case "Battery":
+			if readTrimmed(filepath.Join(s, "scope")) != "Device" { // not a mouse
+				hasBattery = true
+			}
+		case "Mains", "USB", "USB_C", "USB_PD":
+			if readTrimmed(filepath.Join(s, "online")) == "1" {
+				return false
+			}
+		}
+	}
+	return hasBattery
+}
+
+func readTrimmed(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+var hidIdleRe = regexp.MustCompile(`"HIDIdleTime" = (\d+)`)
+
+func userIdle() bool {
+	switch runtime.GOOS {
+	case "linux":
+		return logindIdle()
+	case "darwin":
+		out, err := exec.Command("ioreg", "-c", "IOHIDSystem", "-d", "4").Output()
+		if err != nil {
+			return false
+		}
+		m := hidIdleRe.FindSubmatch(out)
+		if m == nil {
+			return false
+		}
+		ns, _ := strconv.ParseInt(string(m[1]), 10, 64)
+		return time.Duration(ns) >= idleAfter
+	}
+	return false
+}
+
+// logindIdle is true if there are user sessions and logind considers all of
+// them idle. Headless boxes without sessions are not "idle".
+func logindIdle() bool {
+	out, err := exec.Command("loginctl", "list-sessions", "--no-legend").Output()
+	if err != nil {
+		return false
+	}
+	sessions := 0
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		hint, err := exec.Command("loginctl", "show-session", fields[0], "-p", "IdleHint", "--value").Output()
+		if err != nil {
+			continue
+		}
+		sessions++
+		if strings.TrimSpace(string(hint)) != "yes" {
+			return false
+		}
+	}
+	return sessions > 0
+}
+
+// powerSaveDue tells the ticker whether a scheduled cycle should run now,
+// and records the current mode in the collector status.
+func powerSaveDue(now time.Time) bool {
+	reason := powerSaveReason()
+	collectorState.setPowerSave(reason)
+	if reason == "" {
+		return true
+	}
+	last := collectorState.snapshot().LastRunAt
+	return last == nil || now.Sub(*last) >= powerSaveInterval-time.Second
+}
+
+// scanUDP tells the scanner backends whether to enumerate UDP sockets at
+// all this cycle; skipping them is the point of holding UDP.
+func scanUDP() bool {
+	return !heldProtocols()["udp"]
+}
+
+// hostStaleness returns after how long without a scan a host counts as stale
+// and as offline. Hosts this server scans itself are given proportionally
+// longer while power save spaces the cycles out.
+func hostStaleness(hostID string) (stale, offline time.Duration) {
+	stale, offline = fleetStaleAfter, fleetOfflineAfter
+	if collectorState.snapshot().PowerSave == "" || !scannedHere(hostID) || powerSaveInterval <= collectInterval {
+		return stale, offline
+	}
+	factor := float64(powerSaveInterval) / float64(collectInterval)
+	return time.Duration(float64(stale) * factor), time.Duration(float64(offline) * factor)
+}
+
+// heldProtocols lists the local protocols the current cycle doesn't scan;
+// their runtimes are left as they are instead of disappearing.
+func heldProtocols() map[string]bool {
+	if powerSaveSkipUDP && collectorState.snapshot().PowerSave != "" {
+		return map[string]bool{"udp": true}
+	}
+	return nil
+}
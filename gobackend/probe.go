@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Deep scan: local TCP listeners are probed when their process changes and
+// every PORTMONOTE_DEEP_SCAN_INTERVAL to learn what they actually speak: tls,
+// http, ssh, unknown, or unreachable when nothing answers on loopback. Web
+// listeners also get their Server header, status and page title from GET /.
+// A noted port that claims HTTPS/TLS in its title, description or tags but
+// doesn't do TLS gets a tls_mismatch event.
+//
+// Probing connects to other people's services, so it is off unless
+// PORTMONOTE_PROTOCOL_PROBE=true. It runs inside the collection cycle; a
+// cycle stops starting probes after PORTMONOTE_PROBE_BUDGET (default 10s)
+// and leaves the rest for the next one.
+var (
+	protocolProbe    = envBool("PORTMONOTE_PROTOCOL_PROBE", false)
+	deepScanInterval = envDuration("PORTMONOTE_DEEP_SCAN_INTERVAL", time.Hour)
+	probeBudget      = envDuration("PORTMONOTE_PROBE_BUDGET", 10*time.Second)
+)
+
+const (
+	WireTLS         = "tls"
+	WireHTTP        = "http"
+	WireSSH         = "ssh"
+	WireUnknown     = "unknown"
+	WireUnreachable = "unreachable"
+)
+
+const probeTimeout = 2 * time.Second
+
+// probeRuntimes deep-scans listeners that are new, changed process or are
+// due for a refresh, never probed ones first, until probeBudget is spent.
+func probeRuntimes(runtimes []*PortRuntime) {
+	if !protocolProbe {
+		return
+	}
+	now := time.Now()
+	var due []*PortRuntime
+	for _, rt := range runtimes {
+		if rt.HostID != HostID || rt.Protocol != "tcp" {
+			continue
+		}
+		if rt.ProbedAt == nil || rt.ProbedPID != rt.CurrentPID ||
+			(deepScanInterval > 0 && now.Sub(*rt.ProbedAt) >= deepScanInterval) {
+			due = append(due, rt)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b *PortRuntime) int {
+		switch {
+		case a.ProbedAt == nil && b.ProbedAt == nil:
+			return 0
+		case a.ProbedAt == nil:
+			return -1
+		case b.ProbedAt == nil:
+			return 1
+		}
+		return a.ProbedAt.Compare(*b.ProbedAt)
+	})
+
+	for i, rt := range due {
+		if probeBudget > 0 && time.Since(now) >= probeBudget {
+			log.Printf("⏳ Probe budget spent, %d listener(s) wait for the next cycle", len(due)-i)
+			return
+		}
+		changedPID := rt.ProbedPID != rt.CurrentPID
+		wire := probeListener(rt.Port)
+		meta := httpMeta{}
+		if wire == WireHTTP || wire == WireTLS {
+			meta = fetchHTTPMeta(rt.Port, wire == WireTLS)
+		}
+		rt.WireProtocol, rt.ProbedPID, rt.ProbedAt = wire, rt.CurrentPID, &now
+		rt.HTTPStatus, rt.HTTPServer, rt.HTTPTitle = meta.Status, meta.Server, meta.Title
+		DB.Model(rt).Updates(map[string]any{
+			"wire_protocol": wire, "probed_pid": rt.CurrentPID, "probed_at": now,
+			"http_status": meta.Status, "http_server": meta.Server, "http_title": meta.Title,
+		})
+		if changedPID {
+			checkTLSMismatch(rt)
+		}
+	}
+}
+
+type httpMeta struct {
+	Status int
+	Server string
+	Title  string
+}
+
+var titleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// fetchHTTPMeta GETs / on a loopback web listener without following
+// redirects, so the status tells "login redirect" apart from a real page.
+func fetchHTTPMeta(port int, useTLS bool) httpMeta {
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+	client := &http.Client{
+		Timeout:       probeTimeout,
+		Transport:     &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, DisableKeepAlives: true},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s://localhost:%d/", scheme, port), nil)
+	if err != nil {
+		return httpMeta{}
+	}
+	req.Header.Set("User-Agent", "portmonote-probe")
+	resp, err := client.Do(req)
+	if err != nil {
+		return httpMeta{}
+	}
+	defer resp.Body.Close()
+
+	meta := httpMeta{Status: resp.StatusCode, Server: resp.Header.Get("Server")}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if m := titleRe.FindSubmatch(body); m != nil {
+		meta.Title = strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+		if r := []rune(meta.Title); len(r) > 200 {
+			meta.Title = string(r[:200])
+		}
+	}
+	return meta
+}
+
+// probeListener connects to a loopback port and guesses the protocol:
+// server-first banners (SSH) are read before anything is sent, then a TLS
+// handshake is tried, then a plain HTTP request.
+func probeListener(port int) string {
+	var addr string
+	var conn net.Conn
+	var err error
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		addr = net.JoinHostPort(host, strconv.Itoa(port))
+		if conn, err = net.DialTimeout("tcp", addr, probeTimeout); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return WireUnreachable
+	}
+
+	conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	banner := make([]byte, 64)
+	n, _ := conn.Read(banner)
+	conn.Close()
+	if n > 0 {
+		if bytes.HasPrefix(banner[:n], []byte("SSH-")) {
+			return WireSSH
+		}
+		return WireUnknown
+	}
+
+	if conn, err := net.DialTimeout("tcp", addr, probeTimeout); err == nil {
+		tc := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+		tc.SetDeadline(time.Now().Add(probeTimeout))
+		err := tc.Handshake()
+		tc.Close()
+		if err == nil {
+			return WireTLS
+		}
+	}
+
+	if conn, err := net.DialTimeout("tcp", addr, probeTimeout); err == nil {
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(probeTimeout))
+		fmt.Fprintf(conn, "HEAD / HTTP/1.0\r\nHost: localhost\r\nUser-Agent: portmonote-probe\r\n\r\n")
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		if strings.HasPrefix(line, "HTTP/") {
+			return WireHTTP
+		}
+	}
+	return WireUnknown
+}
+
+// claimsTLS reports whether a note describes the port as HTTPS/TLS.
+func claimsTLS(note PortNote) bool {
+	text := note.Title + " " + note.Tags
+	if !note.Sensitive {
+		text += " " + note.Description
+	}
+	text = strings.ToLower(text)
+	return strings.Contains(text, "https") || strings.Contains(text, "tls")
+}
+
+// tlsMismatch is true when the note says HTTPS/TLS but the probe found a
+// plaintext protocol.
+func tlsMismatch(wire string, note PortNote) bool {
+	return (wire == WireHTTP || wire == WireUnknown) && claimsTLS(note)
+}
+
+func checkTLSMismatch(rt *PortRuntime) {
+	var note PortNote
+	if DB.Where("host_id = ? AND protocol = ? AND port = ?", rt.HostID, rt.Protocol, rt.Port).First(&note).Error != nil {
+		return
+	}
+	if !tlsMismatch(rt.WireProtocol, note) {
+		return
+	}
+	log.Printf("⚠️ %s/%d is noted as HTTPS/TLS but speaks %s", rt.Protocol, rt.Port, rt.WireProtocol)
+	recordEvent(rt, PortEvent{
+		PortRuntimeID: rt.ID,
+		EventType:     string(EventTLSMismatch),
+		Timestamp:     time.Now(),
+		PID:           rt.CurrentPID,
+		ProcessName:   rt.ProcessName,
+		Detail:        "Noted as HTTPS/TLS but the listener speaks " + rt.WireProtocol,
+	})
+}
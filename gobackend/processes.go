@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProcessPort struct {
+	Protocol     string `json:"protocol"`
+	Port         int    `json:"port"`
+	CurrentState string `json:"current_state"`
+	Title        string `json:"title,omitempty"`
+}
+
+type ProcessView struct {
+	HostID      string        `json:"host_id"`
+	PID         int           `json:"pid"`
+	ProcessName string        `json:"process_name"`
+	Cmdline     string        `json:"cmdline"`
+	Username    string        `json:"username,omitempty"`
+	SystemdUnit string        `json:"systemd_unit,omitempty"`
+	Ports       []ProcessPort `json:"ports"`
+}
+
+// GET /processes groups runtimes by process, so a daemon listening on
+// several ports (nginx on 80 and 443) is one entry. Live listeners are
+// grouped by PID; with ?all=true disappeared ones are included too, grouped
+// by process name since their PIDs are stale.
+func getProcesses(c *gin.Context) {
+	q := DB.Order("host_id, protocol, port")
+	if c.Query("all") != "true" {
+		q = q.Where("current_state = ?", StateActive)
+	}
+	var runtimes []PortRuntime
+	q.Find(&runtimes)
+
+	var notes []PortNote
+	DB.Find(&notes)
+	titles := make(map[string]string, len(notes))
+	for _, n := range notes {
+		titles[fmtKey(n.HostID, n.Protocol, n.Port)] = n.Title
+	}
+
+	groups := map[string]*ProcessView{}
+	var order []string
+	for _, r := range runtimes {
+		key := r.HostID + "|name|" + r.ProcessName
+		if r.CurrentState == string(StateActive) && r.CurrentPID > 0 {
+			key = r.HostID + "|pid|" + strconv.Itoa(r.CurrentPID)
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &ProcessView{
+				HostID: r.HostID, ProcessName: r.ProcessName, Cmdline: r.Cmdline,
+				Username: r.Username, SystemdUnit: r.SystemdUnit,
+			}
+			if r.CurrentState == string(StateActive) {
+				g.PID = r.CurrentPID
+			}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.Ports = append(g.Ports, ProcessPort{
+			Protocol: r.Protocol, Port: r.Port, CurrentState: r.CurrentState,
+			Title: titles[fmtKey(r.HostID, r.Protocol, r.Port)],
+		})
+	}
+
+	result := make([]ProcessView, 0, len(order))
+	for _, key := range order {
+		result = append(result, *groups[key])
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].HostID != result[j].HostID {
+			return result[i].HostID < result[j].HostID
+		}
+		return result[i].ProcessName < result[j].ProcessName
+	})
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// portQuery is the compact filter syntax for port items, e.g.
+//
+//	status:suspicious proto:tcp proc:python* port:>1024 -tag:dev process~^kube
+//
+// Terms are ANDed. field:value matches case-insensitively, with * and ? as
+// wildcards; port also takes >N, >=N, <N, <=N, ranges (8000-8100) and lists
+// (80,443). field~regex matches a regular expression. A leading - negates
+// a term, and a bare word searches title, process, cmdline and owner.
+//
+// Terms on port_runtime columns are also translated to SQL (scopes), so
+// fewer rows are loaded; the in-memory match stays the reference.
+type portQuery struct {
+	terms []queryTerm
+}
+
+type queryTerm struct {
+	field  string
+	negate bool
+	match  func(item *MergedPortItem) bool
+
+	// SQL equivalent, if any. Key terms (host, protocol, port) hold for
+	// the note table too.
+	where string
+	args  []any
+	key   bool
+}
+
+// Fields with a port_runtime column; the first three are on port_note too.
+var queryColumns = map[string]string{
+	"host":     "host_id",
+	"proto":    "protocol",
+	"protocol": "protocol",
+	"proc":     "process_name",
+	"process":  "process_name",
+	"cmd":      "cmdline",
+	"cmdline":  "cmdline",
+	"user":     "username",
+	"unit":     "systemd_unit",
+	"state":    "current_state",
+}
+
+// Field aliases -> accessor. port and tag are handled separately.
+var queryFields = map[string]func(*MergedPortItem) string{
+	"host":     func(i *MergedPortItem) string { return i.HostID },
+	"proto":    func(i *MergedPortItem) string { return i.Protocol },
+	"proc":     func(i *MergedPortItem) string { return i.ProcessName },
+	"cmd":      func(i *MergedPortItem) string { return i.Cmdline },
+	"user":     func(i *MergedPortItem) string { return i.Username },
+	"unit":     func(i *MergedPortItem) string { return i.SystemdUnit },
+	"status":   func(i *MergedPortItem) string { return i.DerivedStatus },
+	"state":    func(i *MergedPortItem) string { return i.CurrentState },
+	"risk":     func(i *MergedPortItem) string { return i.RiskLevel },
+	"owner":    func(i *MergedPortItem) string { return i.Owner },
+	"title":    func(i *MergedPortItem) string { return i.Title },
+	"drift":    func(i *MergedPortItem) string { return i.Drift },
+	"wire":     func(i *MergedPortItem) string { return i.WireProtocol },
+	"ns":       func(i *MergedPortItem) string { return i.PodNamespace },
+	"protocol": func(i *MergedPortItem) string { return i.Protocol },
+	"process":  func(i *MergedPortItem) string { return i.ProcessName },
+	"cmdline":  func(i *MergedPortItem) string { return i.Cmdline },
+}
+
+func parsePortQuery(q string) (*portQuery, error) {
+	words, err := splitQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	pq := &portQuery{}
+	for _, w := range words {
+		t, err := parseQueryTerm(w)
+		if err != nil {
+			return nil, err
+		}
+		pq.terms = append(pq.terms, t)
+	}
+	return pq, nil
+}
+
+// splitQuery splits on whitespace, keeping "quoted values" together.
+func splitQuery(q string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if cur.Len() > 0 {
+				words = append(words, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in %q", q)
+	}
+	if cur.Len() > 0 {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
+
+func parseQueryTerm(word string) (queryTerm, error) {
+	t := queryTerm{}
+	if rest, ok := strings.CutPrefix(word, "-"); ok && rest != "" {
+		t.negate, word = true, rest
+	}
+
+	i := strings.IndexAny(word, ":~")
+	if i <= 0 {
+		needle := strings.ToLower(word)
+		t.field = "text"
+		t.match = func(item *MergedPortItem) bool {
+			for _, s := range []string{item.Title, item.ProcessName, item.Cmdline, item.Owner} {
+				if strings.Contains(strings.ToLower(s), needle) {
+					return true
+				}
+			}
+			return false
+		}
+		return t, nil
+	}
+	field, op, value := strings.ToLower(word[:i]), word[i], word[i+1:]
+	t.field = field
+
+	var strMatch func(string) bool
+	if col, ok := queryColumns[field]; ok && op == ':' {
+		t.where, t.args = globSQL(col, value)
+		t.key = col == "host_id" || col == "protocol"
+	}
+	if op == '~' {
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return t, fmt.Errorf("%s: %w", word, err)
+		}
+		strMatch = re.MatchString
+	} else {
+		strMatch = globMatcher(value)
+	}
+
+	switch field {
+	case "port":
+		if op == '~' {
+			t.match = func(item *MergedPortItem) bool { return strMatch(strconv.Itoa(item.Port)) }
+			return t, nil
+		}
+		m, where, args, err := portMatcher(value)
+		if err != nil {
+			return t, fmt.Errorf("%s: %w", word, err)
+		}
+		t.match = func(item *MergedPortItem) bool { return m(item.Port) }
+		t.where, t.args, t.key = where, args, true
+	case "tag", "tags":
+		t.match = func(item *MergedPortItem) bool {
+			return slices.ContainsFunc(splitList(item.Tags), strMatch)
+		}
+	case "hidden":
+		t.match = func(item *MergedPortItem) bool { return strMatch(strconv.FormatBool(item.Hidden)) }
+	default:
+		get, ok := queryFields[field]
+		if !ok {
+			return t, fmt.Errorf("unknown field %q", field)
+		}
+		t.match = func(item *MergedPortItem) bool { return strMatch(get(item)) }
+	}
+	return t, nil
+}
+
+// globMatcher matches case-insensitively, with * and ? as wildcards.
+func globMatcher(pattern string) func(string) bool {
+	if !strings.ContainsAny(pattern, "*?") {
+		return func(s string) bool { return strings.EqualFold(s, pattern) }
+	}
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(expr)
+	re := regexp.MustCompile("(?i)^" + expr + "$")
+	return re.MatchString
+}
+
+// globSQL is globMatcher as a condition on column. '!' escapes, as the
+// backslash means different things to different databases.
+func globSQL(column, pattern string) (string, []any) {
+	pattern = strings.ToLower(pattern)
+	if !strings.ContainsAny(pattern, "*?") {
+		return "LOWER(" + column + ") = ?", []any{pattern}
+	}
+	like := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_", "*", "%", "?", "_").Replace(pattern)
+	return "LOWER(" + column + ") LIKE ? ESCAPE '!'", []any{like}
+}
+
+// portMatcher returns the match of a port term and its SQL condition.
+func portMatcher(value string) (func(int) bool, string, []any, error) {
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if rest, ok := strings.CutPrefix(value, op); ok {
+			n, err := strconv.Atoi(rest)
+			if err != nil {
+				return nil, "", nil, fmt.Errorf("invalid port %q", rest)
+			}
+			where, args := "port "+op+" ?", []any{n}
+			switch op {
+			case ">=":
+				return func(p int) bool { return p >= n }, where, args, nil
+			case "<=":
+				return func(p int) bool { return p <= n }, where, args, nil
+			case ">":
+				return func(p int) bool { return p > n }, where, args, nil
+			}
+			return func(p int) bool { return p < n }, where, args, nil
+		}
+	}
+	var ranges [][2]int
+	var conds []string
+	var args []any
+	for _, part := range strings.Split(value, ",") {
+		a, b, isRange := strings.Cut(part, "-")
+		lo, err := strconv.Atoi(a)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("invalid port %q", part)
+		}
+		hi := lo
+		if isRange {
+			if hi, err = strconv.Atoi(b); err != nil || hi < lo {
+				return nil, "", nil, fmt.Errorf("invalid range %q", part)
+			}
+		}
+		ranges = append(ranges, [2]int{lo, hi})
+		conds = append(conds, "port BETWEEN ? AND ?")
+		args = append(args, lo, hi)
+	}
+	return func(p int) bool {
+		return slices.ContainsFunc(ranges, func(r [2]int) bool { return p >= r[0] && p <= r[1] })
+	}, "(" + strings.Join(conds, " OR ") + ")", args, nil
+}
+
+// Matches reports whether item satisfies every term.
+func (q *portQuery) Matches(item *MergedPortItem) bool {
+	for _, t := range q.terms {
+		if t.match(item) == t.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// runtimeScope narrows a port_runtime query to rows that can match.
+// Negated runtime-only terms stay in memory: dropping the runtime would
+// leave its note behind as an item without a process, which matches them.
+func (q *portQuery) runtimeScope(db *gorm.DB) *gorm.DB {
+	for _, t := range q.terms {
+		switch {
+		case t.where == "" || (t.negate && !t.key):
+		case t.negate:
+			db = db.Where("NOT ("+t.where+")", t.args...)
+		default:
+			db = db.Where(t.where, t.args...)
+		}
+	}
+	return db
+}
+
+// noteScope narrows a port_note query by the key terms.
+func (q *portQuery) noteScope(db *gorm.DB) *gorm.DB {
+	for _, t := range q.terms {
+		switch {
+		case !t.key:
+		case t.negate:
+			db = db.Where("NOT ("+t.where+")", t.args...)
+		default:
+			db = db.Where(t.where, t.args...)
+		}
+	}
+	return db
+}
+
+// mentions reports whether a term is on field.
+func (q *portQuery) mentions(field string) bool {
+	return slices.ContainsFunc(q.terms, func(t queryTerm) bool { return t.field == field })
+}
+
+// filter returns the matching items.
+func (q *portQuery) filter(items []MergedPortItem) []MergedPortItem {
+	out := []MergedPortItem{}
+	for i := range items {
+		if q.Matches(&items[i]) {
+			out = append(out, items[i])
+		}
+	}
+	return out
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Roles, lowest to highest. Viewers read everything; editors also change
+// notes, delete ports and trigger scans; admins additionally run
+// /inspect (executes witr), read /audit and everything under /admin.
+//
+// Without logins the browser keeps full access, as before. API keys map
+// their scope onto a role: read -> viewer, write -> editor, admin -> admin.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+var roles = []string{RoleViewer, RoleEditor, RoleAdmin}
+
+// Role given to users created by SSO on first login
+var oidcDefaultRole = envString("PORTMONOTE_OIDC_DEFAULT_ROLE", RoleViewer)
+
+func roleRank(role string) int {
+	for i, r := range roles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
+
+func validRole(role string) bool {
+	return roleRank(role) >= 0
+}
+
+func scopeRole(scope string) string {
+	switch scope {
+	case ScopeWrite:
+		return RoleEditor
+	case ScopeAdmin:
+		return RoleAdmin
+	}
+	return RoleViewer
+}
+
+// requiredRole is the minimum role for a request.
+func requiredRole(method, path string) string {
+	if strings.HasPrefix(path, "/admin/") || strings.HasPrefix(path, "/inspect/") || path == "/audit" {
+		return RoleAdmin
+	}
+	// Everyone keeps their own saved views (views.go)
+	if method == http.MethodGet || method == http.MethodHead || path == "/views" || strings.HasPrefix(path, "/views/") {
+		return RoleViewer
+	}
+	return RoleEditor
+}
+
+// authorize stores the caller's role and aborts if it is not enough.
+func authorize(c *gin.Context, role string) bool {
+	c.Set("role", role)
+	need := requiredRole(c.Request.Method, c.Request.URL.Path)
+	if roleRank(role) < roleRank(need) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Requires %s role", need)})
+		return false
+	}
+	return true
+}
+
+// ensureAdmin promotes the oldest user if nobody holds the admin role, so
+// upgrading from a build without roles doesn't lock everyone out.
+func ensureAdmin() {
+	var admins int64
+	DB.Model(&User{}).Where("role = ?", RoleAdmin).Count(&admins)
+	if admins > 0 {
+		return
+	}
+	var first User
+	if err := DB.Order("id").First(&first).Error; err != nil {
+		return
+	}
+	DB.Model(&first).Update("role", RoleAdmin)
+	log.Printf("👤 No admin user found, promoted %s to admin", first.Username)
+}
+
+func setUserRole(username, role string) error {
+	if !validRole(role) {
+		return fmt.Errorf("unknown role %q (expected %s)", role, strings.Join(roles, ", "))
+	}
+	res := DB.Model(&User{}).Where("username = ?", username).Update("role", role)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("user %q not found", username)
+	}
+	return nil
+}
+
+func listUsers(c *gin.Context) {
+	var users []User
+	DB.Order("id").Find(&users)
+	c.JSON(http.StatusOK, users)
+}
+
+type RoleUpdateRequest struct {
+	Role string `json:"role"`
+}
+
+// POST /admin/users/:id/role
+func updateUserRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		return
+	}
+	var req RoleUpdateRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var user User
+	if err := DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.Role == RoleAdmin && req.Role != RoleAdmin {
+		var admins int64
+		DB.Model(&User{}).Where("role = ?", RoleAdmin).Count(&admins)
+		if admins <= 1 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot demote the last admin"})
+			return
+		}
+	}
+	if err := setUserRole(user.Username, req.Role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user.Role = req.Role
+	c.JSON(http.StatusOK, user)
+}
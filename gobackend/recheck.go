@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// On-demand scans answer "is it still there right now?" without waiting
+// for the next cycle. Both run a collection cycle (waiting for one already
+// running) and return the fresh state; ?timeout= bounds the wait (default
+// 30s, 504 when exceeded, the cycle still finishes in the background).
+//
+//	POST /hosts/:id/trigger-scan                          the host's ports
+//	POST /ports/recheck?host_id=local&protocol=tcp&port=80   one port
+//
+// Hosts this server scans itself (the local host and the SNMP targets) are
+// scanned on the spot. For an agent host the request is queued instead and
+// answered with 202: the agent learns about it from its next report
+// (hosts.go) and sends fresh ports.
+var errRecheckTimeout = errors.New("scan did not finish in time")
+
+// scannedHere reports whether this server's collector scans the host.
+func scannedHere(hostID string) bool {
+	return hostID == HostID || slices.ContainsFunc(snmpTargets, func(t snmpTarget) bool { return t.Name == hostID })
+}
+
+// queueAgentRecheck flags an agent host for an on-demand scan; other hosts
+// this server doesn't scan are unknown.
+func queueAgentRecheck(c *gin.Context, hostID string) {
+	var h Host
+	if DB.Where("host_id = ? AND source = ?", hostID, "agent").First(&h).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Host is not scanned by this server"})
+		return
+	}
+	now := time.Now()
+	if err := DB.Model(&h).Update("recheck_at", now).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"host_id": hostID, "queued": true, "requested_at": now, "last_report_at": h.LastReportAt})
+}
+
+// runCycleWithin runs a collection cycle and waits for it, retrying while
+// another cycle holds the collector.
+func runCycleWithin(timeout time.Duration) (time.Duration, error) {
+	started := time.Now()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for time.Since(started) < timeout {
+			if !collectorState.snapshot().Running && RunCollectionCycle() {
+				return
+			}
+			time.Sleep(200 * time.Millisecond)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		return time.Since(started), errRecheckTimeout
+	}
+	if time.Since(started) >= timeout {
+		return time.Since(started), errRecheckTimeout
+	}
+	if last := collectorState.snapshot(); last.LastError != "" && last.LastErrorAt != nil && last.LastErrorAt.After(started) {
+		return time.Since(started), errors.New(last.LastError)
+	}
+	return time.Since(started), nil
+}
+
+func recheckTimeout(c *gin.Context) (time.Duration, bool) {
+	timeout, err := parseWindow(c.DefaultQuery("timeout", "30s"))
+	if err != nil || timeout <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timeout"})
+		return 0, false
+	}
+	return timeout, true
+}
+
+// scanFailed answers for a cycle that timed out or failed.
+func scanFailed(c *gin.Context, took time.Duration, err error) {
+	status := http.StatusBadGateway
+	if errors.Is(err, errRecheckTimeout) {
+		status = http.StatusGatewayTimeout
+	}
+	c.JSON(status, gin.H{"error": err.Error(), "duration_ms": took.Milliseconds()})
+}
+
+// hostItems returns the merged items of one host that have a runtime.
+func hostItems(c *gin.Context, hostID string) []MergedPortItem {
+	var runtimes []PortRuntime
+	var notes []PortNote
+	DB.Where("host_id = ?", hostID).Find(&runtimes)
+	DB.Where("host_id = ?", hostID).Find(&notes)
+	for i := range notes {
+		revealNote(c, &notes[i])
+	}
+	items := mergePortItems(runtimes, notes)
+	return slices.DeleteFunc(items, func(item MergedPortItem) bool { return item.RuntimeID == 0 })
+}
+
+// POST /hosts/:id/trigger-scan
+func triggerHostScan(c *gin.Context) {
+	hostID := c.Param("id")
+	if !scannedHere(hostID) {
+		queueAgentRecheck(c, hostID)
+		return
+	}
+	timeout, ok := recheckTimeout(c)
+	if !ok {
+		return
+	}
+	took, err := runCycleWithin(timeout)
+	if err != nil {
+		scanFailed(c, took, err)
+		return
+	}
+	items := hostItems(c, hostID)
+	c.JSON(http.StatusOK, gin.H{"host_id": hostID, "scanned_at": time.Now(), "duration_ms": took.Milliseconds(), "ports": items})
+}
+
+// POST /ports/recheck
+func recheckPort(c *gin.Context) {
+	hostID := c.Query("host_id")
+	proto := c.Query("protocol")
+	port, _ := strconv.Atoi(c.Query("port"))
+	if !scannedHere(hostID) {
+		queueAgentRecheck(c, hostID)
+		return
+	}
+	timeout, ok := recheckTimeout(c)
+	if !ok {
+		return
+	}
+
+	var before PortRuntime
+	DB.Where("host_id = ? AND protocol = ? AND port = ?", hostID, proto, port).First(&before)
+	took, err := runCycleWithin(timeout)
+	if err != nil {
+		scanFailed(c, took, err)
+		return
+	}
+	for _, item := range hostItems(c, hostID) {
+		if item.Protocol != proto || item.Port != port {
+			continue
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"listening":      item.CurrentState == string(StateActive),
+			"changed":        before.ID == 0 || before.CurrentState != item.CurrentState || before.CurrentPID != item.CurrentPID,
+			"previous_state": before.CurrentState,
+			"duration_ms":    took.Milliseconds(),
+			"port":           item,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"listening": false, "changed": false, "duration_ms": took.Milliseconds(), "port": nil})
+}
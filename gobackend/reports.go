@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	htmltemplate "html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Summary reports: what changed over the last period (new and disappeared
+// ports, process changes) and which suspicious ports are still waiting for
+// a note, as Markdown or HTML.
+//
+//	GET /reports/latest?format=md|html&period=7d&download=true
+//
+// With PORTMONOTE_REPORT_SCHEDULE (cron, e.g. "0 8 * * 1") the report is
+// also mailed to PORTMONOTE_REPORT_TO (comma-separated) through the SMTP
+// server of mail.go.
+var (
+	reportSchedule   = envString("PORTMONOTE_REPORT_SCHEDULE", "")
+	reportPeriod     = envDuration("PORTMONOTE_REPORT_PERIOD", 7*24*time.Hour)
+	reportRecipients = splitList(envString("PORTMONOTE_REPORT_TO", ""))
+)
+
+type Report struct {
+	From        time.Time        `json:"from"`
+	To          time.Time        `json:"to"`
+	New         []DiffPort       `json:"new"`
+	Disappeared []DiffPort       `json:"disappeared"`
+	Changed     []DiffChange     `json:"changed"`
+	Suspicious  []MergedPortItem `json:"suspicious"`
+	Link        string           `json:"link,omitempty"`
+}
+
+// buildReport summarizes the period ending at to.
+func buildReport(to time.Time, period time.Duration) (Report, error) {
+	cs, err := computeChangeset(to.Add(-period), to, nil)
+	if err != nil {
+		return Report{}, err
+	}
+	var runtimes []PortRuntime
+	var notes []PortNote
+	DB.Where("current_state = ?", StateActive).Find(&runtimes)
+	DB.Find(&notes)
+	suspicious := slices.DeleteFunc(mergePortItems(runtimes, notes), func(item MergedPortItem) bool {
+		return item.RuntimeID == 0 || item.Hidden || item.DerivedStatus != "suspicious"
+	})
+	slices.SortFunc(suspicious, func(a, b MergedPortItem) int {
+		return compareTimes(a.FirstSeenAt, b.FirstSeenAt)
+	})
+	return Report{
+		From: cs.From, To: cs.To,
+		New: cs.Added, Disappeared: cs.Removed, Changed: cs.Changed,
+		Suspicious: suspicious,
+		Link:       strings.TrimSuffix(notifyDashURL, "/"),
+	}, nil
+}
+
+var reportFuncs = map[string]any{
+	"date": func(t time.Time) string { return t.Local().Format("2006-01-02 15:04") },
+	"day":  func(t time.Time) string { return t.Local().Format("2006-01-02") },
+	"age": func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return formatDuration(time.Since(*t))
+	},
+}
+
+const reportMarkdown = `# Portmonote report {{day .From}} – {{day .To}}
+
+| | |
+|---|---|
+| New ports | {{len .New}} |
+| Disappeared ports | {{len .Disappeared}} |
+| Process changes | {{len .Changed}} |
+| Suspicious ports outstanding | {{len .Suspicious}} |
+
+## New ports
+{{range .New}}
+- {{.HostID}} {{.Protocol}}/{{.Port}} — {{or .ProcessName "?"}} ({{date .At}})
+{{- else}}
+None.
+{{- end}}
+
+## Disappeared ports
+{{range .Disappeared}}
+- {{.HostID}} {{.Protocol}}/{{.Port}} — {{or .ProcessName "?"}} ({{date .At}})
+{{- else}}
+None.
+{{- end}}
+
+## Process changes
+{{range .Changed}}
+- {{.HostID}} {{.Protocol}}/{{.Port}} — {{or .FromProcess "?"}} → {{or .ProcessName "?"}} ({{date .At}})
+{{- else}}
+None.
+{{- end}}
+
+## Suspicious ports outstanding
+{{range .Suspicious}}
+- {{.HostID}} {{.Protocol}}/{{.Port}} — {{or .ProcessName "?"}}{{with .Title}} "{{.}}"{{end}}, open for {{age .FirstSeenAt}}
+{{- else}}
+None.
+{{- end}}
+{{with .Link}}
+{{.}}
+{{end}}`
+
+const reportHTML = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Portmonote report {{day .From}} – {{day .To}}</title>
+<style>
+body { font-family: system-ui, sans-serif; color: #1f2937; max-width: 760px; margin: 2em auto; }
+table { border-collapse: collapse; } td, th { padding: 4px 12px; text-align: left; border-bottom: 1px solid #e5e7eb; }
+h2 { margin-top: 1.6em; } .none { color: #6b7280; }
+</style></head><body>
+<h1>Portmonote report {{day .From}} – {{day .To}}</h1>
+<table>
+<tr><td>New ports</td><td>{{len .New}}</td></tr>
+<tr><td>Disappeared ports</td><td>{{len .Disappeared}}</td></tr>
+<tr><td>Process changes</td><td>{{len .Changed}}</td></tr>
+<tr><td>Suspicious ports outstanding</td><td>{{len .Suspicious}}</td></tr>
+</table>
+
+<h2>New ports</h2>
+{{if .New}}<table><tr><th>Host</th><th>Port</th><th>Process</th><th>At</th></tr>
+{{range .New}}<tr><td>{{.HostID}}</td><td>{{.Protocol}}/{{.Port}}</td><td>{{or .ProcessName "?"}}</td><td>{{date .At}}</td></tr>
+{{end}}</table>{{else}}<p class="none">None.</p>{{end}}
+
+<h2>Disappeared ports</h2>
+{{if .Disappeared}}<table><tr><th>Host</th><th>Port</th><th>Process</th><th>At</th></tr>
+{{range .Disappeared}}<tr><td>{{.HostID}}</td><td>{{.Protocol}}/{{.Port}}</td><td>{{or .ProcessName "?"}}</td><td>{{date .At}}</td></tr>
+{{end}}</table>{{else}}<p class="none">None.</p>{{end}}
+
+<h2>Process changes</h2>
+{{if .Changed}}<table><tr><th>Host</th><th>Port</th><th>Process</th><th>At</th></tr>
+{{range .Changed}}<tr><td>{{.HostID}}</td><td>{{.Protocol}}/{{.Port}}</td><td>{{or .FromProcess "?"}} → {{or .ProcessName "?"}}</td><td>{{date .At}}</td></tr>
+{{end}}</table>{{else}}<p class="none">None.</p>{{end}}
+
+<h2>Suspicious ports outstanding</h2>
+{{if .Suspicious}}<table><tr><th>Host</th><th>Port</th><th>Process</th><th>Note</th><th>Open for</th></tr>
+{{range .Suspicious}}<tr><td>{{.HostID}}</td><td>{{.Protocol}}/{{.Port}}</td><td>{{or .ProcessName "?"}}</td><td>{{.Title}}</td><td>{{age .FirstSeenAt}}</td></tr>
+{{end}}</table>{{else}}<p class="none">None.</p>{{end}}
+{{with .Link}}<p><a href="{{.}}">Open the dashboard</a></p>{{end}}
+</body></html>
+`
+
+var (
+	reportMarkdownTmpl = template.Must(template.New("report.md").Funcs(reportFuncs).Parse(reportMarkdown))
+	reportHTMLTmpl     = htmltemplate.Must(htmltemplate.New("report.html").Funcs(reportFuncs).Parse(reportHTML))
+)
+
+// render returns the report as Markdown, or HTML if html is set.
+func (r *Report) render(html bool) (string, error) {
+	var b bytes.Buffer
+	var err error
+	if html {
+		err = reportHTMLTmpl.Execute(&b, r)
+	} else {
+		err = reportMarkdownTmpl.Execute(&b, r)
+	}
+	return b.String(), err
+}
+
+// GET /reports/latest
+func getLatestReport(c *gin.Context) {
+	period := reportPeriod
+	if p := c.Query("period"); p != "" {
+		d, err := parseWindow(p)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		period = d
+	}
+	format := c.DefaultQuery("format", "md")
+	if format != "md" && format != "html" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be md or html"})
+		return
+	}
+	report, err := buildReport(time.Now(), period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	body, err := report.render(format == "html")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	contentType := "text/markdown; charset=utf-8"
+	if format == "html" {
+		contentType = "text/html; charset=utf-8"
+	}
+	if c.Query("download") == "true" {
+		c.Header("Content-Disposition", `attachment; filename="portmonote-report-`+report.To.Format("2006-01-02")+"."+format+`"`)
+	}
+	c.Data(http.StatusOK, contentType, []byte(body))
+}
+
+// mailReport sends the report of the period ending now.
+func mailReport(ctx context.Context, _ string) (string, error) {
+	report, err := buildReport(time.Now(), reportPeriod)
+	if err != nil {
+		return "", err
+	}
+	text, err := report.render(false)
+	if err != nil {
+		return "", err
+	}
+	html, err := report.render(true)
+	if err != nil {
+		return "", err
+	}
+	subject := "[portmonote] Report " + report.From.Local().Format("2006-01-02") + " – " + report.To.Local().Format("2006-01-02")
+	if err := sendMail(reportRecipients, subject, text, html); err != nil {
+		return "", err
+	}
+	log.Printf("📨 Report mailed to %s", strings.Join(reportRecipients, ", "))
+	return "sent to " + strings.Join(reportRecipients, ", "), nil
+}
+
+func init() {
+	registerJob("report", JobKind{Run: mailReport})
+}
+
+// startReports queues the report mail on PORTMONOTE_REPORT_SCHEDULE.
+func startReports(ctx context.Context) {
+	if reportSchedule == "" {
+		return
+	}
+	spec, err := parseCron(reportSchedule)
+	if err != nil {
+		log.Printf("⚠️ Scheduled reports disabled: %v", err)
+		return
+	}
+	if len(reportRecipients) == 0 || smtpSettings.Host == "" {
+		log.Println("⚠️ Scheduled reports disabled: set PORTMONOTE_REPORT_TO and PORTMONOTE_SMTP_HOST")
+		return
+	}
+	log.Printf("📰 Reports mailed on %q to %d recipient(s)", reportSchedule, len(reportRecipients))
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		var last time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				// The ticker drifts; catch a start it stepped over
+				if start, ok := spec.lastStart(now, 2*time.Minute); ok && start.After(last) {
+					last = start
+					enqueueUnique("report", nil)
+				}
+			}
+		}
+	}()
+}
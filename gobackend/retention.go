@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetentionPolicy bounds the PortEvent table. Zero disables a rule.
+type RetentionPolicy struct {
+	KeepDays            int `json:"keep_days"`
+	MaxEventsPerRuntime int `json:"max_events_per_runtime"`
+}
+
+var (
+	retentionPolicy = RetentionPolicy{
+		KeepDays:            envInt("RETENTION_DAYS", 0),
+		MaxEventsPerRuntime: envInt("RETENTION_MAX_EVENTS_PER_RUNTIME", 0),
+	}
+	pruneInterval = envDuration("RETENTION_PRUNE_INTERVAL", 6*time.Hour)
+)
+
+type PruneResult struct {
+	DeletedByAge   int64 `json:"deleted_by_age"`
+	DeletedByLimit int64 `json:"deleted_by_limit"`
+	Total          int64 `json:"total"`
+}
+
+func pruneEvents(policy RetentionPolicy) (PruneResult, error) {
+	var res PruneResult
+
+	if policy.KeepDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -policy.KeepDays)
+		tx := DB.Where("timestamp < ?", cutoff).Delete(&PortEvent{})
+		if tx.Error != nil {
+			return res, tx.Error
+		}
+		res.DeletedByAge = tx.RowsAffected
+	}
+
+	if policy.MaxEventsPerRuntime > 0 {
+		type runtimeCount struct {
+			PortRuntimeID uint
+			N             int
+		}
+		var over []runtimeCount
+		err := DB.Model(&PortEvent{}).
+			Select("port_runtime_id, COUNT(*) AS n").
+			Group("port_runtime_id").
+			Having("COUNT(*) > ?", policy.MaxEventsPerRuntime).
+			Scan(&over).Error
+		if err != nil {
+			return res, err
+		}
+
+		for _, rc := range over {
+			// Resolve IDs in Go: MySQL rejects LIMIT inside IN subqueries
+			var ids []uint
+			err := DB.Model(&PortEvent{}).
+				Where("port_runtime_id = ?", rc.PortRuntimeID).
+				Order("timestamp desc, id desc").
+				Offset(policy.MaxEventsPerRuntime).
+				Limit(rc.N).
+				Pluck("id", &ids).Error
+			if err != nil {
+				return res, err
+			}
+			for start := 0; start < len(ids); start += 500 {
+				end := min(start+500, len(ids))
+				tx := DB.Where("id IN ?", ids[start:end]).Delete(&PortEvent{})
+				if tx.Error != nil {
+					return res, tx.Error
+				}
+				res.DeletedByLimit += tx.RowsAffected
+			}
+		}
+	}
+
+	res.Total = res.DeletedByAge + res.DeletedByLimit
+	return res, nil
+}
+
+func init() {
+	registerJob("prune", JobKind{Run: func(ctx context.Context, _ string) (string, error) {
+		res, err := pruneEvents(retentionPolicy)
+		if err != nil {
+			return "", err
+		}
+		if res.Total > 0 {
+			log.Printf("🧹 Pruned %d events (%d by age, %d by per-runtime limit)", res.Total, res.DeletedByAge, res.DeletedByLimit)
+		}
+		return fmt.Sprintf("%d deleted", res.Total), nil
+	}})
+}
+
+// startPruneLoop schedules the configured retention policy as a job.
+func startPruneLoop(ctx context.Context) {
+	if retentionPolicy.KeepDays == 0 && retentionPolicy.MaxEventsPerRuntime == 0 {
+		return
+	}
+	scheduleJob(ctx, "prune", pruneInterval)
+}
+
+// POST /admin/prune runs the configured policy now. A JSON body may override
+// the policy for this run, e.g. {"keep_days": 30}.
+func handlePrune(c *gin.Context) {
+	policy := retentionPolicy
+	if c.Request.ContentLength > 0 {
+		if err := c.BindJSON(&policy); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	res, err := pruneEvents(policy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"policy": policy, "deleted": res})
+}
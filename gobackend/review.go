@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// Notes can carry a review_after date. Once it passes, a port that would
+// otherwise be healthy is "needs-review" until someone saves its note with
+// a new date, so trusted classifications don't rot forever:
+//
+//	POST /notes?...   {"review_after": "2025-06-30"}   or "90d" from now, "" clears
+//	GET  /ports?status=needs-review
+//
+// PORTMONOTE_REVIEW_INTERVAL (e.g. 180d) gives every note marked trusted a
+// review date that far out unless one is set explicitly.
+var reviewInterval = envString("PORTMONOTE_REVIEW_INTERVAL", "")
+
+const StatusNeedsReview = "needs-review"
+
+// parseReviewAfter reads a date, an RFC 3339 time or a window from now.
+// The empty string clears the date.
+func parseReviewAfter(s string, now time.Time) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return &t, nil
+	}
+	d, err := parseWindow(s)
+	if err != nil {
+		return nil, errors.New("review_after must be a date (2006-01-02), RFC 3339 or a window like 90d")
+	}
+	t := now.Add(d)
+	return &t, nil
+}
+
+// defaultReviewAfter is the review date given to a note newly marked trusted.
+func defaultReviewAfter(now time.Time) *time.Time {
+	if reviewInterval == "" {
+		return nil
+	}
+	t, err := parseReviewAfter(reviewInterval, now)
+	if err != nil {
+		return nil
+	}
+	return t
+}
+
+func reviewOverdue(reviewAfter *time.Time, now time.Time) bool {
+	return reviewAfter != nil && !now.Before(*reviewAfter)
+}
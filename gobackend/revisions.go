@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Every change to a note keeps the values it replaced as a revision, so an
+// accidental edit can be undone:
+//
+//	GET  /notes/:id/history             newest first
+//	POST /notes/:id/revert/:revision    restores the values before that change
+//
+// A revert is a change like any other and gets a revision of its own.
+type NoteRevision struct {
+	ID       uint `gorm:"primaryKey" json:"id"`
+	NoteID   uint `gorm:"index:idx_note_revision,unique" json:"note_id"`
+	Revision int  `gorm:"index:idx_note_revision,unique" json:"revision"` // 1, 2, ... per note
+
+	// The values before the change; the description as stored, so it
+	// stays encrypted when the note was sensitive
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	Sensitive      bool       `json:"sensitive"`
+	Owner          string     `json:"owner"`
+	RiskLevel      string     `json:"risk_level"`
+	IsPinned       bool       `json:"is_pinned"`
+	Tags           string     `json:"tags"`
+	NotifyMuted    bool       `json:"notify_muted"`
+	NotifyEvents   string     `json:"notify_events"`
+	NotifyChannels string     `json:"notify_channels"`
+	ReviewAfter    *time.Time `json:"review_after,omitempty"`
+
+	Changed   string    `json:"changed"`   // fields the change touched, comma-separated
+	EditedBy  string    `json:"edited_by"` // who made the change
+	CreatedAt time.Time `json:"created_at"`
+
+	DescriptionRedacted bool `gorm:"-" json:"description_redacted,omitempty"`
+}
+
+func (NoteRevision) TableName() string {
+	return "note_revision"
+}
+
+// changedNoteFields lists the user-visible fields that differ.
+func changedNoteFields(a, b PortNote) []string {
+	var changed []string
+	add := func(name string, differs bool) {
+		if differs {
+			changed = append(changed, name)
+		}
+	}
+	add("title", a.Title != b.Title)
+	add("description", !sameDescription(a, b))
+	add("sensitive", a.Sensitive != b.Sensitive)
+	add("owner", a.Owner != b.Owner)
+	add("risk_level", a.RiskLevel != b.RiskLevel)
+	add("is_pinned", a.IsPinned != b.IsPinned)
+	add("tags", a.Tags != b.Tags)
+	add("notify_muted", a.NotifyMuted != b.NotifyMuted)
+	add("notify_events", a.NotifyEvents != b.NotifyEvents)
+	add("notify_channels", a.NotifyChannels != b.NotifyChannels)
+	add("review_after", !sameTime(a.ReviewAfter, b.ReviewAfter))
+	return changed
+}
+
+// sameDescription compares plaintexts: a re-encrypted description differs
+// in every byte without being an edit.
+func sameDescription(a, b PortNote) bool {
+	if a.Description == b.Description || !a.Sensitive || !b.Sensitive {
+		return a.Description == b.Description
+	}
+	pa, errA := decryptNoteText(a.Description)
+	pb, errB := decryptNoteText(b.Description)
+	return errA == nil && errB == nil && pa == pb
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// recordNoteRevision keeps prev as a revision of the note if next changed
+// it. New notes (prev.ID == 0) have nothing to keep.
+func recordNoteRevision(tx *gorm.DB, prev, next PortNote, actor string) error {
+	if prev.ID == 0 {
+		return nil
+	}
+	changed := changedNoteFields(prev, next)
+	if len(changed) == 0 {
+		return nil
+	}
+	var last int
+	if err := tx.Model(&NoteRevision{}).Where("note_id = ?", prev.ID).Select("COALESCE(MAX(revision), 0)").Scan(&last).Error; err != nil {
+		return err
+	}
+	return tx.Create(&NoteRevision{
+		NoteID: prev.ID, Revision: last + 1,
+		Title: prev.Title, Description: prev.Description, Sensitive: prev.Sensitive,
+		Owner: prev.Owner, RiskLevel: prev.RiskLevel, IsPinned: prev.IsPinned, Tags: prev.Tags,
+		NotifyMuted: prev.NotifyMuted, NotifyEvents: prev.NotifyEvents, NotifyChannels: prev.NotifyChannels,
+		ReviewAfter: prev.ReviewAfter,
+		Changed:     strings.Join(changed, ","), EditedBy: actor,
+	}).Error
+}
+
+// GET /notes/:id/history
+func noteHistory(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var note PortNote
+	if err := DB.First(&note, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+	var revs []NoteRevision
+	DB.Where("note_id = ?", note.ID).Order("revision desc").Find(&revs)
+	if revs == nil {
+		revs = []NoteRevision{}
+	}
+	for i := range revs {
+		r := &revs[i]
+		r.Description, r.DescriptionRedacted = revealDescription(c, &PortNote{ID: note.ID, Sensitive: r.Sensitive, Description: r.Description})
+	}
+	revealNote(c, &note)
+	c.JSON(http.StatusOK, gin.H{"note": note, "revisions": revs})
+}
+
+// POST /notes/:id/revert/:revision
+func revertNote(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var note PortNote
+	if err := DB.First(&note, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+	revision, _ := strconv.Atoi(c.Param("revision"))
+	var rev NoteRevision
+	if err := DB.Where("note_id = ? AND revision = ?", note.ID, revision).First(&rev).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+	// Same rule as editing: whoever can't read a sensitive description
+	// can't replace it either
+	if (note.Sensitive || rev.Sensitive) && !canReadSensitive(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The note's description is sensitive"})
+		return
+	}
+
+	actor := actorName(c)
+	prev := note
+	note.Title, note.Description, note.Sensitive = rev.Title, rev.Description, rev.Sensitive
+	note.Owner, note.IsPinned, note.Tags = rev.Owner, rev.IsPinned, rev.Tags
+	note.NotifyMuted, note.NotifyEvents, note.NotifyChannels = rev.NotifyMuted, rev.NotifyEvents, rev.NotifyChannels
+	note.ReviewAfter = rev.ReviewAfter
+	var approval *RiskApproval
+	if rev.RiskLevel != note.RiskLevel {
+		if needsApproval(note.RiskLevel, rev.RiskLevel) {
+			a, err := requestRiskApproval(note, rev.RiskLevel, actor, actorOwner(c))
+			if err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			approval = &a
+		} else {
+			note.RiskLevel = rev.RiskLevel
+		}
+	}
+	note.UpdatedBy = actor
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveNote(tx, &note); err != nil {
+			return err
+		}
+		if err := syncNoteTags(tx, &note); err != nil {
+			return err
+		}
+		return recordNoteRevision(tx, prev, note, actor)
+	})
+	if errors.Is(err, errNoteConflict) {
+		noteConflict(c, http.StatusConflict, note.HostID, note.Protocol, note.Port)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	auditValues(c, prev, note)
+	log.Printf("↩️ Note %s/%d reverted to revision %d by %s", note.Protocol, note.Port, rev.Revision, actor)
+	saved := note
+	publish(BusEvent{Topic: TopicNoteEdited, Note: &saved, Actor: actor})
+	revealNote(c, &note)
+	if approval != nil {
+		c.JSON(http.StatusAccepted, gin.H{"note": note, "pending_approval": approval})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"note": note, "reverted_to": rev.Revision})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// Each collection cycle samples CPU and RSS of every listening process, so
+// the UI can tell an idle mystery listener from a busy one. Samples are kept
+// for PORTMONOTE_SAMPLE_RETENTION. A process is flagged as runaway when its
+// last PORTMONOTE_RUNAWAY_SAMPLES samples all exceed PORTMONOTE_RUNAWAY_CPU
+// percent, or its RSS exceeds PORTMONOTE_RUNAWAY_RSS_MB (0 = no limit).
+var (
+	sampleRetention = envDuration("PORTMONOTE_SAMPLE_RETENTION", 6*time.Hour)
+	runawayCPU      = float64(envInt("PORTMONOTE_RUNAWAY_CPU", 90))
+	runawaySamples  = envInt("PORTMONOTE_RUNAWAY_SAMPLES", 5)
+	runawayRSSMB    = envInt("PORTMONOTE_RUNAWAY_RSS_MB", 0)
+)
+
+type ProcessSample struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	PortRuntimeID uint      `gorm:"index:idx_sample_runtime_ts" json:"port_runtime_id"`
+	Timestamp     time.Time `gorm:"index:idx_sample_runtime_ts" json:"timestamp"`
+	PID           int       `json:"pid"`
+	CPUPercent    float64   `json:"cpu_percent"`
+	RSSBytes      uint64    `json:"rss_bytes"`
+}
+
+func (ProcessSample) TableName() string {
+	return "process_sample"
+}
+
+// cpuSeen remembers each PID's CPU time from the previous cycle; CPU% is the
+// delta over wall time since then, not the lifetime average.
+var (
+	cpuSeenMu sync.Mutex
+	cpuSeen   = map[int]cpuMark{}
+)
+
+type cpuMark struct {
+	total float64 // user+system seconds
+	at    time.Time
+}
+
+type usage struct {
+	cpu   float64
+	rss   uint64
+	valid bool
+}
+
+func sampleProcess(pid int, now time.Time) usage {
+	p, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return usage{}
+	}
+	var u usage
+	if mem, err := p.MemoryInfo(); err == nil {
+		u.rss, u.valid = mem.RSS, true
+	}
+	if times, err := p.Times(); err == nil {
+		total := times.User + times.System
+		cpuSeenMu.Lock()
+		prev, ok := cpuSeen[pid]
+		cpuSeen[pid] = cpuMark{total: total, at: now}
+		cpuSeenMu.Unlock()
+		if ok && now.After(prev.at) && total >= prev.total {
+			u.cpu = (total - prev.total) / now.Sub(prev.at).Seconds() * 100
+		}
+		u.valid = true
+	}
+	return u
+}
+
+// sampleRuntimes records usage for the active runtimes of this cycle. Only
+// this host's: the PIDs of SNMP and agent runtimes belong to other machines.
+func sampleRuntimes(runtimes []*PortRuntime) {
+	runtimes = slices.DeleteFunc(slices.Clone(runtimes), func(rt *PortRuntime) bool { return rt.HostID != HostID })
+	now := time.Now()
+	byPID := map[int]usage{}
+	var samples []ProcessSample
+	for _, rt := range runtimes {
+		if rt.CurrentPID <= 0 {
+			continue
+		}
+		u, ok := byPID[rt.CurrentPID]
+		if !ok {
+			u = sampleProcess(rt.CurrentPID, now)
+			byPID[rt.CurrentPID] = u
+		}
+		if !u.valid {
+			continue
+		}
+		rt.CPUPercent, rt.RSSBytes = u.cpu, u.rss
+		DB.Model(rt).Updates(map[string]any{"cpu_percent": u.cpu, "rss_bytes": u.rss})
+		samples = append(samples, ProcessSample{PortRuntimeID: rt.ID, Timestamp: now, PID: rt.CurrentPID, CPUPercent: u.cpu, RSSBytes: u.rss})
+	}
+	if len(samples) > 0 {
+		if err := DB.CreateInBatches(samples, 100).Error; err != nil {
+			log.Println("Error saving process samples:", err)
+		}
+	}
+
+	// Forget PIDs that no longer listen
+	cpuSeenMu.Lock()
+	for pid := range cpuSeen {
+		if _, ok := byPID[pid]; !ok {
+			delete(cpuSeen, pid)
+		}
+	}
+	cpuSeenMu.Unlock()
+
+	DB.Where("timestamp < ?", now.Add(-sampleRetention)).Delete(&ProcessSample{})
+
+	for _, rt := range runtimes {
+		checkRunaway(rt)
+	}
+}
+
+// checkRunaway flips the runtime's runaway flag and records an event when
+// it starts.
+func checkRunaway(rt *PortRuntime) {
+	runaway := runawayRSSMB > 0 && rt.RSSBytes > uint64(runawayRSSMB)<<20
+	reason := fmt.Sprintf("RSS %d MB above %d MB", rt.RSSBytes>>20, runawayRSSMB)
+
+	if !runaway && runawayCPU > 0 && runawaySamples > 0 {
+		var recent []ProcessSample
+		DB.Where("port_runtime_id = ?", rt.ID).Order("timestamp desc").Limit(runawaySamples).Find(&recent)
+		if len(recent) == runawaySamples {
+			runaway = true
+			for _, s := range recent {
+				if s.CPUPercent < runawayCPU {
+					runaway = false
+					break
+				}
+			}
+			reason = fmt.Sprintf("CPU above %.0f%% for %d samples", runawayCPU, runawaySamples)
+		}
+	}
+
+	if runaway == rt.Runaway {
+		return
+	}
+	rt.Runaway = runaway
+	DB.Model(rt).Update("runaway", runaway)
+	if runaway {
+		recordEvent(rt, PortEvent{
+			PortRuntimeID: rt.ID,
+			EventType:     string(EventRunaway),
+			Timestamp:     time.Now(),
+			PID:           rt.CurrentPID,
+			ProcessName:   rt.ProcessName,
+			Detail:        reason,
+		})
+	}
+}
+
+// GET /samples?host_id=&protocol=&port=
+func getSamples(c *gin.Context) {
+	port, _ := strconv.Atoi(c.Query("port"))
+	var rt PortRuntime
+	if err := DB.Where("host_id = ? AND protocol = ? AND port = ?", c.Query("host_id"), c.Query("protocol"), port).First(&rt).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Runtime not found"})
+		return
+	}
+	var samples []ProcessSample
+	DB.Where("port_runtime_id = ?", rt.ID).Order("timestamp").Find(&samples)
+	c.JSON(http.StatusOK, samples)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// runScan implements `portmonote scan --once --format json`: one scan, printed
+// to stdout, no web server. Without --with-notes no database is touched, so it
+// works on hosts where the daemon isn't installed.
+func runScan(args []string) {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	once := fs.Bool("once", true, "Scan once and exit (false repeats every --interval)")
+	interval := fs.Duration("interval", time.Minute, "Delay between scans when --once=false")
+	format := fs.String("format", "json", "Output format: json or table")
+	withNotes := fs.Bool("with-notes", false, "Merge notes from the local portmonote database")
+	fs.Parse(args)
+
+	if *format != "json" && *format != "table" {
+		log.Fatalf("❌ Unknown format %q (expected json or table)", *format)
+	}
+
+	var notes []PortNote
+	if *withNotes {
+		InitDB("portmonote.db")
+		if err := DB.Find(&notes).Error; err != nil {
+			log.Fatalf("❌ Failed to load notes: %v", err)
+		}
+	}
+
+	for {
+		items, err := scanOnce(notes)
+		if err != nil {
+			log.Fatalf("❌ Scan failed: %v", err)
+		}
+		if err := printScan(items, *format); err != nil {
+			log.Fatalf("❌ Output failed: %v", err)
+		}
+		if *once {
+			return
+		}
+		time.Sleep(*interval)
+	}
+}
+
+// scanOnce turns a live scan into merged port items without persisting it.
+func scanOnce(notes []PortNote) ([]MergedPortItem, error) {
+	scanned, err := scanPorts()
+	if err != nil {
+		return nil, err
+	}
+	if k8sEnabled {
+		attributePods(scanned)
+	}
+
+	now := time.Now()
+	runtimes := make([]PortRuntime, 0, len(scanned))
+	for key, res := range scanned {
+		runtimes = append(runtimes, PortRuntime{
+			HostID:       key.HostID,
+			Protocol:     key.Protocol,
+			Port:         key.Port,
+			FirstSeenAt:  now,
+			LastSeenAt:   now,
+			CurrentState: string(StateActive),
+			CurrentPID:   res.PID,
+			ProcessName:  res.ProcessName,
+			Cmdline:      res.Cmdline,
+			Fingerprint:  processFingerprint(res.ProcessName, res.Cmdline),
+			PodName:      res.Pod.Name,
+			PodNamespace: res.Pod.Namespace,
+			PodContainer: res.Pod.Container,
+		})
+	}
+
+	// Notes for ports that are not listening right now are left out: this is
+	// a snapshot of what is open, not the full inventory.
+	items := []MergedPortItem{}
+	for _, item := range mergePortItems(runtimes, notes) {
+		if item.CurrentState == string(StateActive) {
+			item.UptimeHuman = ""
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Port != items[j].Port {
+			return items[i].Port < items[j].Port
+		}
+		return items[i].Protocol < items[j].Protocol
+	})
+	return items, nil
+}
+
+func printScan(items []MergedPortItem, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PROTO\tPORT\tPID\tPROCESS\tSTATUS\tTITLE")
+	for _, item := range items {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\n",
+			item.Protocol, item.Port, item.CurrentPID, item.ProcessName, item.DerivedStatus, item.Title)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// Scanner lists the sockets currently listening on this host.
+// Backends register themselves in init() and are picked at runtime.
+type Scanner interface {
+	Name() string
+	// Available reports why the backend can't run here (missing binary,
+	// wrong OS, no privileges), or nil.
+	Available() error
+	Scan() (map[PortKey]ScanResult, error)
+}
+
+var scannerRegistry = map[string]func() Scanner{}
+
+func registerScanner(name string, factory func() Scanner) {
+	scannerRegistry[name] = factory
+}
+
+// PORTMONOTE_SCANNER is "auto" or a comma-separated preference list such as
+// "lsof,gopsutil". Backends are tried in order; if one fails during a scan
+// the next available one is used for that cycle. Available: gopsutil, ss,
+// lsof, netstat and mock (fixture file, see scanner_mock.go).
+var (
+	scannerPreference = envString("PORTMONOTE_SCANNER", "auto")
+	autoScannerOrder  = []string{"gopsutil", "ss", "lsof", "netstat"}
+)
+
+var (
+	scannerOnce  sync.Once
+	scannerChain []Scanner
+)
+
+func activeScanners() []Scanner {
+	scannerOnce.Do(func() {
+		names := autoScannerOrder
+		if scannerPreference != "auto" {
+			names = strings.Split(scannerPreference, ",")
+		}
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			factory, ok := scannerRegistry[name]
+			if !ok {
+				log.Printf("⚠️ Unknown scanner backend %q (available: %s)", name, strings.Join(mapKeys(scannerRegistry), ", "))
+				continue
+			}
+			s := factory()
+			if err := s.Available(); err != nil {
+				log.Printf("⚠️ Scanner %s unavailable: %v", name, err)
+				continue
+			}
+			scannerChain = append(scannerChain, s)
+		}
+		if len(scannerChain) > 0 {
+			log.Printf("🔎 Using scanner backend: %s", scannerChain[0].Name())
+		}
+	})
+	return scannerChain
+}
+
+// scanPorts runs the preferred scanner, falling back down the chain on error.
+func scanPorts() (map[PortKey]ScanResult, error) {
+	chain := activeScanners()
+	if len(chain) == 0 {
+		return nil, errors.New("no scanner backend available")
+	}
+
+	var errs []error
+	for _, s := range chain {
+		results, err := s.Scan()
+		if err == nil {
+			warnOwnerless(results)
+			return results, nil
+		}
+		log.Printf("Scanner %s failed: %v", s.Name(), err)
+		errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// procInfo is what we can learn about a listening process.
+type procInfo struct {
+	Name     string
+	Cmdline  string
+	Username string // owner; the numeric UID when it has no passwd entry
+	ExePath  string
+	Unit     string // systemd unit and slice, Linux only
+	Slice    string
+}
+
+// processInfo resolves name, cmdline and owner for a PID; fields are empty
+// when not permitted.
+func processInfo(pid int) procInfo {
+	var info procInfo
+	p, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return info
+	}
+	info.Name, _ = p.Name()
+	info.Cmdline, _ = p.Cmdline()
+	info.ExePath, _ = p.Exe()
+	// Linux and macOS cut the name at 15/16 bytes; the binary has the full one
+	if base := filepath.Base(info.ExePath); len(info.Name) >= 15 && strings.HasPrefix(base, info.Name) {
+		info.Name = base
+	}
+	info.Unit, info.Slice = systemdUnit(pid)
+	if user, err := p.Username(); err == nil {
+		info.Username = user
+	} else if uids, err := p.Uids(); err == nil && len(uids) > 0 {
+		info.Username = strconv.FormatUint(uint64(uids[0]), 10)
+	}
+	return info
+}
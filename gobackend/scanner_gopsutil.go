@@ -0,0 +1,163 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// gopsutilScanner is the default, cross-platform backend.
+//
+// Platform quirks handled here:
+//   - UDP sockets have no LISTEN state anywhere ("NONE" on Linux, empty on
+//     Windows and macOS); bound sockets count, connected client sockets
+//     (with a remote port, e.g. DNS lookups) don't.
+//   - PID 0 means the owner couldn't be read: no root on Linux, SIP or a
+//     sandbox on macOS. Those ports are handed to the native tool (ss, lsof,
+//     netstat) which sometimes knows more, and skipped if it doesn't.
+//   - On Windows, kernel listeners (SMB, http.sys) belong to PID 4
+//     "System"; they are real listeners and are kept.
+type gopsutilScanner struct{}
+
+func init() {
+	registerScanner("gopsutil", func() Scanner { return gopsutilScanner{} })
+}
+
+func (gopsutilScanner) Name() string { return "gopsutil" }
+
+func (gopsutilScanner) Available() error { return nil }
+
+// sockDgram is SOCK_DGRAM, 2 on every platform gopsutil supports.
+const sockDgram = 2
+
+// ownerFallback is the native tool asked about sockets without a PID.
+var ownerFallback = map[string]string{
+	"linux":   "ss",
+	"darwin":  "lsof",
+	"freebsd": "lsof",
+	"windows": "netstat",
+}
+
+func (gopsutilScanner) Scan() (map[PortKey]ScanResult, error) {
+	// Get Connections (inet, all protocols unless UDP is held)
+	kind := "inet"
+	if !scanUDP() {
+		kind = "tcp"
+	}
+	conns, err := net.Connections(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	results, unowned := listenersFrom(conns)
+	if len(unowned) > 0 {
+		resolveOwners(results, unowned, time.Now())
+	}
+	return results, nil
+}
+
+// listenersFrom applies the quirks above to gopsutil's socket list. Sockets
+// without a PID come back separately as key -> state.
+func listenersFrom(conns []net.ConnectionStat) (map[PortKey]ScanResult, map[PortKey]string) {
+	results := make(map[PortKey]ScanResult)
+	unowned := map[PortKey]string{}
+	for _, c := range conns {
+		isUDP := c.Type == sockDgram
+		if isUDP && c.Raddr.Port != 0 {
+			continue // connected client socket
+		}
+		if !isUDP && c.Status != "LISTEN" {
+			continue
+		}
+
+		protocol := "tcp"
+		if isUDP {
+			protocol = "udp"
+		}
+		key := PortKey{
+			HostID:   HostID,
+			Protocol: protocol,
+			Port:     int(c.Laddr.Port),
+		}
+
+		pid := int(c.Pid)
+		if pid == 0 {
+			if _, ok := results[key]; !ok {
+				unowned[key] = c.Status
+			}
+			continue
+		}
+		delete(unowned, key) // e.g. the IPv6 twin of a dual-stack socket
+
+		// Get Process Info
+		info := processInfo(pid)
+		results[key] = ScanResult{
+			PID:         pid,
+			ProcessName: info.Name,
+			Cmdline:     info.Cmdline,
+			Username:    info.Username,
+			ExePath:     info.ExePath,
+			Unit:        info.Unit,
+			Slice:       info.Slice,
+			State:       c.Status,
+		}
+	}
+	return results, unowned
+}
+
+// ownerRetry is how long a socket the native tool couldn't attribute either
+// is left alone. Unprivileged, most unowned sockets stay that way, and
+// running ss or lsof for them every cycle is wasted work.
+var ownerRetry = envDuration("PORTMONOTE_OWNER_RETRY", 10*time.Minute)
+
+var ownerMisses = struct {
+	mu sync.Mutex
+	at map[PortKey]time.Time // last failed lookup
+}{at: map[PortKey]time.Time{}}
+
+// resolveOwners asks this platform's native tool about sockets gopsutil
+// couldn't attribute and adds those it could. The tool only runs when some
+// socket is new or its last failed lookup is older than ownerRetry.
+func resolveOwners(results map[PortKey]ScanResult, unowned map[PortKey]string, now time.Time) {
+	ownerMisses.mu.Lock()
+	defer ownerMisses.mu.Unlock()
+
+	due := false
+	misses := make(map[PortKey]time.Time, len(unowned))
+	for key := range unowned {
+		at, ok := ownerMisses.at[key]
+		if !ok || now.Sub(at) >= ownerRetry {
+			due = true
+			continue
+		}
+		misses[key] = at
+	}
+	if !due {
+		ownerMisses.at = misses // forget sockets that closed
+		return
+	}
+
+	factory, ok := scannerRegistry[ownerFallback[runtime.GOOS]]
+	if !ok {
+		return
+	}
+	s := factory()
+	if s.Available() != nil {
+		return
+	}
+	native, err := s.Scan()
+	if err != nil {
+		return
+	}
+	for key := range unowned {
+		if res, ok := native[key]; ok && res.PID != 0 {
+			results[key] = res
+			delete(misses, key)
+		} else {
+			misses[key] = now
+		}
+	}
+	ownerMisses.at = misses
+}
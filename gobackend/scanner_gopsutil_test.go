@@ -0,0 +1,159 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+const sockStream = 1
+
+func conn(typ uint32, status string, port, rport uint32, pid int32) net.ConnectionStat {
+	return net.ConnectionStat{
+		Type:   typ,
+		Status: status,
+		Laddr:  net.Addr{IP: "0.0.0.0", Port: port},
+		Raddr:  net.Addr{Port: rport},
+		Pid:    pid,
+	}
+}
+
+func TestListenersFrom(t *testing.T) {
+	cases := []struct {
+		name    string
+		conns   []net.ConnectionStat
+		want    map[PortKey]ScanResult
+		unowned []PortKey
+	}{
+		{
+			name:  "linux udp reports NONE",
+			conns: []net.ConnectionStat{conn(sockDgram, "NONE", 53, 0, fixturePID)},
+			want:  map[PortKey]ScanResult{udpKey(53): {PID: fixturePID, State: "NONE"}},
+		},
+		{
+			name:  "windows and macos udp have no state",
+			conns: []net.ConnectionStat{conn(sockDgram, "", 500, 0, fixturePID)},
+			want:  map[PortKey]ScanResult{udpKey(500): {PID: fixturePID}},
+		},
+		{
+			name:  "connected udp client skipped",
+			conns: []net.ConnectionStat{conn(sockDgram, "NONE", 41234, 53, fixturePID)},
+			want:  map[PortKey]ScanResult{},
+		},
+		{
+			name: "only listening tcp",
+			conns: []net.ConnectionStat{
+				conn(sockStream, "LISTEN", 22, 0, fixturePID),
+				conn(sockStream, "ESTABLISHED", 22, 51234, fixturePID),
+				conn(sockStream, "TIME_WAIT", 8080, 443, 0),
+			},
+			want: map[PortKey]ScanResult{tcpKey(22): {PID: fixturePID, State: "LISTEN"}},
+		},
+		{
+			name:    "no pid without privileges",
+			conns:   []net.ConnectionStat{conn(sockStream, "LISTEN", 631, 0, 0)},
+			want:    map[PortKey]ScanResult{},
+			unowned: []PortKey{tcpKey(631)},
+		},
+		{
+			name: "dual-stack twin with a pid wins",
+			conns: []net.ConnectionStat{
+				conn(sockStream, "LISTEN", 80, 0, 0),
+				conn(sockStream, "LISTEN", 80, 0, fixturePID),
+				conn(sockStream, "LISTEN", 443, 0, fixturePID2),
+				conn(sockStream, "LISTEN", 443, 0, 0),
+			},
+			want: map[PortKey]ScanResult{
+				tcpKey(80):  {PID: fixturePID, State: "LISTEN"},
+				tcpKey(443): {PID: fixturePID2, State: "LISTEN"},
+			},
+		},
+		{
+			name:  "windows system listener kept",
+			conns: []net.ConnectionStat{conn(sockStream, "LISTEN", 445, 0, fixturePID3)},
+			want:  map[PortKey]ScanResult{tcpKey(445): {PID: fixturePID3, State: "LISTEN"}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, unowned := listenersFrom(tc.conns)
+			checkScan(t, got, tc.want)
+			if len(unowned) != len(tc.unowned) {
+				t.Errorf("unowned = %v, want %v", unowned, tc.unowned)
+			}
+			for _, key := range tc.unowned {
+				if _, ok := unowned[key]; !ok {
+					t.Errorf("%s/%d not unowned", key.Protocol, key.Port)
+				}
+			}
+		})
+	}
+}
+
+// countingScanner stands in for ss/lsof/netstat in resolveOwners.
+type countingScanner struct {
+	calls  *int
+	result map[PortKey]ScanResult
+}
+
+func (countingScanner) Name() string     { return "counting" }
+func (countingScanner) Available() error { return nil }
+func (s countingScanner) Scan() (map[PortKey]ScanResult, error) {
+	*s.calls++
+	return s.result, nil
+}
+
+func TestResolveOwnersRetry(t *testing.T) {
+	calls := 0
+	registerScanner("counting", func() Scanner {
+		return countingScanner{calls: &calls, result: map[PortKey]ScanResult{
+			tcpKey(22): {PID: fixturePID, ProcessName: "sshd", State: "LISTEN"},
+		}}
+	})
+	prev := ownerFallback[runtime.GOOS]
+	ownerFallback[runtime.GOOS] = "counting"
+	t.Cleanup(func() {
+		ownerFallback[runtime.GOOS] = prev
+		delete(scannerRegistry, "counting")
+		ownerMisses.at = map[PortKey]time.Time{}
+	})
+
+	now := time.Now()
+	resolve := func(at time.Time, keys ...PortKey) map[PortKey]ScanResult {
+		results := map[PortKey]ScanResult{}
+		unowned := map[PortKey]string{}
+		for _, key := range keys {
+			unowned[key] = "LISTEN"
+		}
+		resolveOwners(results, unowned, at)
+		return results
+	}
+
+	if got := resolve(now, tcpKey(22), tcpKey(631)); got[tcpKey(22)].PID != fixturePID || calls != 1 {
+		t.Fatalf("first lookup: calls=%d results=%+v", calls, got)
+	}
+	// 631 stayed unowned: not asked about again until ownerRetry passes
+	resolve(now.Add(time.Minute), tcpKey(631))
+	if calls != 1 {
+		t.Errorf("known miss re-ran the tool (calls=%d)", calls)
+	}
+	// A new unowned socket is looked up right away
+	resolve(now.Add(2*time.Minute), tcpKey(631), tcpKey(8080))
+	if calls != 2 {
+		t.Errorf("new socket not looked up (calls=%d)", calls)
+	}
+	// Resolved sockets never count as misses
+	if got := resolve(now.Add(3*time.Minute), tcpKey(22), tcpKey(631), tcpKey(8080)); got[tcpKey(22)].PID != fixturePID || calls != 3 {
+		t.Errorf("resolvable socket skipped: calls=%d results=%+v", calls, got)
+	}
+	resolve(now.Add(3*time.Minute+ownerRetry-time.Second), tcpKey(631), tcpKey(8080))
+	if calls != 3 {
+		t.Errorf("miss retried before ownerRetry (calls=%d)", calls)
+	}
+	resolve(now.Add(3*time.Minute+ownerRetry), tcpKey(631), tcpKey(8080))
+	if calls != 4 {
+		t.Errorf("miss not retried after ownerRetry (calls=%d)", calls)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// lsofScanner parses `lsof -F` field output. Useful on macOS/BSD where
+// gopsutil can't always resolve socket owners.
+type lsofScanner struct{}
+
+func init() {
+	registerScanner("lsof", func() Scanner { return lsofScanner{} })
+}
+
+func (lsofScanner) Name() string { return "lsof" }
+
+func (lsofScanner) Available() error {
+	_, err := exec.LookPath("lsof")
+	return err
+}
+
+func (lsofScanner) Scan() (map[PortKey]ScanResult, error) {
+	sockets := "-i"
+	if !scanUDP() {
+		sockets = "-iTCP"
+	}
+	out, err := exec.Command("lsof", "+c", "0", "-nP", sockets, "-FpcfPnT").Output()
+	// lsof exits 1 when some files couldn't be listed; keep partial output
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return parseLsofOutput(out), nil
+}
+
+// parseLsofOutput reads field lines: p<pid>, c<command>, then per file
+// f<fd>, P<proto>, n<addr>, T<tcp info> (TST=LISTEN).
+func parseLsofOutput(out []byte) map[PortKey]ScanResult {
+	results := make(map[PortKey]ScanResult)
+
+	var (
+		pid         int
+		command     string
+		proto, addr string
+		state       string
+		haveFile    bool
+		procCache   = map[int]procInfo{}
+	)
+
+	flush := func() {
+		if !haveFile || pid == 0 {
+			return
+		}
+		haveFile = false
+
+		protocol := strings.ToLower(proto)
+		if protocol != "tcp" && protocol != "udp" {
+			return
+		}
+		if protocol == "tcp" && state != "LISTEN" {
+			return
+		}
+		if strings.Contains(addr, "->") {
+			return // connected socket, not a listener
+		}
+		i := strings.LastIndex(addr, ":")
+		if i < 0 {
+			return
+		}
+		port, err := strconv.Atoi(addr[i+1:])
+		if err != nil {
+			return
+		}
+
+		// lsof truncates command names; prefer the full name when readable
+		info, ok := procCache[pid]
+		if !ok {
+			info = processInfo(pid)
+			if info.Name == "" {
+				info.Name = command
+			}
+			procCache[pid] = info
+		}
+
+		key := PortKey{HostID: HostID, Protocol: protocol, Port: port}
+		results[key] = ScanResult{
+			PID:         pid,
+			ProcessName: info.Name,
+			Cmdline:     info.Cmdline,
+			Username:    info.Username,
+			ExePath:     info.ExePath,
+			Unit:        info.Unit,
+			Slice:       info.Slice,
+			State:       state,
+		}
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			continue
+		}
+		field, value := line[0], line[1:]
+		switch field {
+		case 'p':
+			flush()
+			pid, _ = strconv.Atoi(value)
+			command = ""
+		case 'c':
+			command = value
+		case 'f':
+			flush()
+			haveFile = true
+			proto, addr, state = "", "", ""
+		case 'P':
+			proto = value
+		case 'n':
+			addr = value
+		case 'T':
+			if s, ok := strings.CutPrefix(value, "ST="); ok {
+				state = s
+			}
+		}
+	}
+	flush()
+	return results
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/goccy/go-yaml"
+)
+
+// mockScanner reports the listeners listed in a fixture file instead of
+// looking at the host, for demos and tests. The file is re-read every
+// cycle, so editing it simulates ports appearing and disappearing.
+//
+//	PORTMONOTE_SCANNER=mock PORTMONOTE_SCANNER_FIXTURE=ports.yaml
+//
+//	- {protocol: tcp, port: 22, pid: 812, process: sshd, cmdline: /usr/sbin/sshd -D}
+//	- {protocol: udp, port: 53, process: dnsmasq}
+type mockScanner struct {
+	path string
+}
+
+type mockListener struct {
+	Protocol string `yaml:"protocol"`
+	Port     int    `yaml:"port"`
+	PID      int    `yaml:"pid"`
+	Process  string `yaml:"process"`
+	Cmdline  string `yaml:"cmdline"`
+	User     string `yaml:"user"`
+}
+
+func init() {
+	registerScanner("mock", func() Scanner {
+		return mockScanner{path: envString("PORTMONOTE_SCANNER_FIXTURE", "")}
+	})
+}
+
+func (mockScanner) Name() string { return "mock" }
+
+func (s mockScanner) Available() error {
+	if s.path == "" {
+		return errors.New("PORTMONOTE_SCANNER_FIXTURE is not set")
+	}
+	return nil
+}
+
+func (s mockScanner) Scan() (map[PortKey]ScanResult, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	var listeners []mockListener
+	if err := yaml.Unmarshal(data, &listeners); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", s.path, err)
+	}
+
+	results := make(map[PortKey]ScanResult, len(listeners))
+	for _, l := range listeners {
+		if l.Protocol == "" {
+			l.Protocol = "tcp"
+		}
+		key := PortKey{HostID: HostID, Protocol: l.Protocol, Port: l.Port}
+		results[key] = ScanResult{
+			PID:         l.PID,
+			ProcessName: l.Process,
+			Cmdline:     l.Cmdline,
+			Username:    l.User,
+			State:       "LISTEN",
+		}
+	}
+	return results, nil
+}
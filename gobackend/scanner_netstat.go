@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// netstatScanner parses `netstat -tulnp` (net-tools, busybox) or, on
+// Windows, `netstat -ano`. Last resort where neither gopsutil nor ss work.
+type netstatScanner struct{}
+
+func init() {
+	registerScanner("netstat", func() Scanner { return netstatScanner{} })
+}
+
+func (netstatScanner) Name() string { return "netstat" }
+
+func (netstatScanner) Available() error {
+	_, err := exec.LookPath("netstat")
+	return err
+}
+
+func (netstatScanner) Scan() (map[PortKey]ScanResult, error) {
+	args := []string{"-tulnp"}
+	if !scanUDP() {
+		args = []string{"-tlnp"}
+	}
+	if runtime.GOOS == "windows" {
+		args = []string{"-ano"}
+	}
+	out, err := exec.Command("netstat", args...).Output()
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return parseNetstatOutput(out), nil
+}
+
+// parseNetstatOutput reads both layouts:
+//
+//	tcp   0  0 0.0.0.0:22  0.0.0.0:*  LISTEN  1234/sshd: /usr/sbin
+//	udp   0  0 0.0.0.0:68  0.0.0.0:*          567/dhclient
+//	TCP   0.0.0.0:135      0.0.0.0:0  LISTENING  1044
+//	UDP   0.0.0.0:500      *:*                   4120
+func parseNetstatOutput(out []byte) map[PortKey]ScanResult {
+	results := make(map[PortKey]ScanResult)
+	procCache := map[int]procInfo{}
+
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		// tcp6 on Linux, tcp4/tcp46 on the BSDs
+		protocol := strings.TrimRight(strings.ToLower(fields[0]), "46")
+		if protocol != "tcp" && protocol != "udp" {
+			continue // headers
+		}
+
+		// Windows has no Recv-Q/Send-Q columns
+		rest := fields[1:]
+		if _, err := strconv.Atoi(rest[0]); err == nil && len(rest) >= 4 {
+			rest = rest[2:]
+		}
+		port, ok := addrPort(rest[0])
+		if !ok {
+			continue
+		}
+		rest = rest[2:] // local, foreign
+
+		state := ""
+		if protocol == "tcp" {
+			if len(rest) == 0 {
+				continue
+			}
+			state, rest = rest[0], rest[1:]
+			if state == "LISTENING" {
+				state = "LISTEN"
+			}
+			if state != "LISTEN" {
+				continue
+			}
+		}
+
+		// "1234/sshd: ...", "1234" (Windows) or "-" without privileges
+		var pid int
+		var command string
+		if len(rest) > 0 {
+			p, cmd, _ := strings.Cut(rest[0], "/")
+			pid, _ = strconv.Atoi(p)
+			command = strings.TrimSuffix(cmd, ":")
+		}
+		key := PortKey{HostID: HostID, Protocol: protocol, Port: port}
+		results[key] = scanResultFor(pid, command, state, procCache)
+	}
+	return results
+}
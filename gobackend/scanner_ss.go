@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ssScanner parses iproute2 `ss -H -tulnp`. For minimal Linux systems and
+// containers where gopsutil can't map sockets to processes.
+type ssScanner struct{}
+
+func init() {
+	registerScanner("ss", func() Scanner { return ssScanner{} })
+}
+
+func (ssScanner) Name() string { return "ss" }
+
+func (ssScanner) Available() error {
+	_, err := exec.LookPath("ss")
+	return err
+}
+
+func (ssScanner) Scan() (map[PortKey]ScanResult, error) {
+	flags := "-tulnp"
+	if !scanUDP() {
+		flags = "-tlnp"
+	}
+	out, err := exec.Command("ss", "-H", flags).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseSSOutput(out), nil
+}
+
+var ssUserPID = regexp.MustCompile(`\("([^"]*)",pid=(\d+)`)
+
+// parseSSOutput reads lines like
+//
+//	tcp LISTEN 0 4096 127.0.0.1:631 0.0.0.0:* users:(("cupsd",pid=812,fd=7))
+//	udp UNCONN 0 0    [::]:5353     [::]:*    users:(("avahi-daemon",pid=640,fd=13))
+func parseSSOutput(out []byte) map[PortKey]ScanResult {
+	results := make(map[PortKey]ScanResult)
+	procCache := map[int]procInfo{}
+
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		protocol, state := fields[0], fields[1]
+		if protocol != "tcp" && protocol != "udp" {
+			continue
+		}
+		if protocol == "tcp" && state != "LISTEN" {
+			continue
+		}
+		port, ok := addrPort(fields[4])
+		if !ok {
+			continue
+		}
+
+		var pid int
+		var command string
+		if len(fields) > 6 {
+			if m := ssUserPID.FindStringSubmatch(fields[6]); m != nil {
+				command = m[1]
+				pid, _ = strconv.Atoi(m[2])
+			}
+		}
+		key := PortKey{HostID: HostID, Protocol: protocol, Port: port}
+		results[key] = scanResultFor(pid, command, state, procCache)
+	}
+	return results
+}
+
+// addrPort takes the port off "host:port", "[v6]:port", "*:port" or the BSD
+// "host.port" form.
+func addrPort(addr string) (int, bool) {
+	i := strings.LastIndexAny(addr, ":.")
+	if i < 0 {
+		return 0, false
+	}
+	port, err := strconv.Atoi(addr[i+1:])
+	if err != nil || port <= 0 {
+		return 0, false
+	}
+	return port, true
+}
+
+// scanResultFor fills in process details for a PID found by a text-parsing
+// backend, falling back to the command name the tool printed.
+func scanResultFor(pid int, command, state string, cache map[int]procInfo) ScanResult {
+	info, ok := cache[pid]
+	if !ok && pid > 0 {
+		info = processInfo(pid)
+		if info.Name == "" {
+			info.Name = command
+		}
+		cache[pid] = info
+	}
+	if info.Name == "" {
+		info.Name = command
+	}
+	return ScanResult{
+		PID:         pid,
+		ProcessName: info.Name,
+		Cmdline:     info.Cmdline,
+		Username:    info.Username,
+		ExePath:     info.ExePath,
+		Unit:        info.Unit,
+		Slice:       info.Slice,
+		State:       state,
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Fixture PIDs sit above the kernel's pid_max so processInfo finds nothing
+// and the parsers fall back to the command name the tool printed.
+const (
+	fixturePID  = 4194401
+	fixturePID2 = 4194402
+	fixturePID3 = 4194403
+)
+
+type scanCase struct {
+	name string
+	out  string
+	want map[PortKey]ScanResult
+}
+
+func tcpKey(port int) PortKey { return PortKey{HostID: HostID, Protocol: "tcp", Port: port} }
+func udpKey(port int) PortKey { return PortKey{HostID: HostID, Protocol: "udp", Port: port} }
+
+func checkScan(t *testing.T, got, want map[PortKey]ScanResult) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("got %d listeners, want %d: %+v", len(got), len(want), got)
+	}
+	for key, w := range want {
+		g, ok := got[key]
+		if !ok {
+			t.Errorf("missing %s/%d", key.Protocol, key.Port)
+			continue
+		}
+		if g.PID != w.PID || g.ProcessName != w.ProcessName || g.State != w.State {
+			t.Errorf("%s/%d = {pid %d, %q, %q}, want {pid %d, %q, %q}", key.Protocol, key.Port,
+				g.PID, g.ProcessName, g.State, w.PID, w.ProcessName, w.State)
+		}
+	}
+}
+
+func TestParseSSOutput(t *testing.T) {
+	cases := []scanCase{
+		{
+			name: "tcp listener",
+			out:  `tcp LISTEN 0 4096 127.0.0.1:631 0.0.0.0:* users:(("cupsd",pid=4194401,fd=7))`,
+			want: map[PortKey]ScanResult{
+				tcpKey(631): {PID: fixturePID, ProcessName: "cupsd", State: "LISTEN"},
+			},
+		},
+		{
+			name: "ipv6 udp",
+			out:  `udp UNCONN 0 0 [::]:5353 [::]:* users:(("avahi-daemon",pid=4194402,fd=13))`,
+			want: map[PortKey]ScanResult{
+				udpKey(5353): {PID: fixturePID2, ProcessName: "avahi-daemon", State: "UNCONN"},
+			},
+		},
+		{
+			name: "wildcard and interface-scoped addresses",
+			out: "tcp LISTEN 0 128 *:8080 *:*\n" +
+				"udp UNCONN 0 0 127.0.0.53%lo:53 0.0.0.0:*\n",
+			want: map[PortKey]ScanResult{
+				tcpKey(8080): {State: "LISTEN"},
+				udpKey(53):   {State: "UNCONN"},
+			},
+		},
+		{
+			name: "no process without privileges",
+			out:  `tcp LISTEN 0 128 0.0.0.0:22 0.0.0.0:*`,
+			want: map[PortKey]ScanResult{
+				tcpKey(22): {State: "LISTEN"},
+			},
+		},
+		{
+			name: "established tcp and other protocols ignored",
+			out: "tcp ESTAB 0 0 10.0.0.2:22 10.0.0.9:51234 users:((\"sshd\",pid=4194401,fd=4))\n" +
+				"u_str LISTEN 0 4096 /run/systemd/private 13722 * 0\n" +
+				"raw UNCONN 0 0 *:ipv6-icmp *:*\n",
+			want: map[PortKey]ScanResult{},
+		},
+		{
+			name: "malformed lines",
+			out: "tcp LISTEN 0 128\n" +
+				"tcp LISTEN 0 128 localhost *:*\n" +
+				"tcp LISTEN 0 128 0.0.0.0:http 0.0.0.0:*\n" +
+				"udp UNCONN 0 0 0.0.0.0:0 0.0.0.0:*\n" +
+				"tcp LISTEN 0 128 0.0.0.0:9000 0.0.0.0:* users:((garbage\n" +
+				"\n",
+			want: map[PortKey]ScanResult{
+				tcpKey(9000): {State: "LISTEN"},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			checkScan(t, parseSSOutput([]byte(tc.out)), tc.want)
+		})
+	}
+}
+
+func TestParseNetstatOutput(t *testing.T) {
+	cases := []scanCase{
+		{
+			name: "net-tools",
+			out: "Active Internet connections (only servers)\n" +
+				"Proto Recv-Q Send-Q Local Address           Foreign Address         State       PID/Program name\n" +
+				"tcp        0      0 0.0.0.0:22              0.0.0.0:*               LISTEN      4194401/sshd: /usr/sbin\n" +
+				"tcp6       0      0 :::80                   :::*                    LISTEN      4194402/nginx: master\n" +
+				"udp        0      0 0.0.0.0:68              0.0.0.0:*                           4194403/dhclient\n",
+			want: map[PortKey]ScanResult{
+				tcpKey(22): {PID: fixturePID, ProcessName: "sshd", State: "LISTEN"},
+				tcpKey(80): {PID: fixturePID2, ProcessName: "nginx", State: "LISTEN"},
+				udpKey(68): {PID: fixturePID3, ProcessName: "dhclient"},
+			},
+		},
+		{
+			name: "busybox without privileges",
+			out: "tcp        0      0 127.0.0.1:5432          0.0.0.0:*               LISTEN      -\n" +
+				"udp        0      0 0.0.0.0:123             0.0.0.0:*                           -\n",
+			want: map[PortKey]ScanResult{
+				tcpKey(5432): {State: "LISTEN"},
+				udpKey(123):  {},
+			},
+		},
+		{
+			name: "windows",
+			out: "Active Connections\n\n" +
+				"  Proto  Local Address          Foreign Address        State           PID\n" +
+				"  TCP    0.0.0.0:135            0.0.0.0:0              LISTENING       4194401\n" +
+				"  TCP    [::]:445               [::]:0                 LISTENING       4194403\n" +
+				"  TCP    10.0.0.2:49712         20.42.65.88:443        ESTABLISHED     4194402\n" +
+				"  UDP    0.0.0.0:500            *:*                                    4194402\n",
+			want: map[PortKey]ScanResult{
+				tcpKey(135): {PID: fixturePID, State: "LISTEN"},
+				tcpKey(445): {PID: fixturePID3, State: "LISTEN"},
+				udpKey(500): {PID: fixturePID2},
+			},
+		},
+		{
+			name: "bsd dotted addresses",
+			out:  "tcp4       0      0  *.8080                 *.*                    LISTEN\n",
+			want: map[PortKey]ScanResult{
+				tcpKey(8080): {State: "LISTEN"},
+			},
+		},
+		{
+			name: "malformed lines",
+			out: "tcp 0 0\n" +
+				"tcp 0 0 0.0.0.0:22\n" +
+				"tcp 0 0 0.0.0.0:22 0.0.0.0:*\n" +
+				"tcp 0 0 localhost 0.0.0.0:* LISTEN 4194401/sshd\n" +
+				"udp 0 0 0.0.0.0:-1 0.0.0.0:*\n" +
+				"TCP 0.0.0.0:135 0.0.0.0:0\n" +
+				"unix 2 [ ACC ] STREAM LISTENING 13722 /run/systemd/private\n" +
+				"tcp 0 0 0.0.0.0:8443 0.0.0.0:* LISTEN junk/\n",
+			want: map[PortKey]ScanResult{
+				tcpKey(8443): {State: "LISTEN"},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			checkScan(t, parseNetstatOutput([]byte(tc.out)), tc.want)
+		})
+	}
+}
+
+func TestMockScanner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.yaml")
+	write := func(s string) {
+		if err := os.WriteFile(path, []byte(s), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := mockScanner{path: path}
+
+	write("- {protocol: tcp, port: 22, pid: 812, process: sshd}\n" +
+		"- {port: 8080, process: app}\n" +
+		"- {protocol: udp, port: 53, process: dnsmasq}\n")
+	got, err := s.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkScan(t, got, map[PortKey]ScanResult{
+		tcpKey(22):   {PID: 812, ProcessName: "sshd", State: "LISTEN"},
+		tcpKey(8080): {ProcessName: "app", State: "LISTEN"},
+		udpKey(53):   {ProcessName: "dnsmasq", State: "LISTEN"},
+	})
+
+	// Re-read every cycle: dropping a line makes the port disappear
+	write("- {protocol: tcp, port: 22, pid: 812, process: sshd}\n")
+	again, err := s.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkScan(t, again, map[PortKey]ScanResult{
+		tcpKey(22): {PID: 812, ProcessName: "sshd", State: "LISTEN"},
+	})
+
+	write("- {port: [not a number]}\n")
+	if _, err := s.Scan(); err == nil {
+		t.Error("malformed fixture parsed without error")
+	}
+	if err := (mockScanner{}).Available(); err == nil {
+		t.Error("mock scanner available without a fixture")
+	}
+}
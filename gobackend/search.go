@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Full-text search over notes (title, description, owner) and runtimes
+// (process name, cmdline):
+//
+//	GET /search?q=redis&limit=50&include_hidden=true
+//
+// Every word must appear in some field of the port; more and better hits
+// (a title over a cmdline) rank higher. Sensitive descriptions are never
+// indexed.
+//
+// On SQLite the index is an FTS5 table kept current by triggers. FTS5 needs
+// the sqlite_fts5 build tag; without it, and on other drivers, search falls
+// back to LIKE with the same field weights.
+var searchFTS bool
+
+// Field weights, shared by bm25 and the LIKE fallback
+var searchWeights = map[string]float64{
+	"title": 10, "owner": 5, "description": 3, "process_name": 5, "cmdline": 1,
+}
+
+var searchIndexDDL = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS note_fts USING fts5(title, description, owner, content='')`,
+	`CREATE VIRTUAL TABLE IF NOT EXISTS runtime_fts USING fts5(process_name, cmdline, content='')`,
+
+	`DROP TRIGGER IF EXISTS note_fts_ai`,
+	`DROP TRIGGER IF EXISTS note_fts_ad`,
+	`DROP TRIGGER IF EXISTS note_fts_au`,
+	`CREATE TRIGGER note_fts_ai AFTER INSERT ON port_note BEGIN
+		INSERT INTO note_fts(rowid, title, description, owner)
+		VALUES (new.id, new.title, CASE WHEN new.sensitive THEN '' ELSE new.description END, new.owner);
+	END`,
+	`CREATE TRIGGER note_fts_ad AFTER DELETE ON port_note BEGIN
+		INSERT INTO note_fts(note_fts, rowid, title, description, owner)
+		VALUES ('delete', old.id, old.title, CASE WHEN old.sensitive THEN '' ELSE old.description END, old.owner);
+	END`,
+	`CREATE TRIGGER note_fts_au AFTER UPDATE ON port_note BEGIN
+		INSERT INTO note_fts(note_fts, rowid, title, description, owner)
+		VALUES ('delete', old.id, old.title, CASE WHEN old.sensitive THEN '' ELSE old.description END, old.owner);
+		INSERT INTO note_fts(rowid, title, description, owner)
+		VALUES (new.id, new.title, CASE WHEN new.sensitive THEN '' ELSE new.description END, new.owner);
+	END`,
+
+	`DROP TRIGGER IF EXISTS runtime_fts_ai`,
+	`DROP TRIGGER IF EXISTS runtime_fts_ad`,
+	`DROP TRIGGER IF EXISTS runtime_fts_au`,
+	`CREATE TRIGGER runtime_fts_ai AFTER INSERT ON port_runtime BEGIN
+		INSERT INTO runtime_fts(rowid, process_name, cmdline) VALUES (new.id, new.process_name, new.cmdline);
+	END`,
+	`CREATE TRIGGER runtime_fts_ad AFTER DELETE ON port_runtime BEGIN
+		INSERT INTO runtime_fts(runtime_fts, rowid, process_name, cmdline) VALUES ('delete', old.id, old.process_name, old.cmdline);
+	END`,
+	`CREATE TRIGGER runtime_fts_au AFTER UPDATE OF process_name, cmdline ON port_runtime BEGIN
+		INSERT INTO runtime_fts(runtime_fts, rowid, process_name, cmdline) VALUES ('delete', old.id, old.process_name, old.cmdline);
+		INSERT INTO runtime_fts(rowid, process_name, cmdline) VALUES (new.id, new.process_name, new.cmdline);
+	END`,
+
+	// Rebuilt on every start, so an index missed by an older binary heals
+	`INSERT INTO note_fts(note_fts) VALUES ('delete-all')`,
+	`INSERT INTO note_fts(rowid, title, description, owner)
+		SELECT id, title, CASE WHEN sensitive THEN '' ELSE description END, owner FROM port_note`,
+	`INSERT INTO runtime_fts(runtime_fts) VALUES ('delete-all')`,
+	`INSERT INTO runtime_fts(rowid, process_name, cmdline) SELECT id, process_name, cmdline FROM port_runtime`,
+}
+
+// initSearchIndex sets up the FTS5 index on SQLite builds that have it.
+func initSearchIndex() {
+	if DB.Dialector.Name() != "sqlite" {
+		return
+	}
+	var n int
+	if err := DB.Raw("SELECT COUNT(*) FROM pragma_module_list WHERE name = 'fts5'").Scan(&n).Error; err != nil || n == 0 {
+		log.Println("ℹ️ SQLite built without FTS5 (-tags sqlite_fts5), search uses LIKE")
+		return
+	}
+	tx := DB.Begin()
+	for _, stmt := range searchIndexDDL {
+		if err := tx.Exec(stmt).Error; err != nil {
+			tx.Rollback()
+			log.Println("⚠️ Cannot create the search index, search uses LIKE:", err)
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Println("⚠️ Cannot create the search index, search uses LIKE:", err)
+		return
+	}
+	searchFTS = true
+}
+
+// SearchResult is a port item with its relevance.
+type SearchResult struct {
+	MergedPortItem
+	Score float64 `json:"score"`
+}
+
+// GET /search?q=
+func searchPorts(c *gin.Context) {
+	words := strings.Fields(strings.ToLower(c.Query("q")))
+	if len(words) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	limit = min(limit, 500)
+
+	var scores map[string]float64
+	engine := "like"
+	if searchFTS {
+		scores, err = searchScoresFTS(words)
+		engine = "fts5"
+	} else {
+		scores, err = searchScoresLike(words)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	results := []SearchResult{}
+	for _, item := range searchCandidates(c, scores) {
+		if !matchesAllWords(&item, words) || (item.Hidden && c.Query("include_hidden") != "true") {
+			continue
+		}
+		results = append(results, SearchResult{MergedPortItem: item, Score: scores[fmtKey(item.HostID, item.Protocol, item.Port)]})
+	}
+	slices.SortStableFunc(results, func(a, b SearchResult) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return a.Port - b.Port
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	c.JSON(http.StatusOK, gin.H{"query": c.Query("q"), "engine": engine, "results": results})
+}
+
+// searchScoresFTS scores ports by bm25 over both indexes. Words are ORed
+// here; matchesAllWords then requires each of them somewhere on the port,
+// which one index alone can't tell.
+func searchScoresFTS(words []string) (map[string]float64, error) {
+	terms := make([]string, len(words))
+	for i, w := range words {
+		terms[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"*`
+	}
+	match := strings.Join(terms, " OR ")
+	w := searchWeights
+
+	type hit struct {
+		HostID   string
+		Protocol string
+		Port     int
+		Bm25     float64
+	}
+	var hits []hit
+	err := DB.Raw(fmt.Sprintf(`
+		SELECT n.host_id, n.protocol, n.port, bm25(note_fts, %g, %g, %g) AS bm25
+		FROM note_fts JOIN port_note n ON n.id = note_fts.rowid
+		WHERE note_fts MATCH ? AND n.deleted_at IS NULL
+		UNION ALL
+		SELECT r.host_id, r.protocol, r.port, bm25(runtime_fts, %g, %g) AS bm25
+		FROM runtime_fts JOIN port_runtime r ON r.id = runtime_fts.rowid
+		WHERE runtime_fts MATCH ?`,
+		w["title"], w["description"], w["owner"], w["process_name"], w["cmdline"]), match, match).Scan(&hits).Error
+	if err != nil {
+		return nil, err
+	}
+	scores := map[string]float64{}
+	for _, h := range hits {
+		scores[fmtKey(h.HostID, h.Protocol, h.Port)] -= h.Bm25 // bm25: lower is better
+	}
+	return scores, nil
+}
+
+// searchScoresLike scores ports by weighted field hits.
+func searchScoresLike(words []string) (map[string]float64, error) {
+	noteQ, rtQ := DB.Model(&PortNote{}), DB.Model(&PortRuntime{})
+	noteCond, rtCond := DB.Where("1 = 0"), DB.Where("1 = 0")
+	for _, w := range words {
+		p := "%" + w + "%"
+		noteCond = noteCond.Or("LOWER(title) LIKE ? OR LOWER(owner) LIKE ? OR (sensitive = ? AND LOWER(description) LIKE ?)", p, p, false, p)
+		rtCond = rtCond.Or("LOWER(process_name) LIKE ? OR LOWER(cmdline) LIKE ?", p, p)
+	}
+	var notes []PortNote
+	var runtimes []PortRuntime
+	if err := noteQ.Where(noteCond).Find(&notes).Error; err != nil {
+		return nil, err
+	}
+	if err := rtQ.Where(rtCond).Find(&runtimes).Error; err != nil {
+		return nil, err
+	}
+
+	scores := map[string]float64{}
+	score := func(key, field, value string) {
+		value = strings.ToLower(value)
+		for _, w := range words {
+			if strings.Contains(value, w) {
+				scores[key] += searchWeights[field]
+			}
+		}
+	}
+	for _, n := range notes {
+		key := fmtKey(n.HostID, n.Protocol, n.Port)
+		score(key, "title", n.Title)
+		score(key, "owner", n.Owner)
+		if !n.Sensitive {
+			score(key, "description", n.Description)
+		}
+	}
+	for _, r := range runtimes {
+		key := fmtKey(r.HostID, r.Protocol, r.Port)
+		score(key, "process_name", r.ProcessName)
+		score(key, "cmdline", r.Cmdline)
+	}
+	return scores, nil
+}
+
+// searchCandidates merges the runtimes and notes of the scored ports.
+func searchCandidates(c *gin.Context, scores map[string]float64) []MergedPortItem {
+	if len(scores) == 0 {
+		return nil
+	}
+	var runtimes []PortRuntime
+	var notes []PortNote
+	DB.Find(&runtimes)
+	DB.Find(&notes)
+	runtimes = slices.DeleteFunc(runtimes, func(r PortRuntime) bool { _, ok := scores[fmtKey(r.HostID, r.Protocol, r.Port)]; return !ok })
+	notes = slices.DeleteFunc(notes, func(n PortNote) bool { _, ok := scores[fmtKey(n.HostID, n.Protocol, n.Port)]; return !ok })
+	for i := range notes {
+		revealNote(c, &notes[i])
+	}
+	return mergePortItems(runtimes, notes)
+}
+
+// matchesAllWords reports whether every word is in a searched field.
+func matchesAllWords(item *MergedPortItem, words []string) bool {
+	fields := []string{item.Title, item.Owner, item.ProcessName, item.Cmdline}
+	if !item.Sensitive {
+		fields = append(fields, item.Description)
+	}
+	text := strings.ToLower(strings.Join(fields, "\n"))
+	for _, w := range words {
+		if !strings.Contains(text, w) {
+			return false
+		}
+	}
+	return true
+}
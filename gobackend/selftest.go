@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// POST /selftest proves the whole pipeline works (scanner permissions,
+// collector, database): it opens a throwaway TCP listener, runs cycles until
+// the appeared event shows up, closes it and waits for the disappeared
+// event. The test runtime and its events are deleted afterwards and never
+// reach the bus, so nobody gets notified. If the random port was tracked
+// before, only what the test added goes; its runtime and note are put back
+// as they were. ?timeout=30s per step (default
+// 15s).
+type SelfTestStep struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	DurationMs int64  `json:"duration_ms"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+type SelfTestResult struct {
+	OK      bool           `json:"ok"`
+	Port    int            `json:"port"`
+	Scanner string         `json:"scanner"`
+	Steps   []SelfTestStep `json:"steps"`
+}
+
+var (
+	selfTestMu   sync.Mutex
+	selfTestPort atomic.Int64 // port of the running self-test, 0 if none
+)
+
+// isSelfTestRuntime keeps the self-test listener off the bus.
+func isSelfTestRuntime(rt PortRuntime) bool {
+	port := selfTestPort.Load()
+	return port != 0 && rt.HostID == HostID && rt.Protocol == string(TCP) && int64(rt.Port) == port
+}
+
+func handleSelfTest(c *gin.Context) {
+	timeout, err := parseWindow(c.DefaultQuery("timeout", "15s"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !selfTestMu.TryLock() {
+		c.JSON(http.StatusConflict, gin.H{"error": "A self-test is already running"})
+		return
+	}
+	defer selfTestMu.Unlock()
+
+	res := runSelfTest(timeout)
+	log.Printf("🩺 Self-test on port %d: ok=%v", res.Port, res.OK)
+	status := http.StatusOK
+	if !res.OK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, res)
+}
+
+func runSelfTest(timeout time.Duration) SelfTestResult {
+	res := SelfTestResult{Scanner: activeScannerName()}
+	step := func(name string, fn func() (string, error)) bool {
+		start := time.Now()
+		detail, err := fn()
+		s := SelfTestStep{Name: name, OK: err == nil, DurationMs: time.Since(start).Milliseconds(), Detail: detail}
+		if err != nil {
+			s.Detail = err.Error()
+		}
+		res.Steps = append(res.Steps, s)
+		return s.OK
+	}
+
+	var ln net.Listener
+	var prior selfTestPrior
+	started := time.Now()
+	ok := step("listen", func() (string, error) {
+		var err error
+		ln, err = net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return "", err
+		}
+		res.Port = ln.Addr().(*net.TCPAddr).Port
+		prior = snapshotSelfTestPort(res.Port)
+		selfTestPort.Store(int64(res.Port))
+		return ln.Addr().String(), nil
+	})
+	if !ok {
+		return res
+	}
+	defer func() {
+		if ln != nil {
+			ln.Close()
+		}
+		cleanupSelfTest(res.Port, started, prior)
+		selfTestPort.Store(0)
+	}()
+
+	ok = step("detect_appeared", func() (string, error) {
+		return waitForSelfTestEvent(res.Port, started, timeout, string(EventAppeared), string(EventReappeared), string(EventInherited))
+	})
+	if !ok {
+		return res
+	}
+
+	closed := time.Now()
+	ln.Close()
+	ln = nil
+	ok = step("detect_disappeared", func() (string, error) {
+		return waitForSelfTestEvent(res.Port, closed, timeout, string(EventDisappeared))
+	})
+	res.OK = ok
+	return res
+}
+
+// waitForSelfTestEvent runs collection cycles until the runtime of port has
+// one of the event types after since.
+func waitForSelfTestEvent(port int, since time.Time, timeout time.Duration, types ...string) (string, error) {
+	deadline := time.Now().Add(timeout)
+	cycles := 0
+	for time.Now().Before(deadline) {
+		if RunCollectionCycle() {
+			cycles++
+		}
+		var evt PortEvent
+		err := DB.Joins("JOIN port_runtime ON port_runtime.id = port_event.port_runtime_id").
+			Where("port_runtime.host_id = ? AND port_runtime.protocol = ? AND port_runtime.port = ?", HostID, TCP, port).
+			Where("port_event.event_type IN ? AND port_event.timestamp >= ?", types, since).
+			First(&evt).Error
+		if err == nil {
+			return fmt.Sprintf("%s after %d cycle(s)", evt.EventType, cycles), nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	if last := collectorState.snapshot().LastError; last != "" {
+		return "", fmt.Errorf("not detected within %s (last collector error: %s)", timeout, last)
+	}
+	return "", fmt.Errorf("not detected within %s after %d cycle(s)", timeout, cycles)
+}
+
+// selfTestPrior is what the database held for the test port beforehand,
+// archived rows included.
+type selfTestPrior struct {
+	runtimes map[uint]PortRuntime
+	notes    []PortNote
+}
+
+func snapshotSelfTestPort(port int) selfTestPrior {
+	prior := selfTestPrior{runtimes: map[uint]PortRuntime{}}
+	var runtimes []PortRuntime
+	DB.Unscoped().Where("host_id = ? AND protocol = ? AND port = ?", HostID, TCP, port).Find(&runtimes)
+	for _, rt := range runtimes {
+		prior.runtimes[rt.ID] = rt
+	}
+	DB.Unscoped().Where("host_id = ? AND protocol = ? AND port = ?", HostID, TCP, port).Find(&prior.notes)
+	return prior
+}
+
+// cleanupSelfTest removes every trace of the test listener: runtimes it
+// created go entirely, runtimes that existed before lose only the rows
+// added since started and get their old state back.
+func cleanupSelfTest(port int, started time.Time, prior selfTestPrior) {
+	var runtimes []PortRuntime
+	DB.Unscoped().Where("host_id = ? AND protocol = ? AND port = ?", HostID, TCP, port).Find(&runtimes)
+	for _, rt := range runtimes {
+		old, existed := prior.runtimes[rt.ID]
+		if !existed {
+			DB.Where("port_runtime_id = ?", rt.ID).Delete(&PortEvent{})
+			DB.Where("port_runtime_id = ?", rt.ID).Delete(&PortPeer{})
+			DB.Where("port_runtime_id = ?", rt.ID).Delete(&ProcessSample{})
+			DB.Unscoped().Delete(&rt)
+			continue
+		}
+		DB.Where("port_runtime_id = ? AND timestamp >= ?", rt.ID, started).Delete(&PortEvent{})
+		DB.Where("port_runtime_id = ? AND first_seen_at >= ?", rt.ID, started).Delete(&PortPeer{})
+		DB.Where("port_runtime_id = ? AND timestamp >= ?", rt.ID, started).Delete(&ProcessSample{})
+		DB.Unscoped().Model(&old).Select("*").UpdateColumns(old)
+	}
+	// An archived note the test re-linked goes back to the archive
+	for _, n := range prior.notes {
+		DB.Unscoped().Model(&n).UpdateColumn("deleted_at", n.DeletedAt)
+	}
+}
+
+func activeScannerName() string {
+	if chain := activeScanners(); len(chain) > 0 {
+		return chain[0].Name()
+	}
+	return ""
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Optional login for the dashboard. PORTMONOTE_AUTH=session puts every page
+// and API route (except /login and static assets) behind a session cookie.
+// API keys keep working as before. Users are created with
+// `portmonote admin useradd` or bootstrapped from PORTMONOTE_ADMIN_USER /
+// PORTMONOTE_ADMIN_PASSWORD when the user table is empty.
+var (
+	authMode   = envString("PORTMONOTE_AUTH", "none")
+	sessionTTL = envDuration("PORTMONOTE_SESSION_TTL", 7*24*time.Hour)
+)
+
+const sessionCookie = "portmonote_session"
+
+func sessionAuthEnabled() bool {
+	return authMode == "session" || oidcEnabled()
+}
+
+// actorName identifies who made a change, for notes and events: the login
+// name, "apikey:<name>", or "" when auth is off.
+func actorName(c *gin.Context) string {
+	principal := c.GetString("principal")
+	if name, ok := strings.CutPrefix(principal, "user:"); ok {
+		return name
+	}
+	return principal
+}
+
+// actorOwner is the user behind the caller: the user itself, or whoever
+// created the API key it uses.
+func actorOwner(c *gin.Context) string {
+	if owner := c.GetString("owner"); owner != "" {
+		return owner
+	}
+	return actorName(c)
+}
+
+type User struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	Username     string `gorm:"uniqueIndex;size:64" json:"username"`
+	PasswordHash string `json:"-"`
+	Role         string `gorm:"default:viewer" json:"role"` // rbac.go
+	// SSO identity (oidc.go): accounts are matched on issuer + subject
+	SSOIssuer  string    `gorm:"index:idx_user_sso" json:"sso_issuer,omitempty"`
+	SSOSubject string    `gorm:"index:idx_user_sso" json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (User) TableName() string {
+	return "app_user" // "user" is reserved in Postgres
+}
+
+// Session: the cookie holds a random token; only its hash is stored.
+type Session struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TokenHash string    `gorm:"uniqueIndex;size:64" json:"-"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+
+	// Activity (activity.go)
+	LoginIP    string     `json:"login_ip"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	LastIP     string     `json:"last_ip"`
+	UserAgent  string     `json:"user_agent"`
+}
+
+func (Session) TableName() string {
+	return "user_session"
+}
+
+func createUser(username, password, role string) (*User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+	if !validRole(role) {
+		return nil, fmt.Errorf("unknown role %q", role)
+	}
+	if len(password) < 8 {
+		return nil, errors.New("password must be at least 8 characters")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user := User{Username: username, PasswordHash: string(hash), Role: role}
+	if err := DB.Create(&user).Error; err != nil {
+		return nil, fmt.Errorf("create user %q: %w", username, err)
+	}
+	return &user, nil
+}
+
+func setUserPassword(username, password string) error {
+	if len(password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	res := DB.Model(&User{}).Where("username = ?", username).Update("password_hash", string(hash))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("user %q not found", username)
+	}
+	// Changing the password logs out existing sessions
+	return DB.Where("username = ?", username).Delete(&Session{}).Error
+}
+
+// startSessionAuth bootstraps the first user and expires old sessions.
+func startSessionAuth() {
+	if !sessionAuthEnabled() {
+		return
+	}
+	bootstrapAdminUser()
+	ensureAdmin()
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		for range ticker.C {
+			pruneSessions()
+		}
+	}()
+}
+
+// bootstrapAdminUser creates the first account from the environment.
+func bootstrapAdminUser() {
+	var count int64
+	DB.Model(&User{}).Count(&count)
+	if count > 0 {
+		return
+	}
+	username := envString("PORTMONOTE_ADMIN_USER", "")
+	password := envString("PORTMONOTE_ADMIN_PASSWORD", "")
+	if username == "" || password == "" {
+		if !oidcEnabled() {
+			log.Println("⚠️  Session auth is on but no users exist. Run: portmonote admin useradd --username NAME")
+		}
+		return
+	}
+	if _, err := createUser(username, password, RoleAdmin); err != nil {
+		log.Printf("❌ Could not create admin user: %v", err)
+		return
+	}
+	log.Printf("👤 Created user %s", username)
+}
+
+func checkPassword(username, password string) (*User, bool) {
+	var user User
+	if err := DB.Where("username = ?", username).First(&user).Error; err != nil {
+		// Burn comparable time so unknown users aren't distinguishable
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return nil, false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, false
+	}
+	return &user, true
+}
+
+var dummyHash = sync.OnceValue(func() []byte {
+	h, _ := bcrypt.GenerateFromPassword([]byte("portmonote"), bcrypt.DefaultCost)
+	return h
+})
+
+func newSession(c *gin.Context, user *User) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	raw := hex.EncodeToString(buf)
+	sess := Session{
+		TokenHash: hashAPIKey(raw),
+		UserID:    user.ID,
+		Username:  user.Username,
+		ExpiresAt: time.Now().Add(sessionTTL),
+		LoginIP:   c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if err := DB.Create(&sess).Error; err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// sessionUser resolves the session cookie to its user. The role is read on
+// every request so role changes apply immediately.
+func sessionUser(c *gin.Context) (*User, bool) {
+	raw, err := c.Cookie(sessionCookie)
+	if err != nil || raw == "" {
+		return nil, false
+	}
+	var sess Session
+	if err := DB.Where("token_hash = ? AND expires_at > ?", hashAPIKey(raw), time.Now()).First(&sess).Error; err != nil {
+		return nil, false
+	}
+	var user User
+	if err := DB.First(&user, sess.UserID).Error; err != nil {
+		return nil, false
+	}
+	touchSession(&sess, c.ClientIP())
+	return &user, true
+}
+
+func setSessionCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookie, value, maxAge, "/", "", c.Request.TLS != nil, true)
+}
+
+type LoginRequest struct {
+	Username string `json:"username" form:"username"`
+	Password string `json:"password" form:"password"`
+}
+
+// POST /login accepts JSON or a form post from the login page.
+func handleLogin(c *gin.Context) {
+	var req LoginRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	isForm := !strings.HasPrefix(c.ContentType(), "application/json")
+
+	user, ok := checkPassword(req.Username, req.Password)
+	if !ok {
+		log.Printf("Failed login for %q from %s", req.Username, c.ClientIP())
+		if isForm {
+			c.Redirect(http.StatusSeeOther, "/login?error=1")
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		return
+	}
+
+	token, err := newSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	setSessionCookie(c, token, int(sessionTTL.Seconds()))
+	if isForm {
+		c.Redirect(http.StatusSeeOther, "/")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "username": user.Username})
+}
+
+// POST /logout drops the session server-side and clears the cookie. It
+// needs no login (an expired session can still clear its cookie) but does
+// need the CSRF token, so other sites can't log users out.
+func handleLogout(c *gin.Context) {
+	if !validCSRF(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid CSRF Token. Refresh page."})
+		return
+	}
+	if raw, err := c.Cookie(sessionCookie); err == nil && raw != "" {
+		DB.Where("token_hash = ?", hashAPIKey(raw)).Delete(&Session{})
+	}
+	setSessionCookie(c, "", -1)
+	c.JSON(http.StatusOK, gin.H{"status": "logged_out"})
+}
+
+// GET /me tells the UI who is logged in.
+func handleMe(c *gin.Context) {
+	mode := authMode
+	if sessionAuthEnabled() {
+		mode = "session"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"auth":      mode,
+		"principal": c.GetString("principal"),
+		"role":      c.GetString("role"),
+	})
+}
+
+func handleLoginPage(c *gin.Context) {
+	msg := ""
+	if c.Query("error") != "" {
+		msg = `<p class="err">Invalid username or password</p>`
+	}
+	if oidcEnabled() {
+		msg += `<a class="sso" href="/auth/oidc/login">Log in with SSO</a><p class="or">or</p>`
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(http.StatusOK, loginPage, msg)
+}
+
+func pruneSessions() {
+	DB.Where("expires_at <= ?", time.Now()).Delete(&Session{})
+}
+
+const loginPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Portmonote – Login</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #0f172a; color: #e2e8f0; display: flex; align-items: center; justify-content: center; height: 100vh; margin: 0; }
+form { background: #1e293b; padding: 2rem; border-radius: 8px; width: 280px; }
+h1 { font-size: 1.2rem; margin-top: 0; }
+input { display: block; width: 100%%; box-sizing: border-box; margin: 0.5rem 0 1rem; padding: 0.5rem; border-radius: 4px; border: 1px solid #334155; background: #0f172a; color: inherit; }
+button { width: 100%%; padding: 0.6rem; border: 0; border-radius: 4px; background: #3b82f6; color: white; cursor: pointer; }
+.err { color: #f87171; }
+.sso { display: block; text-align: center; padding: 0.6rem; border-radius: 4px; background: #334155; color: white; text-decoration: none; }
+.or { text-align: center; color: #64748b; font-size: 0.85rem; }
+</style>
+</head>
+<body>
+<form method="post" action="/login">
+<h1>Portmonote</h1>
+%s
+<label>Username<input name="username" autocomplete="username" autofocus></label>
+<label>Password<input name="password" type="password" autocomplete="current-password"></label>
+<button type="submit">Log in</button>
+</form>
+</body>
+</html>
+`
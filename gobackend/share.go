@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Share links: a read-only, expiring view of one port (merged item plus
+// history) at /share/<token>, for people without dashboard access. Only
+// the token's hash is stored; sensitive descriptions and witr output are
+// never shown. Links last PORTMONOTE_SHARE_TTL (default 24h) unless the
+// request asks for less or more, up to PORTMONOTE_SHARE_MAX_TTL (30d).
+var (
+	shareTTL    = envDuration("PORTMONOTE_SHARE_TTL", 24*time.Hour)
+	shareMaxTTL = envDuration("PORTMONOTE_SHARE_MAX_TTL", 30*24*time.Hour)
+)
+
+const shareTokenPrefix = "pms_"
+
+type ShareLink struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	PortRuntimeID uint       `gorm:"index" json:"port_runtime_id"`
+	Prefix        string     `json:"prefix"`
+	TokenHash     string     `gorm:"uniqueIndex;size:64" json:"-"`
+	CreatedBy     string     `json:"created_by,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ExpiresAt     time.Time  `gorm:"index" json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	Views         int        `gorm:"default:0" json:"views"`
+	LastViewedAt  *time.Time `json:"last_viewed_at,omitempty"`
+}
+
+func (ShareLink) TableName() string {
+	return "share_link"
+}
+
+// POST /ports/:id/share {"ttl": "4h"} returns the link exactly once.
+func createShareLink(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var rt PortRuntime
+	if err := DB.First(&rt, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Runtime not found"})
+		return
+	}
+	var req struct {
+		TTL string `json:"ttl"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	ttl := shareTTL
+	if req.TTL != "" {
+		var err error
+		if ttl, err = parseWindow(req.TTL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if ttl > shareMaxTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ttl exceeds " + shareMaxTTL.String()})
+		return
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	raw := shareTokenPrefix + hex.EncodeToString(buf)
+	link := ShareLink{
+		PortRuntimeID: rt.ID,
+		Prefix:        raw[:len(shareTokenPrefix)+6],
+		TokenHash:     hashAPIKey(raw),
+		CreatedBy:     actorName(c),
+		ExpiresAt:     time.Now().Add(ttl),
+	}
+	if err := DB.Create(&link).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"token": raw, "url": "/share/" + raw, "share": link})
+}
+
+// GET /ports/:id/shares lists the links of a port, including expired ones.
+func listShareLinks(c *gin.Context) {
+	var links []ShareLink
+	DB.Where("port_runtime_id = ?", c.Param("id")).Order("created_at desc").Find(&links)
+	c.JSON(http.StatusOK, links)
+}
+
+// DELETE /shares/:id revokes a link before it expires.
+func revokeShareLink(c *gin.Context) {
+	res := DB.Model(&ShareLink{}).Where("id = ? AND revoked_at IS NULL", c.Param("id")).Update("revoked_at", time.Now())
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found or already revoked"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revoked": true})
+}
+
+type SharedPort struct {
+	Port      MergedPortItem `json:"port"`
+	History   []PortEvent    `json:"history"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
+
+// GET /share/:token (public). HTML unless ?format=json.
+func handleShare(c *gin.Context) {
+	token := c.Param("token")
+	now := time.Now()
+	var link ShareLink
+	if !strings.HasPrefix(token, shareTokenPrefix) ||
+		DB.Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", hashAPIKey(token), now).First(&link).Error != nil {
+		c.String(http.StatusNotFound, "This link is invalid or has expired")
+		return
+	}
+	var rt PortRuntime
+	if err := DB.First(&rt, link.PortRuntimeID).Error; err != nil {
+		c.String(http.StatusNotFound, "The shared port no longer exists")
+		return
+	}
+	DB.Model(&link).Updates(map[string]any{"views": link.Views + 1, "last_viewed_at": now})
+
+	var notes []PortNote
+	DB.Where("host_id = ? AND protocol = ? AND port = ?", rt.HostID, rt.Protocol, rt.Port).Find(&notes)
+	for i := range notes {
+		if notes[i].Sensitive {
+			notes[i].Description, notes[i].DescriptionRedacted = "", true
+		}
+	}
+	shared := SharedPort{ExpiresAt: link.ExpiresAt}
+	if items := mergePortItems([]PortRuntime{rt}, notes); len(items) > 0 {
+		shared.Port = items[0]
+	}
+	DB.Where("port_runtime_id = ?", rt.ID).Order("timestamp desc").Limit(200).Find(&shared.History)
+	for i := range shared.History {
+		shared.History[i].WitrOutput = ""
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Robots-Tag", "noindex")
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, shared)
+		return
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := shareTemplate.Execute(c.Writer, shared); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+	}
+}
+
+var shareTemplate = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Port.Protocol}}/{{.Port.Port}} on {{.Port.HostID}}</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #0f172a; color: #e2e8f0; max-width: 820px; margin: 2rem auto; padding: 0 1rem; }
+dl { display: grid; grid-template-columns: max-content 1fr; gap: 0.4rem 1rem; }
+dt { color: #64748b; } dd { margin: 0; white-space: pre-wrap; }
+table { width: 100%; border-collapse: collapse; font-size: 0.9rem; }
+th, td { text-align: left; padding: 0.5rem 0.4rem; border-bottom: 1px solid #1e293b; vertical-align: top; }
+th { color: #64748b; font-weight: normal; }
+footer { color: #64748b; font-size: 0.8rem; margin-top: 1.5rem; }
+</style>
+</head>
+<body>
+{{with .Port}}
+<h1>{{.Protocol}}/{{.Port}} on {{.HostID}}{{if .Title}} — {{.Title}}{{end}}</h1>
+<dl>
+<dt>Status</dt><dd>{{.DerivedStatus}} ({{.CurrentState}})</dd>
+<dt>Process</dt><dd>{{.ProcessName}}{{if .CurrentPID}} (pid {{.CurrentPID}}){{end}}</dd>
+{{if .Cmdline}}<dt>Command</dt><dd>{{.Cmdline}}</dd>{{end}}
+{{if .SystemdUnit}}<dt>Unit</dt><dd>{{.SystemdUnit}}</dd>{{end}}
+<dt>Owner</dt><dd>{{or .Owner "–"}}</dd>
+<dt>Risk</dt><dd>{{.RiskLevel}}</dd>
+{{if .Tags}}<dt>Tags</dt><dd>{{.Tags}}</dd>{{end}}
+<dt>Notes</dt><dd>{{if .DescriptionRedacted}}(sensitive, not shared){{else}}{{or .Description "–"}}{{end}}</dd>
+<dt>Uptime</dt><dd>{{.UptimeHuman}}</dd>
+</dl>
+{{end}}
+<h2>History</h2>
+<table>
+<tr><th>Time</th><th>Event</th><th>Process</th><th>Detail</th></tr>
+{{range .History}}<tr>
+<td>{{.Timestamp.Format "2006-01-02 15:04"}}</td><td>{{.EventType}}</td>
+<td>{{.ProcessName}}{{if .PID}} ({{.PID}}){{end}}</td><td>{{.Detail}}{{if .WitrTree}}{{.WitrTree}}{{end}}</td>
+</tr>{{else}}<tr><td colspan="4">No events</td></tr>{{end}}
+</table>
+<footer>Read-only link, expires {{.ExpiresAt.Format "2006-01-02 15:04 MST"}}</footer>
+</body>
+</html>
+`))
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Every successful collection cycle stores one scan_snapshot row with the
+// port counts of that moment, so GET /stats/timeline can draw trends such as
+// "suspicious ports over the last 90 days" without replaying events.
+// Snapshots older than PORTMONOTE_SNAPSHOT_KEEP (default 400d, 0 keeps
+// them forever) are deleted once an hour.
+var snapshotKeep = envDuration("PORTMONOTE_SNAPSHOT_KEEP", 400*24*time.Hour)
+
+type ScanSnapshot struct {
+	ID      uint      `gorm:"primaryKey" json:"-"`
+	TakenAt time.Time `gorm:"index" json:"taken_at"`
+
+	Total int `json:"total"` // listening ports, all hosts
+	TCP   int `json:"tcp"`
+	UDP   int `json:"udp"`
+
+	// Derived status of the listening ports (calculateStatus)
+	Healthy    int `json:"healthy"`
+	Suspicious int `json:"suspicious"`
+	Flapping   int `json:"flapping"`
+	Ghosts     int `json:"ghosts"` // disappeared but still tracked
+	Unnoted    int `json:"unnoted"`
+}
+
+func (ScanSnapshot) TableName() string {
+	return "scan_snapshot"
+}
+
+var lastSnapshotPrune time.Time
+
+// recordSnapshot counts the current runtimes after a cycle.
+func recordSnapshot(now time.Time) {
+	var runtimes []PortRuntime
+	var notes []PortNote
+	if err := DB.Find(&runtimes).Error; err != nil {
+		log.Println("Error loading runtimes for snapshot:", err)
+		return
+	}
+	DB.Find(&notes)
+
+	s := ScanSnapshot{TakenAt: now}
+	for _, item := range mergePortItems(runtimes, notes) {
+		if item.RuntimeID == 0 {
+			continue
+		}
+		if item.DerivedStatus == "ghost" {
+			s.Ghosts++
+			continue
+		}
+		if item.CurrentState != string(StateActive) {
+			continue
+		}
+		s.Total++
+		switch item.Protocol {
+		case string(TCP):
+			s.TCP++
+		case string(UDP):
+			s.UDP++
+		}
+		switch item.DerivedStatus {
+		case "healthy":
+			s.Healthy++
+		case "suspicious":
+			s.Suspicious++
+		case "flapping":
+			s.Flapping++
+		}
+		if item.NoteID == 0 {
+			s.Unnoted++
+		}
+	}
+	if err := DB.Create(&s).Error; err != nil {
+		log.Println("Error saving scan snapshot:", err)
+	}
+
+	if snapshotKeep > 0 && now.Sub(lastSnapshotPrune) >= time.Hour {
+		lastSnapshotPrune = now
+		DB.Where("taken_at < ?", now.Add(-snapshotKeep)).Delete(&ScanSnapshot{})
+	}
+}
+
+// GET /stats/timeline?window=90d&bucket=1d
+//
+// Returns the snapshots of the window, oldest first. With a bucket, only the
+// last snapshot of each bucket is returned (picked in SQL); without one it is
+// picked from the window (raw up to 2 days, hourly up to 31 days, daily
+// beyond).
+func getStatsTimeline(c *gin.Context) {
+	window, err := parseWindow(c.DefaultQuery("window", "7d"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var bucket time.Duration
+	switch b := c.Query("bucket"); {
+	case b == "raw":
+	case b != "":
+		if bucket, err = parseWindow(b); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	case window > 31*24*time.Hour:
+		bucket = 24 * time.Hour
+	case window > 2*24*time.Hour:
+		bucket = time.Hour
+	}
+
+	now := time.Now()
+	query := DB.Where("taken_at >= ?", now.Add(-window))
+	if bucket > 0 {
+		// Snapshots are written in order, so the highest id is the latest
+		latest := DB.Model(&ScanSnapshot{}).
+			Select("MAX(id)").
+			Where("taken_at >= ?", now.Add(-window)).
+			Group(snapshotBucket(bucket))
+		query = DB.Where("id IN (?)", latest)
+	}
+	var snapshots []ScanSnapshot
+	if err := query.Order("taken_at").Find(&snapshots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"window":    window.String(),
+		"bucket":    bucket.String(),
+		"snapshots": snapshots,
+	})
+}
+
+// snapshotBucket is the SQL numbering the bucket a snapshot falls in:
+// whole bucket lengths since the Unix epoch.
+func snapshotBucket(bucket time.Duration) string {
+	secs := strconv.FormatInt(max(int64(bucket/time.Second), 1), 10)
+	switch DB.Dialector.Name() {
+	case "postgres":
+		return "FLOOR(EXTRACT(EPOCH FROM taken_at) / " + secs + ")"
+	case "mysql":
+		return "FLOOR(UNIX_TIMESTAMP(taken_at) / " + secs + ")"
+	}
+	return "CAST(strftime('%s', taken_at) AS INTEGER) / " + secs
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// Agentless inventory for switches, printers and appliances: every
+// collection cycle polls the SNMP agents in PORTMONOTE_SNMP_TARGETS and maps
+// their listening TCP and UDP ports into the same runtime table, with the
+// target name as host ID.
+//
+//	PORTMONOTE_SNMP_TARGETS=core-sw=10.0.0.2,nas=10.0.0.5:1161,10.0.0.9
+//	PORTMONOTE_SNMP_COMMUNITY=public
+//	PORTMONOTE_SNMP_VERSION=2c        (or 1)
+//
+// Both the RFC 4022/4113 tables (tcpListenerTable, tcpConnectionTable,
+// udpEndpointTable) and the legacy tcpConnTable/udpTable are read, since
+// older firmware often only has the latter. A target that doesn't answer is
+// skipped for the cycle; its ports are not marked as disappeared.
+var (
+	snmpTargets   = parseSNMPTargets(envString("PORTMONOTE_SNMP_TARGETS", ""))
+	snmpCommunity = envString("PORTMONOTE_SNMP_COMMUNITY", "public")
+	snmpVersion   = envString("PORTMONOTE_SNMP_VERSION", "2c")
+)
+
+type snmpTarget struct {
+	Name string
+	Host string
+	Port uint16
+}
+
+func parseSNMPTargets(s string) []snmpTarget {
+	var targets []snmpTarget
+	for _, item := range splitList(s) {
+		name, addr, ok := strings.Cut(item, "=")
+		if !ok {
+			addr = name
+		}
+		t := snmpTarget{Name: name, Host: addr, Port: 161}
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			p, err := strconv.ParseUint(port, 10, 16)
+			if err != nil {
+				log.Printf("⚠️ Ignoring SNMP target %q: bad port", item)
+				continue
+			}
+			t.Host, t.Port = host, uint16(p)
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+const (
+	oidTCPListenerProcess  = ".1.3.6.1.2.1.6.20.1.4"
+	oidTCPConnectionState  = ".1.3.6.1.2.1.6.19.1.7"
+	oidTCPConnectionProc   = ".1.3.6.1.2.1.6.19.1.8"
+	oidTCPConnState        = ".1.3.6.1.2.1.6.13.1.1" // legacy tcpConnTable
+	oidUDPEndpointProcess  = ".1.3.6.1.2.1.7.7.1.8"
+	oidUDPLocalPort        = ".1.3.6.1.2.1.7.5.1.2" // legacy udpTable
+	oidHrSWRunName         = ".1.3.6.1.2.1.25.4.2.1.2"
+	snmpTCPStateListen     = 2
+	snmpProcessNameBatchSz = 20
+)
+
+// pollSNMPTargets returns the listeners of all reachable targets and the
+// host IDs that answered.
+func pollSNMPTargets() (map[PortKey]ScanResult, []string) {
+	results := make(map[PortKey]ScanResult)
+	var polled []string
+	for _, t := range snmpTargets {
+		ports, err := pollSNMP(t)
+		if err != nil {
+			log.Printf("SNMP poll of %s (%s) failed: %v", t.Name, t.Host, err)
+			continue
+		}
+		polled = append(polled, t.Name)
+		for k, v := range ports {
+			results[k] = v
+		}
+	}
+	return results, polled
+}
+
+func pollSNMP(t snmpTarget) (map[PortKey]ScanResult, error) {
+	g := &gosnmp.GoSNMP{
+		Target:    t.Host,
+		Port:      t.Port,
+		Community: snmpCommunity,
+		Version:   gosnmp.Version2c,
+		Timeout:   5 * time.Second,
+		Retries:   1,
+		MaxOids:   gosnmp.MaxOids,
+	}
+	if snmpVersion == "1" {
+		g.Version = gosnmp.Version1
+	}
+	if err := g.Connect(); err != nil {
+		return nil, err
+	}
+	defer g.Conn.Close()
+
+	walk := func(oid string) ([]gosnmp.SnmpPDU, error) {
+		if g.Version == gosnmp.Version1 {
+			return g.WalkAll(oid)
+		}
+		return g.BulkWalkAll(oid)
+	}
+
+	found := make(map[PortKey]int) // port -> PID (hrSWRunIndex), 0 if unknown
+	add := func(proto string, port, pid int) {
+		if port <= 0 {
+			return
+		}
+		key := PortKey{HostID: t.Name, Protocol: proto, Port: port}
+		if found[key] == 0 {
+			found[key] = pid
+		}
+	}
+
+	// The first walk doubles as the reachability check
+	pdus, err := walk(oidTCPListenerProcess)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pdus {
+		if idx := inetIndex(p.Name, oidTCPListenerProcess); len(idx) >= 1 {
+			add("tcp", idx[0], snmpInt(p))
+		}
+	}
+
+	if pdus, err := walk(oidTCPConnectionState); err == nil {
+		for _, p := range pdus {
+			if snmpInt(p) != snmpTCPStateListen {
+				continue
+			}
+			if idx := inetIndex(p.Name, oidTCPConnectionState); len(idx) >= 1 {
+				add("tcp", idx[0], 0)
+			}
+		}
+	}
+
+	if pdus, err := walk(oidTCPConnState); err == nil {
+		for _, p := range pdus {
+			if snmpInt(p) != snmpTCPStateListen {
+				continue
+			}
+			// index: localAddr(4).localPort.remAddr(4).remPort
+			if parts := oidSuffix(p.Name, oidTCPConnState); len(parts) == 10 {
+				add("tcp", parts[4], 0)
+			}
+		}
+	}
+
+	if pdus, err := walk(oidUDPEndpointProcess); err == nil {
+		for _, p := range pdus {
+			if idx := inetIndex(p.Name, oidUDPEndpointProcess); len(idx) >= 1 {
+				add("udp", idx[0], snmpInt(p))
+			}
+		}
+	}
+
+	if pdus, err := walk(oidUDPLocalPort); err == nil {
+		for _, p := range pdus {
+			add("udp", snmpInt(p), 0)
+		}
+	}
+
+	names := snmpProcessNames(g, found)
+	results := make(map[PortKey]ScanResult, len(found))
+	for key, pid := range found {
+		results[key] = ScanResult{PID: pid, ProcessName: names[pid], State: "LISTEN"}
+	}
+	return results, nil
+}
+
+// snmpProcessNames looks up hrSWRunName for the PIDs the agent reported.
+func snmpProcessNames(g *gosnmp.GoSNMP, found map[PortKey]int) map[int]string {
+	var oids []string
+	seen := map[int]bool{}
+	for _, pid := range found {
+		if pid > 0 && !seen[pid] {
+			seen[pid] = true
+			oids = append(oids, fmt.Sprintf("%s.%d", oidHrSWRunName, pid))
+		}
+	}
+
+	names := map[int]string{}
+	for start := 0; start < len(oids); start += snmpProcessNameBatchSz {
+		pkt, err := g.Get(oids[start:min(start+snmpProcessNameBatchSz, len(oids))])
+		if err != nil {
+			return names
+		}
+		for _, v := range pkt.Variables {
+			b, ok := v.Value.([]byte)
+			parts := oidSuffix(v.Name, oidHrSWRunName)
+			if ok && len(parts) == 1 {
+				names[parts[0]] = string(b)
+			}
+		}
+	}
+	return names
+}
+
+// oidSuffix returns the numeric index components after a column OID.
+func oidSuffix(name, column string) []int {
+	rest, ok := strings.CutPrefix(name, column+".")
+	if !ok {
+		return nil
+	}
+	var parts []int
+	for _, s := range strings.Split(rest, ".") {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// inetIndex decodes a table index that starts with InetAddressType,
+// length-prefixed InetAddress and a port, returning the port followed by any
+// remaining components.
+func inetIndex(name, column string) []int {
+	parts := oidSuffix(name, column)
+	if len(parts) < 3 {
+		return nil
+	}
+	addrLen := parts[1]
+	if len(parts) < 2+addrLen+1 {
+		return nil
+	}
+	return parts[2+addrLen:]
+}
+
+func snmpInt(p gosnmp.SnmpPDU) int {
+	return int(gosnmp.ToBigInt(p.Value).Int64())
+}
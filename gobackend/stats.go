@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatsSummary is the dashboard header in one request. It mirrors the
+// derived statuses of GET /ports (calculateStatus) but is computed with
+// grouped queries instead of merging every port.
+type StatsSummary struct {
+	Total       int            `json:"total"` // tracked runtimes
+	ByStatus    map[string]int `json:"by_status"`
+	ByRisk      map[string]int `json:"by_risk"` // notes by risk level, "unnoted" for listeners without one
+	ByProtocol  map[string]int `json:"by_protocol"`
+	New24h      int            `json:"new_24h"`
+	Newest      []NewListener  `json:"newest"`
+	TopOwners   []OwnerCount   `json:"top_owners"`
+	GeneratedAt time.Time      `json:"generated_at"`
+}
+
+type NewListener struct {
+	RuntimeID   uint      `json:"runtime_id"`
+	HostID      string    `json:"host_id"`
+	Protocol    string    `json:"protocol"`
+	Port        int       `json:"port"`
+	ProcessName string    `json:"process_name"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+}
+
+type OwnerCount struct {
+	Owner string `json:"owner"`
+	Count int    `json:"count"`
+}
+
+const noteJoin = "LEFT JOIN port_note ON port_note.host_id = port_runtime.host_id AND port_note.protocol = port_runtime.protocol AND port_note.port = port_runtime.port AND port_note.deleted_at IS NULL"
+
+// GET /stats/summary
+func getStatsSummary(c *gin.Context) {
+	now := time.Now()
+	s := StatsSummary{
+		ByStatus:    map[string]int{"healthy": 0, "suspicious": 0, "flapping": 0, "ghost": 0, StatusNeedsReview: 0},
+		ByRisk:      map[string]int{},
+		ByProtocol:  map[string]int{},
+		Newest:      []NewListener{},
+		TopOwners:   []OwnerCount{},
+		GeneratedAt: now,
+	}
+
+	// Runtimes by state, protocol and note risk
+	var groups []struct {
+		CurrentState string
+		Protocol     string
+		Risk         string
+		Count        int
+	}
+	err := DB.Model(&PortRuntime{}).
+		Select("port_runtime.current_state, port_runtime.protocol, CASE WHEN port_note.id IS NULL OR port_note.auto_generated THEN 'unnoted' ELSE port_note.risk_level END AS risk, COUNT(*) AS count").
+		Joins(noteJoin).
+		Group("port_runtime.current_state, port_runtime.protocol, risk").
+		Scan(&groups).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, g := range groups {
+		s.Total += g.Count
+		s.ByProtocol[g.Protocol] += g.Count
+		s.ByStatus[summaryStatus(g.CurrentState, g.Risk)] += g.Count
+		if g.Risk == "unnoted" && g.CurrentState == string(StateActive) {
+			s.ByRisk["unnoted"] += g.Count
+		}
+	}
+
+	// Flapping needs the transition times, but only a few runtimes qualify
+	if flapThreshold > 0 {
+		var candidates []struct {
+			PortRuntime
+			Risk string
+		}
+		DB.Model(&PortRuntime{}).
+			Select("port_runtime.*, CASE WHEN port_note.id IS NULL OR port_note.auto_generated THEN 'unnoted' ELSE port_note.risk_level END AS risk").
+			Joins(noteJoin).
+			Where("port_runtime.flap_count >= ?", flapThreshold).
+			Scan(&candidates)
+		for _, rt := range candidates {
+			status := summaryStatus(rt.CurrentState, rt.Risk)
+			if status != "suspicious" && isFlapping(currentFlapCount(&rt.PortRuntime, now)) {
+				s.ByStatus[status]--
+				s.ByStatus["flapping"]++
+			}
+		}
+	}
+
+	// Healthy ports past their review date (review.go)
+	var overdue []PortRuntime
+	DB.Model(&PortRuntime{}).
+		Select("port_runtime.*").
+		Joins(noteJoin).
+		Where("port_runtime.current_state = ? AND port_note.review_after <= ? AND NOT port_note.auto_generated AND port_note.risk_level <> ?", StateActive, now, RiskSuspicious).
+		Scan(&overdue)
+	for i := range overdue {
+		if !isFlapping(currentFlapCount(&overdue[i], now)) {
+			s.ByStatus["healthy"]--
+			s.ByStatus[StatusNeedsReview]++
+		}
+	}
+
+	// Muted ports aren't suspicious while the mute runs (mute.go)
+	for _, m := range activeMutes(now) {
+		var rt struct {
+			CurrentState string
+			Risk         string
+		}
+		err := DB.Model(&PortRuntime{}).
+			Select("port_runtime.current_state, CASE WHEN port_note.id IS NULL OR port_note.auto_generated THEN 'unnoted' ELSE port_note.risk_level END AS risk").
+			Joins(noteJoin).
+			Where("port_runtime.host_id = ? AND port_runtime.protocol = ? AND port_runtime.port = ?", m.HostID, m.Protocol, m.Port).
+			Take(&rt).Error
+		if err == nil && summaryStatus(rt.CurrentState, rt.Risk) == "suspicious" {
+			s.ByStatus["suspicious"]--
+			s.ByStatus["muted"]++
+		}
+	}
+
+	var risks []struct {
+		RiskLevel string
+		Count     int
+	}
+	DB.Model(&PortNote{}).Select("risk_level, COUNT(*) AS count").Group("risk_level").Scan(&risks)
+	for _, r := range risks {
+		s.ByRisk[r.RiskLevel] += r.Count
+	}
+
+	since := now.Add(-24 * time.Hour)
+	var newCount int64
+	DB.Model(&PortRuntime{}).Where("first_seen_at >= ?", since).Count(&newCount)
+	s.New24h = int(newCount)
+	DB.Model(&PortRuntime{}).
+		Select("id AS runtime_id, host_id, protocol, port, process_name, first_seen_at").
+		Where("first_seen_at >= ?", since).
+		Order("first_seen_at desc").Limit(10).
+		Scan(&s.Newest)
+
+	DB.Model(&PortNote{}).
+		Select("owner, COUNT(*) AS count").
+		Where("owner <> ''").
+		Group("owner").Order("count desc, owner").Limit(10).
+		Scan(&s.TopOwners)
+
+	c.JSON(http.StatusOK, s)
+}
+
+// summaryStatus is calculateStatus without the flapping check.
+func summaryStatus(state, risk string) string {
+	switch {
+	case state == string(StateDisappeared):
+		return "ghost"
+	case state != string(StateActive):
+		return "active"
+	case risk == "unnoted" || risk == string(RiskSuspicious):
+		return "suspicious"
+	default:
+		return "healthy"
+	}
+}
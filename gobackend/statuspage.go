@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Public, read-only status page for noted services carrying one of the
+// configured tags. Off unless PORTMONOTE_STATUS_PAGE_TAGS is set; the first
+// tag is the default, others are reachable via /status-page?tag=NAME.
+// Only titles and availability are shown, never hosts, ports, processes or
+// command lines.
+var (
+	statusPageTags  = splitList(envString("PORTMONOTE_STATUS_PAGE_TAGS", ""))
+	statusPageTitle = envString("PORTMONOTE_STATUS_PAGE_TITLE", "Service Status")
+)
+
+type StatusService struct {
+	Name      string   `json:"name"`
+	Up        bool     `json:"up"`
+	Uptime24h *float64 `json:"uptime_24h"`
+	Uptime7d  *float64 `json:"uptime_7d"`
+	Uptime30d *float64 `json:"uptime_30d"`
+}
+
+type StatusPage struct {
+	Title     string          `json:"title"`
+	Tag       string          `json:"tag"`
+	AllUp     bool            `json:"all_up"`
+	Services  []StatusService `json:"services"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+func buildStatusPage(tag string, now time.Time) StatusPage {
+	page := StatusPage{Title: statusPageTitle, Tag: tag, AllUp: true, UpdatedAt: now}
+
+	for _, n := range notesTagged(tag) {
+		svc := StatusService{Name: n.Title}
+		if svc.Name == "" {
+			svc.Name = "Unnamed service"
+		}
+
+		var rt PortRuntime
+		if err := DB.Where("host_id = ? AND protocol = ? AND port = ?", n.HostID, n.Protocol, n.Port).First(&rt).Error; err == nil {
+			svc.Up = rt.CurrentState == string(StateActive)
+			svc.Uptime24h = availabilityPtr(&rt, now.Add(-24*time.Hour), now)
+			svc.Uptime7d = availabilityPtr(&rt, now.AddDate(0, 0, -7), now)
+			svc.Uptime30d = availabilityPtr(&rt, now.AddDate(0, 0, -30), now)
+		}
+		page.AllUp = page.AllUp && svc.Up
+		page.Services = append(page.Services, svc)
+	}
+	sort.Slice(page.Services, func(i, j int) bool { return page.Services[i].Name < page.Services[j].Name })
+	return page
+}
+
+func availabilityPtr(rt *PortRuntime, since, now time.Time) *float64 {
+	if pct, ok := availability(rt, since, now); ok {
+		return &pct
+	}
+	return nil
+}
+
+// GET /status-page (HTML) or /status-page?format=json
+func handleStatusPage(c *gin.Context) {
+	if len(statusPageTags) == 0 {
+		c.String(http.StatusNotFound, "Status page is not enabled")
+		return
+	}
+	tag := c.DefaultQuery("tag", statusPageTags[0])
+	if !slices.Contains(statusPageTags, tag) {
+		c.String(http.StatusNotFound, "Unknown status page")
+		return
+	}
+
+	page := buildStatusPage(tag, time.Now())
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, page)
+		return
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := statusPageTemplate.Execute(c.Writer, page); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+	}
+}
+
+var statusPageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"pct": func(p *float64) string {
+		if p == nil {
+			return "–"
+		}
+		return fmt.Sprintf("%.2f%%", *p)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="60">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #0f172a; color: #e2e8f0; max-width: 720px; margin: 2rem auto; padding: 0 1rem; }
+.banner { padding: 1rem; border-radius: 8px; font-weight: 600; margin-bottom: 1.5rem; }
+.ok { background: #14532d; } .bad { background: #7f1d1d; }
+table { width: 100%; border-collapse: collapse; }
+th, td { text-align: left; padding: 0.6rem 0.4rem; border-bottom: 1px solid #1e293b; }
+th { color: #64748b; font-weight: normal; font-size: 0.85rem; }
+.up { color: #4ade80; } .down { color: #f87171; }
+footer { color: #64748b; font-size: 0.8rem; margin-top: 1.5rem; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .AllUp}}<div class="banner ok">All systems operational</div>{{else}}<div class="banner bad">Some services are down</div>{{end}}
+<table>
+<tr><th>Service</th><th>Status</th><th>24h</th><th>7d</th><th>30d</th></tr>
+{{range .Services}}<tr>
+<td>{{.Name}}</td>
+<td>{{if .Up}}<span class="up">● Up</span>{{else}}<span class="down">● Down</span>{{end}}</td>
+<td>{{pct .Uptime24h}}</td><td>{{pct .Uptime7d}}</td><td>{{pct .Uptime30d}}</td>
+</tr>{{else}}<tr><td colspan="5">No services</td></tr>{{end}}
+</table>
+<footer>Updated {{.UpdatedAt.Format "2006-01-02 15:04:05 MST"}}</footer>
+</body>
+</html>
+`))
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// systemdUnit reads /proc/PID/cgroup and returns the systemd unit and slice
+// the process belongs to, e.g. ("nginx.service", "system.slice"). Both are
+// empty off Linux or for processes outside systemd's tree.
+func systemdUnit(pid int) (unit, slice string) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// cgroup v2: "0::/path"; v1: "1:name=systemd:/path"
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 || (parts[1] != "" && parts[1] != "name=systemd") {
+			continue
+		}
+		for _, elem := range strings.Split(parts[2], "/") {
+			switch {
+			case strings.HasSuffix(elem, ".slice"):
+				slice = elem
+			case strings.HasSuffix(elem, ".service"), strings.HasSuffix(elem, ".scope"), strings.HasSuffix(elem, ".socket"), strings.HasSuffix(elem, ".mount"):
+				unit = elem
+			}
+		}
+		if unit != "" || slice != "" {
+			return unit, slice
+		}
+	}
+	return "", ""
+}
+
+type UnitPort struct {
+	HostID       string `json:"host_id"`
+	Protocol     string `json:"protocol"`
+	Port         int    `json:"port"`
+	ProcessName  string `json:"process_name"`
+	CurrentState string `json:"current_state"`
+}
+
+type UnitGroup struct {
+	HostID string     `json:"host_id"`
+	Unit   string     `json:"unit"`
+	Slice  string     `json:"slice"`
+	Ports  []UnitPort `json:"ports"`
+}
+
+// GET /units groups listening ports by systemd unit. ?all=true also lists
+// ports that have since disappeared.
+func getUnits(c *gin.Context) {
+	q := DB.Where("systemd_unit <> ''")
+	if c.Query("all") != "true" {
+		q = q.Where("current_state = ?", StateActive)
+	}
+	var runtimes []PortRuntime
+	q.Order("host_id, protocol, port").Find(&runtimes)
+
+	groups := map[string]*UnitGroup{}
+	for _, r := range runtimes {
+		key := r.HostID + "|" + r.SystemdUnit
+		g, ok := groups[key]
+		if !ok {
+			g = &UnitGroup{HostID: r.HostID, Unit: r.SystemdUnit, Slice: r.SystemdSlice}
+			groups[key] = g
+		}
+		g.Ports = append(g.Ports, UnitPort{
+			HostID: r.HostID, Protocol: r.Protocol, Port: r.Port,
+			ProcessName: r.ProcessName, CurrentState: r.CurrentState,
+		})
+	}
+
+	result := make([]UnitGroup, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].HostID != result[j].HostID {
+			return result[i].HostID < result[j].HostID
+		}
+		return result[i].Unit < result[j].Unit
+	})
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Tags group ports by service, team or environment. Each tag is a row of
+// its own, linked to notes through port_note_tag; the note's comma-separated
+// tags field stays the way clients read and write them and is kept in sync.
+//
+//	GET  /tags                 all tags with their port counts
+//	POST /tags                 {"name": "database", "color": "#3b82f6"}
+//	DELETE /tags/:id           removes the tag from every note
+//	GET  /ports?tag=database   ports carrying the tag
+type Tag struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"uniqueIndex;size:64" json:"name"`
+	Color       string    `json:"color,omitempty"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	PortCount int `gorm:"-" json:"port_count"`
+}
+
+func (Tag) TableName() string {
+	return "tag"
+}
+
+// syncNoteTags links the note to the tags named in note.Tags, creating
+// missing ones. Call after the note is saved.
+func syncNoteTags(tx *gorm.DB, note *PortNote) error {
+	names := splitList(note.Tags)
+	tags := make([]Tag, 0, len(names))
+	for _, name := range names {
+		t := Tag{Name: name}
+		if err := tx.Where("name = ?", name).FirstOrCreate(&t).Error; err != nil {
+			return err
+		}
+		tags = append(tags, t)
+	}
+	return tx.Model(note).Association("TagRefs").Replace(tags)
+}
+
+// backfillTags links notes written without going through the API (older
+// versions, imports). Runs at startup.
+func backfillTags() {
+	var notes []PortNote
+	DB.Where("tags <> '' AND id NOT IN (?)", DB.Table("port_note_tag").Select("port_note_id")).Find(&notes)
+	for i := range notes {
+		if err := syncNoteTags(DB, &notes[i]); err != nil {
+			log.Println("Error linking note tags:", err)
+			return
+		}
+	}
+	if len(notes) > 0 {
+		log.Printf("🏷️ Linked the tags of %d note(s)", len(notes))
+	}
+}
+
+// notesTagged returns the notes carrying the tag.
+func notesTagged(name string) []PortNote {
+	var notes []PortNote
+	DB.Joins("JOIN port_note_tag ON port_note_tag.port_note_id = port_note.id").
+		Joins("JOIN tag ON tag.id = port_note_tag.tag_id").
+		Where("tag.name = ?", name).
+		Find(&notes)
+	return notes
+}
+
+// GET /tags
+func listTags(c *gin.Context) {
+	var tags []Tag
+	DB.Order("name").Find(&tags)
+	var counts []struct {
+		TagID uint
+		Count int
+	}
+	DB.Table("port_note_tag").
+		Select("port_note_tag.tag_id, COUNT(*) AS count").
+		Joins("JOIN port_note ON port_note.id = port_note_tag.port_note_id AND port_note.deleted_at IS NULL").
+		Group("port_note_tag.tag_id").
+		Scan(&counts)
+	for i := range tags {
+		for _, n := range counts {
+			if n.TagID == tags[i].ID {
+				tags[i].PortCount = n.Count
+			}
+		}
+	}
+	if tags == nil {
+		tags = []Tag{}
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+// POST /tags creates a tag, or updates color and description of an
+// existing one with the same name.
+func createTag(c *gin.Context) {
+	var req Tag
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || strings.Contains(req.Name, ",") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required and can't contain commas"})
+		return
+	}
+	var t Tag
+	err := DB.Where("name = ?", req.Name).First(&t).Error
+	status := http.StatusOK
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		t, status = Tag{Name: req.Name}, http.StatusCreated
+	}
+	t.Color, t.Description = req.Color, req.Description
+	if err := DB.Save(&t).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(status, t)
+}
+
+// DELETE /tags/:id
+func deleteTag(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var t Tag
+	if err := DB.First(&t, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+	notes := notesTagged(t.Name)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for i := range notes {
+			tags := slices.DeleteFunc(splitList(notes[i].Tags), func(s string) bool { return s == t.Name })
+			if err := tx.Model(&notes[i]).Updates(map[string]any{"tags": strings.Join(tags, ","), "version": gorm.Expr("version + 1")}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("DELETE FROM port_note_tag WHERE tag_id = ?", t.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&t).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": true, "notes_updated": len(notes)})
+}
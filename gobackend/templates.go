@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Note templates pre-fill the note of a port appearing for the first time:
+// "nginx appears" -> a web server note with owner and risk already set.
+// A template matches like a policy rule (process glob, protocol, ports,
+// host glob; empty matches everything) and the most specific match wins:
+// process and ports, then process, then ports. Unlike auto-notes
+// (iana.go), a template is someone's decision, so the note counts as
+// reviewed.
+//
+// A set of common services is created on first start; edit or delete them
+// like any other template.
+//
+//	GET  /note-templates
+//	POST /note-templates       {"name": "nginx", "process": "nginx", "title": "Web server", "risk_level": "expected"}
+//	PUT  /note-templates/:id
+//	DELETE /note-templates/:id
+type NoteTemplate struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	Name     string `gorm:"uniqueIndex;size:128" json:"name"`
+	Host     string `json:"host,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	Ports    string `json:"ports,omitempty"`   // "80,443,8000-8100"
+	Process  string `json:"process,omitempty"` // process name glob
+
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Owner       string `json:"owner,omitempty"`
+	RiskLevel   string `gorm:"default:expected" json:"risk_level"`
+	Tags        string `json:"tags,omitempty"`
+
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	rule PolicyRule
+}
+
+func (NoteTemplate) TableName() string {
+	return "note_template"
+}
+
+var defaultNoteTemplates = []NoteTemplate{
+	{Name: "nginx", Process: "nginx", Title: "Web server (nginx)", Tags: "web"},
+	{Name: "apache", Process: "apache2", Title: "Web server (Apache)", Tags: "web"},
+	{Name: "httpd", Process: "httpd", Title: "Web server (Apache)", Tags: "web"},
+	{Name: "caddy", Process: "caddy", Title: "Web server (Caddy)", Tags: "web"},
+	{Name: "haproxy", Process: "haproxy", Title: "Load balancer (HAProxy)", Tags: "web"},
+	{Name: "sshd", Process: "sshd", Protocol: "tcp", Title: "SSH server", Tags: "remote-access"},
+	{Name: "postgres", Process: "postgres*", Title: "PostgreSQL database", Tags: "database"},
+	{Name: "mysql", Process: "mysqld", Title: "MySQL database", Tags: "database"},
+	{Name: "mariadb", Process: "mariadbd", Title: "MariaDB database", Tags: "database"},
+	{Name: "redis", Process: "redis-server", Title: "Redis", Tags: "database,cache"},
+	{Name: "mongodb", Process: "mongod", Title: "MongoDB database", Tags: "database"},
+	{Name: "docker", Process: "docker*", Title: "Docker", Tags: "containers"},
+	{Name: "dns", Ports: "53", Title: "DNS resolver", Tags: "network"},
+	{Name: "chrony", Process: "chronyd", Title: "Time sync (NTP)", Tags: "network"},
+	{Name: "cups", Process: "cupsd", Title: "Printing (CUPS)", Tags: "desktop"},
+	{Name: "mail", Ports: "25,465,587", Title: "Mail server", Tags: "mail"},
+}
+
+func (t *NoteTemplate) compile() error {
+	p := Policy{Forbid: []PolicyRule{{Name: t.Name, Host: t.Host, Protocol: t.Protocol, Ports: t.Ports, Process: t.Process}}}
+	if err := p.compile(); err != nil {
+		return err
+	}
+	t.rule = p.Forbid[0]
+	return nil
+}
+
+// specificity ranks matching templates.
+func (t *NoteTemplate) specificity() int {
+	n := 0
+	if t.Process != "" {
+		n += 2
+	}
+	if t.Ports != "" {
+		n++
+	}
+	return n
+}
+
+// seedNoteTemplates creates the default templates once per database.
+func seedNoteTemplates() {
+	var seeded int64
+	if DB.Model(&AppSetting{}).Where("key = ?", "note_templates_seeded").Count(&seeded); seeded > 0 {
+		return
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, t := range defaultNoteTemplates {
+			t.RiskLevel, t.CreatedBy = string(RiskExpected), "default"
+			if err := tx.Where("name = ?", t.Name).FirstOrCreate(&t).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(&AppSetting{Key: "note_templates_seeded", Value: "true"}).Error
+	})
+	if err != nil {
+		log.Println("Error creating default note templates:", err)
+	}
+}
+
+// templateFor returns the best template for the runtime, or nil.
+func templateFor(templates []NoteTemplate, rt *PortRuntime) *NoteTemplate {
+	item := MergedPortItem{HostID: rt.HostID, Protocol: rt.Protocol, Port: rt.Port, ProcessName: rt.ProcessName}
+	var best *NoteTemplate
+	for i := range templates {
+		t := &templates[i]
+		if t.rule.Matches(&item) && (best == nil || t.specificity() > best.specificity()) {
+			best = t
+		}
+	}
+	return best
+}
+
+// applyNoteTemplates writes template notes for the ports that appeared for
+// the first time in a cycle. Runs before auto-notes, which then skip them.
+func applyNoteTemplates(b *eventBatch) {
+	if !slices.ContainsFunc(b.events, func(e PortEvent) bool { return e.EventType == string(EventAppeared) }) {
+		return
+	}
+	var stored []NoteTemplate
+	DB.Order("id").Find(&stored)
+	var templates []NoteTemplate
+	for _, t := range stored {
+		if t.compile() == nil {
+			templates = append(templates, t)
+		}
+	}
+	if len(templates) == 0 {
+		return
+	}
+	for i, evt := range b.events {
+		if evt.EventType != string(EventAppeared) {
+			continue
+		}
+		rt := b.runtimes[i]
+		t := templateFor(templates, &rt)
+		if t == nil {
+			continue
+		}
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			var n int64
+			tx.Unscoped().Model(&PortNote{}).Where("host_id = ? AND protocol = ? AND port = ?", rt.HostID, rt.Protocol, rt.Port).Count(&n)
+			if n > 0 {
+				return nil
+			}
+			note := PortNote{
+				HostID: rt.HostID, Protocol: rt.Protocol, Port: rt.Port,
+				Title: t.Title, Description: t.Description, Owner: t.Owner, RiskLevel: t.RiskLevel,
+				Tags: t.Tags, UpdatedBy: "template:" + t.Name,
+			}
+			if err := saveNote(tx, &note); err != nil {
+				return err
+			}
+			return syncNoteTags(tx, &note)
+		})
+		if err != nil {
+			log.Printf("Error applying note template %s to %s/%d: %v", t.Name, rt.Protocol, rt.Port, err)
+			continue
+		}
+		log.Printf("📝 Note template %s applied to %s/%d (%s)", t.Name, rt.Protocol, rt.Port, rt.ProcessName)
+	}
+}
+
+// GET /note-templates
+func listNoteTemplates(c *gin.Context) {
+	var templates []NoteTemplate
+	DB.Order("name").Find(&templates)
+	if templates == nil {
+		templates = []NoteTemplate{}
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+// POST /note-templates
+func createNoteTemplate(c *gin.Context) {
+	var t NoteTemplate
+	if err := c.BindJSON(&t); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t.ID, t.CreatedBy = 0, actorName(c)
+	saveNoteTemplate(c, &t, http.StatusCreated)
+}
+
+// PUT /note-templates/:id replaces a template.
+func updateNoteTemplate(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var existing NoteTemplate
+	if err := DB.First(&existing, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note template not found"})
+		return
+	}
+	var t NoteTemplate
+	if err := c.BindJSON(&t); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t.ID, t.CreatedBy, t.CreatedAt = existing.ID, existing.CreatedBy, existing.CreatedAt
+	saveNoteTemplate(c, &t, http.StatusOK)
+}
+
+func saveNoteTemplate(c *gin.Context, t *NoteTemplate, status int) {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" || t.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and title are required"})
+		return
+	}
+	if t.Process == "" && t.Ports == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "set process, ports or both"})
+		return
+	}
+	if t.RiskLevel == "" {
+		t.RiskLevel = string(RiskExpected)
+	}
+	if !slices.Contains([]RiskLevel{RiskTrusted, RiskExpected, RiskSuspicious}, RiskLevel(t.RiskLevel)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "risk_level must be trusted, expected or suspicious"})
+		return
+	}
+	if err := t.compile(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t.Tags = strings.Join(splitList(t.Tags), ",")
+	var n int64
+	DB.Model(&NoteTemplate{}).Where("name = ? AND id <> ?", t.Name, t.ID).Count(&n)
+	if n > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A template with this name exists"})
+		return
+	}
+	if err := DB.Save(t).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(status, t)
+}
+
+// DELETE /note-templates/:id
+func deleteNoteTemplate(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	res := DB.Delete(&NoteTemplate{}, id)
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = gorm.ErrRecordNotFound
+	}
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note template not found"})
+		return
+	}
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tickets: a port that stays suspicious (no note, or noted as suspicious)
+// for longer than PORTMONOTE_TICKET_AFTER gets an issue in the team's
+// tracker, with the process details and a link to the port. The time
+// counts from the start of the current up-interval, so a port that keeps
+// stopping and starting doesn't file an issue each time it comes back. Once someone
+// fills in the note, or the port stops listening, the issue is closed
+// with a comment saying why. Links need dashboard_url in the notification
+// config.
+//
+//	PORTMONOTE_TICKETS=github|jira
+//	PORTMONOTE_TICKET_AFTER=24h
+//
+//	PORTMONOTE_GITHUB_REPO=owner/repo, PORTMONOTE_GITHUB_TOKEN,
+//	PORTMONOTE_GITHUB_LABELS=security,portmonote, PORTMONOTE_GITHUB_API
+//
+//	PORTMONOTE_JIRA_URL=https://example.atlassian.net, PORTMONOTE_JIRA_USER,
+//	PORTMONOTE_JIRA_TOKEN, PORTMONOTE_JIRA_PROJECT=OPS,
+//	PORTMONOTE_JIRA_ISSUE_TYPE=Task, PORTMONOTE_JIRA_DONE=Done (transition)
+//
+//	GET /tickets?state=open|closed
+var (
+	ticketTracker = envString("PORTMONOTE_TICKETS", "")
+	ticketAfter   = envDuration("PORTMONOTE_TICKET_AFTER", 24*time.Hour)
+
+	githubRepo   = envString("PORTMONOTE_GITHUB_REPO", "")
+	githubToken  = envString("PORTMONOTE_GITHUB_TOKEN", "")
+	githubLabels = splitList(envString("PORTMONOTE_GITHUB_LABELS", "portmonote"))
+	githubAPI    = envString("PORTMONOTE_GITHUB_API", "https://api.github.com")
+
+	jiraURL       = envString("PORTMONOTE_JIRA_URL", "")
+	jiraUser      = envString("PORTMONOTE_JIRA_USER", "")
+	jiraToken     = envString("PORTMONOTE_JIRA_TOKEN", "")
+	jiraProject   = envString("PORTMONOTE_JIRA_PROJECT", "")
+	jiraIssueType = envString("PORTMONOTE_JIRA_ISSUE_TYPE", "Task")
+	jiraDone      = envString("PORTMONOTE_JIRA_DONE", "Done")
+)
+
+// PortTicket is an issue opened for a port; a port has at most one open.
+type PortTicket struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	HostID      string     `gorm:"index:idx_port_ticket" json:"host_id"`
+	Protocol    string     `gorm:"index:idx_port_ticket" json:"protocol"`
+	Port        int        `gorm:"index:idx_port_ticket" json:"port"`
+	Tracker     string     `json:"tracker"`     // github, jira
+	ExternalID  string     `json:"external_id"` // issue number or key
+	URL         string     `json:"url"`
+	OpenedAt    time.Time  `json:"opened_at"`
+	ClosedAt    *time.Time `gorm:"index" json:"closed_at,omitempty"`
+	CloseReason string     `json:"close_reason,omitempty"`
+}
+
+func (PortTicket) TableName() string {
+	return "port_ticket"
+}
+
+// issueTracker files and closes issues.
+type issueTracker interface {
+	open(title, body string) (id, url string, err error)
+	close(id, comment string) error
+}
+
+var ticketClient = &http.Client{Timeout: 20 * time.Second}
+
+// trackerRequest sends a JSON request and decodes the JSON answer into out.
+func trackerRequest(method, url string, headers map[string]string, payload, out any) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := ticketClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s %s", method, url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type githubTracker struct{}
+
+func (githubTracker) headers() map[string]string {
+	return map[string]string{"Authorization": "Bearer " + githubToken, "Accept": "application/vnd.github+json"}
+}
+
+func (g githubTracker) open(title, body string) (string, string, error) {
+	var issue struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	payload := map[string]any{"title": title, "body": body, "labels": githubLabels}
+	if err := trackerRequest(http.MethodPost, githubAPI+"/repos/"+githubRepo+"/issues", g.headers(), payload, &issue); err != nil {
+		return "", "", err
+	}
+	return strconv.Itoa(issue.Number), issue.HTMLURL, nil
+}
+
+func (g githubTracker) close(id, comment string) error {
+	issue := githubAPI + "/repos/" + githubRepo + "/issues/" + id
+	if err := trackerRequest(http.MethodPost, issue+"/comments", g.headers(), map[string]any{"body": comment}, nil); err != nil {
+		return err
+	}
+	return trackerRequest(http.MethodPatch, issue, g.headers(), map[string]any{"state": "closed", "state_reason": "completed"}, nil)
+}
+
+type jiraTracker struct{}
+
+func (jiraTracker) headers() map[string]string {
+	return map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(jiraUser+":"+jiraToken))}
+}
+
+func (j jiraTracker) open(title, body string) (string, string, error) {
+	var issue struct {
+		Key string `json:"key"`
+	}
+	payload := map[string]any{"fields": map[string]any{
+		"project":     map[string]string{"key": jiraProject},
+		"issuetype":   map[string]string{"name": jiraIssueType},
+		"summary":     title,
+		"description": body,
+		"labels":      []string{"portmonote"},
+	}}
+	base := strings.TrimSuffix(jiraURL, "/")
+	if err := trackerRequest(http.MethodPost, base+"/rest/api/2/issue", j.headers(), payload, &issue); err != nil {
+		return "", "", err
+	}
+	return issue.Key, base + "/browse/" + issue.Key, nil
+}
+
+func (j jiraTracker) close(key, comment string) error {
+	issue := strings.TrimSuffix(jiraURL, "/") + "/rest/api/2/issue/" + key
+	if err := trackerRequest(http.MethodPost, issue+"/comment", j.headers(), map[string]any{"body": comment}, nil); err != nil {
+		return err
+	}
+	var res struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"transitions"`
+	}
+	if err := trackerRequest(http.MethodGet, issue+"/transitions", j.headers(), nil, &res); err != nil {
+		return err
+	}
+	for _, t := range res.Transitions {
+		if strings.EqualFold(t.Name, jiraDone) {
+			return trackerRequest(http.MethodPost, issue+"/transitions", j.headers(), map[string]any{"transition": map[string]string{"id": t.ID}}, nil)
+		}
+	}
+	return fmt.Errorf("issue %s has no %q transition", key, jiraDone)
+}
+
+// configuredTracker returns the tracker of PORTMONOTE_TICKETS, or an error
+// naming what is missing.
+func configuredTracker() (issueTracker, error) {
+	switch ticketTracker {
+	case "github":
+		if githubRepo == "" || githubToken == "" {
+			return nil, fmt.Errorf("set PORTMONOTE_GITHUB_REPO and PORTMONOTE_GITHUB_TOKEN")
+		}
+		return githubTracker{}, nil
+	case "jira":
+		if jiraURL == "" || jiraUser == "" || jiraToken == "" || jiraProject == "" {
+			return nil, fmt.Errorf("set PORTMONOTE_JIRA_URL, PORTMONOTE_JIRA_USER, PORTMONOTE_JIRA_TOKEN and PORTMONOTE_JIRA_PROJECT")
+		}
+		return jiraTracker{}, nil
+	}
+	return nil, fmt.Errorf("unknown tracker %q (expected github or jira)", ticketTracker)
+}
+
+// unreviewed tells whether nobody has vouched for the port yet: no note, a
+// guessed one, or one that calls it suspicious. Mutes and review dates
+// don't change that.
+func unreviewed(item *MergedPortItem) bool {
+	return item.NoteID == 0 || item.NoteAuto || item.RiskLevel == string(RiskSuspicious)
+}
+
+// suspiciousSince is when the port became one nobody had vouched for: the
+// start of its current up-interval, or the last edit of a note that marks
+// it suspicious.
+func suspiciousSince(rt *PortRuntime, note *PortNote) time.Time {
+	since := rt.FirstSeenAt
+	if rt.UpSince != nil {
+		since = *rt.UpSince
+	}
+	if note != nil && note.UpdatedAt.After(since) {
+		since = note.UpdatedAt
+	}
+	return since
+}
+
+// reviewedReason is the closing comment of a ticket whose port got a note.
+func reviewedReason(item *MergedPortItem, note *PortNote) string {
+	var details []string
+	if item.Title != "" {
+		details = append(details, fmt.Sprintf("%q", item.Title))
+	}
+	details = append(details, "risk "+item.RiskLevel)
+	if item.Owner != "" {
+		details = append(details, "owner "+item.Owner)
+	}
+	if note != nil && note.UpdatedBy != "" {
+		details = append(details, "by "+note.UpdatedBy)
+	}
+	return "The port was reviewed in portmonote: " + strings.Join(details, ", ") + "."
+}
+
+func ticketBody(item *MergedPortItem, since time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Port %s/%d on %s has been listening without a review since %s.\n\n", item.Protocol, item.Port, item.HostID, since.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Process: %s (pid %d)\n", item.ProcessName, item.CurrentPID)
+	for _, kv := range [][2]string{
+		{"Command line", item.Cmdline}, {"User", item.Username}, {"Executable", item.ExePath},
+		{"SHA-256", item.ExeSHA256}, {"systemd unit", item.SystemdUnit}, {"Note", item.Title},
+	} {
+		if kv[1] != "" {
+			fmt.Fprintf(&b, "%s: %s\n", kv[0], kv[1])
+		}
+	}
+	if link := portLink(PortRuntime{HostID: item.HostID, Protocol: item.Protocol, Port: item.Port}); link != "" {
+		fmt.Fprintf(&b, "\n%s\n", link)
+	}
+	b.WriteString("\nFill in the port's note in portmonote to close this issue.")
+	return b.String()
+}
+
+// syncTickets opens issues for ports suspicious for too long and closes
+// those of ports that were reviewed or went away.
+func syncTickets(now time.Time) (opened, closed int, err error) {
+	tracker, err := configuredTracker()
+	if err != nil {
+		return 0, 0, err
+	}
+	var runtimes []PortRuntime
+	var notes []PortNote
+	DB.Where("current_state = ?", StateActive).Find(&runtimes)
+	DB.Find(&notes)
+	notesByKey := map[string]*PortNote{}
+	for i := range notes {
+		n := &notes[i]
+		notesByKey[fmtKey(n.HostID, n.Protocol, n.Port)] = n
+	}
+	runtimesByKey := map[string]*PortRuntime{}
+	for i := range runtimes {
+		rt := &runtimes[i]
+		runtimesByKey[fmtKey(rt.HostID, rt.Protocol, rt.Port)] = rt
+	}
+	items := map[string]*MergedPortItem{}
+	merged := mergePortItems(runtimes, notes)
+	for i := range merged {
+		if merged[i].RuntimeID != 0 {
+			items[fmtKey(merged[i].HostID, merged[i].Protocol, merged[i].Port)] = &merged[i]
+		}
+	}
+
+	var open []PortTicket
+	DB.Where("closed_at IS NULL").Find(&open)
+	ticketed := map[string]bool{}
+	for _, t := range open {
+		key := fmtKey(t.HostID, t.Protocol, t.Port)
+		item := items[key]
+		var reason string
+		switch {
+		case item == nil:
+			reason = "The port stopped listening."
+		case unreviewed(item):
+			// Still nobody's: suspicious, muted, or flapping without a note
+			ticketed[key] = true
+			continue
+		default:
+			reason = reviewedReason(item, notesByKey[key])
+		}
+		if err := tracker.close(t.ExternalID, reason); err != nil {
+			log.Printf("Error closing ticket %s for %s/%d: %v", t.ExternalID, t.Protocol, t.Port, err)
+			continue
+		}
+		DB.Model(&t).Updates(map[string]any{"closed_at": now, "close_reason": reason})
+		log.Printf("🎫 Closed ticket %s for %s/%d", t.ExternalID, t.Protocol, t.Port)
+		closed++
+	}
+
+	for key, item := range items {
+		if !unreviewed(item) || item.DerivedStatus == "muted" || ticketed[key] || item.Hidden {
+			continue
+		}
+		since := suspiciousSince(runtimesByKey[key], notesByKey[key])
+		if now.Sub(since) < ticketAfter {
+			continue
+		}
+		title := fmt.Sprintf("Unreviewed port %s/%d on %s (%s)", item.Protocol, item.Port, item.HostID, cmp.Or(item.ProcessName, "unknown process"))
+		id, url, err := tracker.open(title, ticketBody(item, since))
+		if err != nil {
+			log.Printf("Error opening ticket for %s/%d: %v", item.Protocol, item.Port, err)
+			continue
+		}
+		DB.Create(&PortTicket{HostID: item.HostID, Protocol: item.Protocol, Port: item.Port, Tracker: ticketTracker, ExternalID: id, URL: url, OpenedAt: now})
+		log.Printf("🎫 Opened ticket %s for %s/%d", id, item.Protocol, item.Port)
+		opened++
+	}
+	return opened, closed, nil
+}
+
+func init() {
+	registerJob("tickets", JobKind{Run: func(ctx context.Context, _ string) (string, error) {
+		opened, closed, err := syncTickets(time.Now())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d opened, %d closed", opened, closed), nil
+	}})
+}
+
+// startTickets checks for overdue ports every 5 minutes, and right after a
+// note is edited so its ticket closes promptly.
+func startTickets(ctx context.Context) {
+	if ticketTracker == "" {
+		return
+	}
+	if _, err := configuredTracker(); err != nil {
+		log.Printf("⚠️ Tickets disabled: %v", err)
+		return
+	}
+	log.Printf("🎫 Tickets in %s for ports suspicious for over %s", ticketTracker, ticketAfter)
+	subscribe("tickets", func(BusEvent) { enqueueUnique("tickets", nil) }, TopicNoteEdited)
+	scheduleJob(ctx, "tickets", 5*time.Minute)
+}
+
+// GET /tickets?state=open|closed
+func listTickets(c *gin.Context) {
+	q := DB.Order("opened_at desc")
+	switch c.Query("state") {
+	case "open":
+		q = q.Where("closed_at IS NULL")
+	case "closed":
+		q = q.Where("closed_at IS NOT NULL")
+	}
+	var tickets []PortTicket
+	q.Limit(500).Find(&tickets)
+	if tickets == nil {
+		tickets = []PortTicket{}
+	}
+	c.JSON(http.StatusOK, tickets)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLSOptions: either a certificate/key pair from disk, or Let's Encrypt via
+// autocert for the listed hostnames. Without either we serve plain HTTP.
+type TLSOptions struct {
+	CertFile      string
+	KeyFile       string
+	AutocertHosts []string
+	AutocertEmail string
+	AutocertCache string
+	// Plain-HTTP listener for ACME challenges and redirects to https
+	HTTPAddr string
+}
+
+func tlsOptionsFromEnv() TLSOptions {
+	return TLSOptions{
+		CertFile:      envString("PORTMONOTE_TLS_CERT", ""),
+		KeyFile:       envString("PORTMONOTE_TLS_KEY", ""),
+		AutocertHosts: splitList(envString("PORTMONOTE_AUTOCERT_HOSTS", "")),
+		AutocertEmail: envString("PORTMONOTE_AUTOCERT_EMAIL", ""),
+		AutocertCache: envString("PORTMONOTE_AUTOCERT_CACHE", filepath.Join("data", "autocert")),
+		HTTPAddr:      envString("PORTMONOTE_AUTOCERT_HTTP_ADDR", ":80"),
+	}
+}
+
+func (o TLSOptions) Mode() string {
+	switch {
+	case len(o.AutocertHosts) > 0:
+		return "autocert"
+	case o.CertFile != "" || o.KeyFile != "":
+		return "tls"
+	}
+	return "plain"
+}
+
+// tlsMode is what the running server uses, for /capabilities.
+var tlsMode = "plain"
+
+// serve runs the web server in the configured mode until ctx is cancelled,
+// then gives in-flight requests up to drain to finish.
+func serve(ctx context.Context, r *gin.Engine, addr string, o TLSOptions, drain time.Duration) error {
+	tlsMode = o.Mode()
+	srv := &http.Server{Addr: addr, Handler: r}
+	var acmeSrv *http.Server
+
+	var start func() error
+	switch tlsMode {
+	case "tls":
+		if o.CertFile == "" || o.KeyFile == "" {
+			return errors.New("both PORTMONOTE_TLS_CERT and PORTMONOTE_TLS_KEY are required")
+		}
+		log.Printf("🔒 Serving HTTPS with %s", o.CertFile)
+		start = func() error { return srv.ListenAndServeTLS(o.CertFile, o.KeyFile) }
+
+	case "autocert":
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(o.AutocertHosts...),
+			Cache:      autocert.DirCache(o.AutocertCache),
+			Email:      o.AutocertEmail,
+		}
+		// HTTP-01 challenges; everything else is redirected to https
+		acmeSrv = &http.Server{Addr: o.HTTPAddr, Handler: m.HTTPHandler(nil)}
+		go func() {
+			if err := acmeSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("⚠️ ACME HTTP listener on %s failed: %v", o.HTTPAddr, err)
+			}
+		}()
+		srv.TLSConfig = &tls.Config{GetCertificate: m.GetCertificate, NextProtos: []string{"h2", "http/1.1", "acme-tls/1"}}
+		log.Printf("🔒 Serving HTTPS with Let's Encrypt for %s", strings.Join(o.AutocertHosts, ", "))
+		start = func() error { return srv.ListenAndServeTLS("", "") }
+
+	default:
+		start = srv.ListenAndServe
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- start() }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if acmeSrv != nil {
+		acmeSrv.Shutdown(shutdownCtx)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("draining HTTP server: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import "time"
+
+// Uptime accounting. Each sighting of an active port adds the time since
+// the previous sighting to TotalUptimeSeconds; the time between the last
+// sighting and a reappearance goes to TotalDowntimeSeconds. Gaps longer
+// than maxAccrualGap while the port stays up (monitor stopped, collector
+// paused) count as neither, since nobody was watching.
+const maxAccrualGap = 5 * time.Minute
+
+// accrueUptime updates the counters for a sighting at now. Call it before
+// LastSeenAt is moved to now.
+func accrueUptime(rt *PortRuntime, wasGone bool, now time.Time) {
+	gap := now.Sub(rt.LastSeenAt)
+	if gap <= 0 {
+		return
+	}
+	if wasGone {
+		rt.TotalDowntimeSeconds += int(gap.Seconds())
+		rt.UpSince = &now
+		return
+	}
+	if gap <= maxAccrualGap {
+		rt.TotalUptimeSeconds += int(gap.Seconds())
+	}
+}
+
+// uptimeStats returns lifetime downtime (including an ongoing outage) and
+// the uptime percentage; 100 for a port seen only once.
+func uptimeStats(rt *PortRuntime, now time.Time) (downtime int, pct float64) {
+	downtime = rt.TotalDowntimeSeconds
+	if rt.CurrentState == string(StateDisappeared) {
+		downtime += int(now.Sub(rt.LastSeenAt).Seconds())
+	}
+	total := rt.TotalUptimeSeconds + downtime
+	if total == 0 {
+		return downtime, 100
+	}
+	return downtime, float64(rt.TotalUptimeSeconds) / float64(total) * 100
+}
+
+// upSince is when the current up-interval began.
+func upSince(rt *PortRuntime) time.Time {
+	if rt.UpSince != nil {
+		return *rt.UpSince
+	}
+	return rt.FirstSeenAt
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Saved views: a named /ports query (query.go) and sort order, kept per
+// user, so "My suspicious prod ports" is one click away:
+//
+//	GET    /views
+//	POST   /views      {"name": "Suspicious prod", "query": "status:suspicious tag:prod", "sort": "-last_seen,port"}
+//	PUT    /views/:id
+//	DELETE /views/:id
+//
+// Open a view with GET /ports?q=<query>&sort=<sort>. Everyone manages
+// their own views, viewers included.
+type SavedView struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Owner     string    `gorm:"uniqueIndex:idx_saved_view;size:128" json:"owner"`
+	Name      string    `gorm:"uniqueIndex:idx_saved_view;size:128" json:"name"`
+	Query     string    `json:"query"`
+	Sort      string    `json:"sort,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (SavedView) TableName() string {
+	return "saved_view"
+}
+
+// Sort keys of /ports?sort=; a leading - sorts descending.
+var portSortKeys = map[string]func(a, b *MergedPortItem) int{
+	"port":     func(a, b *MergedPortItem) int { return cmp.Compare(a.Port, b.Port) },
+	"host":     func(a, b *MergedPortItem) int { return strings.Compare(a.HostID, b.HostID) },
+	"protocol": func(a, b *MergedPortItem) int { return strings.Compare(a.Protocol, b.Protocol) },
+	"process":  func(a, b *MergedPortItem) int { return strings.Compare(a.ProcessName, b.ProcessName) },
+	"status":   func(a, b *MergedPortItem) int { return strings.Compare(a.DerivedStatus, b.DerivedStatus) },
+	"risk":     func(a, b *MergedPortItem) int { return strings.Compare(a.RiskLevel, b.RiskLevel) },
+	"owner":    func(a, b *MergedPortItem) int { return strings.Compare(a.Owner, b.Owner) },
+	"title":    func(a, b *MergedPortItem) int { return strings.Compare(a.Title, b.Title) },
+	"first_seen": func(a, b *MergedPortItem) int {
+		return compareTimes(a.FirstSeenAt, b.FirstSeenAt)
+	},
+	"last_seen": func(a, b *MergedPortItem) int {
+		return compareTimes(a.LastSeenAt, b.LastSeenAt)
+	},
+	"uptime": func(a, b *MergedPortItem) int { return cmp.Compare(a.UptimeSeconds, b.UptimeSeconds) },
+}
+
+// compareTimes orders nil first.
+func compareTimes(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return a.Compare(*b)
+}
+
+// parsePortSort turns "-last_seen,port" into a comparison.
+func parsePortSort(s string) (func(a, b MergedPortItem) int, error) {
+	type key struct {
+		cmp  func(a, b *MergedPortItem) int
+		desc bool
+	}
+	var keys []key
+	for _, f := range splitList(s) {
+		name, desc := strings.CutPrefix(f, "-")
+		c, ok := portSortKeys[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort key %q", name)
+		}
+		keys = append(keys, key{c, desc})
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return func(a, b MergedPortItem) int {
+		for _, k := range keys {
+			if n := k.cmp(&a, &b); n != 0 {
+				if k.desc {
+					return -n
+				}
+				return n
+			}
+		}
+		return 0
+	}, nil
+}
+
+// GET /views, the caller's
+func listViews(c *gin.Context) {
+	var views []SavedView
+	DB.Where("owner = ?", actorName(c)).Order("name").Find(&views)
+	if views == nil {
+		views = []SavedView{}
+	}
+	c.JSON(http.StatusOK, views)
+}
+
+// POST /views
+func createView(c *gin.Context) {
+	var v SavedView
+	if err := c.BindJSON(&v); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	v.ID, v.Owner = 0, actorName(c)
+	saveView(c, &v, http.StatusCreated)
+}
+
+// PUT /views/:id replaces one of the caller's views.
+func updateView(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	var existing SavedView
+	if err := DB.Where("owner = ?", actorName(c)).First(&existing, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "View not found"})
+		return
+	}
+	var v SavedView
+	if err := c.BindJSON(&v); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	v.ID, v.Owner, v.CreatedAt = existing.ID, existing.Owner, existing.CreatedAt
+	saveView(c, &v, http.StatusOK)
+}
+
+func saveView(c *gin.Context, v *SavedView, status int) {
+	v.Name = strings.TrimSpace(v.Name)
+	if v.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if _, err := parsePortQuery(v.Query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query: " + err.Error()})
+		return
+	}
+	if _, err := parsePortSort(v.Sort); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort: " + err.Error()})
+		return
+	}
+	var n int64
+	DB.Model(&SavedView{}).Where("owner = ? AND name = ? AND id <> ?", v.Owner, v.Name, v.ID).Count(&n)
+	if n > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have a view with this name"})
+		return
+	}
+	if err := DB.Save(v).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(status, v)
+}
+
+// DELETE /views/:id
+func deleteView(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	res := DB.Where("owner = ?", actorName(c)).Delete(&SavedView{}, id)
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = gorm.ErrRecordNotFound
+	}
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "View not found"})
+		return
+	}
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
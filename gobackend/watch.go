@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Event-driven collection: the minute poll misses listeners that live for
+// a few seconds. PORTMONOTE_WATCH=netlink asks the kernel for the socket
+// table over netlink sock_diag every PORTMONOTE_WATCH_INTERVAL (default 1s),
+// which is cheap enough to run continuously. When a listener opens or
+// closes it triggers a collection cycle right away, and listeners that are
+// already gone again by then are still fed into that cycle, so they get
+// their appeared/disappeared events like any other. Linux only.
+//
+// sock_diag has no notification for bind/listen, hence the fast dump
+// rather than a subscription; eBPF tracepoints would need CAP_BPF and a
+// loader, so they are not offered.
+var (
+	watchMode     = envString("PORTMONOTE_WATCH", "")
+	watchInterval = envDuration("PORTMONOTE_WATCH_INTERVAL", time.Second)
+	// Minimum gap between cycles the watch triggers; changes in between
+	// wait for the next one
+	watchDebounce = envDuration("PORTMONOTE_WATCH_DEBOUNCE", 10*time.Second)
+)
+
+// watchTrigger asks runCollector for an early cycle; buffered so bursts of
+// changes coalesce into one.
+var watchTrigger = make(chan struct{}, 1)
+
+var watched = struct {
+	mu        sync.Mutex
+	transient map[PortKey]ScanResult // opened since the last cycle
+}{transient: map[PortKey]ScanResult{}}
+
+// runListenWatch diffs the kernel's listener table until ctx is done.
+func runListenWatch(ctx context.Context) {
+	switch watchMode {
+	case "":
+		return
+	case "netlink":
+	default:
+		log.Printf("⚠️ Unknown PORTMONOTE_WATCH %q (supported: netlink)", watchMode)
+		return
+	}
+	prev, err := kernelListeners()
+	if err != nil {
+		log.Printf("⚠️ Listener watch unavailable: %v", err)
+		return
+	}
+	log.Printf("👀 Watching listeners via %s every %s", watchMode, watchInterval)
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		cur, err := kernelListeners()
+		if err != nil {
+			log.Println("Error reading listeners:", err)
+			continue
+		}
+		changed := len(cur) != len(prev)
+		for key, inode := range cur {
+			if _, ok := prev[key]; ok {
+				continue
+			}
+			changed = true
+			if res, ok := listenerProcess(inode); ok {
+				watched.mu.Lock()
+				watched.transient[key] = res
+				watched.mu.Unlock()
+			}
+		}
+		prev = cur
+		if changed {
+			select {
+			case watchTrigger <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// mergeWatched adds listeners the watcher saw since the last cycle but the
+// scan no longer finds.
+func mergeWatched(results map[PortKey]ScanResult) {
+	watched.mu.Lock()
+	transient := watched.transient
+	watched.transient = map[PortKey]ScanResult{}
+	watched.mu.Unlock()
+
+	for key, res := range transient {
+		if _, ok := results[key]; !ok {
+			results[key] = res
+		}
+	}
+}
+
+// listenerProcess resolves the owner of a socket inode into a scan result.
+func listenerProcess(inode uint32) (ScanResult, bool) {
+	pid := socketOwner(inode)
+	if pid == 0 {
+		return ScanResult{}, false
+	}
+	info := processInfo(pid)
+	return ScanResult{
+		PID:         pid,
+		ProcessName: info.Name,
+		Cmdline:     info.Cmdline,
+		Username:    info.Username,
+		ExePath:     info.ExePath,
+		Unit:        info.Unit,
+		Slice:       info.Slice,
+		State:       "LISTEN",
+	}, true
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	sockDiagByFamily = 20 // SOCK_DIAG_BY_FAMILY
+	tcpListenState   = 10 // TCP_LISTEN
+	udpUnconnState   = 7  // TCP_CLOSE, how ss shows an unconnected UDP socket
+)
+
+// inet_diag_req_v2 from <linux/inet_diag.h>
+type inetDiagReq struct {
+	Family   uint8
+	Protocol uint8
+	Ext      uint8
+	Pad      uint8
+	States   uint32
+	ID       [48]byte // inet_diag_sockid, zero = all sockets
+}
+
+// Offsets into inet_diag_msg
+const (
+	diagMsgSport = 4
+	diagMsgDport = 6
+	diagMsgDst   = 24 // 16 bytes, IPv4 in the first 4
+	diagMsgInode = 68
+	diagMsgLen   = 72
+)
+
+// kernelListeners returns this host's TCP listeners and bound UDP sockets
+// with their socket inode. Connected UDP sockets are clients (a DNS lookup,
+// say), not services, and are left out.
+func kernelListeners() (map[PortKey]uint32, error) {
+	found := make(map[PortKey]uint32)
+	for _, q := range []struct {
+		proto  uint8
+		name   string
+		states uint32
+	}{
+		{unix.IPPROTO_TCP, "tcp", 1 << tcpListenState},
+		{unix.IPPROTO_UDP, "udp", 1 << udpUnconnState},
+	} {
+		for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+			err := sockDiagDump(family, q.proto, q.states, func(msg []byte) {
+				if q.name == "udp" && !unspecifiedRemote(msg) {
+					return
+				}
+				port := int(binary.BigEndian.Uint16(msg[diagMsgSport:]))
+				key := PortKey{HostID: HostID, Protocol: q.name, Port: port}
+				if _, ok := found[key]; !ok {
+					found[key] = binary.NativeEndian.Uint32(msg[diagMsgInode:])
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return found, nil
+}
+
+func unspecifiedRemote(msg []byte) bool {
+	if binary.BigEndian.Uint16(msg[diagMsgDport:]) != 0 {
+		return false
+	}
+	for _, b := range msg[diagMsgDst : diagMsgDst+16] {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func sockDiagDump(family, proto uint8, states uint32, fn func(msg []byte)) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.NETLINK_SOCK_DIAG)
+	if err != nil {
+		return fmt.Errorf("netlink socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	req := inetDiagReq{Family: family, Protocol: proto, States: states}
+	hdr := unix.NlMsghdr{
+		Len:   uint32(unix.SizeofNlMsghdr + unsafe.Sizeof(req)),
+		Type:  sockDiagByFamily,
+		Flags: unix.NLM_F_REQUEST | unix.NLM_F_DUMP,
+		Seq:   1,
+	}
+	buf := make([]byte, 0, hdr.Len)
+	buf = append(buf, unsafe.Slice((*byte)(unsafe.Pointer(&hdr)), unix.SizeofNlMsghdr)...)
+	buf = append(buf, unsafe.Slice((*byte)(unsafe.Pointer(&req)), unsafe.Sizeof(req))...)
+	if err := unix.Sendto(fd, buf, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return fmt.Errorf("netlink send: %w", err)
+	}
+
+	rb := make([]byte, 32*1024)
+	for {
+		n, _, err := unix.Recvfrom(fd, rb, 0)
+		if err != nil {
+			return fmt.Errorf("netlink receive: %w", err)
+		}
+		data := rb[:n]
+		for len(data) >= unix.SizeofNlMsghdr {
+			h := (*unix.NlMsghdr)(unsafe.Pointer(&data[0]))
+			if h.Len < unix.SizeofNlMsghdr || int(h.Len) > len(data) {
+				return fmt.Errorf("netlink: malformed message")
+			}
+			switch h.Type {
+			case unix.NLMSG_DONE:
+				return nil
+			case unix.NLMSG_ERROR:
+				if h.Len >= unix.SizeofNlMsghdr+4 {
+					if errno := int32(binary.NativeEndian.Uint32(data[unix.SizeofNlMsghdr:])); errno != 0 {
+						return fmt.Errorf("netlink: %w", unix.Errno(-errno))
+					}
+				}
+				return nil
+			case sockDiagByFamily:
+				if msg := data[unix.SizeofNlMsghdr:h.Len]; len(msg) >= diagMsgLen {
+					fn(msg)
+				}
+			}
+			next := (int(h.Len) + unix.NLMSG_ALIGNTO - 1) &^ (unix.NLMSG_ALIGNTO - 1)
+			if next > len(data) {
+				break
+			}
+			data = data[next:]
+		}
+	}
+}
+
+// socketOwner finds the PID holding a socket inode by scanning /proc/*/fd,
+// or 0 if it is gone or not visible to us.
+func socketOwner(inode uint32) int {
+	if inode == 0 {
+		return 0
+	}
+	target := "socket:[" + strconv.FormatUint(uint64(inode), 10) + "]"
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		if link, err := os.Readlink(fd); err == nil && link == target {
+			pid, _ := strconv.Atoi(strings.Split(fd, "/")[2])
+			return pid
+		}
+	}
+	return 0
+}
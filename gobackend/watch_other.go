@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func kernelListeners() (map[PortKey]uint32, error) {
+	return nil, errors.New("listener watch needs Linux netlink")
+}
+
+func socketOwner(inode uint32) int {
+	return 0
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Inspections are stored as diagnosis events; the newest one per runtime
+// doubles as a cache. GET /inspect/:port serves it while it is younger than
+// PORTMONOTE_INSPECT_TTL (?refresh=true forces a new run, ?cached=only never
+// runs witr). PORTMONOTE_INSPECT_ON_CHANGE=true re-inspects a port in the
+// background whenever its process changes.
+var (
+	inspectTTL      = envDuration("PORTMONOTE_INSPECT_TTL", 10*time.Minute)
+	inspectOnChange = envBool("PORTMONOTE_INSPECT_ON_CHANGE", false)
+)
+
+const witrErrorMarker = "\nError: "
+
+// WitrDiagnosis is the structured part of a witr report. The raw text is
+// still kept in PortEvent.WitrOutput; these fields are what gets filtered on.
+type WitrDiagnosis struct {
+	Process    string   `json:"process,omitempty"`
+	User       string   `json:"user,omitempty"`
+	Command    string   `json:"command,omitempty"`
+	Started    string   `json:"started,omitempty"`
+	Tree       []string `json:"tree,omitempty"` // ancestry, outermost first
+	Source     string   `json:"source,omitempty"`
+	Unit       string   `json:"unit,omitempty"`
+	Container  string   `json:"container,omitempty"`
+	WorkingDir string   `json:"working_dir,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+var (
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+	unitName   = regexp.MustCompile(`[\w@.\-]+\.(service|socket|timer|scope)`)
+)
+
+// parseWitrOutput reads witr's "Key : value" report. Unknown keys are
+// ignored so newer witr versions don't break anything; the "Why It Exists"
+// block holds the process tree, one "a (pid 1) → b (pid 2)" chain.
+func parseWitrOutput(text string) WitrDiagnosis {
+	var d WitrDiagnosis
+	section := ""
+	for _, line := range strings.Split(ansiEscape.ReplaceAllString(text, ""), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		indented := line != strings.TrimLeft(line, " \t")
+		if indented && section != "" {
+			switch section {
+			case "why it exists":
+				d.Tree = append(d.Tree, splitWitrChain(trimmed)...)
+			case "warnings":
+				d.Warnings = append(d.Warnings, strings.TrimLeft(trimmed, "•-* "))
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		section = ""
+		if value == "" {
+			section = key
+			continue
+		}
+		switch key {
+		case "process", "target":
+			if d.Process == "" || key == "process" {
+				d.Process = value
+			}
+		case "user":
+			d.User = value
+		case "command":
+			d.Command = value
+		case "started":
+			d.Started = value
+		case "source":
+			d.Source = value
+		case "service", "unit", "systemd", "systemd unit":
+			d.Unit = value
+		case "container":
+			d.Container = value
+		case "working dir":
+			d.WorkingDir = value
+		}
+	}
+	if d.Unit == "" {
+		d.Unit = unitName.FindString(d.Source)
+	}
+	return d
+}
+
+func splitWitrChain(s string) []string {
+	s = strings.ReplaceAll(s, "->", "→")
+	var parts []string
+	for _, p := range strings.Split(s, "→") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
+
+// GET /diagnoses?unit=&container=&source=&q= lists stored witr runs,
+// newest first. q matches anywhere in the process tree.
+func listDiagnoses(c *gin.Context) {
+	q := DB.Where("event_type = ?", EventDiagnosis).Order("timestamp desc").Limit(200)
+	if v := c.Query("unit"); v != "" {
+		q = q.Where("witr_unit = ?", v)
+	}
+	if v := c.Query("container"); v != "" {
+		q = q.Where("witr_container = ?", v)
+	}
+	if v := c.Query("source"); v != "" {
+		q = q.Where("witr_source LIKE ?", v+"%")
+	}
+	if v := c.Query("q"); v != "" {
+		q = q.Where("witr_tree LIKE ?", "%"+v+"%")
+	}
+	var events []PortEvent
+	q.Omit("witr_output").Find(&events)
+	c.JSON(http.StatusOK, events)
+}
+
+// GET /inspect/:port
+func runWitr(c *gin.Context) {
+	port, err := strconv.Atoi(c.Param("port"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid port"})
+		return
+	}
+
+	// witr only takes a port, so if it's open on both tcp and udp we pick the first
+	var runtime PortRuntime
+	found := DB.Where("host_id = ? AND port = ? AND current_state = ?", HostID, port, StateActive).First(&runtime).Error == nil
+
+	if found && c.Query("refresh") != "true" {
+		if evt, ok := cachedDiagnosis(runtime, c.Query("cached") == "only"); ok {
+			c.JSON(http.StatusOK, gin.H{
+				"output":       evt.WitrOutput,
+				"diagnosis":    parseWitrOutput(evt.WitrOutput),
+				"error":        strings.Contains(evt.WitrOutput, witrErrorMarker),
+				"cached":       true,
+				"inspected_at": evt.Timestamp,
+			})
+			return
+		}
+	}
+	if c.Query("cached") == "only" {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	output, diag, runErr := inspectPort(c.Request.Context(), port)
+	if errors.Is(runErr, exec.ErrNotFound) {
+		c.JSON(http.StatusOK, gin.H{"output": "witr not found on path", "error": true})
+		return
+	}
+	if found {
+		saveDiagnosis(&runtime, output, diag)
+	} else {
+		log.Printf("Could not log witr event for port %d: no active runtime", port)
+	}
+	c.JSON(http.StatusOK, gin.H{"output": output, "diagnosis": diag, "error": runErr != nil, "cached": false, "inspected_at": time.Now()})
+}
+
+// cachedDiagnosis returns the newest diagnosis of a runtime, if still fresh
+// (or at any age with anyAge). One taken of a process that has since been
+// replaced describes the wrong process and is never returned.
+func cachedDiagnosis(runtime PortRuntime, anyAge bool) (PortEvent, bool) {
+	var evt PortEvent
+	err := DB.Where("port_runtime_id = ? AND event_type = ?", runtime.ID, EventDiagnosis).Order("timestamp desc").First(&evt).Error
+	if err != nil || evt.PID != runtime.CurrentPID {
+		return evt, false
+	}
+	return evt, anyAge || (inspectTTL > 0 && time.Since(evt.Timestamp) < inspectTTL)
+}
+
+// inspectPort runs witr with the configured timeout and output cap.
+func inspectPort(ctx context.Context, port int) (string, WitrDiagnosis, error) {
+	path, err := exec.LookPath("witr")
+	if err != nil {
+		return "", WitrDiagnosis{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, witrTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, "--port", strconv.Itoa(port))
+	out := &cappedBuffer{limit: witrMaxOutput}
+	cmd.Stdout, cmd.Stderr = out, out
+	err = cmd.Run()
+	output := out.String()
+	if ctx.Err() == context.DeadlineExceeded {
+		output += witrErrorMarker + "witr timed out after " + witrTimeout.String()
+	} else if err != nil {
+		output += witrErrorMarker + err.Error()
+	}
+	return output, parseWitrOutput(output), err
+}
+
+func saveDiagnosis(runtime *PortRuntime, output string, diag WitrDiagnosis) {
+	evt := PortEvent{
+		PortRuntimeID: runtime.ID,
+		EventType:     string(EventDiagnosis),
+		Timestamp:     time.Now(),
+		PID:           runtime.CurrentPID,
+		ProcessName:   runtime.ProcessName,
+		WitrOutput:    output,
+		WitrTree:      strings.Join(diag.Tree, " → "),
+		WitrSource:    diag.Source,
+		WitrUnit:      diag.Unit,
+		WitrContainer: diag.Container,
+	}
+	if err := DB.Create(&evt).Error; err != nil {
+		log.Println("Error saving diagnosis:", err)
+	}
+}
+
+type inspectJob struct {
+	RuntimeID uint `json:"runtime_id"`
+}
+
+func init() {
+	registerJob("inspect", JobKind{Run: runInspectJob, MaxAttempts: 1})
+}
+
+// scheduleReinspections queues local runtimes whose process changed.
+func scheduleReinspections(b *eventBatch) {
+	if !inspectOnChange {
+		return
+	}
+	for i, evt := range b.events {
+		if evt.EventType != string(EventProcessChange) || b.runtimes[i].HostID != HostID {
+			continue
+		}
+		enqueueUnique("inspect", inspectJob{RuntimeID: evt.PortRuntimeID})
+	}
+}
+
+func runInspectJob(ctx context.Context, payload string) (string, error) {
+	var p inspectJob
+	if err := json.Unmarshal([]byte(payload), &p); err != nil {
+		return "", err
+	}
+	var rt PortRuntime
+	if DB.First(&rt, p.RuntimeID).Error != nil || rt.CurrentState != string(StateActive) {
+		return "port no longer active", nil
+	}
+	output, diag, err := inspectPort(ctx, rt.Port)
+	if errors.Is(err, exec.ErrNotFound) {
+		return "witr not installed", nil
+	}
+	saveDiagnosis(&rt, output, diag)
+	log.Printf("🔍 Re-inspected %s/%d after process change", rt.Protocol, rt.Port)
+	return strings.Join(diag.Tree, " → "), nil
+}